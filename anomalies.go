@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// compareReport is the run report of an earlier run of the same job whose
+// per-tag row counts this run is compared against (--compare-report). A
+// missing file is the first run and is not compared.
+var compareReport string
+
+// anomalyFactor is the growth or shrinkage of a row count, as a ratio,
+// that is reported as an anomaly (--anomaly-factor)
+var anomalyFactor = 10.0
+
+// failOnAnomaly fails the conversion when anomalies are found, after its
+// outputs and run report are written (--fail-on-anomaly)
+var failOnAnomaly bool
+
+// loadBaseline reads the run report named by --compare-report before this
+// run replaces it, returning nil when there is none
+func loadBaseline() (*RunReport, error) {
+	if compareReport == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(compareReport)
+	if os.IsNotExist(err) {
+		log.Printf("No previous run report at %s; skipping anomaly detection", compareReport)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, wrapFSError("read run report", compareReport, err)
+	}
+	var baseline RunReport
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to parse run report %s: %v", compareReport, err)
+	}
+	if baseline.TagRows == nil {
+		log.Printf("Run report %s has no per-tag statistics; only the total row count is compared", compareReport)
+	}
+	return &baseline, nil
+}
+
+// findAnomalies compares the row counts of current against baseline:
+// tags that disappeared or appeared, and tags or totals whose row count
+// changed by anomalyFactor or more
+func findAnomalies(baseline, current *RunReport) []string {
+	var anomalies []string
+	if change := countChange(baseline.Rows, current.Rows); change != "" {
+		anomalies = append(anomalies, "total rows "+change)
+	}
+	if baseline.TagRows == nil {
+		return anomalies
+	}
+	tags := make([]string, 0, len(baseline.TagRows)+len(current.TagRows))
+	for tag := range baseline.TagRows {
+		tags = append(tags, tag)
+	}
+	for tag := range current.TagRows {
+		if _, ok := baseline.TagRows[tag]; !ok {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		before, after := baseline.TagRows[tag], current.TagRows[tag]
+		switch {
+		case after == 0:
+			anomalies = append(anomalies, fmt.Sprintf("tag %s disappeared (%d rows before)", tag, before))
+		case before == 0:
+			anomalies = append(anomalies, fmt.Sprintf("tag %s is new (%d rows)", tag, after))
+		default:
+			if change := countChange(before, after); change != "" {
+				anomalies = append(anomalies, fmt.Sprintf("tag %s %s", tag, change))
+			}
+		}
+	}
+	return anomalies
+}
+
+// countChange describes a change of a row count by anomalyFactor or more,
+// or returns ""
+func countChange(before, after int64) string {
+	if before == 0 || after == 0 {
+		if before == after {
+			return ""
+		}
+		return fmt.Sprintf("went from %d to %d", before, after)
+	}
+	ratio := float64(after) / float64(before)
+	switch {
+	case ratio >= anomalyFactor:
+		return fmt.Sprintf("grew %.1fx from %d to %d", ratio, before, after)
+	case ratio <= 1/anomalyFactor:
+		return fmt.Sprintf("shrank %.1fx from %d to %d", 1/ratio, before, after)
+	}
+	return ""
+}
+
+// checkAnomalies compares this run against baseline, recording the
+// anomalies in the run report. It returns an error when they should fail
+// the conversion.
+func checkAnomalies(baseline *RunReport) error {
+	if baseline == nil {
+		return nil
+	}
+	anomalies := findAnomalies(baseline, report)
+	report.Anomalies = anomalies
+	if len(anomalies) == 0 {
+		return nil
+	}
+	if failOnAnomaly {
+		return fmt.Errorf("anomalies found against %s: %s", compareReport, strings.Join(anomalies, "; "))
+	}
+	for _, a := range anomalies {
+		log.Printf("Anomaly against %s: %s", compareReport, a)
+	}
+	return nil
+}
+
+// validAnomalyFactor checks an --anomaly-factor value
+func validAnomalyFactor(factor float64) error {
+	if factor <= 1 || math.IsInf(factor, 0) || math.IsNaN(factor) {
+		return fmt.Errorf("--anomaly-factor must be a finite ratio above 1")
+	}
+	return nil
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+func init() {
+	extractors["articles"] = articlesExtractor{}
+}
+
+// ArticleRow is one scholarly article in articles.parquet. format is
+// pubmed, jats or arxiv; the identifiers an article lacks are "".
+type ArticleRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Format        string `parquet:"name=format, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	PMID          string `parquet:"name=pmid, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PMCID         string `parquet:"name=pmcid, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ArXivID       string `parquet:"name=arxiv_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DOI           string `parquet:"name=doi, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Title         string `parquet:"name=title, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Journal       string `parquet:"name=journal, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Year          int32  `parquet:"name=year, type=INT32, convertedtype=INT_32"`
+	Language      string `parquet:"name=language, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Abstract      string `parquet:"name=abstract, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// ArticleAuthorRow is one author of an article in article_authors.parquet,
+// joined to its article on file_path and article_node_id. Group authors
+// have their name in last_name.
+type ArticleAuthorRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ArticleNodeID int64  `parquet:"name=article_node_id, type=INT64, convertedtype=INT_64"`
+	Position      int32  `parquet:"name=position, type=INT32, convertedtype=INT_32"`
+	LastName      string `parquet:"name=last_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ForeName      string `parquet:"name=fore_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ORCID         string `parquet:"name=orcid, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Affiliation   string `parquet:"name=affiliation, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// ArticleReferenceRow is one entry of an article's reference list in
+// article_references.parquet. citation is the text of the reference; the
+// other columns are filled only where the source marks them up.
+type ArticleReferenceRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ArticleNodeID int64  `parquet:"name=article_node_id, type=INT64, convertedtype=INT_64"`
+	Position      int32  `parquet:"name=position, type=INT32, convertedtype=INT_32"`
+	Citation      string `parquet:"name=citation, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Title         string `parquet:"name=title, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Source        string `parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Year          int32  `parquet:"name=year, type=INT32, convertedtype=INT_32"`
+	PMID          string `parquet:"name=pmid, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DOI           string `parquet:"name=doi, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// articlesExtractor turns bibliographic XML into articles, authors and
+// references: PubMed baseline and update files (PubmedArticleSet), JATS
+// articles as distributed by PMC (article, pmc-articleset) and arXiv
+// metadata harvested over OAI-PMH in the arXiv format.
+type articlesExtractor struct{}
+
+// Tables returns the articles, article_authors and article_references
+// tables
+func (articlesExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{
+		"articles":           new(ArticleRow),
+		"article_authors":    new(ArticleAuthorRow),
+		"article_references": new(ArticleReferenceRow),
+	}
+}
+
+// Detect accepts the roots of PubMed, JATS and arXiv OAI-PMH documents
+func (articlesExtractor) Detect(doc *Document) bool {
+	switch doc.Root.XMLName.Local {
+	case "PubmedArticleSet", "PubmedArticle", "pmc-articleset":
+		return true
+	case "article":
+		return childElement(doc.Root, "front") != nil
+	case "OAI-PMH":
+		for _, record := range childElements(childElement(doc.Root, "ListRecords"), "record") {
+			if arxiv := childElement(childElement(record, "metadata"), "arXiv"); arxiv != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Extract writes a row per article and per author and reference. The
+// walk is in document order, so authors and references belong to the
+// article most recently entered.
+func (articlesExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	var (
+		err        error
+		format     string
+		article    int64
+		authors    int32
+		references int32
+		affs       map[string]string
+	)
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil {
+			return
+		}
+		local := node.XMLName.Local
+		parentLocal := ""
+		if parent != nil {
+			parentLocal = parent.XMLName.Local
+		}
+
+		if f := articleFormat(node, parent); f != "" {
+			format, article, authors, references = f, nodeID, 0, 0
+			row := ArticleRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, Format: f}
+			switch f {
+			case "pubmed":
+				pubmedArticle(node, &row)
+			case "jats":
+				affs = jatsArticle(node, &row)
+			case "arxiv":
+				arxivArticle(node, &row)
+			}
+			err = rows("articles", row)
+			return
+		}
+		if article == 0 {
+			return
+		}
+		if local == "sub-article" {
+			article = 0 // its front matter is not the enclosing article's
+			return
+		}
+
+		author := ArticleAuthorRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, ArticleNodeID: article}
+		reference := ArticleReferenceRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, ArticleNodeID: article}
+		switch {
+		case format == "pubmed" && local == "Author" && parentLocal == "AuthorList" && attrValue(parent, "Type") != "editors":
+			authors++
+			author.Position = authors
+			pubmedAuthor(node, &author)
+			err = rows("article_authors", author)
+		case format == "pubmed" && local == "Reference" && parentLocal == "ReferenceList":
+			references++
+			reference.Position = references
+			reference.Citation = flatText(childElement(node, "Citation"))
+			for _, id := range childElements(childElement(node, "ArticleIdList"), "ArticleId") {
+				switch attrValue(id, "IdType") {
+				case "pubmed":
+					reference.PMID = strings.TrimSpace(id.Content)
+				case "doi":
+					reference.DOI = strings.TrimSpace(id.Content)
+				}
+			}
+			err = rows("article_references", reference)
+		case format == "jats" && local == "contrib" && parentLocal == "contrib-group" && attrValue(node, "contrib-type") == "author":
+			authors++
+			author.Position = authors
+			jatsAuthor(node, affs, &author)
+			err = rows("article_authors", author)
+		case format == "jats" && local == "ref" && parentLocal == "ref-list":
+			references++
+			reference.Position = references
+			jatsReference(node, &reference)
+			err = rows("article_references", reference)
+		case format == "arxiv" && local == "author" && parentLocal == "authors":
+			authors++
+			author.Position = authors
+			author.LastName = flatText(childElement(node, "keyname"))
+			author.ForeName = flatText(childElement(node, "forenames"))
+			author.Affiliation = flatText(childElement(node, "affiliation"))
+			err = rows("article_authors", author)
+		}
+	})
+	return err
+}
+
+// articleFormat names the format of an article element, or "" when node
+// is not one
+func articleFormat(node, parent *XMLNode) string {
+	switch node.XMLName.Local {
+	case "PubmedArticle":
+		return "pubmed"
+	case "article":
+		if parent == nil || parent.XMLName.Local == "pmc-articleset" {
+			return "jats"
+		}
+	case "arXiv":
+		if parent != nil && parent.XMLName.Local == "metadata" {
+			return "arxiv"
+		}
+	}
+	return ""
+}
+
+// pubmedArticle fills an article row from a PubmedArticle element
+func pubmedArticle(node *XMLNode, row *ArticleRow) {
+	citation := childElement(node, "MedlineCitation")
+	article := childElement(citation, "Article")
+	journal := childElement(article, "Journal")
+	row.PMID = flatText(childElement(citation, "PMID"))
+	row.Title = flatText(childElement(article, "ArticleTitle"))
+	row.Journal = flatText(childElement(journal, "Title"))
+	row.Language = flatText(childElement(article, "Language"))
+
+	pubDate := childElement(childElement(journal, "JournalIssue"), "PubDate")
+	if year := childElement(pubDate, "Year"); year != nil {
+		row.Year = articleYear(year.Content)
+	} else {
+		row.Year = articleYear(flatText(childElement(pubDate, "MedlineDate")))
+	}
+
+	var abstract []string
+	for _, text := range childElements(childElement(article, "Abstract"), "AbstractText") {
+		if label := attrValue(text, "Label"); label != "" {
+			abstract = append(abstract, label+": "+flatText(text))
+		} else {
+			abstract = append(abstract, flatText(text))
+		}
+	}
+	row.Abstract = strings.Join(abstract, "\n")
+
+	for _, location := range childElements(article, "ELocationID") {
+		if attrValue(location, "EIdType") == "doi" {
+			row.DOI = flatText(location)
+		}
+	}
+	for _, id := range childElements(childElement(childElement(node, "PubmedData"), "ArticleIdList"), "ArticleId") {
+		switch attrValue(id, "IdType") {
+		case "doi":
+			row.DOI = flatText(id)
+		case "pmc":
+			row.PMCID = flatText(id)
+		}
+	}
+}
+
+// pubmedAuthor fills an author row from a PubMed Author element
+func pubmedAuthor(node *XMLNode, row *ArticleAuthorRow) {
+	row.LastName = flatText(childElement(node, "LastName"))
+	if row.LastName == "" {
+		row.LastName = flatText(childElement(node, "CollectiveName"))
+	}
+	row.ForeName = flatText(childElement(node, "ForeName"))
+	for _, id := range childElements(node, "Identifier") {
+		if attrValue(id, "Source") == "ORCID" {
+			row.ORCID = flatText(id)
+		}
+	}
+	var affiliations []string
+	for _, info := range childElements(node, "AffiliationInfo") {
+		if aff := flatText(childElement(info, "Affiliation")); aff != "" {
+			affiliations = append(affiliations, aff)
+		}
+	}
+	row.Affiliation = strings.Join(affiliations, "; ")
+}
+
+// jatsArticle fills an article row from a JATS article element and returns
+// the affiliations of its front matter by id, for the authors to resolve
+func jatsArticle(node *XMLNode, row *ArticleRow) map[string]string {
+	front := childElement(node, "front")
+	meta := childElement(front, "article-meta")
+	journalMeta := childElement(front, "journal-meta")
+	row.Language = attrValue(node, "lang")
+	row.Title = flatText(childElement(childElement(meta, "title-group"), "article-title"))
+	if title := childElement(childElement(journalMeta, "journal-title-group"), "journal-title"); title != nil {
+		row.Journal = flatText(title)
+	} else {
+		row.Journal = flatText(childElement(journalMeta, "journal-title"))
+	}
+	for _, id := range childElements(meta, "article-id") {
+		switch attrValue(id, "pub-id-type") {
+		case "pmid":
+			row.PMID = flatText(id)
+		case "pmc", "pmcid":
+			row.PMCID = flatText(id)
+		case "doi":
+			row.DOI = flatText(id)
+		}
+	}
+	for _, date := range childElements(meta, "pub-date") {
+		if year := articleYear(flatText(childElement(date, "year"))); year != 0 {
+			row.Year = year
+			break
+		}
+	}
+	if abstract := childElement(meta, "abstract"); abstract != nil {
+		row.Abstract = flatText(abstract)
+	}
+
+	affs := make(map[string]string)
+	var collect func(n *XMLNode)
+	collect = func(n *XMLNode) {
+		for i := range n.Nodes {
+			child := &n.Nodes[i]
+			if child.XMLName.Local == "aff" {
+				if id := attrValue(child, "id"); id != "" {
+					affs[id] = jatsAffiliation(child)
+				}
+				continue
+			}
+			collect(child)
+		}
+	}
+	if meta != nil {
+		collect(meta)
+	}
+	return affs
+}
+
+// jatsAuthor fills an author row from a JATS contrib element, resolving
+// its xref links to the affiliations of the front matter
+func jatsAuthor(node *XMLNode, affs map[string]string, row *ArticleAuthorRow) {
+	name := childElement(node, "name")
+	if name == nil {
+		name = childElement(childElement(node, "name-alternatives"), "name")
+	}
+	row.LastName = flatText(childElement(name, "surname"))
+	row.ForeName = flatText(childElement(name, "given-names"))
+	if row.LastName == "" {
+		row.LastName = flatText(childElement(node, "collab"))
+	}
+	for _, id := range childElements(node, "contrib-id") {
+		if attrValue(id, "contrib-id-type") == "orcid" {
+			row.ORCID = flatText(id)
+		}
+	}
+	var affiliations []string
+	for _, aff := range childElements(node, "aff") {
+		affiliations = append(affiliations, jatsAffiliation(aff))
+	}
+	for _, xref := range childElements(node, "xref") {
+		if attrValue(xref, "ref-type") != "aff" {
+			continue
+		}
+		for _, id := range strings.Fields(attrValue(xref, "rid")) {
+			if aff, ok := affs[id]; ok {
+				affiliations = append(affiliations, aff)
+			}
+		}
+	}
+	row.Affiliation = strings.Join(affiliations, "; ")
+}
+
+// jatsAffiliation returns the text of an aff element without its label
+func jatsAffiliation(aff *XMLNode) string {
+	trimmed := *aff
+	trimmed.Nodes = nil
+	for _, child := range aff.Nodes {
+		if child.XMLName.Local != "label" {
+			trimmed.Nodes = append(trimmed.Nodes, child)
+		}
+	}
+	return flatText(&trimmed)
+}
+
+// jatsReference fills a reference row from a JATS ref element
+func jatsReference(node *XMLNode, row *ArticleReferenceRow) {
+	citation := childElement(node, "element-citation")
+	if citation == nil {
+		citation = childElement(node, "mixed-citation")
+	}
+	if citation == nil {
+		citation = childElement(node, "citation")
+	}
+	row.Citation = flatText(citation)
+	row.Title = flatText(childElement(citation, "article-title"))
+	row.Source = flatText(childElement(citation, "source"))
+	row.Year = articleYear(flatText(childElement(citation, "year")))
+	for _, id := range childElements(citation, "pub-id") {
+		switch attrValue(id, "pub-id-type") {
+		case "pmid":
+			row.PMID = flatText(id)
+		case "doi":
+			row.DOI = flatText(id)
+		}
+	}
+}
+
+// arxivArticle fills an article row from the arXiv metadata of an OAI-PMH
+// record
+func arxivArticle(node *XMLNode, row *ArticleRow) {
+	row.ArXivID = flatText(childElement(node, "id"))
+	row.Title = flatText(childElement(node, "title"))
+	row.Journal = flatText(childElement(node, "journal-ref"))
+	row.DOI = flatText(childElement(node, "doi"))
+	row.Abstract = flatText(childElement(node, "abstract"))
+	row.Year = articleYear(flatText(childElement(node, "created")))
+}
+
+// articleYear reads the year at the start of a date such as 2019,
+// 2019-05-01 or the MedlineDate 2019 Jan-Feb, 0 when there is none
+func articleYear(date string) int32 {
+	date = strings.TrimSpace(date)
+	if len(date) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return int32(year)
+}
+
+// flatText returns the text of an element and its descendants with runs
+// of whitespace collapsed. The decoded tree keeps an element's own text
+// apart from its children's, so inline markup (<i>, <sup>) comes out after
+// the surrounding text rather than in place.
+func flatText(node *XMLNode) string {
+	if node == nil {
+		return ""
+	}
+	var words []string
+	var collect func(n *XMLNode)
+	collect = func(n *XMLNode) {
+		words = append(words, strings.Fields(n.Content)...)
+		for i := range n.Nodes {
+			collect(&n.Nodes[i])
+		}
+	}
+	collect(node)
+	return strings.Join(words, " ")
+}
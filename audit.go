@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// auditLog is the append-only JSON Lines file every conversion is recorded
+// in (--audit-log). Each entry carries the hash of the one before it, so
+// editing, removing or reordering entries breaks the chain that
+// "xmlgo verify-audit" checks. Processes appending to the same log must
+// not run at the same time.
+var auditLog string
+
+// auditSecret, when set, keys the entry hashes with HMAC-SHA256, so the
+// chain cannot be recomputed after a change without the secret
+// (--audit-secret)
+var auditSecret string
+
+// auditEntry records who ran which conversion, when, with which flags, on
+// which inputs and producing which outputs
+type auditEntry struct {
+	Seq       int64       `json:"seq"`
+	Time      string      `json:"time"`
+	User      string      `json:"user"`
+	Host      string      `json:"host"`
+	Tenant    string      `json:"tenant,omitempty"`
+	JobID     string      `json:"job_id,omitempty"`
+	Action    string      `json:"action"`
+	Args      []string    `json:"args"`
+	Input     string      `json:"input"`
+	OutputDir string      `json:"output_dir"`
+	Inputs    []auditFile `json:"inputs"`
+	Outputs   []auditFile `json:"outputs"`
+	Status    string      `json:"status"`
+	Error     string      `json:"error,omitempty"`
+	PrevHash  string      `json:"prev_hash"`
+	Hash      string      `json:"hash,omitempty"`
+}
+
+// auditFile is an input or output file with its content hash
+type auditFile struct {
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// recordAudit appends the conversion of input into outputDir that just
+// finished with err to the audit log. j is the server job, or nil.
+func recordAudit(j *job, input, outputDir string, err error) {
+	entry := &auditEntry{
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		Action:    "convert",
+		Args:      os.Args[1:],
+		Input:     input,
+		OutputDir: outputDir,
+		Status:    jobDone,
+	}
+	if j != nil {
+		entry.Tenant, entry.JobID = j.tenant.Name, j.ID
+	}
+	if err != nil {
+		entry.Status, entry.Error = jobFailed, err.Error()
+	}
+	if err := appendAudit(entry); err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+// appendAudit fills in the entry's identity, file hashes and place in the
+// chain and appends it to the audit log
+func appendAudit(entry *auditEntry) error {
+	if u, err := user.Current(); err == nil {
+		entry.User = u.Username
+	}
+	entry.Host, _ = os.Hostname()
+
+	var err error
+	if entry.Inputs, err = auditInputs(entry.Input); err != nil {
+		return err
+	}
+	if entry.Outputs, err = auditOutputs(entry.OutputDir); err != nil {
+		return err
+	}
+
+	last, err := lastAuditEntry(auditLog)
+	if err != nil {
+		return err
+	}
+	entry.Seq, entry.PrevHash = 1, ""
+	if last != nil {
+		entry.Seq, entry.PrevHash = last.Seq+1, last.Hash
+	}
+	if entry.Hash, err = auditHash(entry); err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %v", err)
+	}
+
+	f, err := os.OpenFile(auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return wrapFSError("open audit log", auditLog, err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return wrapFSError("append to audit log", auditLog, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return wrapFSError("sync audit log", auditLog, err)
+	}
+	if err := f.Close(); err != nil {
+		return wrapFSError("close audit log", auditLog, err)
+	}
+	return nil
+}
+
+// auditHash chains an entry to the one before it: the SHA-256 (or, with
+// --audit-secret, HMAC-SHA256) of its JSON encoding without the hash
+func auditHash(entry *auditEntry) (string, error) {
+	unhashed := *entry
+	unhashed.Hash = ""
+	data, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit entry: %v", err)
+	}
+	var h hash.Hash
+	if auditSecret != "" {
+		h = hmac.New(sha256.New, []byte(auditSecret))
+	} else {
+		h = sha256.New()
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// auditInputs hashes the input file, or every file below the input
+// directory, or a --filelist and the files it lists. Inputs that are not
+// local files, such as URLs, are recorded without a hash.
+func auditInputs(input string) ([]auditFile, error) {
+	if fileListFile != "" && input == fileListFile {
+		paths := []string{input}
+		for path := range fileListEntries {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths[1:])
+		files := make([]auditFile, 0, len(paths))
+		for _, path := range paths {
+			file, err := hashAuditFile(path, filepath.ToSlash(path))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+		}
+		return files, nil
+	}
+	info, err := os.Stat(input)
+	if err != nil {
+		return []auditFile{}, nil
+	}
+	if !info.IsDir() {
+		file, err := hashAuditFile(input, input)
+		if err != nil {
+			return nil, err
+		}
+		return []auditFile{file}, nil
+	}
+	files := []auditFile{}
+	err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return wrapFSError("read", path, err)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(input, path)
+		if err != nil {
+			return err
+		}
+		file, err := hashAuditFile(path, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		return nil
+	})
+	return files, err
+}
+
+// auditOutputs hashes the files the conversion left in outputDir, except
+// the audit log itself
+func auditOutputs(outputDir string) ([]auditFile, error) {
+	logPath, _ := filepath.Abs(auditLog)
+	files := []auditFile{}
+	for _, name := range listOutputs(outputDir) {
+		path := filepath.Join(outputDir, filepath.FromSlash(name))
+		if abs, _ := filepath.Abs(path); abs == logPath {
+			continue
+		}
+		file, err := hashAuditFile(path, name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// hashAuditFile hashes the file at path, recording it under name
+func hashAuditFile(path, name string) (auditFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return auditFile{}, wrapFSError("open", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return auditFile{}, fmt.Errorf("failed to hash %s: %v", path, err)
+	}
+	return auditFile{Path: name, Bytes: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// lastAuditEntry returns the final entry of the audit log, or nil when the
+// log does not exist yet
+func lastAuditEntry(fileName string) (*auditEntry, error) {
+	data, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, wrapFSError("read audit log", fileName, err)
+	}
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var last auditEntry
+	if err := json.Unmarshal(data[bytes.LastIndexByte(data, '\n')+1:], &last); err != nil {
+		return nil, fmt.Errorf("failed to parse last entry of audit log %s: %v", fileName, err)
+	}
+	return &last, nil
+}
+
+// verifyAuditLog checks that every entry of the audit log is intact and
+// follows the one before it, returning the number of entries
+func verifyAuditLog(fileName string) (int64, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return 0, wrapFSError("open audit log", fileName, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	var prev auditEntry
+	var n int64
+	for line := 1; scanner.Scan(); line++ {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return n, fmt.Errorf("line %d: failed to parse entry: %v", line, err)
+		}
+		if entry.Seq != prev.Seq+1 {
+			return n, fmt.Errorf("line %d: entry %d follows entry %d", line, entry.Seq, prev.Seq)
+		}
+		if entry.PrevHash != prev.Hash {
+			return n, fmt.Errorf("line %d: entry %d does not chain to entry %d", line, entry.Seq, prev.Seq)
+		}
+		want, err := auditHash(&entry)
+		if err != nil {
+			return n, err
+		}
+		if !hmac.Equal([]byte(entry.Hash), []byte(want)) {
+			return n, fmt.Errorf("line %d: entry %d was modified", line, entry.Seq)
+		}
+		prev = entry
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, wrapFSError("read audit log", fileName, err)
+	}
+	return n, nil
+}
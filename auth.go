@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// apiTokensFile lists the bearer tokens accepted by a server without
+// tenants, one per line. Blank lines and lines starting with # are ignored.
+var apiTokensFile string
+
+// TLS settings of the server. With tlsClientCAFile set clients must present
+// a certificate signed by one of its CAs (mutual TLS).
+var (
+	tlsCertFile     string
+	tlsKeyFile      string
+	tlsClientCAFile string
+)
+
+// loadAPITokens reads the tokens of apiTokensFile
+func loadAPITokens(fileName string) ([]string, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, wrapFSError("read", fileName, err)
+	}
+	var tokens []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%s lists no tokens", fileName)
+	}
+	return tokens, nil
+}
+
+// bearerToken returns the token of the request's Authorization header
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// tokenMatches compares tokens in constant time
+func tokenMatches(token, want string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// serverTLSConfig builds the TLS configuration of the server, or nil when
+// it serves plaintext HTTP
+func serverTLSConfig() (*tls.Config, error) {
+	if tlsCertFile == "" && tlsKeyFile == "" {
+		if tlsClientCAFile != "" {
+			return nil, fmt.Errorf("--tls-client-ca requires --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+	if tlsCertFile == "" || tlsKeyFile == "" {
+		return nil, fmt.Errorf("--tls-cert and --tls-key must be given together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if tlsClientCAFile != "" {
+		data, err := os.ReadFile(tlsClientCAFile)
+		if err != nil {
+			return nil, wrapFSError("read", tlsClientCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s contains no PEM certificates", tlsClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
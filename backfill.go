@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backfill settings: the modification window of the objects to convert,
+// how many objects each batch converts and how many download at once
+var (
+	backfillSince   string
+	backfillUntil   string
+	backfillBatch   = 500
+	downloadWorkers = 8
+)
+
+// backfillFileName records the objects a backfill has converted, so an
+// interrupted backfill continues with the first unconverted batch
+const backfillFileName = "backfill.json"
+
+// stagingDirName holds the downloaded objects of the batch being converted
+const stagingDirName = ".staging"
+
+// parseBackfillTime accepts a date (2024-01-01) or an RFC 3339 timestamp
+func parseBackfillTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date (2006-01-02) nor an RFC 3339 time", value)
+	}
+	return t, nil
+}
+
+// stagedBatch is a batch of objects downloaded into dir
+type stagedBatch struct {
+	dir     string
+	objects []objectInfo
+	err     error
+}
+
+// runBackfill converts the objects below an object store URL that were
+// modified in the --since/--until window. Objects are converted in batches
+// into numbered directories below outputDir; the next batch downloads while
+// the current one converts.
+func runBackfill(cfg *runConfig, source string, outputDir string) error {
+	var since, until time.Time
+	var err error
+	if backfillSince != "" {
+		if since, err = parseBackfillTime(backfillSince); err != nil {
+			return fmt.Errorf("invalid --since: %v", err)
+		}
+	}
+	if backfillUntil != "" {
+		if until, err = parseBackfillTime(backfillUntil); err != nil {
+			return fmt.Errorf("invalid --until: %v", err)
+		}
+	}
+	if backfillBatch < 1 || downloadWorkers < 1 {
+		return fmt.Errorf("--backfill-batch and --download-workers must be at least 1")
+	}
+
+	store, prefix, err := openObjectStore(source)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return wrapFSError("create", outputDir, err)
+	}
+	release, err := acquireLock(outputDir)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	manifestFile := filepath.Join(outputDir, backfillFileName)
+	manifest, err := loadIncrementalManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+
+	listed, err := store.list(prefix)
+	if err != nil {
+		return err
+	}
+	batchRuns := make(map[string]bool)
+	for _, entry := range manifest.Inputs {
+		batchRuns[entry.Run] = true
+	}
+	var pending []objectInfo
+	for _, object := range listed {
+		if object.LastModified.Before(since) || (!until.IsZero() && !object.LastModified.Before(until)) {
+			continue
+		}
+		last, ok := manifest.Inputs[object.Key]
+		if ok && last.Size == object.Size && last.ModTime.Equal(object.LastModified) {
+			continue
+		}
+		pending = append(pending, object)
+	}
+	sort.Slice(pending, func(a, b int) bool { return pending[a].Key < pending[b].Key })
+	log.Printf("Listed %d objects below %s, %d to convert", len(listed), source, len(pending))
+
+	var batches [][]objectInfo
+	for len(pending) > 0 {
+		n := min(backfillBatch, len(pending))
+		batches = append(batches, pending[:n])
+		pending = pending[n:]
+	}
+
+	stagingRoot := filepath.Join(outputDir, stagingDirName)
+	defer os.RemoveAll(stagingRoot)
+	stage := func(n int) <-chan stagedBatch {
+		staged := make(chan stagedBatch, 1)
+		go func() {
+			dir := filepath.Join(stagingRoot, fmt.Sprint(n%2))
+			err := downloadBatch(store, batches[n], dir)
+			staged <- stagedBatch{dir: dir, objects: batches[n], err: err}
+		}()
+		return staged
+	}
+
+	// A batch downloading ahead must finish before staging is removed
+	var next <-chan stagedBatch
+	defer func() {
+		if next != nil {
+			<-next
+		}
+	}()
+	if len(batches) > 0 {
+		next = stage(0)
+	}
+	for n := range batches {
+		batch := <-next
+		next = nil
+		if n+1 < len(batches) {
+			next = stage(n + 1)
+		}
+		if batch.err != nil {
+			return batch.err
+		}
+
+		run := fmt.Sprintf("batch-%05d", len(batchRuns)+1)
+		batchRuns[run] = true
+		tombstones, err := convertBatch(cfg, manifest, batch, filepath.Join(outputDir, run), run)
+		if err != nil {
+			return err
+		}
+		if err := manifest.write(manifestFile); err != nil {
+			return err
+		}
+		if err := recordSnapshot(outputDir, run, tombstones); err != nil {
+			return err
+		}
+		os.RemoveAll(batch.dir)
+		if deadlineExceeded {
+			log.Printf("Deadline reached after %s; run the backfill again to continue", run)
+			break
+		}
+	}
+	return nil
+}
+
+// convertBatch converts a staged batch into runDir and records its
+// converted objects in manifest, returning the tombstones of the objects
+// it converted again
+func convertBatch(cfg *runConfig, manifest *incrementalManifest, batch stagedBatch, runDir string, run string) ([]TombstoneRow, error) {
+	log.Printf("Converting %d objects into %s", len(batch.objects), runDir)
+	provenanceRoot = batch.dir
+	_, err := convert(cfg, batch.dir, runDir)
+	provenanceRoot = ""
+	if webhookURL != "" {
+		if err := notifyWebhook(webhookURL, newWebhookPayload(batch.dir, runDir, err)); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	if auditLog != "" {
+		recordAudit(nil, batch.dir, runDir, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s: %v", run, err)
+	}
+	report.logSummary()
+
+	// Objects that failed or were cut off by a limit are picked up again
+	// by the next backfill
+	pending := make(map[string]bool)
+	for _, failure := range report.Failures {
+		pending[failure.Input] = true
+	}
+	for _, path := range report.Unprocessed {
+		pending[path] = true
+	}
+	var tombstones []TombstoneRow
+	for _, object := range batch.objects {
+		if pending[filepath.Join(batch.dir, filepath.FromSlash(object.Key))] {
+			continue
+		}
+		entry := incrementalEntry{Size: object.Size, ModTime: object.LastModified}
+		tombstones = append(tombstones, manifest.supersede(object.Key, filepath.FromSlash(object.Key), run, entry)...)
+	}
+	return tombstones, writeTombstones(runDir, tombstones)
+}
+
+// downloadBatch fetches objects into dir, keeping their keys as paths so
+// they become the provenance of the converted rows
+func downloadBatch(store objectStore, objects []objectInfo, dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return wrapFSError("clear", dir, err)
+	}
+
+	keys := make(chan objectInfo)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for range downloadWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range keys {
+				if err := downloadObject(store, object, dir); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, object := range objects {
+		keys <- object
+	}
+	close(keys)
+	wg.Wait()
+	return firstErr
+}
+
+// downloadObject copies one object below dir
+func downloadObject(store objectStore, object objectInfo, dir string) error {
+	rel := filepath.FromSlash(object.Key)
+	if !filepath.IsLocal(rel) || strings.HasSuffix(object.Key, "/") {
+		return fmt.Errorf("object key %q cannot be stored as a file", object.Key)
+	}
+	fileName := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return wrapFSError("create", filepath.Dir(fileName), err)
+	}
+
+	body, err := store.open(object.Key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	f, err := os.Create(fileName)
+	if err != nil {
+		return wrapFSError("create", fileName, err)
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to download %s: %v", object.Key, err)
+	}
+	if err := f.Close(); err != nil {
+		return wrapFSError("write", fileName, err)
+	}
+	return os.Chtimes(fileName, object.LastModified, object.LastModified)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cacheDir holds converted containers keyed by content hash (--cache-dir)
+var cacheDir string
+
+// cacheFormatVersion is part of every cache key, so entries written by an
+// incompatible version of the tool are never replayed
+const cacheFormatVersion = 4
+
+// cachedDocument is one entry of a converted container, stored in archive
+// order. Node IDs are stored relative to the document's ID block, starting
+// at 1, so the rows can be replayed at any position in a later run.
+// SkipReason is set for entries that were not parsed.
+type cachedDocument struct {
+	RelativePath string
+	EntryPath    string
+	Bytes        int64
+	Nodes        int64
+	Empty        bool
+	SkipReason   string
+	Declaration  *XMLDeclaration
+	Rows         []ParquetRow
+}
+
+// containerCacheKey hashes a container's content together with the
+// settings that change which rows it produces
+func containerCacheKey(zipFile string, extensions []string) (string, error) {
+	f, err := os.Open(zipFile)
+	if err != nil {
+		return "", wrapFSError("open container", zipFile, err)
+	}
+	defer f.Close()
+
+	// Handlers decide which entries become rows; map keys marshal sorted
+	handlers, err := json.Marshal(configuredHandlers)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode handlers: %v", err)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "xmlgo-cache-v%d\x00%s\x00%s\x00%d\x00%s\x00%s\x00%s\x00%s\x00%s\x00%t\x00", cacheFormatVersion, strings.Join(extensions, ","), emptyPartPolicy, maxFileSize, handlers, strings.Join(partTypes, ","), unicodeForm, caseFolding, textLocale, markSelfClosing)
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash container %s: %v", zipFile, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cachePath returns where the cache entry for key is stored
+func cachePath(key string) string {
+	return filepath.Join(cacheDir, key[:2], key+".gob")
+}
+
+// cacheRecorder streams the documents of a container into a new cache
+// entry, which only becomes visible once the whole container succeeded
+type cacheRecorder struct {
+	file *os.File
+	buf  *bufio.Writer
+	enc  *gob.Encoder
+	path string
+	rows []ParquetRow
+}
+
+// newCacheRecorder starts a cache entry for key
+func newCacheRecorder(key string) (*cacheRecorder, error) {
+	path := cachePath(key)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, wrapFSError("create cache directory", filepath.Dir(path), err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return nil, wrapFSError("create cache entry in", filepath.Dir(path), err)
+	}
+	buf := bufio.NewWriter(f)
+	return &cacheRecorder{file: f, buf: buf, enc: gob.NewEncoder(buf), path: path}, nil
+}
+
+// capture returns a writer that forwards to next while keeping a copy of
+// the rows for the document being written
+func (c *cacheRecorder) capture(next RowWriter) RowWriter {
+	c.rows = c.rows[:0]
+	return &captureWriter{next: next, recorder: c}
+}
+
+// add stores a finished document, rebasing its node IDs on ids
+func (c *cacheRecorder) add(relativePath string, entryPath string, bytes int64, ids *idBlock, declaration *XMLDeclaration, empty bool) error {
+	doc := cachedDocument{RelativePath: relativePath, EntryPath: entryPath, Bytes: bytes, Empty: empty, Declaration: declaration, Rows: c.rows}
+	if ids != nil {
+		doc.Nodes = ids.end - ids.first
+		for i := range doc.Rows {
+			doc.Rows[i].NodeID -= ids.first - 1
+			if doc.Rows[i].ParentNodeID != 0 {
+				doc.Rows[i].ParentNodeID -= ids.first - 1
+			}
+		}
+	}
+	if err := c.enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to write cache entry: %v", err)
+	}
+	return nil
+}
+
+// skip stores an entry that was not parsed, with the reason it was skipped
+func (c *cacheRecorder) skip(relativePath string, entryPath string, bytes int64, reason string) error {
+	doc := cachedDocument{RelativePath: relativePath, EntryPath: entryPath, Bytes: bytes, SkipReason: reason}
+	if err := c.enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to write cache entry: %v", err)
+	}
+	return nil
+}
+
+// finish publishes the cache entry
+func (c *cacheRecorder) finish() error {
+	if err := c.buf.Flush(); err != nil {
+		c.abort()
+		return fmt.Errorf("failed to write cache entry: %v", err)
+	}
+	if err := c.file.Close(); err != nil {
+		os.Remove(c.file.Name())
+		return fmt.Errorf("failed to write cache entry: %v", err)
+	}
+	return os.Rename(c.file.Name(), c.path)
+}
+
+// abort discards the partial cache entry
+func (c *cacheRecorder) abort() {
+	c.file.Close()
+	os.Remove(c.file.Name())
+}
+
+// captureWriter copies rows into its recorder on the way to next
+type captureWriter struct {
+	next     RowWriter
+	recorder *cacheRecorder
+}
+
+// Write records and forwards a row
+func (w *captureWriter) Write(row ParquetRow) error {
+	w.recorder.rows = append(w.recorder.rows, row)
+	return w.next.Write(row)
+}
+
+// WriteBatch records and forwards rows
+func (w *captureWriter) WriteBatch(rows []ParquetRow) error {
+	w.recorder.rows = append(w.recorder.rows, rows...)
+	return w.next.WriteBatch(rows)
+}
+
+// Commit forwards the commit point
+func (w *captureWriter) Commit(point CommitPoint) error {
+	return commitNext(w.next, point)
+}
+
+// WriteStop finalizes the next writer
+func (w *captureWriter) WriteStop() error {
+	return w.next.WriteStop()
+}
+
+// replayCachedContainer writes the cached rows for key if an entry exists,
+// assigning fresh node IDs, and copies the container's non-XML entries as
+// a normal conversion would, in the container's order. It reports whether
+// the cache was used.
+func replayCachedContainer(key string, files []*zip.File, containerPath string, outputDir string, rowWriter RowWriter) (bool, error) {
+	f, err := os.Open(cachePath(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, wrapFSError("open cache entry", cachePath(key), err)
+	}
+	defer f.Close()
+
+	byName := make(map[string]*zip.File, len(files))
+	for _, file := range files {
+		byName[file.Name] = file
+	}
+
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	for {
+		var doc cachedDocument
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return true, fmt.Errorf("failed to read cache entry %s: %v", cachePath(key), err)
+		}
+		if doc.SkipReason != "" {
+			if err := replayCachedSkip(doc, byName[doc.EntryPath], containerPath, outputDir); err != nil {
+				return true, err
+			}
+			continue
+		}
+		if checkpoints.skip(containerPath, doc.EntryPath) {
+			recordEntry(containerPath, doc.EntryPath, entrySkipped, skipResumed)
+			if err := recordSkip(doc.RelativePath, doc.Bytes, skipResumed); err != nil {
+				return true, err
+			}
+			continue
+		}
+
+		ids := nodeIDs.reserve(doc.Nodes)
+		if doc.Empty {
+			src := docSource{path: doc.RelativePath, container: containerPath, entry: doc.EntryPath}
+			if err := handleEmptyDocument(src, doc.Bytes, ids, 0, rowWriter); err != nil {
+				return true, err
+			}
+			recordEmptyEntry(containerPath, doc.EntryPath)
+			continue
+		}
+		if err := writeCachedDocument(doc, ids, containerPath, rowWriter); err != nil {
+			return true, err
+		}
+		recordEntry(containerPath, doc.EntryPath, entryParsed, "")
+	}
+}
+
+// replayCachedSkip records a cached entry that was not parsed. Entries
+// skipped before they were opened are recorded as they were; the rest go
+// through processZipEntry again, which copies assets to outputDir and
+// refuses names that would leave it.
+func replayCachedSkip(doc cachedDocument, file *zip.File, containerPath string, outputDir string) error {
+	reason := doc.SkipReason
+	if reason != skipPartType && reason != skipTooLarge && reason != skipUnsafe {
+		if file == nil {
+			return fmt.Errorf("cache entry for %s lists %s, which is not in the container", containerPath, doc.EntryPath)
+		}
+		result := &zipEntryResult{file: file, relativePath: doc.RelativePath}
+		if processZipEntry(result, outputDir); result.err != nil {
+			return result.err
+		}
+		reason = result.skipReason
+	}
+	recordEntry(containerPath, doc.EntryPath, entrySkipDisposition(reason), reason)
+	return recordSkip(doc.RelativePath, doc.Bytes, reason)
+}
+
+// writeCachedDocument writes a cached document's rows on ids and records it
+// like writeDocument does for a freshly parsed one
+func writeCachedDocument(doc cachedDocument, ids *idBlock, containerPath string, rowWriter RowWriter) error {
+	if err := checkLimits(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	for i := range doc.Rows {
+		doc.Rows[i].NodeID += ids.first - 1
+		if doc.Rows[i].ParentNodeID != 0 {
+			doc.Rows[i].ParentNodeID += ids.first - 1
+		}
+	}
+	ids.next = ids.end
+
+	src := docSource{path: doc.RelativePath, container: containerPath, entry: doc.EntryPath}
+	counter := &countingWriter{next: rowWriter, src: src, start: start}
+	if err := counter.WriteBatch(doc.Rows); err != nil {
+		return err
+	}
+	report.recordFile(counter.rows)
+
+	if filesTable != nil {
+		row := newFileRow(doc.RelativePath, doc.Bytes, counter.rows, ids, time.Since(start))
+		row.setDeclaration(doc.Declaration)
+		if err := recordFileRow(row); err != nil {
+			return err
+		}
+	}
+	return checkpoints.documentDone(containerPath, doc.EntryPath, rowWriter)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestCacheReplayMatchesConversion converts a container, then replays it
+// from --cache-dir: the files table must list the same entries in the same
+// order, including those skipped before they were opened.
+func TestCacheReplayMatchesConversion(t *testing.T) {
+	defer func(dir string, size int64, det bool, base string) {
+		cacheDir, maxFileSize, deterministic, pathBase = dir, size, det, base
+	}(cacheDir, maxFileSize, deterministic, pathBase)
+	input := t.TempDir()
+	cacheDir, maxFileSize, deterministic, pathBase = t.TempDir(), 64, true, input
+
+	writeTestZip(t, filepath.Join(input, "a.zip"), map[string]string{
+		"1.xml":     "<first/>",
+		"2-big.xml": "<big>" + strings.Repeat("x", 100) + "</big>",
+		"3.png":     "not really a picture",
+		"4.xml":     "<last><child/></last>",
+	})
+	cfg := &runConfig{formats: []string{"parquet"}, extensions: []string{".xml"}}
+
+	var outputs [2][][]string
+	for run := range outputs {
+		outputDir := t.TempDir()
+		if _, err := convert(cfg, input, outputDir); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(outputDir, "3.png")); err != nil {
+			t.Errorf("run %d did not copy the asset: %v", run+1, err)
+		}
+		_, rows, err := parquetRows(filepath.Join(outputDir, "files.parquet"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs[run] = rows
+	}
+	if len(outputs[0]) != 5 {
+		t.Fatalf("conversion recorded %d files, want the container and its 4 entries", len(outputs[0]))
+	}
+	if !slices.EqualFunc(outputs[0], outputs[1], slices.Equal[[]string]) {
+		t.Errorf("cached run recorded\n%v\nwant\n%v", outputs[1], outputs[0])
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// checkpointFileName records the committed parts of a run using --flush-interval
+const checkpointFileName = "checkpoint.json"
+
+// flushInterval is how often completed documents are committed to a finalized part (0 disables)
+var flushInterval time.Duration
+
+// resumeRun continues a previous run from its checkpoint instead of starting over
+var resumeRun bool
+
+// CommitPoint marks a position in the row stream where every row of the
+// listed documents, by documentKey, has been written
+type CommitPoint struct {
+	Documents  []string
+	NextNodeID int64
+}
+
+// committer is implemented by writers that can finalize output at a commit point
+type committer interface {
+	Commit(point CommitPoint) error
+}
+
+// commitNext forwards a commit point to next if it supports commits
+func commitNext(next RowWriter, point CommitPoint) error {
+	if c, ok := next.(committer); ok {
+		return c.Commit(point)
+	}
+	return nil
+}
+
+// Checkpoint is the content of checkpoint.json
+type Checkpoint struct {
+	Parts      []string `json:"parts"`
+	Documents  []string `json:"documents"`
+	NextNodeID int64    `json:"next_node_id"`
+}
+
+// loadCheckpoint reads a checkpoint file
+func loadCheckpoint(fileName string) (*Checkpoint, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s: %v", fileName, err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %v", fileName, err)
+	}
+	return &cp, nil
+}
+
+// save writes the checkpoint atomically so a crash never leaves it half written
+func (cp *Checkpoint) save(fileName string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %v", err)
+	}
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, fileName); err != nil {
+		return fmt.Errorf("failed to replace checkpoint %s: %v", fileName, err)
+	}
+	return nil
+}
+
+// RollingWriter splits the output into numbered parts (combined-00000.parquet,
+// combined-00001.parquet, ...). Each commit finalizes the current part with
+// its footer and records it in checkpoint.json, so every committed part stays
+// readable even if the process dies later in the run.
+type RollingWriter struct {
+	format         string
+	base           string
+	ext            string
+	checkpointFile string
+	checkpoint     *Checkpoint
+	current        RowWriter
+}
+
+// NewRollingWriter creates a rolling writer for format. fileName is the
+// unsplit output name that part names are derived from. With --resume the
+// existing checkpoint is loaded and numbering continues after its last part.
+func NewRollingWriter(format string, fileName string, checkpointFile string) (*RollingWriter, error) {
+	ext := filepath.Ext(fileName)
+	w := &RollingWriter{
+		format:         format,
+		base:           strings.TrimSuffix(fileName, ext),
+		ext:            ext,
+		checkpointFile: checkpointFile,
+		checkpoint:     &Checkpoint{NextNodeID: 1},
+	}
+
+	if _, err := os.Stat(checkpointFile); err == nil && resumeRun {
+		cp, err := loadCheckpoint(checkpointFile)
+		if err != nil {
+			return nil, err
+		}
+		w.checkpoint = cp
+	}
+	return w, nil
+}
+
+// partName returns the file name of part n
+func (w *RollingWriter) partName(n int) string {
+	return fmt.Sprintf("%s-%05d%s", w.base, n, w.ext)
+}
+
+// Write appends a row to the current part, opening it if needed
+func (w *RollingWriter) Write(row ParquetRow) error {
+	if w.current == nil {
+		part, err := openFormatWriter(w.format, w.partName(len(w.checkpoint.Parts)))
+		if err != nil {
+			return err
+		}
+		w.current = part
+	}
+	return w.current.Write(row)
+}
+
+// WriteBatch appends rows to the current part
+func (w *RollingWriter) WriteBatch(rows []ParquetRow) error {
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit finalizes the current part and records it with the documents it completes
+func (w *RollingWriter) Commit(point CommitPoint) error {
+	if w.current != nil {
+		if err := w.current.WriteStop(); err != nil {
+			return err
+		}
+		w.current = nil
+		w.checkpoint.Parts = append(w.checkpoint.Parts, filepath.Base(w.partName(len(w.checkpoint.Parts))))
+	}
+	if len(point.Documents) == 0 {
+		return nil
+	}
+	w.checkpoint.Documents = append(w.checkpoint.Documents, point.Documents...)
+	w.checkpoint.NextNodeID = point.NextNodeID
+	return w.checkpoint.save(w.checkpointFile)
+}
+
+// WriteStop finalizes a part left open since the last commit
+func (w *RollingWriter) WriteStop() error {
+	if w.current == nil {
+		return nil
+	}
+	err := w.current.WriteStop()
+	w.current = nil
+	return err
+}
+
+// Checkpointer decides when completed documents are committed and which
+// documents a resumed run can skip
+type Checkpointer struct {
+	interval time.Duration
+	last     time.Time
+	pending  []string
+	done     map[string]bool
+}
+
+// checkpoints is set when --flush-interval is enabled
+var checkpoints *Checkpointer
+
+// newCheckpointer creates a checkpointer; documents in a resumed checkpoint are skipped
+func newCheckpointer(interval time.Duration, resumed *Checkpoint) *Checkpointer {
+	c := &Checkpointer{interval: interval, last: time.Now(), done: make(map[string]bool)}
+	if resumed != nil {
+		for _, doc := range resumed.Documents {
+			c.done[doc] = true
+		}
+	}
+	return c
+}
+
+// documentKey identifies a document in checkpoint.json: a container entry
+// by its container_path and entry_path, since containers of the same layout
+// share entry names, and any other input by its path. A container entry's
+// key cannot be another input's path, as the container is a file.
+func documentKey(container, entry string) string {
+	if entry == "" {
+		return container
+	}
+	return container + "/" + entry
+}
+
+// skip reports whether a document was already committed by a previous run.
+// entry is empty for documents that are not container entries.
+func (c *Checkpointer) skip(container, entry string) bool {
+	return c != nil && c.done[documentKey(container, entry)]
+}
+
+// documentDone records a fully written document and commits once the interval has passed
+func (c *Checkpointer) documentDone(container, entry string, rowWriter RowWriter) error {
+	if c == nil {
+		return nil
+	}
+	c.pending = append(c.pending, documentKey(container, entry))
+	if time.Since(c.last) < c.interval {
+		return nil
+	}
+	return c.commit(rowWriter)
+}
+
+// commit sends the pending documents down the writer chain as a commit point
+func (c *Checkpointer) commit(rowWriter RowWriter) error {
+	if c == nil || len(c.pending) == 0 {
+		return nil
+	}
+	point := CommitPoint{Documents: c.pending, NextNodeID: nodeIDs.peek()}
+	c.pending = nil
+	c.last = time.Now()
+	return commitNext(rowWriter, point)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"archive/zip"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// writeTestZip writes a container with the given entries, in name order
+func writeTestZip(t *testing.T, fileName string, entries map[string]string) {
+	t.Helper()
+	f, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range slices.Sorted(maps.Keys(entries)) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entries[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// containerRows counts the rows of each container_path in the parts of outputDir
+func containerRows(t *testing.T, outputDir string) map[string]int {
+	t.Helper()
+	parts, err := filepath.Glob(filepath.Join(outputDir, "combined-*.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, part := range parts {
+		columns, rows, err := parquetRows(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, column := range columns {
+			if column != "container_path" {
+				continue
+			}
+			for _, row := range rows {
+				container, err := strconv.Unquote(row[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				counts[filepath.Base(container)]++
+			}
+		}
+	}
+	return counts
+}
+
+// TestResumeKeysEntriesByContainer resumes a run after a second container
+// with the same entry names was added: its entries were never committed,
+// so they must be converted rather than skipped as done.
+func TestResumeKeysEntriesByContainer(t *testing.T) {
+	defer func(interval time.Duration, resume bool) { flushInterval, resumeRun = interval, resume }(flushInterval, resumeRun)
+	flushInterval = time.Nanosecond
+
+	input, outputDir := t.TempDir(), t.TempDir()
+	entries := map[string]string{
+		"[Content_Types].xml": `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`,
+		"xl/workbook.xml":     `<workbook><sheets><sheet name="One"/></sheets></workbook>`,
+	}
+	cfg := &runConfig{formats: []string{"parquet"}, extensions: []string{".xml", ".rels"}}
+
+	writeTestZip(t, filepath.Join(input, "a.xlsx"), entries)
+	resumeRun = false
+	if _, err := convert(cfg, input, outputDir); err != nil {
+		t.Fatal(err)
+	}
+	first := containerRows(t, outputDir)
+	if first["a.xlsx"] == 0 {
+		t.Fatalf("first run wrote no rows of a.xlsx: %v", first)
+	}
+
+	writeTestZip(t, filepath.Join(input, "b.xlsx"), entries)
+	resumeRun = true
+	if _, err := convert(cfg, input, outputDir); err != nil {
+		t.Fatal(err)
+	}
+	resumed := containerRows(t, outputDir)
+	if resumed["a.xlsx"] != first["a.xlsx"] {
+		t.Errorf("a.xlsx has %d rows after resuming, want %d", resumed["a.xlsx"], first["a.xlsx"])
+	}
+	if resumed["b.xlsx"] != first["a.xlsx"] {
+		t.Errorf("b.xlsx has %d rows after resuming, want %d", resumed["b.xlsx"], first["a.xlsx"])
+	}
+}
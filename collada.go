@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	documentDecoders["collada"] = decodeCollada
+	formatHandlers[".dae"] = "collada"
+}
+
+// colladaRawArrays keeps the values of COLLADA geometry arrays instead of
+// summarizing them (--collada-raw-arrays)
+var colladaRawArrays bool
+
+// colladaArrays are the COLLADA elements holding whitespace-separated
+// value lists: the arrays of sources and the index lists of primitives
+var colladaArrays = map[string]bool{
+	"float_array": true, "int_array": true, "bool_array": true, "Name_array": true, "IDREF_array": true, "SIDREF_array": true,
+	"p": true, "v": true, "vcount": true, "h": true,
+}
+
+// decodeCollada decodes a COLLADA (.dae) document, replacing the content of
+// geometry arrays and index lists with summary-count, summary-min and
+// summary-max attributes, so a mesh of millions of vertices is a handful of
+// rows. Numeric arrays read through an accessor with a stride of up to 4
+// are summarized per component (the bounding box of a position array).
+// The option arrays=raw or --collada-raw-arrays keeps the values.
+func decodeCollada(r io.Reader, options map[string]string) (XMLNode, error) {
+	root, err := decodeXML(r, options)
+	if err != nil || colladaRawArrays || options["arrays"] == "raw" {
+		return root, err
+	}
+	strides := make(map[string]int)
+	var findAccessors func(node *XMLNode)
+	findAccessors = func(node *XMLNode) {
+		if node.XMLName.Local == "accessor" {
+			if stride, err := strconv.Atoi(attrValue(node, "stride")); err == nil {
+				strides[strings.TrimPrefix(attrValue(node, "source"), "#")] = stride
+			}
+		}
+		for i := range node.Nodes {
+			findAccessors(&node.Nodes[i])
+		}
+	}
+	findAccessors(&root)
+
+	var summarize func(node *XMLNode)
+	summarize = func(node *XMLNode) {
+		if colladaArrays[node.XMLName.Local] {
+			summarizeColladaArray(node, strides[attrValue(node, "id")])
+			return
+		}
+		for i := range node.Nodes {
+			summarize(&node.Nodes[i])
+		}
+	}
+	summarize(&root)
+	return root, nil
+}
+
+// summarizeColladaArray replaces the values of an array element with their
+// count and, for numbers, their minimum and maximum per component
+func summarizeColladaArray(node *XMLNode, stride int) {
+	values := strings.Fields(node.Content)
+	node.Content = ""
+	node.Attrs = append(node.Attrs, xml.Attr{Name: xml.Name{Local: "summary-count"}, Value: strconv.Itoa(len(values))})
+	switch node.XMLName.Local {
+	case "bool_array", "Name_array", "IDREF_array", "SIDREF_array":
+		return
+	}
+	if stride < 1 || stride > 4 {
+		stride = 1
+	}
+	min := make([]float64, stride)
+	max := make([]float64, stride)
+	for i := range min {
+		min[i], max[i] = math.Inf(1), math.Inf(-1)
+	}
+	for i, value := range values {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return // not numeric after all; leave the count only
+		}
+		c := i % stride
+		min[c], max[c] = math.Min(min[c], f), math.Max(max[c], f)
+	}
+	if len(values) == 0 {
+		return
+	}
+	format := func(bounds []float64) string {
+		parts := make([]string, 0, len(bounds))
+		for _, b := range bounds {
+			if math.IsInf(b, 0) {
+				continue // components the array has no values for
+			}
+			parts = append(parts, strconv.FormatFloat(b, 'g', -1, 64))
+		}
+		return strings.Join(parts, " ")
+	}
+	node.Attrs = append(node.Attrs,
+		xml.Attr{Name: xml.Name{Local: "summary-min"}, Value: format(min)},
+		xml.Attr{Name: xml.Name{Local: "summary-max"}, Value: format(max)},
+	)
+}
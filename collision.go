@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// collisionPolicy decides what happens when two copied files map to the same
+// output name during a run: overwrite, rename, or skip
+var collisionPolicy = "rename"
+
+// collisionPolicies lists the accepted --on-collision values
+var collisionPolicies = []string{"overwrite", "rename", "skip"}
+
+// copiedFiles records the output names claimed by copies in this run, so
+// files left over from earlier runs are replaced rather than renamed around
+var copiedFiles = struct {
+	sync.Mutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+// validCollisionPolicy reports whether policy is one of collisionPolicies
+func validCollisionPolicy(policy string) bool {
+	for _, p := range collisionPolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// claimCopyTarget returns the output name a copy to fileName should use, or
+// "" when the policy is skip and another copy already claimed it. Renamed
+// files get a numeric suffix before the extension (image1-2.png).
+func claimCopyTarget(fileName string) string {
+	copiedFiles.Lock()
+	defer copiedFiles.Unlock()
+
+	key := strings.ToLower(filepath.Clean(fileName))
+	if !copiedFiles.names[key] || collisionPolicy == "overwrite" {
+		copiedFiles.names[key] = true
+		return fileName
+	}
+	if collisionPolicy == "skip" {
+		return ""
+	}
+
+	ext := filepath.Ext(fileName)
+	stem := strings.TrimSuffix(fileName, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d%s", stem, n, ext)
+		key := strings.ToLower(filepath.Clean(candidate))
+		if !copiedFiles.names[key] {
+			copiedFiles.names[key] = true
+			return candidate
+		}
+	}
+}
+
+// createCopyTarget claims an output name for fileName and creates it. It
+// returns a nil file when the copy should be skipped.
+func createCopyTarget(fileName string) (*os.File, string, error) {
+	target := claimCopyTarget(fileName)
+	if target == "" {
+		return nil, fileName, nil
+	}
+	f, err := os.Create(longPath(target))
+	if err != nil {
+		return nil, target, wrapFSError("create file", target, err)
+	}
+	return f, target, nil
+}
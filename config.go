@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// xmlgoConfig is the content of a --config file:
+//
+//	{
+//	  "flags": {"format": "parquet,jsonl", "keep-going": true},
+//	  "handlers": {
+//	    ".svg": {"handler": "xml"},
+//	    ".html": {"handler": "html"},
+//	    "application/json": {"handler": "json", "options": {"root": "doc"}},
+//	    ".bak": {"handler": "skip"}
+//	  },
+//	  "contract": {"required_tags": ["Invoice"], "min_rows": 1},
+//	  "hooks": {"after_file": [{"command": "./mark-processed.sh"}]}
+//	}
+//
+// Flags given on the command line win over the file, which wins over
+// --profile. See dataContract for the contract section and runHooks for
+// the hooks.
+type xmlgoConfig struct {
+	Flags    map[string]any         `json:"flags"`
+	Handlers map[string]fileHandler `json:"handlers"`
+	Contract *dataContract          `json:"contract"`
+	Hooks    *runHooks              `json:"hooks"`
+}
+
+// loadConfig reads and validates a config file
+func loadConfig(fileName string) (*xmlgoConfig, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, wrapFSError("read config", fileName, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	var config xmlgoConfig
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", fileName, err)
+	}
+
+	handlers := make(map[string]fileHandler, len(config.Handlers))
+	for key, h := range config.Handlers {
+		handlers[strings.ToLower(key)] = h
+	}
+	if err := validHandlers(handlers); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", fileName, err)
+	}
+	config.Handlers = handlers
+	if config.Contract != nil {
+		if err := config.Contract.validate(); err != nil {
+			return nil, fmt.Errorf("invalid config %s: %v", fileName, err)
+		}
+	}
+	if config.Hooks != nil {
+		if err := config.Hooks.validate(); err != nil {
+			return nil, fmt.Errorf("invalid config %s: %v", fileName, err)
+		}
+	}
+	return &config, nil
+}
+
+// apply sets the config's flags that the command line did not give and
+// installs its handlers, contract and hooks
+func (c *xmlgoConfig) apply(flags *flag.FlagSet, fileName string) error {
+	values := make(map[string]string, len(c.Flags))
+	for name, value := range c.Flags {
+		switch v := value.(type) {
+		case string:
+			values[name] = v
+		case bool, json.Number:
+			values[name] = fmt.Sprint(v)
+		default:
+			return fmt.Errorf("config %s: flag %q must be a string, number or boolean", fileName, name)
+		}
+	}
+	if err := applyFlagDefaults(flags, values, "config "+fileName); err != nil {
+		return err
+	}
+	configuredHandlers = c.Handlers
+	activeContract = c.Contract
+	activeHooks = c.Hooks
+	return nil
+}
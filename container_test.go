@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// TestEntriesCannotLeaveOutputDir converts a container holding an entry
+// named ../../escaped.bin, with and without a cache replay: nothing may be
+// written outside the output directory, and the entry is listed in the
+// files table as skipped.
+func TestEntriesCannotLeaveOutputDir(t *testing.T) {
+	defer func(dir, base string) { cacheDir, pathBase = dir, base }(cacheDir, pathBase)
+	root, input := t.TempDir(), t.TempDir()
+	cacheDir, pathBase = t.TempDir(), input
+	writeTestZip(t, filepath.Join(input, "a.zip"), map[string]string{
+		"a.xml":             "<a/>",
+		"../../escaped.bin": "payload",
+	})
+	cfg := &runConfig{formats: []string{"parquet"}, extensions: []string{".xml"}}
+
+	for _, run := range []string{"converted", "replayed"} {
+		outputDir := filepath.Join(root, run, "deep", "out")
+		if _, err := convert(cfg, input, outputDir); err != nil {
+			t.Fatal(err)
+		}
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && filepath.Base(path) == "escaped.bin" {
+				t.Errorf("%s run wrote %s", run, path)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		columns, rows, err := parquetRows(filepath.Join(outputDir, "files.parquet"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		reasons := make(map[string]string)
+		for _, row := range rows {
+			var path, reason string
+			for i, column := range columns {
+				switch column {
+				case "file_path":
+					path, _ = strconv.Unquote(row[i])
+				case "skip_reason":
+					reason, _ = strconv.Unquote(row[i])
+				}
+			}
+			reasons[path] = reason
+		}
+		if reason, ok := reasons["../../escaped.bin"]; !ok || reason != skipUnsafe {
+			t.Errorf("%s run recorded ../../escaped.bin with skip reason %q, want %q", run, reason, skipUnsafe)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// dataContract is the "contract" section of a --config file, the
+// expectations a conversion's combined output must meet:
+//
+//	"contract": {
+//	  "required_tags": ["Invoice", "Total"],
+//	  "max_null_rate": {"attribute_value": 0.05},
+//	  "min_rows": 1000,
+//	  "max_rows": 50000000,
+//	  "on_violation": "warn"
+//	}
+//
+// Required tags must each name at least one element of the output. A null
+// rate is the share of rows whose OPTIONAL column is null as written to
+// Parquet under --nulls. A violated contract fails the conversion after its
+// outputs and run report are written, or with "on_violation": "warn" is
+// only logged; either way the run report lists the violations.
+type dataContract struct {
+	RequiredTags []string           `json:"required_tags"`
+	MaxNullRate  map[string]float64 `json:"max_null_rate"`
+	MinRows      *int64             `json:"min_rows"`
+	MaxRows      *int64             `json:"max_rows"`
+	OnViolation  string             `json:"on_violation"`
+}
+
+// activeContract is the contract of the --config file, or nil
+var activeContract *dataContract
+
+// validate checks the contract's settings
+func (c *dataContract) validate() error {
+	switch c.OnViolation {
+	case "", "fail", "warn":
+	default:
+		return fmt.Errorf("contract: unknown on_violation %q (expected fail or warn)", c.OnViolation)
+	}
+	for column, rate := range c.MaxNullRate {
+		if !slices.Contains(nullColumns[:], column) {
+			return fmt.Errorf("contract: max_null_rate for unknown column %q (expected one of %s)", column, strings.Join(nullColumns[:], ", "))
+		}
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("contract: max_null_rate for %s must be between 0 and 1", column)
+		}
+	}
+	if c.MinRows != nil && c.MaxRows != nil && *c.MinRows > *c.MaxRows {
+		return fmt.Errorf("contract: min_rows %d is above max_rows %d", *c.MinRows, *c.MaxRows)
+	}
+	return nil
+}
+
+// check returns the ways stats violate the contract, in a stable order
+func (c *dataContract) check(stats *outputStats) []string {
+	var violations []string
+	for _, tag := range c.RequiredTags {
+		if stats.tags[tag] == 0 {
+			violations = append(violations, fmt.Sprintf("required tag %s is absent", tag))
+		}
+	}
+	columns := make([]string, 0, len(c.MaxNullRate))
+	for column := range c.MaxNullRate {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		if stats.rows == 0 {
+			break
+		}
+		if rate := float64(stats.nulls[slices.Index(nullColumns[:], column)]) / float64(stats.rows); rate > c.MaxNullRate[column] {
+			violations = append(violations, fmt.Sprintf("%s is null in %.2f%% of rows, above the %.2f%% allowed", column, rate*100, c.MaxNullRate[column]*100))
+		}
+	}
+	if c.MinRows != nil && stats.rows < *c.MinRows {
+		violations = append(violations, fmt.Sprintf("%d rows written, below min_rows %d", stats.rows, *c.MinRows))
+	}
+	if c.MaxRows != nil && stats.rows > *c.MaxRows {
+		violations = append(violations, fmt.Sprintf("%d rows written, above max_rows %d", stats.rows, *c.MaxRows))
+	}
+	return violations
+}
+
+// checkContract checks the run's output against the contract, recording
+// the violations in the run report. It returns an error when they should
+// fail the conversion.
+func checkContract() error {
+	if activeContract == nil || runOutputStats == nil {
+		return nil
+	}
+	violations := activeContract.check(runOutputStats)
+	report.Violations = violations
+	if len(violations) == 0 {
+		return nil
+	}
+	if activeContract.OnViolation == "warn" {
+		for _, v := range violations {
+			log.Printf("Data contract violated: %s", v)
+		}
+		return nil
+	}
+	return fmt.Errorf("data contract violated: %s", strings.Join(violations, "; "))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// copyAsset copies src into dst, the newly created file target, then reads
+// the written file back and compares its SHA-256 with that of the bytes
+// read, so a short or failed write (a full disk, a quota) fails the copy
+// instead of leaving a truncated asset. size is the expected length, or -1
+// when it is unknown. A failed copy's target is removed.
+func copyAsset(dst *os.File, target string, src io.Reader, size int64) error {
+	err := writeAsset(dst, target, src, size)
+	if closeErr := dst.Close(); err == nil && closeErr != nil {
+		err = wrapFSError("close", target, closeErr)
+	}
+	if err != nil {
+		os.Remove(longPath(target))
+	}
+	return err
+}
+
+// writeAsset hashes and writes src to dst, syncs it and verifies the
+// written bytes
+func writeAsset(dst *os.File, target string, src io.Reader, size int64) error {
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(dst, h), src)
+	if err != nil {
+		return err
+	}
+	if size >= 0 && n != size {
+		return fmt.Errorf("read %d of %d bytes", n, size)
+	}
+	if err := dst.Sync(); err != nil {
+		return wrapFSError("sync", target, err)
+	}
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		return wrapFSError("reread", target, err)
+	}
+	written := sha256.New()
+	m, err := io.Copy(written, dst)
+	if err != nil {
+		return wrapFSError("reread", target, err)
+	}
+	if m != n || !bytes.Equal(written.Sum(nil), h.Sum(nil)) {
+		return fmt.Errorf("%s holds %d bytes that do not match the %d copied", target, m, n)
+	}
+	return nil
+}
+
+// preserveAttributes gives a copied asset its source's permissions and
+// modification time; a zero mode or time is left as created
+func preserveAttributes(target string, mode fs.FileMode, modTime time.Time) error {
+	if perm := mode.Perm(); perm != 0 {
+		if err := os.Chmod(longPath(target), perm); err != nil {
+			return wrapFSError("set permissions of", target, err)
+		}
+	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(longPath(target), modTime, modTime); err != nil {
+			return wrapFSError("set modification time of", target, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+// Package dataset reads the datasets xmlgo writes, for Go programs that
+// consume the combined output without reimplementing its parent/child
+// joins: typed iteration over the rows, reconstruction of a document's
+// element tree, and path lookups within it.
+package dataset
+
+import (
+	"encoding/xml"
+	"fmt"
+	"iter"
+	"path/filepath"
+	"sort"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// Row is one row of the combined output. Columns written as nulls read as
+// their zero value: a root element has ParentNodeID 0, and names written
+// as --tag-ids or --attribute-ids are filled in from the dictionaries.
+type Row struct {
+	NodeID         int64
+	ParentNodeID   int64
+	TagName        string
+	AttributeName  string
+	AttributeValue string
+	IsNode         bool
+	IsRoot         bool
+	TagID          int32
+	AttributeID    int32
+	FilePath       string
+	ContainerPath  string
+	EntryPath      string
+	SelfClosing    bool
+}
+
+// IsText reports whether the row holds the text content of its element
+func (r Row) IsText() bool {
+	return !r.IsNode && r.AttributeName == ""
+}
+
+// isNamespace reports whether the row records its element's namespace,
+// which xmlgo writes as an xmlns:<uri> attribute whose value is the URI
+func (r Row) isNamespace() bool {
+	return !r.IsNode && r.AttributeName == "xmlns:"+r.AttributeValue
+}
+
+// record is the on-disk shape of a combined output row
+type record struct {
+	NodeID         int64   `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ParentNodeID   *int64  `parquet:"name=parent_node_id, type=INT64, convertedtype=INT_64, repetitiontype=OPTIONAL"`
+	TagName        *string `parquet:"name=tag_name, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	AttributeName  *string `parquet:"name=attribute_name, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	AttributeValue *string `parquet:"name=attribute_value, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	IsNode         bool    `parquet:"name=is_node, type=BOOLEAN"`
+	IsRoot         bool    `parquet:"name=is_root, type=BOOLEAN"`
+	TagID          *int32  `parquet:"name=tag_id, type=INT32, convertedtype=INT_32, repetitiontype=OPTIONAL"`
+	AttributeID    *int32  `parquet:"name=attribute_id, type=INT32, convertedtype=INT_32, repetitiontype=OPTIONAL"`
+	FilePath       string  `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ContainerPath  string  `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8"`
+	EntryPath      *string `parquet:"name=entry_path, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	SelfClosing    *bool   `parquet:"name=self_closing, type=BOOLEAN, repetitiontype=OPTIONAL"`
+}
+
+// tagRecord is a row of tags.parquet
+type tagRecord struct {
+	TagID     int32  `parquet:"name=tag_id, type=INT32, convertedtype=INT_32"`
+	TagName   string `parquet:"name=tag_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Namespace string `parquet:"name=namespace, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// attributeRecord is a row of attributes.parquet
+type attributeRecord struct {
+	AttributeID int32  `parquet:"name=attribute_id, type=INT32, convertedtype=INT_32"`
+	Name        string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Namespace   string `parquet:"name=namespace, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// batchSize is how many rows are read from a file at a time
+const batchSize = 4096
+
+// Dataset is an xmlgo output directory opened for reading
+type Dataset struct {
+	files      []string
+	tags       map[int32]xml.Name
+	attributes map[int32]xml.Name
+}
+
+// Open opens the dataset in dir: its combined Parquet output, including
+// rolled parts and --retry-failed additions, and its tag and attribute
+// dictionaries when present
+func Open(dir string) (*Dataset, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "combined*.parquet"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no combined Parquet output in %s", dir)
+	}
+	sort.Strings(files)
+	d := &Dataset{files: files}
+	d.tags, err = readDictionary(dir, "tags*.parquet", func(r tagRecord) (int32, xml.Name) {
+		return r.TagID, xml.Name{Space: r.Namespace, Local: r.TagName}
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.attributes, err = readDictionary(dir, "attributes*.parquet", func(r attributeRecord) (int32, xml.Name) {
+		return r.AttributeID, xml.Name{Space: r.Namespace, Local: r.Name}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Files returns the combined output files of the dataset, in read order
+func (d *Dataset) Files() []string {
+	return d.files
+}
+
+// readDictionary reads the tag or attribute dictionary files matching
+// pattern, which --retry-failed runs add to. Retry runs continue the
+// numbering of the runs before them, so an ID that names two different
+// entries means the dataset cannot be resolved and is an error.
+func readDictionary[T any](dir, pattern string, entry func(T) (int32, xml.Name)) (map[int32]xml.Name, error) {
+	files, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	names := make(map[int32]xml.Name)
+	for _, fileName := range files {
+		err := readFile(fileName, func(rows []T) error {
+			for _, row := range rows {
+				id, name := entry(row)
+				if earlier, ok := names[id]; ok && earlier != name {
+					return fmt.Errorf("%s: ID %d names both %s and %s; the dataset's runs numbered their dictionaries separately", fileName, id, earlier.Local, name.Local)
+				}
+				names[id] = name
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// readFile reads every row of a Parquet file in batches of T
+func readFile[T any](fileName string, batch func([]T) error) error {
+	fr, err := local.NewLocalFileReader(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", fileName, err)
+	}
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, new(T), 1)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", fileName, err)
+	}
+	defer pr.ReadStop()
+
+	for remaining := int(pr.GetNumRows()); remaining > 0; {
+		rows := make([]T, min(batchSize, remaining))
+		if err := pr.Read(&rows); err != nil {
+			return fmt.Errorf("failed to read %s: %v", fileName, err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		if err := batch(rows); err != nil {
+			return err
+		}
+		remaining -= len(rows)
+	}
+	return nil
+}
+
+// Rows iterates over the rows of the dataset in the order they were
+// written, which is document order within each file. Iteration stops at
+// the first read error, which is yielded with a zero Row.
+func (d *Dataset) Rows() iter.Seq2[Row, error] {
+	return func(yield func(Row, error) bool) {
+		stopped := fmt.Errorf("stopped")
+		for _, fileName := range d.files {
+			err := readFile(fileName, func(records []record) error {
+				for i := range records {
+					if !yield(d.row(&records[i]), nil) {
+						return stopped
+					}
+				}
+				return nil
+			})
+			if err == stopped {
+				return
+			}
+			if err != nil {
+				yield(Row{}, err)
+				return
+			}
+		}
+	}
+}
+
+// row converts a record, filling in names from the dictionaries
+func (d *Dataset) row(rec *record) Row {
+	row := Row{
+		NodeID:        rec.NodeID,
+		IsNode:        rec.IsNode,
+		IsRoot:        rec.IsRoot,
+		FilePath:      rec.FilePath,
+		ContainerPath: rec.ContainerPath,
+	}
+	if rec.ParentNodeID != nil {
+		row.ParentNodeID = *rec.ParentNodeID
+	}
+	if rec.TagName != nil {
+		row.TagName = *rec.TagName
+	}
+	if rec.AttributeName != nil {
+		row.AttributeName = *rec.AttributeName
+	}
+	if rec.AttributeValue != nil {
+		row.AttributeValue = *rec.AttributeValue
+	}
+	if rec.TagID != nil {
+		row.TagID = *rec.TagID
+	}
+	if rec.AttributeID != nil {
+		row.AttributeID = *rec.AttributeID
+	}
+	if rec.EntryPath != nil {
+		row.EntryPath = *rec.EntryPath
+	}
+	if rec.SelfClosing != nil {
+		row.SelfClosing = *rec.SelfClosing
+	}
+	if row.IsNode && row.TagName == "" && row.TagID != 0 {
+		row.TagName = d.tags[row.TagID].Local
+	}
+	if !row.IsNode && row.AttributeName == "" && row.AttributeID != 0 {
+		row.AttributeName = d.attributes[row.AttributeID].Local
+	}
+	return row
+}
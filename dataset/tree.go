@@ -0,0 +1,377 @@
+package dataset
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Node is an element of a reconstructed document. SelfClosing is only
+// set in datasets written with --self-closing.
+type Node struct {
+	ID          int64
+	Name        xml.Name
+	Attrs       []xml.Attr
+	Text        string
+	SelfClosing bool
+	Parent      *Node
+	Children    []*Node
+}
+
+// Attr returns the value of the node's attribute with the given local name
+func (n *Node) Attr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// Document is one document of the dataset. ID is the node_id of its root
+// element, which files.parquet records as first_node_id; node IDs are
+// unique across a dataset, so ID identifies the document even where two
+// containers hold entries of the same name.
+type Document struct {
+	ID            int64
+	FilePath      string
+	ContainerPath string
+	EntryPath     string
+}
+
+// Documents lists the documents of the dataset in the order they were written
+func (d *Dataset) Documents() ([]Document, error) {
+	var docs []Document
+	for row, err := range d.Rows() {
+		if err != nil {
+			return nil, err
+		}
+		if row.IsRoot {
+			docs = append(docs, Document{ID: row.NodeID, FilePath: row.FilePath, ContainerPath: row.ContainerPath, EntryPath: row.EntryPath})
+		}
+	}
+	return docs, nil
+}
+
+// Document returns the document recorded under filePath, the file_path
+// column of its rows, inside containerPath. An empty containerPath
+// matches any container. Matching no document or more than one is an
+// error.
+func (d *Dataset) Document(containerPath, filePath string) (Document, error) {
+	docs, err := d.Documents()
+	if err != nil {
+		return Document{}, err
+	}
+	var found []Document
+	for _, doc := range docs {
+		if doc.FilePath == filePath && (containerPath == "" || doc.ContainerPath == containerPath) {
+			found = append(found, doc)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Document{}, fmt.Errorf("no document recorded under file_path %q in container %q", filePath, containerPath)
+	case 1:
+		return found[0], nil
+	}
+	var containers []string
+	for _, doc := range found {
+		containers = append(containers, fmt.Sprintf("%s (ID %d)", doc.ContainerPath, doc.ID))
+	}
+	return Document{}, fmt.Errorf("file_path %q names %d documents, in %s; name the container or use the document ID", filePath, len(found), strings.Join(containers, ", "))
+}
+
+// Tree reconstructs the element tree of the document whose root element
+// has node ID id, returning its root
+func (d *Dataset) Tree(id int64) (*Node, error) {
+	var doc *Document
+	for row, err := range d.Rows() {
+		if err != nil {
+			return nil, err
+		}
+		if row.IsRoot && row.NodeID == id {
+			doc = &Document{ID: id, FilePath: row.FilePath, ContainerPath: row.ContainerPath}
+			break
+		}
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("no document has root node %d", id)
+	}
+
+	// Rows of the document share its container and file path; another
+	// document recorded under both, such as a duplicate input, is read
+	// alongside and left out when the tree is assembled
+	nodes := make(map[int64]*Node)
+	var order []*Node
+	parents := make(map[int64]int64)
+	for row, err := range d.Rows() {
+		if err != nil {
+			return nil, err
+		}
+		if row.FilePath != doc.FilePath || row.ContainerPath != doc.ContainerPath {
+			continue
+		}
+		if row.IsNode {
+			n := &Node{ID: row.NodeID, Name: xml.Name{Local: row.TagName}, SelfClosing: row.SelfClosing}
+			if name, ok := d.tags[row.TagID]; ok && row.TagID != 0 {
+				n.Name = name
+			}
+			nodes[n.ID] = n
+			if !row.IsRoot {
+				order = append(order, n)
+				parents[n.ID] = row.ParentNodeID
+			}
+			continue
+		}
+		n := nodes[row.NodeID]
+		if n == nil {
+			return nil, fmt.Errorf("%s: row for node %d precedes the node", doc.FilePath, row.NodeID)
+		}
+		switch {
+		case row.isNamespace():
+			n.Name.Space = row.AttributeValue
+		case row.IsText():
+			n.Text += row.AttributeValue
+		default:
+			name := xml.Name{Local: row.AttributeName}
+			if a, ok := d.attributes[row.AttributeID]; ok && row.AttributeID != 0 {
+				name = a
+			}
+			n.Attrs = append(n.Attrs, xml.Attr{Name: name, Value: row.AttributeValue})
+		}
+	}
+
+	sort.Slice(order, func(a, b int) bool { return order[a].ID < order[b].ID })
+	for _, n := range order {
+		parent := nodes[parents[n.ID]]
+		if parent == nil {
+			return nil, fmt.Errorf("%s: node %d has no parent %d", doc.FilePath, n.ID, parents[n.ID])
+		}
+		n.Parent = parent
+		parent.Children = append(parent.Children, n)
+	}
+	return nodes[id], nil
+}
+
+// step is one location step of a path: the elements named name, or any
+// element for "*", among the children or, after "//", the descendants of
+// each context node, that satisfy every predicate
+type step struct {
+	descendants bool
+	name        string
+	predicates  []predicate
+}
+
+// predicate filters the elements a step selects: [@name], [@name='value']
+// or the 1-based position [n]
+type predicate struct {
+	attribute string
+	value     *string
+	position  int
+}
+
+// Find returns the elements the XPath-like path selects starting at n, in
+// document order. Paths are "/"-separated element names or "*", with
+// "//" selecting descendants at any depth, ".." the parent, and predicates
+// [@attr], [@attr='value'] and [n]; a leading "/" starts at the root:
+//
+//	root.Find("channel/item[@type='news']/title")
+//	root.Find("//item/link[2]")
+//	root.Find("/rss/*")
+func (n *Node) Find(path string) ([]*Node, error) {
+	absolute := strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//")
+	steps, err := parsePath(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if !absolute {
+		return evaluate([]*Node{n}, steps), nil
+	}
+	root := n
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	if len(steps) == 0 {
+		return []*Node{root}, nil
+	}
+	// The first step of an absolute path names the root itself
+	if first := steps[0]; first.descendants || !first.matches(root) || !first.satisfied(root, 1) {
+		return nil, nil
+	}
+	return evaluate([]*Node{root}, steps[1:]), nil
+}
+
+// FindFirst returns the first element path selects from n, or nil
+func (n *Node) FindFirst(path string) (*Node, error) {
+	found, err := n.Find(path)
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	return found[0], nil
+}
+
+// evaluate applies steps to the context nodes in turn
+func evaluate(context []*Node, steps []step) []*Node {
+	for _, s := range steps {
+		seen := make(map[*Node]bool)
+		var next []*Node
+		for _, c := range context {
+			for _, m := range s.apply(c) {
+				if !seen[m] {
+					seen[m] = true
+					next = append(next, m)
+				}
+			}
+		}
+		sort.Slice(next, func(a, b int) bool { return next[a].ID < next[b].ID })
+		context = next
+	}
+	return context
+}
+
+// apply returns the elements the step selects from one context node
+func (s step) apply(c *Node) []*Node {
+	if s.name == ".." {
+		if c.Parent == nil {
+			return nil
+		}
+		return []*Node{c.Parent}
+	}
+	if s.name == "." {
+		return []*Node{c}
+	}
+	var candidates []*Node
+	if s.descendants {
+		var walk func(*Node)
+		walk = func(p *Node) {
+			for _, child := range p.Children {
+				candidates = append(candidates, child)
+				walk(child)
+			}
+		}
+		walk(c)
+	} else {
+		candidates = c.Children
+	}
+	// Positions count among the matching children of each parent, as in
+	// XPath, where //link[2] is every link that is its parent's second
+	var selected []*Node
+	positions := make(map[*Node]int)
+	for _, m := range candidates {
+		if !s.matches(m) {
+			continue
+		}
+		positions[m.Parent]++
+		if s.satisfied(m, positions[m.Parent]) {
+			selected = append(selected, m)
+		}
+	}
+	return selected
+}
+
+// matches reports whether the element has the step's local name
+func (s step) matches(m *Node) bool {
+	return s.name == "*" || m.Name.Local == s.name
+}
+
+// satisfied reports whether the element, at the given position among the
+// step's matches, satisfies every predicate
+func (s step) satisfied(m *Node, position int) bool {
+	for _, p := range s.predicates {
+		if p.position != 0 {
+			if p.position != position {
+				return false
+			}
+			continue
+		}
+		value, ok := m.Attr(p.attribute)
+		if !ok || (p.value != nil && value != *p.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePath splits a relative path into its steps
+func parsePath(path string) ([]step, error) {
+	var steps []step
+	descendants := false
+	for len(path) > 0 {
+		if strings.HasPrefix(path, "/") {
+			if descendants {
+				return nil, fmt.Errorf("path %q: unexpected %q", path, "/")
+			}
+			descendants = true
+			path = path[1:]
+			continue
+		}
+		end := len(path)
+		depth := 0
+		for i, r := range path {
+			if r == '[' {
+				depth++
+			} else if r == ']' {
+				depth--
+			} else if r == '/' && depth == 0 {
+				end = i
+				break
+			}
+		}
+		s, err := parseStep(path[:end])
+		if err != nil {
+			return nil, err
+		}
+		s.descendants = descendants
+		steps = append(steps, s)
+		descendants = false
+		path = path[end:]
+		if path != "" {
+			path = path[1:]
+			if path == "" {
+				return nil, fmt.Errorf("path ends with %q", "/")
+			}
+		}
+	}
+	if descendants {
+		return nil, fmt.Errorf("path ends with %q", "//")
+	}
+	return steps, nil
+}
+
+// parseStep parses a name followed by predicates
+func parseStep(text string) (step, error) {
+	name, rest, hasPredicates := strings.Cut(text, "[")
+	s := step{name: name}
+	if name == "" {
+		return s, fmt.Errorf("path step %q has no element name", text)
+	}
+	if !hasPredicates {
+		return s, nil
+	}
+	if !strings.HasSuffix(rest, "]") {
+		return s, fmt.Errorf("path step %q: unclosed predicate", text)
+	}
+	for _, raw := range strings.Split(strings.TrimSuffix(rest, "]"), "][") {
+		if !strings.HasPrefix(raw, "@") {
+			position, err := strconv.Atoi(raw)
+			if err != nil || position < 1 {
+				return s, fmt.Errorf("path step %q: predicate [%s] is neither [@attr], [@attr='value'] nor a position", text, raw)
+			}
+			s.predicates = append(s.predicates, predicate{position: position})
+			continue
+		}
+		attribute, value, hasValue := strings.Cut(raw[1:], "=")
+		p := predicate{attribute: attribute}
+		if hasValue {
+			if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+				return s, fmt.Errorf("path step %q: value of [%s] must be quoted", text, raw)
+			}
+			value = value[1 : len(value)-1]
+			p.value = &value
+		}
+		s.predicates = append(s.predicates, p)
+	}
+	return s, nil
+}
@@ -0,0 +1,164 @@
+package dataset
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// testdata/twobooks is xmlgo's output for two copies of the same workbook,
+// a.xlsx and b.xlsx, converted with --deterministic --path-base at the
+// directory holding them
+
+// openTwoBooks opens the fixture dataset
+func openTwoBooks(t *testing.T) *Dataset {
+	t.Helper()
+	d, err := Open("testdata/twobooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// sheet returns the root of a.xlsx's worksheet
+func sheet(t *testing.T, d *Dataset) *Node {
+	t.Helper()
+	doc, err := d.Document("a.xlsx", "xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := d.Tree(doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestFind(t *testing.T) {
+	root := sheet(t, openTwoBooks(t))
+	if root.Name.Local != "worksheet" || root.Name.Space != "http://schemas.openxmlformats.org/spreadsheetml/2006/main" {
+		t.Fatalf("root is %v, want the namespaced worksheet", root.Name)
+	}
+
+	// Each case lists the r attribute, or else the text, of the matches
+	for path, want := range map[string][]string{
+		"sheetData/row":              {"1", "2", "3"},
+		"sheetData/row[2]":           {"2"},
+		"sheetData/*[@r='3']":        {"3"},
+		"//c[@t='s']":                {"A1", "B1", "A2"},
+		"//c[@t]":                    {"A1", "B1", "A2", "A3"},
+		"//c[2]":                     {"B1", "B2", "B3"},
+		"//c[5]":                     nil,
+		"//row[@r='3']/c[2]/f":       {"SUM(B2:B2)"},
+		"//f/..":                     {"B3"},
+		"//is/t":                     {"Total"},
+		"/worksheet/*":               {""},
+		"/sheetData":                 nil,
+		"//row[@r='2']/./c[@t='s']":  {"A2"},
+		"sheetData/row[@r][1]/c/v":   {"0", "1"},
+		"sheetData/row[@r=\"1\"]/c":  {"A1", "B1"},
+		"//missing":                  nil,
+		"sheetData/row[@missing]/c":  nil,
+		"sheetData/row/c[@r='B2']/v": {"12.5"},
+	} {
+		found, err := root.Find(path)
+		if err != nil {
+			t.Errorf("Find(%q): %v", path, err)
+			continue
+		}
+		var got []string
+		for _, n := range found {
+			label, ok := n.Attr("r")
+			if !ok {
+				label = n.Text
+			}
+			got = append(got, label)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") || len(got) != len(want) {
+			t.Errorf("Find(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	// Absolute paths start at the root from any node
+	row, err := root.FindFirst("sheetData/row[3]")
+	if err != nil || row == nil {
+		t.Fatalf("FindFirst(sheetData/row[3]) = %v, %v", row, err)
+	}
+	if n, err := row.FindFirst("/worksheet/sheetData/row"); err != nil || n == nil || n.ID >= row.ID {
+		t.Errorf("absolute FindFirst from row 3 = %v, %v; want the first row", n, err)
+	}
+	if n, err := root.FindFirst("//missing"); n != nil || err != nil {
+		t.Errorf("FindFirst(//missing) = %v, %v; want nil", n, err)
+	}
+}
+
+func TestDuplicateEntryNames(t *testing.T) {
+	d := openTwoBooks(t)
+	docs, err := d.Documents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 12 {
+		t.Fatalf("dataset lists %d documents, want 6 in each workbook", len(docs))
+	}
+
+	if _, err := d.Document("", "xl/workbook.xml"); err == nil || !strings.Contains(err.Error(), "2 documents") {
+		t.Errorf("Document(\"\", xl/workbook.xml) = %v, want an error naming 2 documents", err)
+	}
+	if _, err := d.Document("c.xlsx", "xl/workbook.xml"); err == nil {
+		t.Error("Document found xl/workbook.xml in a container that is not in the dataset")
+	}
+	if _, err := d.Tree(2); err == nil {
+		t.Error("Tree(2) succeeded on a node that is not a document root")
+	}
+
+	ids := make(map[int64]bool)
+	for _, container := range []string{"a.xlsx", "b.xlsx"} {
+		doc, err := d.Document(container, "xl/workbook.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.ContainerPath != container || doc.EntryPath != "xl/workbook.xml" {
+			t.Errorf("Document(%s) = %+v", container, doc)
+		}
+		ids[doc.ID] = true
+		root, err := d.Tree(doc.ID)
+		if err != nil {
+			t.Fatalf("Tree of %s's workbook: %v", container, err)
+		}
+		sheets, err := root.Find("sheets/sheet[@name='Budget']")
+		if err != nil || len(sheets) != 1 {
+			t.Errorf("%s: Find(sheets/sheet[@name='Budget']) = %v, %v", container, sheets, err)
+		}
+		if names, _ := root.Find("definedNames/definedName"); len(names) != 1 || names[0].Text != "Budget!$B$3" {
+			t.Errorf("%s: defined names %v", container, names)
+		}
+	}
+	if len(ids) != 2 {
+		t.Errorf("both workbooks have document ID %v", ids)
+	}
+}
+
+func TestFindRejectsMalformedPaths(t *testing.T) {
+	root := &Node{Name: xml.Name{Local: "root"}}
+	for _, path := range []string{
+		"a/",
+		"a//",
+		"a///b",
+		"//",
+		"[@x]",
+		"a/[1]",
+		"a[",
+		"a[@x",
+		"a[0]",
+		"a[-1]",
+		"a[x]",
+		"a[@x=v]",
+		"a[@x='v\"]",
+		"a[@x=']",
+	} {
+		if _, err := root.Find(path); err == nil {
+			t.Errorf("Find(%q) accepted a malformed path", path)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "strings"
+
+// XMLDeclaration is a document's <?xml ...?> prolog as written, recorded in
+// the xml_version, xml_encoding and xml_standalone columns of files.parquet
+// so encoding audits and reconstruction know how the original was declared.
+// Pseudo-attributes the document leaves out are empty.
+type XMLDeclaration struct {
+	Version    string
+	Encoding   string
+	Standalone string
+}
+
+// parseXMLDeclaration reads the pseudo-attributes of an XML declaration
+func parseXMLDeclaration(inst string) *XMLDeclaration {
+	d := &XMLDeclaration{}
+	for inst = strings.TrimSpace(inst); inst != ""; inst = strings.TrimSpace(inst) {
+		name, rest, ok := strings.Cut(inst, "=")
+		rest = strings.TrimSpace(rest)
+		if !ok || rest == "" || (rest[0] != '"' && rest[0] != '\'') {
+			break
+		}
+		end := strings.IndexByte(rest[1:], rest[0])
+		if end < 0 {
+			break
+		}
+		value := rest[1 : end+1]
+		switch strings.TrimSpace(name) {
+		case "version":
+			d.Version = value
+		case "encoding":
+			d.Encoding = value
+		case "standalone":
+			d.Standalone = value
+		}
+		inst = rest[end+2:]
+	}
+	return d
+}
+
+// setDeclaration records a document's XML declaration in its files table
+// entry; documents without one leave the columns null
+func (row *FileRow) setDeclaration(d *XMLDeclaration) {
+	if d == nil {
+		return
+	}
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+	row.XMLVersion = optional(d.Version)
+	row.XMLEncoding = optional(d.Encoding)
+	switch d.Standalone {
+	case "yes", "no":
+		standalone := d.Standalone == "yes"
+		row.XMLStandalone = &standalone
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import "runtime"
+
+// deterministic makes identical inputs convert to byte-identical outputs
+// (--deterministic): Parquet files are marshalled by a single goroutine so
+// column dictionaries fill in row order, and the timings and memory figures
+// of run_report.json and files.parquet are written as zero
+var deterministic bool
+
+// parquetParallelism is the most goroutines parquet-go marshals a row group
+// with, one per CPU up to maxParquetParallelism. Concurrent marshalling adds
+// dictionary values in whatever order the goroutines get there, which
+// changes the bytes of the file.
+func parquetParallelism() int64 {
+	if deterministic {
+		return 1
+	}
+	return int64(min(runtime.GOMAXPROCS(0), maxParquetParallelism))
+}
+
+// maxParquetParallelism bounds the marshalling goroutines of one writer
+const maxParquetParallelism = 8
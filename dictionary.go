@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// TagRow is one entry of the tags.parquet dictionary
+type TagRow struct {
+	TagID     int32  `parquet:"name=tag_id, type=INT32, convertedtype=INT_32"`
+	TagName   string `parquet:"name=tag_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Namespace string `parquet:"name=namespace, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// AttributeRow is one entry of the attributes.parquet dictionary
+type AttributeRow struct {
+	AttributeID int32  `parquet:"name=attribute_id, type=INT32, convertedtype=INT_32"`
+	Name        string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Namespace   string `parquet:"name=namespace, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// dictionaryKey identifies a name within its namespace
+type dictionaryKey struct {
+	name      string
+	namespace string
+}
+
+// Dictionary assigns small integer IDs to names in first-seen order and
+// writes each new entry to its sidecar table. Documents are written in
+// order, so IDs are stable for a given input regardless of --workers. A
+// --retry-failed run continues the dictionaries of the runs before it, so
+// an ID names the same tag or attribute throughout the dataset.
+type Dictionary struct {
+	table  *ParquetTable
+	ids    map[dictionaryKey]int32
+	next   int32
+	newRow func(id int32, name, namespace string) interface{}
+}
+
+// tagDictionary is the optional tags.parquet sidecar enabled by --tag-dictionary
+var tagDictionary *Dictionary
+
+// tagIDsOnly writes tag_id instead of tag_name in the main table (--tag-ids)
+var tagIDsOnly bool
+
+// attributeDictionary is the optional attributes.parquet sidecar enabled by --attribute-dictionary
+var attributeDictionary *Dictionary
+
+// attributeIDsOnly writes attribute_id instead of attribute_name in the main table (--attribute-ids)
+var attributeIDsOnly bool
+
+// newDictionary creates a dictionary sidecar at fileName with obj's schema
+func newDictionary(fileName string, obj interface{}, newRow func(id int32, name, namespace string) interface{}) (*Dictionary, error) {
+	table, err := NewParquetTable(fileName, obj)
+	if err != nil {
+		return nil, err
+	}
+	return &Dictionary{table: table, ids: make(map[dictionaryKey]int32), next: 1, newRow: newRow}, nil
+}
+
+// NewTagDictionary creates the tag dictionary sidecar at fileName
+func NewTagDictionary(fileName string) (*Dictionary, error) {
+	d, err := newDictionary(fileName, new(TagRow), func(id int32, name, namespace string) interface{} {
+		return TagRow{TagID: id, TagName: name, Namespace: namespace}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return seedDictionary(d, fileName, "tags*.parquet", func(row TagRow) (int32, dictionaryKey) {
+		return row.TagID, dictionaryKey{name: row.TagName, namespace: row.Namespace}
+	})
+}
+
+// NewAttributeDictionary creates the attribute dictionary sidecar at fileName
+func NewAttributeDictionary(fileName string) (*Dictionary, error) {
+	d, err := newDictionary(fileName, new(AttributeRow), func(id int32, name, namespace string) interface{} {
+		return AttributeRow{AttributeID: id, Name: name, Namespace: namespace}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return seedDictionary(d, fileName, "attributes*.parquet", func(row AttributeRow) (int32, dictionaryKey) {
+		return row.AttributeID, dictionaryKey{name: row.Name, namespace: row.Namespace}
+	})
+}
+
+// seedDictionary loads into d, in a --retry-failed run, the entries of the
+// dictionary files matching pattern that earlier runs wrote next to
+// fileName. Names they hold keep their IDs and are not written again; new
+// names are numbered after the highest ID in use.
+func seedDictionary[T any](d *Dictionary, fileName, pattern string, entry func(T) (int32, dictionaryKey)) (*Dictionary, error) {
+	if runSuffix == "" {
+		return d, nil
+	}
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(fileName), pattern))
+	if err != nil {
+		d.Close()
+		return nil, fmt.Errorf("failed to list dictionaries for %s: %v", fileName, err)
+	}
+	for _, earlier := range files {
+		if earlier == fileName {
+			continue
+		}
+		rows, err := readTableRows[T](earlier)
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		for _, row := range rows {
+			id, key := entry(row)
+			if _, ok := d.ids[key]; !ok {
+				d.ids[key] = id
+			}
+			d.next = max(d.next, id+1)
+		}
+	}
+	return d, nil
+}
+
+// readTableRows reads every row of the sidecar table fileName
+func readTableRows[T any](fileName string) ([]T, error) {
+	fr, err := local.NewLocalFileReader(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", fileName, err)
+	}
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, new(T), 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", fileName, err)
+	}
+	defer pr.ReadStop()
+	rows := make([]T, pr.GetNumRows())
+	if err := pr.Read(&rows); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", fileName, err)
+	}
+	return rows, nil
+}
+
+// id returns the ID of name in namespace, adding it to the table on first
+// use. A nil dictionary returns 0, which never names an entry.
+func (d *Dictionary) id(name, namespace string) (int32, error) {
+	if d == nil {
+		return 0, nil
+	}
+	key := dictionaryKey{name: name, namespace: namespace}
+	if id, ok := d.ids[key]; ok {
+		return id, nil
+	}
+	id := d.next
+	if err := d.table.Write(d.newRow(id, name, namespace)); err != nil {
+		return 0, err
+	}
+	d.ids[key] = id
+	d.next++
+	return id, nil
+}
+
+// Close finalizes the sidecar table
+func (d *Dictionary) Close() error {
+	return d.table.Close()
+}
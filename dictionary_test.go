@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"xmlgo/dataset"
+)
+
+// TestRetryContinuesDictionaries retries a failed document with --tag-ids:
+// the retry's tags.retry-1.parquet must number its tags after those of
+// tags.parquet, or the original rows resolve to the retry's names.
+func TestRetryContinuesDictionaries(t *testing.T) {
+	defer func(ids, keep bool, base string) { tagIDsOnly, keepGoing, pathBase = ids, keep, base }(tagIDsOnly, keepGoing, pathBase)
+	input, outputDir := t.TempDir(), t.TempDir()
+	tagIDsOnly, keepGoing, pathBase = true, true, input
+
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(input, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("first.xml", "<alpha><beta/></alpha>")
+	write("second.xml", "<gamma><delta>")
+	cfg := &runConfig{formats: []string{"parquet"}, extensions: []string{".xml"}}
+	if _, err := convert(cfg, input, outputDir); err != nil {
+		t.Fatal(err)
+	}
+
+	write("second.xml", "<gamma><delta/></gamma>")
+	retry := &runConfig{formats: cfg.formats, extensions: cfg.extensions, retryManifest: filepath.Join(outputDir, "run_report.json")}
+	if _, err := convert(retry, input, outputDir); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := dataset.Open(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string][2]string{"first.xml": {"alpha", "beta"}, "second.xml": {"gamma", "delta"}} {
+		doc, err := d.Document("", file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, err := d.Tree(doc.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root.Name.Local != want[0] || len(root.Children) != 1 || root.Children[0].Name.Local != want[1] {
+			t.Errorf("%s reads back as %s with children %v, want %s/%s", file, root.Name.Local, root.Children, want[0], want[1])
+		}
+	}
+}
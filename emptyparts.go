@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// errEmptyDocument is returned by decodeXMLDocument for zero-byte and
+// whitespace-only input, which contains no root element
+var errEmptyDocument = errors.New("document is empty")
+
+// emptyPartPolicy decides how empty XML documents are handled: error, skip,
+// warn, or record
+var emptyPartPolicy = "warn"
+
+// emptyPartPolicies lists the accepted --empty-parts values
+var emptyPartPolicies = []string{"error", "skip", "warn", "record"}
+
+// validEmptyPartPolicy reports whether policy is one of emptyPartPolicies
+func validEmptyPartPolicy(policy string) bool {
+	for _, p := range emptyPartPolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// handleEmptyDocument applies emptyPartPolicy to an empty document. Skipped
+// and recorded documents still count as done for checkpointing. Recorded
+// ones get a zero-row entry in the files table, skipped ones an entry with
+// skip reason "empty".
+func handleEmptyDocument(src docSource, size int64, ids *idBlock, elapsed time.Duration, rowWriter RowWriter) error {
+	relativePath := src.path
+	switch emptyPartPolicy {
+	case "error":
+		return errEmptyDocument
+	case "skip", "warn":
+		if err := recordSkip(relativePath, size, skipEmpty); err != nil {
+			return err
+		}
+		if emptyPartPolicy == "warn" {
+			log.Printf("Skipping empty XML document %s", relativePath)
+		}
+	case "record":
+		report.recordFile(0)
+		if filesTable != nil {
+			if err := recordFileRow(newFileRow(relativePath, size, 0, ids, elapsed)); err != nil {
+				return err
+			}
+		}
+	}
+	return checkpoints.documentDone(src.container, src.entry, rowWriter)
+}
@@ -0,0 +1,85 @@
+package main
+
+import "log"
+
+// logEntries logs what became of every ZIP entry: parsed, copied to the
+// output directory as an asset, skipped, or failed (--log-entries)
+var logEntries bool
+
+// Entry dispositions
+const (
+	entryParsed  = "parsed"
+	entryCopied  = "copied"
+	entrySkipped = "skipped"
+	entryFailed  = "failed"
+)
+
+// EntryCounts tallies the dispositions of the run's ZIP entries, so a run
+// report shows whether anything expected to be parsed was copied as an
+// asset instead. SkipReasons breaks Skipped down by files table skip
+// reason.
+type EntryCounts struct {
+	Parsed      int64            `json:"parsed"`
+	Copied      int64            `json:"copied"`
+	Skipped     int64            `json:"skipped"`
+	Failed      int64            `json:"failed"`
+	SkipReasons map[string]int64 `json:"skip_reasons,omitempty"`
+}
+
+// writtenEntries lists the entries of the current input parsed, copied or
+// skipped so far, so a run stopped inside a container can tell a retry
+// which entries to leave alone. Failed entries are retried and not listed.
+var writtenEntries []string
+
+// recordEntry counts the entry of container with its disposition, logging
+// it with --log-entries. reason is the skip reason of skipped entries.
+func recordEntry(container, entry, disposition, reason string) {
+	if disposition != entryFailed {
+		writtenEntries = append(writtenEntries, entry)
+	}
+	if report.Entries == nil {
+		report.Entries = &EntryCounts{}
+	}
+	counts := report.Entries
+	switch disposition {
+	case entryParsed:
+		counts.Parsed++
+	case entryCopied:
+		counts.Copied++
+	case entryFailed:
+		counts.Failed++
+	case entrySkipped:
+		counts.Skipped++
+		if counts.SkipReasons == nil {
+			counts.SkipReasons = make(map[string]int64)
+		}
+		counts.SkipReasons[reason]++
+	}
+	if !logEntries {
+		return
+	}
+	if disposition == entrySkipped {
+		log.Printf("%s: %s skipped (%s)", container, entry, reason)
+	} else {
+		log.Printf("%s: %s %s", container, entry, disposition)
+	}
+}
+
+// entrySkipDisposition classifies an entry that was not parsed: entries with
+// skip reason "extension" were copied, all others skipped
+func entrySkipDisposition(reason string) string {
+	if reason == skipExtension {
+		return entryCopied
+	}
+	return entrySkipped
+}
+
+// recordEmptyEntry counts an empty XML entry, which --empty-parts=record
+// keeps as a parsed document and the other policies skip
+func recordEmptyEntry(container, entry string) {
+	if emptyPartPolicy == "record" {
+		recordEntry(container, entry, entryParsed, "")
+	} else {
+		recordEntry(container, entry, entrySkipped, skipEmpty)
+	}
+}
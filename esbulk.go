@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// esIndexName is the target index written into every bulk action line
+var esIndexName = "xmlgo"
+
+// esBulkAction is the action line preceding each document in the bulk API format
+type esBulkAction struct {
+	Index struct {
+		Index string `json:"_index"`
+		ID    string `json:"_id"`
+	} `json:"index"`
+}
+
+// esDocument is one element rendered as a search document
+type esDocument struct {
+	NodeID        int64             `json:"node_id"`
+	ParentNodeID  int64             `json:"parent_node_id,omitempty"`
+	Tag           string            `json:"tag"`
+	Namespace     string            `json:"namespace,omitempty"`
+	Path          string            `json:"path"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	Text          string            `json:"text,omitempty"`
+	FilePath      string            `json:"file_path"`
+	ContainerPath string            `json:"container_path"`
+	EntryPath     string            `json:"entry_path,omitempty"`
+	SelfClosing   bool              `json:"self_closing,omitempty"`
+}
+
+// ESBulkWriter folds the row stream back into one document per element and
+// writes them as Elasticsearch/OpenSearch bulk-API NDJSON. Rows for an
+// element always follow its node row, so a document is complete as soon as
+// the next node row arrives.
+type ESBulkWriter struct {
+	file    io.WriteCloser
+	buf     *bufio.Writer
+	index   string
+	current *esDocument
+	paths   map[int64]string
+	docPath string
+}
+
+// NewESBulkWriter creates the NDJSON file for fileName
+func NewESBulkWriter(fileName string, index string) (*ESBulkWriter, error) {
+	file, err := openOutputStream(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bulk file %s: %v", fileName, err)
+	}
+	return &ESBulkWriter{
+		file:  file,
+		buf:   bufio.NewWriter(file),
+		index: index,
+		paths: make(map[int64]string),
+	}, nil
+}
+
+// Write accumulates a row into the current element document
+func (w *ESBulkWriter) Write(row ParquetRow) error {
+	if row.IsNode {
+		if err := w.flush(); err != nil {
+			return err
+		}
+
+		// Node paths are only meaningful within a document
+		if row.FilePath != w.docPath {
+			w.paths = make(map[int64]string)
+			w.docPath = row.FilePath
+		}
+
+		path := w.paths[row.ParentNodeID] + "/" + row.TagName
+		w.paths[row.NodeID] = path
+		w.current = &esDocument{
+			NodeID:        row.NodeID,
+			ParentNodeID:  row.ParentNodeID,
+			Tag:           row.TagName,
+			Path:          path,
+			FilePath:      row.FilePath,
+			ContainerPath: row.ContainerPath,
+			EntryPath:     row.EntryPath,
+			SelfClosing:   row.SelfClosing,
+		}
+		return nil
+	}
+
+	if w.current == nil || w.current.NodeID != row.NodeID {
+		return fmt.Errorf("attribute row for node %d arrived without its element", row.NodeID)
+	}
+
+	switch {
+	case row.AttributeName == "":
+		w.current.Text = row.AttributeValue
+	case strings.HasPrefix(row.AttributeName, "xmlns:") && w.current.Namespace == "":
+		w.current.Namespace = row.AttributeValue
+	default:
+		if w.current.Attributes == nil {
+			w.current.Attributes = make(map[string]string)
+		}
+		w.current.Attributes[row.AttributeName] = row.AttributeValue
+	}
+	return nil
+}
+
+// WriteBatch accumulates rows into element documents
+func (w *ESBulkWriter) WriteBatch(rows []ParquetRow) error {
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush writes the pending element document, if any
+func (w *ESBulkWriter) flush() error {
+	if w.current == nil {
+		return nil
+	}
+	doc := w.current
+	w.current = nil
+
+	var action esBulkAction
+	action.Index.Index = w.index
+	action.Index.ID = fmt.Sprintf("%s#%d", doc.FilePath, doc.NodeID)
+
+	enc := json.NewEncoder(w.buf)
+	if err := enc.Encode(action); err != nil {
+		return fmt.Errorf("failed to write bulk action: %v", err)
+	}
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write bulk document: %v", err)
+	}
+	return nil
+}
+
+// WriteStop writes the last document and closes the file
+func (w *ESBulkWriter) WriteStop() error {
+	if err := w.flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to flush bulk file: %v", err)
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// estimateMode converts a sample of the inputs into a scratch directory
+// and predicts the rows, output size and runtime of the full run instead of
+// running it (--estimate)
+var estimateMode bool
+
+// estimateSample is how many inputs --estimate converts (--estimate-sample)
+var estimateSample = 20
+
+// runEstimate converts evenly spaced inputs, counting --estimate-sample of
+// them, and scales the results by the share of input bytes they make up
+func runEstimate(cfg *runConfig, input string) error {
+	inputs, _, err := runInputs(input, "")
+	if err != nil {
+		return fmt.Errorf("error reading input: %v", err)
+	}
+	var totalBytes int64
+	sizes := make([]int64, len(inputs))
+	for i, path := range inputs {
+		if info, err := os.Stat(path); err == nil {
+			sizes[i] = info.Size()
+			totalBytes += info.Size()
+		}
+	}
+	if len(inputs) == 0 || totalBytes == 0 {
+		return fmt.Errorf("no input to estimate in %s", input)
+	}
+
+	sampleCfg := *cfg
+	sampleCfg.only = make(map[string]bool)
+	var sampleBytes int64
+	count := min(estimateSample, len(inputs))
+	for i := 0; i < count; i++ {
+		n := i * len(inputs) / count
+		sampleCfg.only[inputs[n]] = true
+		sampleBytes += sizes[n]
+	}
+	if sampleBytes == 0 {
+		return fmt.Errorf("the sampled inputs of %s are empty", input)
+	}
+
+	scratch, err := os.MkdirTemp("", "xmlgo-estimate-")
+	if err != nil {
+		return wrapFSError("create scratch directory", os.TempDir(), err)
+	}
+	defer os.RemoveAll(scratch)
+
+	// The sample is not the run: its failures, contract and anomalies are
+	// not acted on
+	savedContract, savedCompare, savedQuarantine, savedKeepGoing := activeContract, compareReport, quarantineDir, keepGoing
+	activeContract, compareReport, quarantineDir, keepGoing = nil, "", "", true
+	defer func() {
+		activeContract, compareReport, quarantineDir, keepGoing = savedContract, savedCompare, savedQuarantine, savedKeepGoing
+	}()
+	if _, err := convert(&sampleCfg, input, scratch); err != nil {
+		return fmt.Errorf("failed to convert the sample: %v", err)
+	}
+
+	scale := float64(totalBytes) / float64(sampleBytes)
+	duration := time.Duration(float64(report.DurationMs)*scale) * time.Millisecond
+	fmt.Printf("Sampled %d of %d inputs (%.1f MiB of %.1f MiB) in %dms\n",
+		count, len(inputs), mebibytes(uint64(sampleBytes)), mebibytes(uint64(totalBytes)), report.DurationMs)
+	if len(report.Failures) > 0 {
+		fmt.Printf("%d sampled documents failed and are not counted\n", len(report.Failures))
+	}
+	fmt.Printf("Estimated rows:    %d\n", int64(float64(report.Rows)*scale))
+	fmt.Printf("Estimated runtime: %s\n", duration.Round(10*time.Millisecond))
+
+	// Outputs are listed largest first, the way storage is budgeted; the run
+	// report and schema do not grow with the input
+	type estimatedOutput struct {
+		name  string
+		bytes int64
+	}
+	var outputs []estimatedOutput
+	var outputBytes int64
+	for _, name := range listOutputs(scratch) {
+		if ext := filepath.Ext(name); ext == ".json" || ext == ".avsc" {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(scratch, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+		bytes := int64(float64(info.Size()) * scale)
+		outputs = append(outputs, estimatedOutput{name, bytes})
+		outputBytes += bytes
+	}
+	sort.SliceStable(outputs, func(i, j int) bool { return outputs[i].bytes > outputs[j].bytes })
+	fmt.Printf("Estimated output:  %.1f MiB\n", mebibytes(uint64(outputBytes)))
+	for _, output := range outputs {
+		fmt.Printf("  %-32s %10.1f MiB\n", output.name, mebibytes(uint64(output.bytes)))
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Document is a decoded document handed to the extractors after its generic
+// rows were written. Node IDs are assigned in document order starting at
+// FirstNodeID, so extractor rows can be joined back to combined.parquet.
+// ContainerPath and EntryPath are the provenance columns of its rows.
+type Document struct {
+	FilePath      string
+	ContainerPath string
+	EntryPath     string
+	Root          *XMLNode
+	FirstNodeID   int64
+	parts         *containerParts
+}
+
+// containerParts decodes the entries of the container a document is part
+// of, for extractors that join several parts (a worksheet and its styles)
+type containerParts struct {
+	files   map[string]*zip.File
+	decoded map[string]*XMLNode
+}
+
+// newContainerParts indexes the entries of a container by name
+func newContainerParts(files []*zip.File) *containerParts {
+	parts := &containerParts{files: make(map[string]*zip.File), decoded: make(map[string]*XMLNode)}
+	for _, f := range files {
+		parts.files[f.Name] = f
+	}
+	return parts
+}
+
+// part decodes the container entry name as XML, caching the tree for the
+// other documents of the container. It returns nil when the document is not
+// a container entry or the container has no such entry.
+func (doc *Document) part(name string) (*XMLNode, error) {
+	if doc.parts == nil {
+		return nil, nil
+	}
+	if root, ok := doc.parts.decoded[name]; ok {
+		return root, nil
+	}
+	f, ok := doc.parts.files[name]
+	if !ok {
+		return nil, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s in ZIP: %v", name, err)
+	}
+	defer rc.Close()
+	root, err := decodeXML(rc, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", name, err)
+	}
+	doc.parts.decoded[name] = &root
+	return &root, nil
+}
+
+// partFile returns the raw container entry name for reading binary parts,
+// or nil when the document is not a container entry or there is no such
+// entry
+func (doc *Document) partFile(name string) *zip.File {
+	if doc.parts == nil {
+		return nil
+	}
+	return doc.parts.files[name]
+}
+
+// name is the slash-separated name of doc inside its container or on disk
+func (doc *Document) name() string {
+	if doc.EntryPath != "" {
+		return doc.EntryPath
+	}
+	return strings.ReplaceAll(doc.FilePath, "\\", "/")
+}
+
+// walk calls fn for every element of doc in document order with its node ID
+// and the element it is nested in (nil for the root)
+func (doc *Document) walk(fn func(node, parent *XMLNode, nodeID int64)) {
+	next := doc.FirstNodeID
+	var visit func(node, parent *XMLNode)
+	visit = func(node, parent *XMLNode) {
+		fn(node, parent, next)
+		next++
+		for i := range node.Nodes {
+			visit(&node.Nodes[i], node)
+		}
+	}
+	visit(doc.Root, nil)
+}
+
+// attrValue returns the value of the attribute of node named local, or ""
+// when node is nil or has no such attribute
+func attrValue(node *XMLNode, local string) string {
+	if node == nil {
+		return ""
+	}
+	for _, a := range node.Attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Extractor turns the documents of one format into typed tables written
+// next to combined.parquet, so format-specific modes do not have to live in
+// the generic flattener
+type Extractor interface {
+	// Tables maps the names of the extractor's tables to a pointer to
+	// their row struct, which carries the parquet tags of the schema.
+	// Table names are prefixed with the format (junit_cases) and written
+	// as <table>.parquet.
+	Tables() map[string]interface{}
+	// Detect reports whether doc is a document the extractor handles
+	Detect(doc *Document) bool
+	// Extract writes the rows of a detected document with
+	// rows(table, row)
+	Extract(doc *Document, rows func(table string, row interface{}) error) error
+}
+
+// StreamExtractor is an Extractor for formats whose documents are too
+// large to decode into a node tree. Top-level inputs it streams are read
+// straight into its tables instead of the combined output.
+type StreamExtractor interface {
+	Extractor
+	// Streams reports whether the top-level input fileName is streamed
+	Streams(fileName string) bool
+	// Stream writes the rows of the document read from r, whose
+	// provenance doc carries without a Root
+	Stream(r io.Reader, doc *Document, rows func(table string, row interface{}) error) error
+}
+
+// extractors maps --extract names to extractors. Each format registers
+// itself from its own file (see junit.go).
+var extractors = map[string]Extractor{}
+
+// extractorNames lists the registered extractors in sorted order
+func extractorNames() []string {
+	names := make([]string, 0, len(extractors))
+	for name := range extractors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseExtractors splits the value of --extract, where all enables every
+// registered extractor
+func parseExtractors(value string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "":
+			continue
+		case name == "all":
+			return extractorNames(), nil
+		case extractors[name] == nil:
+			return nil, fmt.Errorf("unknown extractor %q (available: %s)", name, strings.Join(extractorNames(), ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// activeExtractor is an enabled extractor with its open tables
+type activeExtractor struct {
+	name      string
+	extractor Extractor
+	tables    map[string]*ParquetTable
+}
+
+// activeExtractors are the extractors of the running conversion
+var activeExtractors []*activeExtractor
+
+// openExtractors creates the tables of the named extractors in outputDir
+func openExtractors(names []string, outputDir string) error {
+	owners := make(map[string]string)
+	for _, name := range names {
+		active := &activeExtractor{name: name, extractor: extractors[name], tables: make(map[string]*ParquetTable)}
+		activeExtractors = append(activeExtractors, active)
+		for table, obj := range active.extractor.Tables() {
+			if owner, ok := owners[table]; ok {
+				return fmt.Errorf("extractors %s and %s both write table %s", owner, name, table)
+			}
+			owners[table] = name
+			fileName := filepath.Join(outputDir, withRunSuffix(table+".parquet"))
+			t, err := NewParquetTable(fileName, obj)
+			if err != nil {
+				return fmt.Errorf("failed to create %s table %s: %v", name, table, err)
+			}
+			active.tables[table] = t
+		}
+	}
+	return nil
+}
+
+// write writes a row to one of the extractor's tables
+func (active *activeExtractor) write(table string, row interface{}) error {
+	t, ok := active.tables[table]
+	if !ok {
+		return fmt.Errorf("extractor %s has no table %q", active.name, table)
+	}
+	return t.Write(row)
+}
+
+// runExtractors passes a written document to the extractors that detect it
+func runExtractors(doc *Document) error {
+	for _, active := range activeExtractors {
+		if !active.extractor.Detect(doc) {
+			continue
+		}
+		if err := active.extractor.Extract(doc, active.write); err != nil {
+			return fmt.Errorf("%s extractor failed on %s: %v", active.name, doc.FilePath, err)
+		}
+	}
+	return nil
+}
+
+// streamingExtractor returns the active extractor streaming the top-level
+// input fileName, or nil
+func streamingExtractor(fileName string) *activeExtractor {
+	for _, active := range activeExtractors {
+		if s, ok := active.extractor.(StreamExtractor); ok && s.Streams(fileName) {
+			return active
+		}
+	}
+	return nil
+}
+
+// streamFile streams a top-level input into the tables of its streaming
+// extractor and records it in the files table with no combined rows
+func streamFile(fileName, relativePath string, active *activeExtractor, rowWriter RowWriter) error {
+	if retrySkip(fileName, relativePath) {
+		return nil
+	}
+	if checkpoints.skip(relativePath, "") {
+		return recordSkip(relativePath, 0, skipResumed)
+	}
+	if err := checkLimits(); err != nil {
+		return err
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		if skipUnreadable {
+			log.Printf("Skipping unreadable file %s: %v", fileName, err)
+			return recordSkip(relativePath, 0, unreadableReason(err))
+		}
+		return wrapFSError("open file", fileName, err)
+	}
+	defer file.Close()
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	if maxFileSize > 0 && size > maxFileSize {
+		return recordSkip(relativePath, size, skipTooLarge)
+	}
+
+	start := time.Now()
+	doc := &Document{FilePath: relativePath, ContainerPath: relativePath}
+	if err := active.extractor.(StreamExtractor).Stream(file, doc, active.write); err != nil {
+		return recordFailure(fileName, relativePath, fmt.Errorf("%s extractor failed on %s: %v", active.name, fileName, err))
+	}
+	report.recordFile(0)
+	if filesTable != nil {
+		if err := recordFileRow(newFileRow(relativePath, size, 0, nodeIDs.reserve(0), time.Since(start))); err != nil {
+			return err
+		}
+	}
+	return checkpoints.documentDone(relativePath, "", rowWriter)
+}
+
+// closeExtractors finalizes the extractor tables, returning the first error
+func closeExtractors() error {
+	var firstErr error
+	for _, active := range activeExtractors {
+		for _, t := range active.tables {
+			if err := t.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	activeExtractors = nil
+	return firstErr
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileListFile names the exact inputs of the run, one per line, in place
+// of the input argument (--filelist). A line is a path, optionally
+// followed by a tab and space-separated options:
+//
+//	# comments and blank lines are ignored
+//	/data/2024/a.xml
+//	/data/2024/b.zip	handler=container
+//	exports/page.htm	handler=html file_path=site/page.htm
+//
+// handler= overrides the file's handler (xml, html, json, iwa, container,
+// copy or skip), file_path= the file_path recorded for it, and any other
+// key=value becomes a handler option. Relative paths are relative to the
+// list's directory.
+var fileListFile string
+
+// fileListEntry holds the options of one listed input
+type fileListEntry struct {
+	handler  string
+	filePath string
+	options  map[string]string
+}
+
+// fileListEntries are the options of the listed inputs, keyed by path
+var fileListEntries map[string]fileListEntry
+
+// runInputs returns the inputs of a run and the root their paths are
+// relative to: the --filelist entries, or the files below input except
+// those in outputDir
+func runInputs(input, outputDir string) ([]string, string, error) {
+	if fileListFile != "" {
+		return loadFileList(fileListFile)
+	}
+	return collectInputs(input, outputDir)
+}
+
+// loadFileList reads a --filelist file. Every listed input must exist and
+// be listed once.
+func loadFileList(fileName string) ([]string, string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, "", wrapFSError("open file list", fileName, err)
+	}
+	defer f.Close()
+
+	root := filepath.Dir(fileName)
+	fileListEntries = make(map[string]fileListEntry)
+	var inputs []string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(strings.TrimSpace(text), "#") {
+			continue
+		}
+		path, optionText, _ := strings.Cut(text, "\t")
+		path = normalizePath(strings.TrimSpace(path))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		if _, ok := fileListEntries[path]; ok {
+			return nil, "", fmt.Errorf("file list %s line %d: %s is listed twice", fileName, line, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("file list %s line %d: %v", fileName, line, wrapFSError("read input", path, err))
+		}
+		if info.IsDir() {
+			return nil, "", fmt.Errorf("file list %s line %d: %s is a directory", fileName, line, path)
+		}
+
+		var entry fileListEntry
+		for _, option := range strings.Fields(optionText) {
+			key, value, ok := strings.Cut(option, "=")
+			if !ok || key == "" {
+				return nil, "", fmt.Errorf("file list %s line %d: option %q is not key=value", fileName, line, option)
+			}
+			switch key {
+			case "handler":
+				if !validHandler(value) {
+					return nil, "", fmt.Errorf("file list %s line %d: unknown handler %q", fileName, line, value)
+				}
+				entry.handler = value
+			case "file_path":
+				entry.filePath = filepath.FromSlash(value)
+			default:
+				if entry.options == nil {
+					entry.options = make(map[string]string)
+				}
+				entry.options[key] = value
+			}
+		}
+		fileListEntries[path] = entry
+		inputs = append(inputs, path)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", wrapFSError("read file list", fileName, err)
+	}
+	return inputs, root, nil
+}
+
+// inputHandler returns the handler of a top-level input, applying the
+// options of its --filelist entry
+func inputHandler(fileName string, extensions []string) fileHandler {
+	h := handlerFor(fileName, extensions)
+	entry, ok := fileListEntries[fileName]
+	if !ok {
+		return h
+	}
+	if entry.handler != "" {
+		h = fileHandler{Handler: entry.handler}
+	}
+	if entry.options != nil {
+		options := make(map[string]string, len(h.Options)+len(entry.options))
+		for key, value := range h.Options {
+			options[key] = value
+		}
+		for key, value := range entry.options {
+			options[key] = value
+		}
+		h.Options = options
+	}
+	return h
+}
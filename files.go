@@ -0,0 +1,145 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// FileRow describes one parsed source document in files.parquet
+type FileRow struct {
+	FilePath        string  `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY" json:"file_path"`
+	Bytes           int64   `parquet:"name=bytes, type=INT64, convertedtype=INT_64" json:"bytes"`
+	Rows            int64   `parquet:"name=rows, type=INT64, convertedtype=INT_64" json:"rows"`
+	FirstNodeID     int64   `parquet:"name=first_node_id, type=INT64, convertedtype=INT_64" json:"first_node_id"`
+	LastNodeID      int64   `parquet:"name=last_node_id, type=INT64, convertedtype=INT_64" json:"last_node_id"`
+	ParseDurationMs int64   `parquet:"name=parse_duration_ms, type=INT64, convertedtype=INT_64" json:"parse_duration_ms"`
+	BytesPerSecond  float64 `parquet:"name=bytes_per_second, type=DOUBLE" json:"bytes_per_second"`
+	RowsPerSecond   float64 `parquet:"name=rows_per_second, type=DOUBLE" json:"rows_per_second"`
+	SkipReason      *string `parquet:"name=skip_reason, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL" json:"skip_reason,omitempty"`
+	// HasVBA, HasActiveX and VBAModules are only set for containers
+	HasVBA     *bool   `parquet:"name=has_vba, type=BOOLEAN, repetitiontype=OPTIONAL" json:"has_vba,omitempty"`
+	HasActiveX *bool   `parquet:"name=has_activex, type=BOOLEAN, repetitiontype=OPTIONAL" json:"has_activex,omitempty"`
+	VBAModules *string `parquet:"name=vba_modules, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL" json:"vba_modules,omitempty"`
+	// XMLVersion, XMLEncoding and XMLStandalone are the document's XML
+	// declaration, null where it has none or leaves a pseudo-attribute out
+	XMLVersion    *string `parquet:"name=xml_version, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL" json:"xml_version,omitempty"`
+	XMLEncoding   *string `parquet:"name=xml_encoding, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL" json:"xml_encoding,omitempty"`
+	XMLStandalone *bool   `parquet:"name=xml_standalone, type=BOOLEAN, repetitiontype=OPTIONAL" json:"xml_standalone,omitempty"`
+}
+
+// filesTable records per-document statistics in files.parquet
+var filesTable *ParquetTable
+
+// Skip reasons recorded in the skip_reason column of files.parquet. Files
+// that could not be read with --skip-unreadable use their fsErrorClass
+// (permission, locked, network, not_found) or "unreadable".
+const (
+	skipExtension = "extension"   // not a configured XML extension; copied as is
+	skipDuplicate = "duplicate"   // copy skipped by --on-collision=skip
+	skipEmpty     = "empty"       // empty document dropped by --empty-parts
+	skipTooLarge  = "too_large"   // larger than --max-file-size
+	skipResumed   = "resumed"     // already committed by the run being resumed
+	skipPartType  = "part_type"   // container entry not selected by --part-types
+	skipContainer = "container"   // ZIP container; its entries are listed separately
+	skipUnsafe    = "unsafe_path" // container entry whose name leaves the output directory
+)
+
+// maxFileSize skips XML documents larger than this many bytes (0 means no limit)
+var maxFileSize int64
+
+// skipUnreadable records files that cannot be opened instead of failing the run
+var skipUnreadable bool
+
+// recordSkip adds a files table entry for a file that was not parsed
+func recordSkip(relativePath string, bytes int64, reason string) error {
+	if err := strictSkip(relativePath, reason); err != nil {
+		return err
+	}
+	return recordFileRow(FileRow{FilePath: relativePath, Bytes: bytes, SkipReason: &reason})
+}
+
+// unreadableReason names the skip reason for a file that failed to open
+func unreadableReason(err error) string {
+	if class := classifyFSError(err); class != fsErrorNone {
+		return string(class)
+	}
+	return "unreadable"
+}
+
+// newFileRow builds the files table entry for a document parsed in elapsed
+func newFileRow(relativePath string, bytes int64, rows int64, ids *idBlock, elapsed time.Duration) FileRow {
+	if deterministic {
+		elapsed = 0
+	}
+	row := FileRow{
+		FilePath:        relativePath,
+		Bytes:           bytes,
+		Rows:            rows,
+		FirstNodeID:     ids.first,
+		LastNodeID:      ids.last(),
+		ParseDurationMs: elapsed.Milliseconds(),
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		row.BytesPerSecond = float64(bytes) / seconds
+		row.RowsPerSecond = float64(rows) / seconds
+	}
+	return row
+}
+
+// progressRows is how many rows a single document writes between progress
+// log lines (0 disables them), so very wide documents don't go silent
+var progressRows int64 = 1 << 20
+
+// docSource is where a document was read from: its file_path, the
+// provenance of the top-level input holding it and, for container entries,
+// its name in that input and the container's other entries
+type docSource struct {
+	path      string
+	container string
+	entry     string
+	parts     *containerParts
+}
+
+// countingWriter counts the rows of one document passing through to the
+// next writer, stamping them with the document's container and entry path
+// and logging progress for documents that produce more than progressRows
+type countingWriter struct {
+	next  RowWriter
+	src   docSource
+	rows  int64
+	start time.Time
+}
+
+// Write counts, stamps and forwards a row
+func (w *countingWriter) Write(row ParquetRow) error {
+	row.ContainerPath, row.EntryPath = w.src.container, w.src.entry
+	w.rows++
+	if progressRows > 0 && w.rows%progressRows == 0 {
+		w.logProgress()
+	}
+	return w.next.Write(row)
+}
+
+// WriteBatch counts, stamps and forwards rows
+func (w *countingWriter) WriteBatch(rows []ParquetRow) error {
+	for i := range rows {
+		rows[i].ContainerPath, rows[i].EntryPath = w.src.container, w.src.entry
+	}
+	before := w.rows
+	w.rows += int64(len(rows))
+	if progressRows > 0 && w.rows/progressRows != before/progressRows {
+		w.logProgress()
+	}
+	return w.next.WriteBatch(rows)
+}
+
+// logProgress reports how far the current document has got
+func (w *countingWriter) logProgress() {
+	elapsed := time.Since(w.start)
+	log.Printf("Still writing %s: %d rows in %s (%.0f rows/s)", w.src.path, w.rows, elapsed.Round(time.Second), float64(w.rows)/elapsed.Seconds())
+}
+
+// WriteStop finalizes the next writer
+func (w *countingWriter) WriteStop() error {
+	return w.next.WriteStop()
+}
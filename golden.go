@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// defaultGoldenDir holds the bundled golden corpus "xmlgo golden" checks
+// when no directory is given
+const defaultGoldenDir = "testdata/golden"
+
+// updateGolden makes "xmlgo golden" record the outputs of the current build
+// as the expected ones instead of comparing them (--update-golden)
+var updateGolden bool
+
+// goldenDifferences caps the differences reported per output file
+const goldenDifferences = 5
+
+// A golden case is a directory of the corpus holding input/, the files
+// converted, and expected/, the output directory they converted to. An
+// optional args file adds command-line flags, one per line, # comments
+// allowed. Every case converts with --deterministic, parquet and jsonl
+// output, and --path-base=input, so its outputs do not depend on the
+// machine or directory the corpus is checked on.
+type goldenCase struct {
+	name string
+	dir  string
+}
+
+// goldenCases lists the cases of the corpus at dir in sorted order
+func goldenCases(dir string) ([]goldenCase, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, wrapFSError("read", dir, err)
+	}
+	var cases []goldenCase
+	for _, entry := range entries {
+		caseDir := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(caseDir, "input")); err != nil || !info.IsDir() {
+			continue
+		}
+		cases = append(cases, goldenCase{name: entry.Name(), dir: caseDir})
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no golden cases in %s", dir)
+	}
+	return cases, nil
+}
+
+// args returns the flags the case converts with
+func (c goldenCase) args() ([]string, error) {
+	args := []string{"--deterministic", "--format=parquet,jsonl", "--path-base=input"}
+	fileName := filepath.Join(c.dir, "args")
+	f, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return args, nil
+	}
+	if err != nil {
+		return nil, wrapFSError("open", fileName, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			args = append(args, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, wrapFSError("read", fileName, err)
+	}
+	return args, nil
+}
+
+// run converts the case's input with this executable into outputDir. The
+// conversion runs as a child process from the case directory, so no flag
+// or run state carries over from one case to the next.
+func (c goldenCase) run(outputDir string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the xmlgo executable: %v", err)
+	}
+	args, err := c.args()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, append(args, "input", outputDir)...)
+	cmd.Dir = c.dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("conversion failed: %v\n%s", err, out)
+	}
+	return nil
+}
+
+// runGolden converts every case of the corpus at dir and compares its
+// outputs with the expected ones, or records them with --update-golden. It
+// returns the names of the cases whose outputs differ.
+func runGolden(dir string) ([]string, error) {
+	cases, err := goldenCases(dir)
+	if err != nil {
+		return nil, err
+	}
+	scratch, err := os.MkdirTemp("", "xmlgo-golden-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %v", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	var failed []string
+	for _, c := range cases {
+		outputDir := filepath.Join(scratch, c.name)
+		if err := c.run(outputDir); err != nil {
+			fmt.Printf("FAIL %s: %v\n", c.name, err)
+			failed = append(failed, c.name)
+			continue
+		}
+		expectedDir := filepath.Join(c.dir, "expected")
+		if updateGolden {
+			if err := replaceGolden(expectedDir, outputDir); err != nil {
+				return nil, err
+			}
+			fmt.Printf("updated %s\n", c.name)
+			continue
+		}
+		differences, err := compareOutputs(expectedDir, outputDir)
+		if err != nil {
+			return nil, err
+		}
+		if len(differences) == 0 {
+			fmt.Printf("ok   %s\n", c.name)
+			continue
+		}
+		fmt.Printf("FAIL %s:\n", c.name)
+		for _, d := range differences {
+			fmt.Printf("    %s\n", d)
+		}
+		failed = append(failed, c.name)
+	}
+	return failed, nil
+}
+
+// replaceGolden makes outputDir the expected outputs of a case
+func replaceGolden(expectedDir, outputDir string) error {
+	if err := os.RemoveAll(expectedDir); err != nil {
+		return wrapFSError("remove", expectedDir, err)
+	}
+	if err := os.CopyFS(expectedDir, os.DirFS(outputDir)); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %v", outputDir, expectedDir, err)
+	}
+	return nil
+}
+
+// outputFiles lists the regular files below dir, relative to it
+func outputFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outputs in %s: %v", dir, err)
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// compareOutputs describes how the output directory actualDir differs from
+// expectedDir. Parquet files are compared by their decoded rows, so a
+// change in how pages or row groups are cut is not a regression; all other
+// files byte for byte.
+func compareOutputs(expectedDir, actualDir string) ([]string, error) {
+	if _, err := os.Stat(expectedDir); err != nil {
+		return []string{fmt.Sprintf("no expected outputs (%v); record them with --update-golden", err)}, nil
+	}
+	expected, err := outputFiles(expectedDir)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := outputFiles(actualDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var differences []string
+	for _, name := range expected {
+		if !slices.Contains(actual, name) {
+			differences = append(differences, fmt.Sprintf("%s: missing", name))
+		}
+	}
+	for _, name := range actual {
+		if !slices.Contains(expected, name) {
+			differences = append(differences, fmt.Sprintf("%s: not expected", name))
+			continue
+		}
+		want, got := filepath.Join(expectedDir, name), filepath.Join(actualDir, name)
+		var fileDifferences []string
+		if strings.EqualFold(filepath.Ext(name), ".parquet") {
+			fileDifferences, err = compareParquet(want, got)
+		} else {
+			fileDifferences, err = compareFiles(want, got)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range fileDifferences {
+			differences = append(differences, name+": "+d)
+		}
+	}
+	return differences, nil
+}
+
+// compareFiles compares two files line by line
+func compareFiles(want, got string) ([]string, error) {
+	wantData, err := os.ReadFile(want)
+	if err != nil {
+		return nil, wrapFSError("read", want, err)
+	}
+	gotData, err := os.ReadFile(got)
+	if err != nil {
+		return nil, wrapFSError("read", got, err)
+	}
+	if bytes.Equal(wantData, gotData) {
+		return nil, nil
+	}
+	wantLines, gotLines := strings.Split(string(wantData), "\n"), strings.Split(string(gotData), "\n")
+	var differences []string
+	for i := 0; i < max(len(wantLines), len(gotLines)) && len(differences) < goldenDifferences; i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			column, gotPart, wantPart := differingPart(g, w)
+			differences = append(differences, fmt.Sprintf("line %d, column %d: got %q, want %q", i+1, column, gotPart, wantPart))
+		}
+	}
+	if len(differences) == 0 {
+		differences = append(differences, "contents differ")
+	}
+	return differences, nil
+}
+
+// compareParquet compares the columns and rows of two Parquet files
+func compareParquet(want, got string) ([]string, error) {
+	wantColumns, wantRows, err := parquetRows(want)
+	if err != nil {
+		return nil, err
+	}
+	gotColumns, gotRows, err := parquetRows(got)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Equal(wantColumns, gotColumns) {
+		return []string{fmt.Sprintf("columns %s, want %s", strings.Join(gotColumns, ", "), strings.Join(wantColumns, ", "))}, nil
+	}
+	var differences []string
+	if len(gotRows) != len(wantRows) {
+		differences = append(differences, fmt.Sprintf("%d rows, want %d", len(gotRows), len(wantRows)))
+	}
+	for i := 0; i < min(len(wantRows), len(gotRows)) && len(differences) < goldenDifferences; i++ {
+		for j, column := range wantColumns {
+			if gotRows[i][j] != wantRows[i][j] {
+				differences = append(differences, fmt.Sprintf("row %d %s: got %s, want %s", i, column, abbreviate(gotRows[i][j]), abbreviate(wantRows[i][j])))
+			}
+		}
+	}
+	return differences, nil
+}
+
+// parquetRows decodes every column of fileName with parquet-go, returning
+// the column names and each row's values formatted as strings
+func parquetRows(fileName string) ([]string, [][]string, error) {
+	fr, err := local.NewLocalFileReader(fileName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %v", fileName, err)
+	}
+	defer fr.Close()
+
+	pr, err := reader.NewParquetColumnReader(fr, 1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read footer of %s: %v", fileName, err)
+	}
+	defer pr.ReadStop()
+
+	n := pr.GetNumRows()
+	rows := make([][]string, n)
+	var columns []string
+	for _, path := range pr.SchemaHandler.ValueColumns {
+		values, _, _, err := pr.ReadColumnByPath(path, n)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read column %s of %s: %v", columnName(path), fileName, err)
+		}
+		column := columnName(pr.SchemaHandler.InPathToExPath[path])
+		if int64(len(values)) != n {
+			return nil, nil, fmt.Errorf("column %s of %s has %d values, footer says %d rows", column, fileName, len(values), n)
+		}
+		columns = append(columns, column)
+		for i, value := range values {
+			if value == nil {
+				rows[i] = append(rows[i], "null")
+			} else {
+				rows[i] = append(rows[i], fmt.Sprintf("%q", fmt.Sprint(value)))
+			}
+		}
+	}
+	return columns, rows, nil
+}
+
+// goldenContext is the bytes of context a difference report shows
+const goldenContext = 60
+
+// abbreviate shortens a value for a difference report
+func abbreviate(s string) string {
+	if len(s) > 2*goldenContext {
+		return s[:2*goldenContext] + "..."
+	}
+	return s
+}
+
+// differingPart finds the first byte where got and want differ, returning
+// its 1-based column and the text of each from a little before it, so the
+// difference shows even deep in a long JSON line
+func differingPart(got, want string) (int, string, string) {
+	i := 0
+	for i < len(got) && i < len(want) && got[i] == want[i] {
+		i++
+	}
+	start := max(i-goldenContext/2, 0)
+	part := func(s string) string {
+		s = abbreviate(s[start:])
+		if start > 0 {
+			s = "..." + s
+		}
+		return s
+	}
+	return i + 1, part(got), part(want)
+}
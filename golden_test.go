@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// goldenChildEnv makes the test binary run as xmlgo, so the child process
+// runGolden starts for each case converts instead of running the tests
+const goldenChildEnv = "XMLGO_GOLDEN_CHILD"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(goldenChildEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// TestGoldenCorpus converts every case of testdata/golden and fails on any
+// whose outputs differ from the recorded ones, as "xmlgo golden" does
+func TestGoldenCorpus(t *testing.T) {
+	defer func(saved bool) { updateGolden = saved }(updateGolden)
+	updateGolden = false
+	t.Setenv(goldenChildEnv, "1")
+
+	failed, err := runGolden(defaultGoldenDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range failed {
+		t.Errorf("golden case %s differs from its expected outputs; see the output above, and re-record intended changes with xmlgo golden --update-golden", name)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+)
+
+// fileHandler says how files of one extension or content type are processed
+type fileHandler struct {
+	// Handler is xml, html, json or iwa (decoded into rows), container (a ZIP
+	// whose entries are processed), copy or skip
+	Handler string            `json:"handler"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Handlers that do not decode the file into rows
+const (
+	handlerContainer = "container"
+	handlerCopy      = "copy"
+	handlerSkip      = "skip"
+)
+
+// skipConfigured is the skip reason of files whose handler is skip
+const skipConfigured = "configured"
+
+// documentDecoders maps handler names to the decoders turning a document
+// into a node tree; every other handler name is one of the constants above
+var documentDecoders = map[string]func(r io.Reader, options map[string]string) (XMLNode, error){
+	"xml":  decodeXML,
+	"html": decodeHTML,
+	"json": decodeJSON,
+}
+
+// configuredHandlers are the handlers of the config file, keyed by
+// lower-case extension (".svg") or content type ("image/svg+xml"). They
+// take precedence over --extensions and the built-in container list, for
+// top-level files and container entries alike.
+var configuredHandlers map[string]fileHandler
+
+// validHandlers checks the handler names and keys of a handler table
+func validHandlers(handlers map[string]fileHandler) error {
+	for key, h := range handlers {
+		if !strings.HasPrefix(key, ".") && !strings.Contains(key, "/") {
+			return fmt.Errorf("handler key %q is neither an extension (.ext) nor a content type (type/subtype)", key)
+		}
+		if !validHandler(h.Handler) {
+			return fmt.Errorf("unknown handler %q for %s", h.Handler, key)
+		}
+	}
+	return nil
+}
+
+// validHandler reports whether name is a decoder or one of the handlers
+// that do not decode
+func validHandler(name string) bool {
+	if _, ok := documentDecoders[name]; ok {
+		return true
+	}
+	return name == handlerContainer || name == handlerCopy || name == handlerSkip
+}
+
+// configuredHandler looks name up in the config file handlers, by extension
+// first and then by the content type the extension maps to
+func configuredHandler(name string) (fileHandler, bool) {
+	ext := strings.ToLower(path.Ext(name))
+	if h, ok := configuredHandlers[ext]; ok {
+		return h, true
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		contentType, _, _ = strings.Cut(contentType, ";")
+		if h, ok := configuredHandlers[strings.TrimSpace(contentType)]; ok {
+			return h, true
+		}
+	}
+	return fileHandler{}, false
+}
+
+// handlerFor returns the handler of a top-level input: the config file's,
+// else xml for --extensions and known XML formats, container for known
+// archive types, or copy
+func handlerFor(fileName string, extensions []string) fileHandler {
+	if h, ok := configuredHandler(fileName); ok {
+		return h
+	}
+	ext := strings.ToLower(path.Ext(fileName))
+	for _, extension := range extensions {
+		if ext == extension {
+			return fileHandler{Handler: "xml"}
+		}
+	}
+	if handler, ok := formatHandlers[ext]; ok {
+		return fileHandler{Handler: handler}
+	}
+	if isContainerExt(ext) {
+		return fileHandler{Handler: handlerContainer}
+	}
+	return fileHandler{Handler: handlerCopy}
+}
+
+// formatHandlers maps the extensions of known document formats to the
+// decoder they are parsed with, without having to list them in
+// --extensions. Formats with their own decoder register it from their own
+// file (see iwa.go).
+var formatHandlers = map[string]string{".resx": "xml", ".csproj": "xml", ".vbproj": "xml", ".nuspec": "xml"}
+
+// entryHandlerFor returns the handler of a container entry: the config
+// file's, else xml for .xml and .rels parts, the decoder of known formats,
+// or copy. Nested containers are copied.
+func entryHandlerFor(name string) fileHandler {
+	h, ok := configuredHandler(name)
+	if !ok && (strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".rels")) {
+		h = fileHandler{Handler: "xml"}
+	} else if handler, format := formatHandlers[strings.ToLower(path.Ext(name))]; !ok && format {
+		h = fileHandler{Handler: handler}
+	}
+	if h.Handler == "" || h.Handler == handlerContainer {
+		h.Handler = handlerCopy
+	}
+	return h
+}
+
+// decodes reports whether h turns files into rows
+func (h fileHandler) decodes() bool {
+	_, ok := documentDecoders[h.Handler]
+	return ok
+}
+
+// decode decodes a document with the decoder of h
+func (h fileHandler) decode(r io.Reader) (XMLNode, error) {
+	return documentDecoders[h.Handler](r, h.Options)
+}
+
+// decodeXML decodes an XML document. The option strict=false accepts
+// unquoted attributes, unknown entities and unclosed elements.
+func decodeXML(r io.Reader, options map[string]string) (XMLNode, error) {
+	r, tags := trackTagEnds(r)
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = options["strict"] != "false"
+	return decodeNodes(decoder, tags)
+}
+
+// decodeHTML decodes an HTML page leniently, closing void elements and
+// resolving HTML entities, into the tree of its root element
+func decodeHTML(r io.Reader, options map[string]string) (XMLNode, error) {
+	r, tags := trackTagEnds(r)
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	return decodeNodes(decoder, tags)
+}
+
+// decodeNodes decodes the root element of decoder, keeping the XML
+// declaration that precedes it. tags, when set, is the decoder's byte
+// source, used to mark empty-element tags.
+func decodeNodes(decoder *xml.Decoder, tags *tagEndReader) (XMLNode, error) {
+	var root XMLNode
+	var declaration *XMLDeclaration
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return root, errEmptyDocument
+		}
+		if err != nil {
+			return root, err
+		}
+		switch t := tok.(type) {
+		case xml.ProcInst:
+			if t.Target == "xml" && declaration == nil {
+				declaration = parseXMLDeclaration(string(t.Inst))
+			}
+		case xml.StartElement:
+			root, err = decodeElement(decoder, t, tags, make(nameTable))
+			root.Declaration = declaration
+			return root, err
+		}
+	}
+}
+
+// decodeElement decodes the element started by start into an XMLNode the
+// way xml.Decoder.DecodeElement would, interning its names in names and,
+// when tags is set, marking the elements written as empty-element tags
+func decodeElement(decoder *xml.Decoder, start xml.StartElement, tags *tagEndReader, names nameTable) (XMLNode, error) {
+	node := XMLNode{XMLName: names.name(start.Name)}
+	if tags != nil {
+		node.SelfClosing = tags.selfClosing()
+	}
+	if len(start.Attr) > 0 {
+		node.Attrs = make([]xml.Attr, len(start.Attr))
+		for i, attr := range start.Attr {
+			node.Attrs[i] = xml.Attr{Name: names.name(attr.Name), Value: attr.Value}
+		}
+	}
+	var content []byte
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return node, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeElement(decoder, tok, tags, names)
+			node.Nodes = append(node.Nodes, child)
+			if err != nil {
+				return node, err
+			}
+		case xml.CharData:
+			content = append(content, tok...)
+		case xml.EndElement:
+			node.Content = string(content)
+			return node, nil
+		}
+	}
+}
+
+// decodeJSON maps a JSON document onto a node tree: the document becomes
+// an element named by the root option (json by default), object members
+// become child elements named after their keys, array items repeat the
+// element of their array (item at the top level) and scalars become text
+func decodeJSON(r io.Reader, options map[string]string) (XMLNode, error) {
+	name := options["root"]
+	if name == "" {
+		name = "json"
+	}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	tok, err := decoder.Token()
+	if err == io.EOF {
+		return XMLNode{}, errEmptyDocument
+	}
+	if err != nil {
+		return XMLNode{}, err
+	}
+	root := XMLNode{XMLName: xml.Name{Local: name}}
+	if delim, ok := tok.(json.Delim); ok && delim == '[' {
+		if err := decodeJSONArray(decoder, &root, "item"); err != nil {
+			return XMLNode{}, err
+		}
+	} else if err := decodeJSONValue(decoder, tok, &root); err != nil {
+		return XMLNode{}, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return XMLNode{}, fmt.Errorf("unexpected data after the JSON document")
+	}
+	return root, nil
+}
+
+// decodeJSONValue fills node from the value starting with tok
+func decodeJSONValue(decoder *json.Decoder, tok json.Token, node *XMLNode) error {
+	switch v := tok.(type) {
+	case json.Delim:
+		if v != '{' {
+			return fmt.Errorf("unexpected %v", v)
+		}
+		for decoder.More() {
+			keyTok, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			key := keyTok.(string)
+			valueTok, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			if delim, ok := valueTok.(json.Delim); ok && delim == '[' {
+				if err := decodeJSONArray(decoder, node, key); err != nil {
+					return err
+				}
+				continue
+			}
+			child := XMLNode{XMLName: xml.Name{Local: key}}
+			if err := decodeJSONValue(decoder, valueTok, &child); err != nil {
+				return err
+			}
+			node.Nodes = append(node.Nodes, child)
+		}
+		_, err := decoder.Token() // closing }
+		return err
+	case nil:
+		return nil
+	default:
+		node.Content = fmt.Sprint(v)
+		return nil
+	}
+}
+
+// decodeJSONArray appends one element named name to parent per array item
+func decodeJSONArray(decoder *json.Decoder, parent *XMLNode, name string) error {
+	for decoder.More() {
+		tok, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		child := XMLNode{XMLName: xml.Name{Local: name}}
+		if delim, ok := tok.(json.Delim); ok && delim == '[' {
+			if err := decodeJSONArray(decoder, &child, "item"); err != nil {
+				return err
+			}
+		} else if err := decodeJSONValue(decoder, tok, &child); err != nil {
+			return err
+		}
+		parent.Nodes = append(parent.Nodes, child)
+	}
+	_, err := decoder.Token() // closing ]
+	return err
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// runHooks is the "hooks" section of a --config file, the shell commands
+// and webhooks fired around each top-level input and at the end of the run:
+//
+//	"hooks": {
+//	  "before_file": [{"command": "test ! -e \"$XMLGO_INPUT.lock\""}],
+//	  "after_file": [{"command": "setfattr -n user.xmlgo -v \"$XMLGO_STATUS\" \"$XMLGO_INPUT\""}],
+//	  "run_end": [{"url": "https://etl.example.com/hooks/xmlgo"}]
+//	}
+//
+// Every hook receives a hookPayload as JSON: commands on stdin, with its
+// event, input and status also in XMLGO_HOOK_EVENT, XMLGO_INPUT,
+// XMLGO_FILE_PATH and XMLGO_STATUS; webhooks as a POST signed like
+// --webhook deliveries. A failing before_file hook fails the input, which
+// --keep-going records and skips; other hook failures are only logged.
+type runHooks struct {
+	BeforeFile []hook `json:"before_file"`
+	AfterFile  []hook `json:"after_file"`
+	RunEnd     []hook `json:"run_end"`
+}
+
+// hook is a shell command or a webhook URL
+type hook struct {
+	Command string `json:"command"`
+	URL     string `json:"url"`
+}
+
+// Hook events
+const (
+	hookBeforeFile = "before_file"
+	hookAfterFile  = "after_file"
+	hookRunEnd     = "run_end"
+)
+
+// hookTimeout bounds how long a hook command may run
+const hookTimeout = 5 * time.Minute
+
+// hookPayload describes the event a hook fires for. Manifest holds the
+// files.parquet entries written for the input: one for a document, or the
+// container and each of its entries.
+type hookPayload struct {
+	Event     string     `json:"event"`
+	Input     string     `json:"input,omitempty"`
+	FilePath  string     `json:"file_path,omitempty"`
+	OutputDir string     `json:"output_dir"`
+	Status    string     `json:"status,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Manifest  []FileRow  `json:"manifest,omitempty"`
+	Report    *RunReport `json:"report,omitempty"`
+}
+
+// activeHooks are the hooks of the --config file, or nil
+var activeHooks *runHooks
+
+// hookManifest collects the files table entries of the input being
+// processed while hooks are configured
+var hookManifest []FileRow
+
+// validate checks that every hook is either a command or a URL
+func (h *runHooks) validate() error {
+	for event, hooks := range map[string][]hook{hookBeforeFile: h.BeforeFile, hookAfterFile: h.AfterFile, hookRunEnd: h.RunEnd} {
+		for i, hk := range hooks {
+			if (hk.Command == "") == (hk.URL == "") {
+				return fmt.Errorf("hooks: %s hook %d needs either a command or a url", event, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// recordFileRow writes a files table entry, keeping it for the after_file
+// hooks of the current input
+func recordFileRow(row FileRow) error {
+	if activeHooks != nil {
+		hookManifest = append(hookManifest, row)
+	}
+	if filesTable == nil {
+		return nil
+	}
+	return filesTable.Write(row)
+}
+
+// beforeFileHooks fires the before_file hooks of an input, returning the
+// first failure
+func beforeFileHooks(fileName, relativePath, outputDir string) error {
+	hookManifest = nil
+	payload := &hookPayload{Event: hookBeforeFile, Input: fileName, FilePath: relativePath, OutputDir: outputDir}
+	for _, hk := range activeHooks.BeforeFile {
+		if err := hk.fire(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// afterFileHooks fires the after_file hooks of an input that finished with
+// err, or whose documents failed under --keep-going since failures was the
+// run's failure count
+func afterFileHooks(fileName, relativePath, outputDir string, failures int, err error) {
+	payload := &hookPayload{Event: hookAfterFile, Input: fileName, FilePath: relativePath, OutputDir: outputDir, Status: jobDone, Manifest: hookManifest}
+	hookManifest = nil
+	switch {
+	case err == errLimitReached:
+		payload.Status = "unprocessed"
+	case err != nil:
+		payload.Status, payload.Error = jobFailed, err.Error()
+	case len(report.Failures) > failures:
+		payload.Status, payload.Error = jobFailed, report.Failures[len(report.Failures)-1].Error
+	}
+	for _, hk := range activeHooks.AfterFile {
+		if err := hk.fire(payload); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+}
+
+// runEndHooks fires the run_end hooks of the conversion of input into
+// outputDir that finished with err
+func runEndHooks(input, outputDir string, err error) {
+	payload := &hookPayload{Event: hookRunEnd, Input: input, OutputDir: outputDir, Status: jobDone, Report: report}
+	if err != nil {
+		payload.Status, payload.Error = jobFailed, err.Error()
+	}
+	for _, hk := range activeHooks.RunEnd {
+		if err := hk.fire(payload); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+}
+
+// fire runs the hook's command or posts to its URL
+func (hk hook) fire(payload *hookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s hook payload: %v", payload.Event, err)
+	}
+	if hk.URL != "" {
+		if err := deliverWebhook(hk.URL, body); err != nil {
+			return fmt.Errorf("%s hook: %v", payload.Event, err)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(runCtx, hookTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hk.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hk.Command)
+	}
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	cmd.Env = append(os.Environ(),
+		"XMLGO_HOOK_EVENT="+payload.Event,
+		"XMLGO_INPUT="+payload.Input,
+		"XMLGO_FILE_PATH="+payload.FilePath,
+		"XMLGO_STATUS="+payload.Status,
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook %q failed: %v", payload.Event, hk.Command, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sync"
+)
+
+// idAllocator hands out contiguous node ID blocks, one per document. Blocks
+// are reserved strictly in ticket order, so with parallel workers each
+// document still gets exactly the IDs a serial run would have given it and
+// document order can be reconstructed from the IDs alone.
+type idAllocator struct {
+	mu         sync.Mutex
+	cond       *sync.Cond
+	next       int64
+	nextTicket int64
+	redeemed   int64
+}
+
+// nodeIDs is the run-wide node ID coordinator
+var nodeIDs = newIDAllocator(1)
+
+// newIDAllocator creates an allocator whose first block starts at first
+func newIDAllocator(first int64) *idAllocator {
+	a := &idAllocator{next: first}
+	a.cond = sync.NewCond(&a.mu)
+	return a
+}
+
+// ticket returns the next ticket for reserveInOrder
+func (a *idAllocator) ticket() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t := a.nextTicket
+	a.nextTicket++
+	return t
+}
+
+// reserve takes a block of n IDs immediately, outside ticket order
+func (a *idAllocator) reserve(n int64) *idBlock {
+	return a.reserveInOrder(a.ticket(), n)
+}
+
+// reserveInOrder waits until every earlier ticket has reserved its block,
+// then takes a block of n IDs. Every ticket must be redeemed, with n = 0
+// for documents that produce no rows, or later tickets wait forever.
+func (a *idAllocator) reserveInOrder(ticket int64, n int64) *idBlock {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for a.redeemed < ticket {
+		a.cond.Wait()
+	}
+	block := &idBlock{first: a.next, next: a.next, end: a.next + n}
+	a.next += n
+	a.redeemed++
+	a.cond.Broadcast()
+	return block
+}
+
+// peek returns the first ID of the next block
+func (a *idAllocator) peek() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.next
+}
+
+// idBlock is the ID range reserved for one document
+type idBlock struct {
+	first int64
+	next  int64
+	end   int64
+}
+
+// take returns the next ID of the block
+func (b *idBlock) take() int64 {
+	if b.next >= b.end {
+		panic("node ID block exhausted")
+	}
+	id := b.next
+	b.next++
+	return id
+}
+
+// last returns the final ID of the block, or first-1 when it is empty
+func (b *idBlock) last() int64 {
+	return b.end - 1
+}
+
+// countNodes returns the number of elements in a decoded tree
+func countNodes(node XMLNode) int64 {
+	n := int64(1)
+	for _, child := range node.Nodes {
+		n += countNodes(child)
+	}
+	return n
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// perFile writes one output per source file instead of a single combined output
+var perFile bool
+
+// perFileLayout decides where per-file outputs go: mirror or flat
+var perFileLayout = "mirror"
+
+// flatSeparator joins directory levels in flat per-file output names
+const flatSeparator = "__"
+
+// collectInputs expands the input argument into the files to convert. A
+// directory is walked recursively in lexical order; its root is returned so
+// per-file outputs can be placed relative to it. An output directory inside
+// the input directory is left out of the walk, so a run never reads back
+// its own outputs and copied assets; one that contains or is the input
+// directory is refused.
+func collectInputs(input, outputDir string) ([]string, string, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, "", wrapFSError("read input", input, err)
+	}
+	if !info.IsDir() {
+		return []string{input}, filepath.Dir(input), nil
+	}
+
+	excluded, err := nestedOutputDir(input, outputDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var files []string
+	err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && excluded != "" && filepath.Clean(path) == excluded {
+			log.Printf("Skipping output directory %s inside input directory %s", path, input)
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", wrapFSError("walk input directory", input, err)
+	}
+	return files, input, nil
+}
+
+// nestedOutputDir returns the path below input at which the walk meets
+// outputDir when the output directory is inside the input directory, or
+// "" when the two are unrelated. An output directory that is or contains
+// the input directory is an error, since the run would write into the
+// tree it reads.
+func nestedOutputDir(input, outputDir string) (string, error) {
+	if outputDir == "" {
+		return "", nil
+	}
+	if relPath(outputDir, input) == "." {
+		return "", fmt.Errorf("input directory %s is also the output directory; write the output elsewhere", input)
+	}
+	if withinDir(outputDir, input) {
+		return "", fmt.Errorf("input directory %s is inside output directory %s; write the output elsewhere", input, outputDir)
+	}
+	if withinDir(input, outputDir) {
+		return filepath.Join(input, relPath(input, outputDir)), nil
+	}
+	return "", nil
+}
+
+// perFileNamer assigns per-file output names under outputDir. The mirror
+// layout recreates the source directory structure; the flat layout joins
+// the relative path into a single file name and appends a short hash of the
+// original path whenever two sources would otherwise collide.
+type perFileNamer struct {
+	outputDir string
+	root      string
+	layout    string
+	used      map[string]string
+}
+
+// newPerFileNamer creates a namer for sources below root
+func newPerFileNamer(outputDir, root, layout string) (*perFileNamer, error) {
+	if layout != "mirror" && layout != "flat" {
+		return nil, fmt.Errorf("unknown per-file layout %q (expected mirror or flat)", layout)
+	}
+	return &perFileNamer{outputDir: outputDir, root: root, layout: layout, used: make(map[string]string)}, nil
+}
+
+// name returns the output path for source without a format extension,
+// creating its directory if needed
+func (n *perFileNamer) name(source string) (string, error) {
+	rel := relPath(n.root, source)
+
+	var fileName string
+	if n.layout == "mirror" {
+		fileName = filepath.Join(n.outputDir, rel)
+		if err := os.MkdirAll(longPath(filepath.Dir(fileName)), os.ModePerm); err != nil {
+			return "", wrapFSError("create output directory for", source, err)
+		}
+	} else {
+		flat := strings.Join(strings.Split(filepath.ToSlash(rel), "/"), flatSeparator)
+		if prev, ok := n.used[strings.ToLower(flat)]; ok && prev != rel {
+			sum := sha1.Sum([]byte(filepath.ToSlash(rel)))
+			flat += "-" + hex.EncodeToString(sum[:4])
+		}
+		n.used[strings.ToLower(flat)] = rel
+		fileName = filepath.Join(n.outputDir, flat)
+	}
+	return fileName, nil
+}
+
+// parseArgs parses flags wherever they appear among args, so options can
+// follow the positional arguments, and returns the positional arguments.
+// Everything after "--" is positional.
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		// The flag set exits on parse errors
+		flags.Parse(args)
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...)
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "encoding/xml"
+
+// nameTable interns the element and attribute names of a document while it
+// is decoded. encoding/xml allocates a new string for every name it reads,
+// and OOXML parts repeat a handful of names millions of times; interning
+// keeps one copy of each while the tree is held, and lets the tag and
+// attribute dictionaries and Parquet's dictionary encoding compare the
+// shared strings by pointer.
+type nameTable map[string]string
+
+// intern returns the table's copy of s
+func (t nameTable) intern(s string) string {
+	if v, ok := t[s]; ok {
+		return v
+	}
+	t[s] = s
+	return s
+}
+
+// name interns both parts of an XML name
+func (t nameTable) name(n xml.Name) xml.Name {
+	return xml.Name{Space: t.intern(n.Space), Local: t.intern(n.Local)}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// benchmarkSheet returns a worksheet part of rows rows and 10 cells each,
+// shaped like what spreadsheet tools write: a namespaced root, a handful
+// of element and attribute names repeated in every row, and short values
+func benchmarkSheet(rows int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheetData>`)
+	for r := 1; r <= rows; r++ {
+		fmt.Fprintf(&b, `<row r="%d" spans="1:10">`, r)
+		for c := 0; c < 10; c++ {
+			ref := fmt.Sprintf("%c%d", 'A'+c, r)
+			if c%3 == 0 {
+				fmt.Fprintf(&b, `<c r="%s" t="s"><v>%d</v></c>`, ref, r%500)
+			} else {
+				fmt.Fprintf(&b, `<c r="%s" s="1"><v>%d.%d</v></c>`, ref, r*c, c)
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.Bytes()
+}
+
+// decodeReflection decodes doc with xml.Decoder.Decode into XMLNode's
+// struct tags, the decoder used before names were interned
+func decodeReflection(doc []byte) (XMLNode, error) {
+	var root XMLNode
+	err := xml.NewDecoder(bytes.NewReader(doc)).Decode(&root)
+	return root, err
+}
+
+// decodeInterned decodes doc the way decodeXML does
+func decodeInterned(doc []byte) (XMLNode, error) {
+	return decodeNodes(xml.NewDecoder(bytes.NewReader(doc)), nil)
+}
+
+// TestDecodeElementMatchesReflection checks that the token decoder builds
+// the tree the reflection decoder did
+func TestDecodeElementMatchesReflection(t *testing.T) {
+	doc := benchmarkSheet(50)
+	want, err := decodeReflection(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeInterned(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Declaration = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Error("token decoder and reflection decoder built different trees")
+	}
+}
+
+// Measured on one CPU, go test -bench BenchmarkDecode -count 3, on the
+// 5,000-row sheet below (1.9 MB, 50,000 cells):
+//
+//	BenchmarkDecodeReflection   363-420 ms/op   81.2 MB/op   1.26M allocs/op
+//	BenchmarkDecodeInterned     192-253 ms/op   63.2 MB/op   0.88M allocs/op
+//
+// The same comparison on a 22 MB worksheet took 3.00 s, 936 MB and 13.7M
+// allocations with reflection against 2.13 s, 668 MB and 9.1M interned.
+
+// benchmarkDecode measures decode on a 5,000-row worksheet
+func benchmarkDecode(b *testing.B, decode func([]byte) (XMLNode, error)) {
+	doc := benchmarkSheet(5000)
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := decode(doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeReflection(b *testing.B) {
+	benchmarkDecode(b, decodeReflection)
+}
+
+func BenchmarkDecodeInterned(b *testing.B) {
+	benchmarkDecode(b, decodeInterned)
+}
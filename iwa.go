@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/golang/snappy"
+)
+
+func init() {
+	documentDecoders["iwa"] = decodeIWA
+	formatHandlers[".iwa"] = "iwa"
+}
+
+// maxIWASize bounds how many decompressed bytes an .iwa document may hold
+const maxIWASize = 1 << 30
+
+// maxProtoDepth bounds how deeply length-delimited fields are decoded as
+// nested messages
+const maxProtoDepth = 32
+
+// decodeIWA decodes an iWork archive (the Index/*.iwa entries of .key,
+// .pages and .numbers packages) into a node tree. Without Apple's message
+// schemas the tree is generic: an iwa root holding one archive element per
+// object with its identifier, one message element per protobuf message with
+// its type and version, and field elements named by number, whose
+// length-delimited values become text when they are printable strings and
+// nested fields when they parse as a message.
+func decodeIWA(r io.Reader, options map[string]string) (XMLNode, error) {
+	data, err := readIWAChunks(r)
+	if err != nil {
+		return XMLNode{}, err
+	}
+	if len(data) == 0 {
+		return XMLNode{}, errEmptyDocument
+	}
+	root := XMLNode{XMLName: xml.Name{Local: "iwa"}}
+	for len(data) > 0 {
+		n, size := binary.Uvarint(data)
+		if size <= 0 || n > uint64(len(data)-size) {
+			return XMLNode{}, fmt.Errorf("truncated archive header")
+		}
+		info, err := parseProto(data[size : size+int(n)])
+		if err != nil {
+			return XMLNode{}, fmt.Errorf("invalid archive header: %v", err)
+		}
+		data = data[size+int(n):]
+
+		archive := XMLNode{XMLName: xml.Name{Local: "archive"}}
+		for _, field := range info {
+			switch {
+			case field.number == 1 && field.wire == protoVarint:
+				archive.Attrs = append(archive.Attrs, xml.Attr{Name: xml.Name{Local: "identifier"}, Value: strconv.FormatUint(field.varint, 10)})
+			case field.number == 2 && field.wire == protoBytes:
+				message, length, err := iwaMessageInfo(field.bytes)
+				if err != nil {
+					return XMLNode{}, err
+				}
+				if length > uint64(len(data)) {
+					return XMLNode{}, fmt.Errorf("truncated message payload")
+				}
+				fields, err := parseProto(data[:length])
+				if err != nil {
+					return XMLNode{}, fmt.Errorf("invalid message payload: %v", err)
+				}
+				message.Nodes = protoNodes(fields, 0)
+				archive.Nodes = append(archive.Nodes, message)
+				data = data[length:]
+			}
+		}
+		root.Nodes = append(root.Nodes, archive)
+	}
+	return root, nil
+}
+
+// readIWAChunks decompresses the chunks of an .iwa stream, each a zero
+// byte, a 3-byte little-endian length and a Snappy block without the
+// framing format's checksums
+func readIWAChunks(r io.Reader) ([]byte, error) {
+	var data []byte
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return data, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("truncated chunk header: %v", err)
+		}
+		if header[0] != 0 {
+			return nil, fmt.Errorf("unknown chunk type %d", header[0])
+		}
+		block := make([]byte, int(header[1])|int(header[2])<<8|int(header[3])<<16)
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, fmt.Errorf("truncated chunk: %v", err)
+		}
+		n, err := snappy.DecodedLen(block)
+		if err != nil {
+			return nil, err
+		}
+		if len(data)+n > maxIWASize {
+			return nil, fmt.Errorf("archive is larger than %d bytes", maxIWASize)
+		}
+		chunk, err := snappy.Decode(nil, block)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+}
+
+// iwaMessageInfo builds the message element of a MessageInfo header and
+// returns the length of the payload it describes
+func iwaMessageInfo(b []byte) (XMLNode, uint64, error) {
+	fields, err := parseProto(b)
+	if err != nil {
+		return XMLNode{}, 0, fmt.Errorf("invalid message header: %v", err)
+	}
+	message := XMLNode{XMLName: xml.Name{Local: "message"}}
+	var length uint64
+	var version []string
+	for _, field := range fields {
+		switch field.number {
+		case 1:
+			message.Attrs = append(message.Attrs, xml.Attr{Name: xml.Name{Local: "type"}, Value: strconv.FormatUint(field.varint, 10)})
+		case 2:
+			for _, v := range field.varints() {
+				version = append(version, strconv.FormatUint(v, 10))
+			}
+		case 3:
+			length = field.varint
+		}
+	}
+	if len(version) > 0 {
+		message.Attrs = append(message.Attrs, xml.Attr{Name: xml.Name{Local: "version"}, Value: strings.Join(version, ".")})
+	}
+	return message, length, nil
+}
+
+// Protobuf wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// protoField is one field of an encoded protobuf message
+type protoField struct {
+	number uint64
+	wire   int
+	varint uint64 // value of varint and fixed fields
+	bytes  []byte // value of length-delimited fields
+}
+
+// varints returns the values of a varint field or of a packed repeated one
+func (f protoField) varints() []uint64 {
+	if f.wire != protoBytes {
+		return []uint64{f.varint}
+	}
+	var values []uint64
+	for b := f.bytes; len(b) > 0; {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			break
+		}
+		values = append(values, v)
+		b = b[n:]
+	}
+	return values
+}
+
+// parseProto splits an encoded protobuf message into its fields
+func parseProto(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid field key")
+		}
+		b = b[n:]
+		field := protoField{number: key >> 3, wire: int(key & 7)}
+		if field.number == 0 {
+			return nil, fmt.Errorf("invalid field number 0")
+		}
+		switch field.wire {
+		case protoVarint:
+			if field.varint, n = binary.Uvarint(b); n <= 0 {
+				return nil, fmt.Errorf("invalid varint in field %d", field.number)
+			}
+			b = b[n:]
+		case protoFixed64:
+			if len(b) < 8 {
+				return nil, fmt.Errorf("truncated field %d", field.number)
+			}
+			field.varint, b = binary.LittleEndian.Uint64(b), b[8:]
+		case protoFixed32:
+			if len(b) < 4 {
+				return nil, fmt.Errorf("truncated field %d", field.number)
+			}
+			field.varint, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case protoBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return nil, fmt.Errorf("truncated field %d", field.number)
+			}
+			field.bytes, b = b[n:n+int(length)], b[n+int(length):]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d in field %d", field.wire, field.number)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// protoNodes turns message fields into field elements with number and wire
+// attributes
+func protoNodes(fields []protoField, depth int) []XMLNode {
+	nodes := make([]XMLNode, 0, len(fields))
+	for _, field := range fields {
+		node := XMLNode{XMLName: xml.Name{Local: "field"}, Attrs: []xml.Attr{
+			{Name: xml.Name{Local: "number"}, Value: strconv.FormatUint(field.number, 10)},
+		}}
+		wire := "varint"
+		switch field.wire {
+		case protoVarint:
+			node.Content = strconv.FormatUint(field.varint, 10)
+		case protoFixed64:
+			wire, node.Content = "fixed64", strconv.FormatUint(field.varint, 10)
+		case protoFixed32:
+			wire, node.Content = "fixed32", strconv.FormatUint(field.varint, 10)
+		case protoBytes:
+			if isPrintable(field.bytes) {
+				wire, node.Content = "string", string(field.bytes)
+			} else if nested, err := parseProto(field.bytes); err == nil && depth < maxProtoDepth {
+				wire, node.Nodes = "message", protoNodes(nested, depth+1)
+			} else {
+				wire, node.Content = "bytes", hex.EncodeToString(field.bytes)
+			}
+		}
+		node.Attrs = append(node.Attrs, xml.Attr{Name: xml.Name{Local: "wire"}, Value: wire})
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// isPrintable reports whether b is non-empty UTF-8 text without control
+// characters other than whitespace
+func isPrintable(b []byte) bool {
+	if len(b) == 0 || !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
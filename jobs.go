@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// jobFileName holds the state of a job next to its input and output, so
+// results can still be fetched after the server restarts
+const jobFileName = "job.json"
+
+// jobDir is the directory holding everything a job keeps on disk
+func jobDir(j *job) string {
+	return filepath.Dir(j.OutputDir)
+}
+
+// savedJob is the content of a job file: the job as clients see it, plus
+// the paths that are kept from them
+type savedJob struct {
+	*job
+	Input     string `json:"input"`
+	OutputDir string `json:"output_dir"`
+}
+
+// save writes the state of j to its job file. The caller holds s.mu.
+func (s *jobServer) save(j *job) {
+	data, err := json.MarshalIndent(savedJob{j, j.Input, j.OutputDir}, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(jobDir(j), jobFileName), append(data, '\n'), 0644)
+	}
+	if err != nil {
+		log.Printf("Failed to save job %s: %v", j.ID, err)
+	}
+}
+
+// restore loads the jobs saved by an earlier run of the server. Jobs that
+// had not finished are queued again as long as the queue has room.
+func (s *jobServer) restore() error {
+	tenants := s.tenants
+	if tenants == nil {
+		tenants = []*Tenant{anonymousTenant}
+	}
+
+	var pending []*job
+	for _, t := range tenants {
+		tenantDir := filepath.Join(serveDir, t.Prefix)
+		entries, err := os.ReadDir(tenantDir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return wrapFSError("read", tenantDir, err)
+		}
+		for _, entry := range entries {
+			data, err := os.ReadFile(filepath.Join(tenantDir, entry.Name(), jobFileName))
+			if err != nil {
+				continue
+			}
+			saved := savedJob{job: new(job)}
+			if err := json.Unmarshal(data, &saved); err != nil {
+				log.Printf("Ignoring unreadable job %s: %v", entry.Name(), err)
+				continue
+			}
+			j := saved.job
+			j.Input, j.OutputDir, j.tenant = saved.Input, saved.OutputDir, t
+			s.jobs[j.ID] = j
+			if j.Status == jobQueued || j.Status == jobRunning {
+				pending = append(pending, j)
+			}
+		}
+	}
+
+	sort.Slice(pending, func(a, b int) bool { return pending[a].SubmittedAt.Before(pending[b].SubmittedAt) })
+	for _, j := range pending {
+		select {
+		case s.queue <- j:
+			j.Status, j.StartedAt = jobQueued, nil
+		default:
+			now := time.Now()
+			j.Status, j.Error, j.FinishedAt = jobFailed, "server restarted before the job ran", &now
+		}
+		s.save(j)
+	}
+	if len(s.jobs) > 0 {
+		log.Printf("Restored %d jobs, %d queued again", len(s.jobs), len(s.queue))
+	}
+	return nil
+}
+
+// list reports the jobs of the requesting tenant, oldest first
+func (s *jobServer) list(w http.ResponseWriter, r *http.Request) {
+	tenant := s.authenticate(r)
+	if tenant == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := []*job{}
+	for _, j := range s.jobs {
+		if j.tenant == tenant {
+			jobs = append(jobs, j)
+		}
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].SubmittedAt.Before(jobs[b].SubmittedAt) })
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// remove deletes a finished job or an abandoned upload and its files,
+// freeing the tenant's quota
+func (s *jobServer) remove(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	s.mu.Lock()
+	if j.Status == jobQueued || j.Status == jobRunning || j.writing {
+		s.mu.Unlock()
+		http.Error(w, "job is "+j.Status, http.StatusConflict)
+		return
+	}
+	delete(s.jobs, j.ID)
+	s.mu.Unlock()
+
+	if err := os.RemoveAll(jobDir(j)); err != nil {
+		http.Error(w, wrapFSError("remove", jobDir(j), err).Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listOutputs returns the files below dir, relative to it
+func listOutputs(dir string) []string {
+	var outputs []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if rel, err := filepath.Rel(dir, path); err == nil {
+				outputs = append(outputs, filepath.ToSlash(rel))
+			}
+		}
+		return nil
+	})
+	return outputs
+}
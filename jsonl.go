@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// jsonlElement is one element of a nested JSONL document
+type jsonlElement struct {
+	NodeID      int64             `json:"node_id"`
+	Tag         string            `json:"tag"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Text        string            `json:"text,omitempty"`
+	SelfClosing bool              `json:"self_closing,omitempty"`
+	Children    []*jsonlElement   `json:"children,omitempty"`
+}
+
+// jsonlDocument is one source document rendered as a single JSON line
+type jsonlDocument struct {
+	FilePath      string        `json:"file_path"`
+	ContainerPath string        `json:"container_path"`
+	EntryPath     string        `json:"entry_path,omitempty"`
+	Root          *jsonlElement `json:"root"`
+}
+
+// JSONLWriter rebuilds the element tree of each source document from the
+// row stream and writes it as one nested JSON object per line. A document
+// ends when the next root node row arrives.
+type JSONLWriter struct {
+	file     io.WriteCloser
+	buf      *bufio.Writer
+	doc      *jsonlDocument
+	elements map[int64]*jsonlElement
+	current  *jsonlElement
+}
+
+// NewJSONLWriter creates the JSONL file for fileName
+func NewJSONLWriter(fileName string) (*JSONLWriter, error) {
+	file, err := openOutputStream(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JSONL file %s: %v", fileName, err)
+	}
+	return &JSONLWriter{file: file, buf: bufio.NewWriter(file)}, nil
+}
+
+// Write adds a row to the document being rebuilt
+func (w *JSONLWriter) Write(row ParquetRow) error {
+	if row.IsNode {
+		parent, ok := w.elements[row.ParentNodeID]
+		if !ok || w.doc == nil || row.FilePath != w.doc.FilePath {
+			if err := w.flush(); err != nil {
+				return err
+			}
+			w.doc = &jsonlDocument{FilePath: row.FilePath, ContainerPath: row.ContainerPath, EntryPath: row.EntryPath}
+			w.elements = make(map[int64]*jsonlElement)
+			parent = nil
+		}
+
+		el := &jsonlElement{NodeID: row.NodeID, Tag: row.TagName, SelfClosing: row.SelfClosing}
+		w.elements[row.NodeID] = el
+		w.current = el
+		if parent != nil {
+			parent.Children = append(parent.Children, el)
+		} else {
+			w.doc.Root = el
+		}
+		return nil
+	}
+
+	if w.current == nil || w.current.NodeID != row.NodeID {
+		return fmt.Errorf("attribute row for node %d arrived without its element", row.NodeID)
+	}
+
+	switch {
+	case row.AttributeName == "":
+		w.current.Text = row.AttributeValue
+	case strings.HasPrefix(row.AttributeName, "xmlns:") && w.current.Namespace == "":
+		w.current.Namespace = row.AttributeValue
+	default:
+		if w.current.Attributes == nil {
+			w.current.Attributes = make(map[string]string)
+		}
+		w.current.Attributes[row.AttributeName] = row.AttributeValue
+	}
+	return nil
+}
+
+// WriteBatch adds rows to the documents being rebuilt
+func (w *JSONLWriter) WriteBatch(rows []ParquetRow) error {
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush writes the pending document, if any
+func (w *JSONLWriter) flush() error {
+	if w.doc == nil {
+		return nil
+	}
+	doc := w.doc
+	w.doc = nil
+	w.elements = nil
+	w.current = nil
+
+	if err := json.NewEncoder(w.buf).Encode(doc); err != nil {
+		return fmt.Errorf("failed to write JSONL document: %v", err)
+	}
+	return nil
+}
+
+// WriteStop writes the last document and closes the file
+func (w *JSONLWriter) WriteStop() error {
+	if err := w.flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to flush JSONL file: %v", err)
+	}
+	return w.file.Close()
+}
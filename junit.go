@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+func init() {
+	extractors["junit"] = junitExtractor{}
+}
+
+// JUnitCaseRow is one test case of a JUnit XML report in junit_cases.parquet
+type JUnitCaseRow struct {
+	FilePath  string  `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID    int64   `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	Suite     string  `parquet:"name=suite, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ClassName string  `parquet:"name=classname, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Name      string  `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Seconds   float64 `parquet:"name=seconds, type=DOUBLE"`
+	Status    string  `parquet:"name=status, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Message   string  `parquet:"name=message, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// junitExtractor lists the test cases of JUnit XML reports with their
+// outcome (passed, failure, error or skipped)
+type junitExtractor struct{}
+
+// Tables returns the junit_cases table
+func (junitExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{"junit_cases": new(JUnitCaseRow)}
+}
+
+// Detect accepts documents rooted at testsuites or testsuite
+func (junitExtractor) Detect(doc *Document) bool {
+	root := doc.Root.XMLName.Local
+	return root == "testsuites" || root == "testsuite"
+}
+
+// Extract writes one row per testcase element
+func (junitExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	var err error
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil || node.XMLName.Local != "testcase" {
+			return
+		}
+		row := JUnitCaseRow{
+			FilePath:  doc.FilePath,
+			NodeID:    nodeID,
+			ClassName: attrValue(node, "classname"),
+			Name:      attrValue(node, "name"),
+			Status:    "passed",
+		}
+		if parent != nil && parent.XMLName.Local == "testsuite" {
+			row.Suite = attrValue(parent, "name")
+		}
+		row.Seconds, _ = strconv.ParseFloat(attrValue(node, "time"), 64)
+		for i := range node.Nodes {
+			outcome := &node.Nodes[i]
+			switch outcome.XMLName.Local {
+			case "failure", "error", "skipped":
+				row.Status = outcome.XMLName.Local
+				row.Message = attrValue(outcome, "message")
+				if row.Message == "" {
+					row.Message = strings.TrimSpace(outcome.Content)
+				}
+			}
+		}
+		err = rows("junit_cases", row)
+	})
+	return err
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// LanguageRow is the detected language of one text node in the
+// languages.parquet sidecar, joined against combined.parquet on
+// (file_path, node_id). Language is an ISO 639-1 code, or "und" when the
+// text matches none of the known languages.
+type LanguageRow struct {
+	NodeID     int64   `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	FilePath   string  `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Language   string  `parquet:"name=language, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Confidence float64 `parquet:"name=confidence, type=DOUBLE"`
+	Characters int32   `parquet:"name=characters, type=INT32, convertedtype=INT_32"`
+}
+
+// LanguageTable writes detected languages to languages.parquet
+type LanguageTable struct {
+	table *ParquetTable
+}
+
+// languageTable is the optional sidecar enabled by --detect-language
+var languageTable *LanguageTable
+
+// languageMinChars is how many letters a text node needs before its
+// language is detected; shorter values are mostly codes and labels
+// (--language-min-chars)
+var languageMinChars = 40
+
+// NewLanguageTable creates the language sidecar at fileName
+func NewLanguageTable(fileName string) (*LanguageTable, error) {
+	table, err := NewParquetTable(fileName, new(LanguageRow))
+	if err != nil {
+		return nil, err
+	}
+	return &LanguageTable{table: table}, nil
+}
+
+// Add detects the language of a text node with enough letters and writes it
+func (lt *LanguageTable) Add(nodeID int64, relativePath, text string) error {
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < languageMinChars {
+		return nil
+	}
+	code, confidence := detectLanguage(text)
+	return lt.table.Write(LanguageRow{
+		NodeID:     nodeID,
+		FilePath:   relativePath,
+		Language:   code,
+		Confidence: confidence,
+		Characters: int32(letters),
+	})
+}
+
+// Close writes the sidecar footer and closes the file
+func (lt *LanguageTable) Close() error {
+	return lt.table.Close()
+}
+
+// languageScripts decides the language of text written mostly in a script
+// used by one language; Latin, Cyrillic and Arabic text is told apart by
+// its words
+var languageScripts = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Georgian, "ka"},
+	{unicode.Armenian, "hy"},
+}
+
+// languageStopwords are frequent short words of each language detected in
+// Latin, Cyrillic and Arabic script
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "in", "is", "that", "for", "it", "with", "as", "was", "on", "are", "this", "be", "by", "not"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "von", "zu", "sich", "auf", "ein", "eine", "für", "dem", "auch", "wird"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "du", "que", "pas", "pour", "dans", "qui", "sur", "au", "avec", "il", "sont"},
+	"es": {"el", "la", "los", "las", "y", "que", "en", "es", "por", "una", "con", "para", "del", "se", "como", "más", "pero", "su"},
+	"it": {"il", "di", "che", "la", "e", "per", "non", "una", "sono", "del", "della", "con", "gli", "nel", "anche", "come", "più", "è"},
+	"pt": {"o", "os", "que", "não", "uma", "com", "para", "do", "da", "em", "se", "por", "mais", "como", "dos", "são", "ao", "à"},
+	"nl": {"de", "het", "een", "en", "van", "is", "niet", "dat", "op", "te", "zijn", "met", "voor", "ook", "aan", "er", "maar", "wordt"},
+	"sv": {"och", "är", "att", "det", "som", "en", "på", "för", "med", "av", "inte", "den", "till", "har", "om", "ett", "var", "jag"},
+	"da": {"og", "er", "at", "det", "som", "en", "på", "for", "med", "af", "ikke", "den", "til", "har", "de", "et", "var", "jeg"},
+	"pl": {"i", "w", "nie", "na", "się", "to", "jest", "że", "z", "do", "jak", "ale", "o", "co", "tak", "dla", "od", "są"},
+	"cs": {"a", "je", "se", "na", "v", "to", "že", "s", "z", "do", "ale", "jak", "jsou", "by", "tak", "pro", "od", "také"},
+	"tr": {"ve", "bir", "bu", "da", "de", "için", "ile", "çok", "daha", "gibi", "ama", "olarak", "en", "var", "ne", "kadar", "sonra", "olan"},
+	"fi": {"ja", "on", "ei", "se", "että", "oli", "ole", "kun", "mutta", "niin", "myös", "ovat", "tai", "sen", "kuin", "hän", "vain", "jo"},
+	"id": {"yang", "dan", "di", "ini", "itu", "dengan", "untuk", "tidak", "dari", "dalam", "akan", "pada", "adalah", "ke", "juga", "atau", "ada", "oleh"},
+	"ru": {"и", "в", "не", "на", "что", "с", "он", "как", "это", "по", "но", "из", "к", "для", "от", "так", "его", "все"},
+	"uk": {"і", "в", "не", "на", "що", "з", "як", "це", "та", "до", "але", "для", "від", "так", "його", "й", "ми", "є"},
+	"bg": {"и", "в", "не", "на", "че", "се", "с", "да", "за", "от", "като", "това", "е", "по", "но", "са", "ще", "към"},
+	"ar": {"في", "من", "على", "أن", "إلى", "عن", "مع", "هذا", "التي", "الذي", "ما", "لا", "كان", "هذه", "بين", "كل", "قد", "ذلك"},
+	"fa": {"و", "در", "به", "از", "که", "این", "را", "با", "است", "برای", "آن", "یک", "تا", "می", "شود", "بر", "هم", "نیز"},
+}
+
+// languageIndex maps each stopword to the languages using it
+var languageIndex = func() map[string][]string {
+	index := make(map[string][]string)
+	for code, words := range languageStopwords {
+		for _, word := range words {
+			index[word] = append(index[word], code)
+		}
+	}
+	return index
+}()
+
+// detectLanguage returns the ISO 639-1 code of text and a confidence
+// between 0 and 1: the share of letters in the deciding script, or the
+// share of matched stopwords belonging to the chosen language
+func detectLanguage(text string) (string, float64) {
+	letters := 0
+	counts := make([]int, len(languageScripts))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for i, script := range languageScripts {
+			if unicode.Is(script.table, r) {
+				counts[i]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return "und", 0
+	}
+	// Japanese mixes kana with Han, so any substantial kana decides it
+	kana := counts[0] + counts[1]
+	if kana*10 >= letters {
+		return "ja", float64(kana+counts[3]) / float64(letters)
+	}
+	best := -1
+	for i := range languageScripts {
+		if best < 0 || counts[i] > counts[best] {
+			best = i
+		}
+	}
+	if counts[best]*2 >= letters {
+		return languageScripts[best].code, float64(counts[best]) / float64(letters)
+	}
+
+	scores := make(map[string]int)
+	matched := 0
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		codes := languageIndex[word]
+		if len(codes) > 0 {
+			matched++
+		}
+		for _, code := range codes {
+			scores[code]++
+		}
+	}
+	code, top := "und", 0
+	for c, score := range scores {
+		if score > top || score == top && c < code {
+			code, top = c, score
+		}
+	}
+	if top == 0 {
+		return "und", 0
+	}
+	return code, float64(top) / float64(matched)
+}
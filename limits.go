@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxFiles stops the run after this many documents (0 means no limit)
+var maxFiles int64
+
+// maxTotalRows stops the run once this many rows were written (0 means no limit)
+var maxTotalRows int64
+
+// deadline is how long the run may convert before it finalizes what it has
+// completed (0 means no deadline)
+var deadline time.Duration
+
+// exitDeadline is the exit status of a run cut short by --deadline
+const exitDeadline = 3
+
+// deadlineExceeded records that the run stopped because of --deadline
+var deadlineExceeded bool
+
+// errLimitReached is returned once --max-files, --max-total-rows or
+// --deadline trips.
+// The run stops before the next document and finalizes what it has written.
+var errLimitReached = errors.New("run limit reached")
+
+// checkLimits returns errLimitReached, noting the reason in the run report,
+// when another document would exceed the configured limits
+func checkLimits() error {
+	switch {
+	case deadline > 0 && time.Since(report.StartedAt) >= deadline:
+		deadlineExceeded = true
+		report.StoppedEarly = fmt.Sprintf("deadline %s reached", deadline)
+	case maxFiles > 0 && report.Files >= maxFiles:
+		report.StoppedEarly = fmt.Sprintf("max-files %d reached", maxFiles)
+	case maxTotalRows > 0 && report.Rows >= maxTotalRows:
+		report.StoppedEarly = fmt.Sprintf("max-total-rows %d reached after %d rows", maxTotalRows, report.Rows)
+	default:
+		return nil
+	}
+	return errLimitReached
+}
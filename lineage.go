@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// lineageFile writes an OpenLineage run event for each conversion to
+// lineage.json in the output directory (--lineage)
+var lineageFile bool
+
+// lineageURL receives each run event as a POST, e.g. Marquez's
+// /api/v1/lineage (--lineage-url)
+var lineageURL string
+
+// lineageNamespace is the OpenLineage namespace of the conversion job
+var lineageNamespace = "xmlgo"
+
+// lineageJob names the conversion job; "" uses the output directory's name
+var lineageJob string
+
+// lineageFileName is the run event written next to the outputs
+const lineageFileName = "lineage.json"
+
+// lineageProducer identifies xmlgo as the producer of events and facets
+const lineageProducer = "https://github.com/isaacnfairplay/xmlgo"
+
+// lineageEvent is an OpenLineage RunEvent
+type lineageEvent struct {
+	EventType string           `json:"eventType"`
+	EventTime string           `json:"eventTime"`
+	Run       lineageRun       `json:"run"`
+	Job       lineageJobRef    `json:"job"`
+	Inputs    []lineageDataset `json:"inputs"`
+	Outputs   []lineageDataset `json:"outputs"`
+	Producer  string           `json:"producer"`
+	SchemaURL string           `json:"schemaURL"`
+}
+
+// lineageRun identifies the run and carries its error on failure
+type lineageRun struct {
+	RunID  string                 `json:"runId"`
+	Facets map[string]interface{} `json:"facets,omitempty"`
+}
+
+// lineageJobRef names the job a run belongs to
+type lineageJobRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// lineageDataset is an input or output of a run
+type lineageDataset struct {
+	Namespace    string                 `json:"namespace"`
+	Name         string                 `json:"name"`
+	Facets       map[string]interface{} `json:"facets,omitempty"`
+	OutputFacets map[string]interface{} `json:"outputFacets,omitempty"`
+}
+
+// lineageField is a column of a dataset's schema facet
+type lineageField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// lineageColumnSources describes which part of the XML input each column
+// of the combined output comes from, for its column lineage facet
+var lineageColumnSources = map[string]string{
+	"node_id":         "element position in document order",
+	"parent_node_id":  "parent element",
+	"tag_name":        "element name",
+	"attribute_name":  "attribute name or namespace declaration",
+	"attribute_value": "attribute value, namespace URI or element text",
+	"is_node":         "element or attribute",
+	"is_root":         "document root element",
+	"tag_id":          "element name and namespace",
+	"attribute_id":    "attribute name and namespace",
+	"file_path":       "document path",
+	"container_path":  "container path",
+	"entry_path":      "container entry path",
+	"self_closing":    "empty-element tag",
+}
+
+// lineageEnabled reports whether run events are written or sent
+func lineageEnabled() bool {
+	return lineageFile || lineageURL != ""
+}
+
+// emitLineage writes and sends the run event of the conversion of input
+// into outputDir that just finished with err
+func emitLineage(input, outputDir string, err error) error {
+	event, buildErr := newLineageEvent(input, outputDir, err)
+	if buildErr != nil {
+		return buildErr
+	}
+	body, jsonErr := json.MarshalIndent(event, "", "  ")
+	if jsonErr != nil {
+		return fmt.Errorf("failed to encode lineage event: %v", jsonErr)
+	}
+	if lineageFile {
+		fileName := filepath.Join(outputDir, withRunSuffix(lineageFileName))
+		if err := os.WriteFile(fileName, append(body, '\n'), 0644); err != nil {
+			return wrapFSError("write lineage event", fileName, err)
+		}
+	}
+	if lineageURL != "" {
+		return postLineage(lineageURL, body)
+	}
+	return nil
+}
+
+// newLineageEvent describes the run: its input, and on success every table
+// it wrote with the table's schema and row count
+func newLineageEvent(input, outputDir string, err error) (*lineageEvent, error) {
+	runID, idErr := newRunUUID()
+	if idErr != nil {
+		return nil, idErr
+	}
+	job := lineageJob
+	if job == "" {
+		job = filepath.Base(outputDir)
+	}
+	event := &lineageEvent{
+		EventType: "COMPLETE",
+		EventTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Run:       lineageRun{RunID: runID},
+		Job:       lineageJobRef{Namespace: lineageNamespace, Name: job},
+		Inputs:    []lineageDataset{{Namespace: "file", Name: lineagePath(input)}},
+		Outputs:   []lineageDataset{},
+		Producer:  lineageProducer,
+		SchemaURL: "https://openlineage.io/spec/2-0-2/OpenLineage.json#/definitions/RunEvent",
+	}
+	if err != nil {
+		event.EventType = "FAIL"
+		event.Run.Facets = map[string]interface{}{
+			"errorMessage": map[string]interface{}{
+				"_producer":           lineageProducer,
+				"_schemaURL":          "https://openlineage.io/spec/facets/1-0-1/ErrorMessageRunFacet.json",
+				"message":             err.Error(),
+				"programmingLanguage": "go",
+			},
+		}
+		return event, nil
+	}
+
+	for _, output := range listOutputs(outputDir) {
+		ext := filepath.Ext(output)
+		if ext != ".parquet" && ext != ".jsonl" && ext != ".ndjson" {
+			continue
+		}
+		dataset := lineageDataset{Namespace: "file", Name: lineagePath(filepath.Join(outputDir, output))}
+		if ext == ".parquet" {
+			fields, rows, err := parquetSchemaFields(filepath.Join(outputDir, output))
+			if err != nil {
+				return nil, err
+			}
+			dataset.Facets = map[string]interface{}{
+				"schema": map[string]interface{}{
+					"_producer":  lineageProducer,
+					"_schemaURL": "https://openlineage.io/spec/facets/1-1-1/SchemaDatasetFacet.json",
+					"fields":     fields,
+				},
+			}
+			if strings.HasPrefix(filepath.Base(output), "combined") {
+				dataset.Facets["columnLineage"] = columnLineageFacet(event.Inputs[0], fields)
+			}
+			dataset.OutputFacets = map[string]interface{}{
+				"outputStatistics": map[string]interface{}{
+					"_producer":  lineageProducer,
+					"_schemaURL": "https://openlineage.io/spec/facets/1-0-2/OutputStatisticsOutputDatasetFacet.json",
+					"rowCount":   rows,
+				},
+			}
+		}
+		event.Outputs = append(event.Outputs, dataset)
+	}
+	return event, nil
+}
+
+// columnLineageFacet traces each column of the combined output to the part
+// of the XML input it comes from
+func columnLineageFacet(input lineageDataset, fields []lineageField) map[string]interface{} {
+	columns := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		source, ok := lineageColumnSources[field.Name]
+		if !ok {
+			continue
+		}
+		columns[field.Name] = map[string]interface{}{
+			"inputFields":               []map[string]string{{"namespace": input.Namespace, "name": input.Name, "field": source}},
+			"transformationDescription": "flattened from " + source,
+			"transformationType":        "IDENTITY",
+		}
+	}
+	return map[string]interface{}{
+		"_producer":  lineageProducer,
+		"_schemaURL": "https://openlineage.io/spec/facets/1-2-0/ColumnLineageDatasetFacet.json",
+		"fields":     columns,
+	}
+}
+
+// parquetSchemaFields reads the columns and row count of a Parquet file
+// from its footer
+func parquetSchemaFields(fileName string) ([]lineageField, int64, error) {
+	fr, err := local.NewLocalFileReader(fileName)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open %s: %v", fileName, err)
+	}
+	defer fr.Close()
+	pr, err := reader.NewParquetColumnReader(fr, 1)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read footer of %s: %v", fileName, err)
+	}
+	defer pr.ReadStop()
+
+	// the reader renames the footer's columns to Go names; Infos keeps the
+	// names as written
+	var fields []lineageField
+	for i, element := range pr.Footer.Schema[1:] {
+		field := lineageField{Name: pr.SchemaHandler.Infos[i+1].ExName}
+		switch {
+		case element.ConvertedType != nil && *element.ConvertedType == parquet.ConvertedType_UTF8:
+			field.Type = "STRING"
+		case element.ConvertedType != nil && *element.ConvertedType == parquet.ConvertedType_TIMESTAMP_MICROS:
+			field.Type = "TIMESTAMP"
+		case element.Type != nil:
+			field.Type = element.Type.String()
+		}
+		fields = append(fields, field)
+	}
+	return fields, pr.GetNumRows(), nil
+}
+
+// lineagePath is the dataset name of a local path: absolute, with forward
+// slashes
+func lineagePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return filepath.ToSlash(path)
+}
+
+// newRunUUID returns a random (version 4) UUID for an OpenLineage run
+func newRunUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate run id: %v", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// postLineage sends a run event to an OpenLineage endpoint, retrying
+// failed deliveries like webhooks
+func postLineage(endpoint string, body []byte) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var err error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * time.Second)
+		}
+		var resp *http.Response
+		resp, err = client.Post(endpoint, "application/json", bytes.NewReader(body))
+		if err != nil {
+			err = fmt.Errorf("failed to send lineage event to %s: %v", endpoint, err)
+			continue
+		}
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			return nil
+		}
+		err = fmt.Errorf("lineage endpoint %s rejected the event: %s: %s", endpoint, resp.Status, strings.TrimSpace(string(reply)))
+	}
+	return err
+}
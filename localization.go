@@ -0,0 +1,173 @@
+package main
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	extractors["localization"] = localizationExtractor{}
+}
+
+// LocalizationRow is one translatable string of a .resx file or an Android
+// values resource in localization_strings.parquet. resource names the set
+// of files translating the same keys (the .resx path without its culture,
+// the res directory of Android values), so translation coverage is a group
+// by resource and key. language is "" for the neutral or default file.
+type LocalizationRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Format        string `parquet:"name=format, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Resource      string `parquet:"name=resource, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Key           string `parquet:"name=key, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Language      string `parquet:"name=language, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Value         string `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Comment       string `parquet:"name=comment, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Translatable  bool   `parquet:"name=translatable, type=BOOLEAN"`
+}
+
+// localizationExtractor lists the strings of .NET resource files and
+// Android string resources. Plurals and string arrays become one key per
+// quantity or item (name[one], name[0]). Markup inside an Android string
+// (<b>, xliff:g placeholders) is flattened, its text following the text of
+// the string itself.
+type localizationExtractor struct{}
+
+// Tables returns the localization_strings table
+func (localizationExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{"localization_strings": new(LocalizationRow)}
+}
+
+// Detect accepts .resx files and the resources files of Android values
+// directories
+func (localizationExtractor) Detect(doc *Document) bool {
+	name := doc.name()
+	switch doc.Root.XMLName.Local {
+	case "root":
+		return strings.EqualFold(path.Ext(name), ".resx")
+	case "resources":
+		return strings.HasPrefix(path.Base(path.Dir(name)), "values")
+	}
+	return false
+}
+
+// Extract writes one row per string
+func (localizationExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	name := doc.name()
+	row := LocalizationRow{FilePath: doc.FilePath, ContainerPath: doc.ContainerPath, Translatable: true}
+	if doc.Root.XMLName.Local == "root" {
+		row.Format = "resx"
+		row.Resource, row.Language = resxCulture(name)
+	} else {
+		row.Format = "android"
+		row.Resource, row.Language = path.Dir(path.Dir(name)), androidLanguage(path.Base(path.Dir(name)))
+	}
+
+	var err error
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil {
+			return
+		}
+		r := row
+		r.NodeID = nodeID
+		switch {
+		case row.Format == "resx" && parent == doc.Root && node.XMLName.Local == "data":
+			// Typed resources (images, files) are not strings
+			if attrValue(node, "type") != "" || attrValue(node, "mimetype") != "" {
+				return
+			}
+			r.Key = attrValue(node, "name")
+			if value := childElement(node, "value"); value != nil {
+				r.Value = value.Content
+			}
+			if comment := childElement(node, "comment"); comment != nil {
+				r.Comment = comment.Content
+			}
+		case row.Format == "android" && parent == doc.Root && node.XMLName.Local == "string":
+			r.Key = attrValue(node, "name")
+			r.Value = androidString(node)
+			r.Translatable = attrValue(node, "translatable") != "false"
+		case row.Format == "android" && parent != nil && parent.XMLName.Local == "plurals" && node.XMLName.Local == "item":
+			r.Key = attrValue(parent, "name") + "[" + attrValue(node, "quantity") + "]"
+			r.Value = androidString(node)
+			r.Translatable = attrValue(parent, "translatable") != "false"
+		case row.Format == "android" && parent != nil && (parent.XMLName.Local == "string-array" || parent.XMLName.Local == "array") && node.XMLName.Local == "item":
+			index := 0
+			for i := range parent.Nodes {
+				if &parent.Nodes[i] == node {
+					break
+				}
+				if parent.Nodes[i].XMLName.Local == "item" {
+					index++
+				}
+			}
+			r.Key = attrValue(parent, "name") + "[" + strconv.Itoa(index) + "]"
+			r.Value = androidString(node)
+			r.Translatable = attrValue(parent, "translatable") != "false"
+		default:
+			return
+		}
+		err = rows("localization_strings", r)
+	})
+	return err
+}
+
+// cultureName matches .NET culture names such as fr, fr-FR and zh-Hans
+var cultureName = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// resxCulture splits Strings.fr-FR.resx into its resource (Strings) and
+// culture (fr-FR)
+func resxCulture(name string) (resource, language string) {
+	resource = strings.TrimSuffix(name, path.Ext(name))
+	if i := strings.LastIndexByte(resource, '.'); i > strings.LastIndexByte(resource, '/') && cultureName.MatchString(resource[i+1:]) {
+		return resource[:i], resource[i+1:]
+	}
+	return resource, ""
+}
+
+// androidLanguage reads the language of a values directory from its
+// qualifiers: values-fr-rCA is fr-CA and values-b+sr+Latn is sr-Latn.
+// Other qualifiers (night, v21, sw600dp) are ignored.
+func androidLanguage(dir string) string {
+	qualifiers := strings.Split(dir, "-")[1:]
+	for i, q := range qualifiers {
+		if strings.HasPrefix(q, "b+") {
+			return strings.ReplaceAll(q[2:], "+", "-")
+		}
+		if len(q) < 2 || len(q) > 3 || strings.ToLower(q) != q || !cultureName.MatchString(q) {
+			continue
+		}
+		if i+1 < len(qualifiers) {
+			if region := qualifiers[i+1]; len(region) == 3 && region[0] == 'r' {
+				return q + "-" + region[1:]
+			}
+		}
+		return q
+	}
+	return ""
+}
+
+// androidEscapes are the backslash escapes of Android string resources
+var androidEscapes = strings.NewReplacer(`\'`, `'`, `\"`, `"`, `\n`, "\n", `\t`, "\t", `\@`, "@", `\?`, "?", `\\`, `\`)
+
+// androidString returns the text of a string resource with its quoting
+// and escapes resolved
+func androidString(node *XMLNode) string {
+	var b strings.Builder
+	var collect func(n *XMLNode)
+	collect = func(n *XMLNode) {
+		b.WriteString(n.Content)
+		for i := range n.Nodes {
+			collect(&n.Nodes[i])
+		}
+	}
+	collect(node)
+	s := strings.TrimSpace(b.String())
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	return androidEscapes.Replace(s)
+}
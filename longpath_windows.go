@@ -0,0 +1,27 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxShortPath is the length beyond which Win32 APIs need the extended-length
+// prefix; directories are limited to 248 characters rather than MAX_PATH
+const maxShortPath = 248
+
+// longPath returns path in extended-length form (\\?\C:\... or
+// \\?\UNC\server\share\...) when it is too long for the legacy Win32 limit,
+// so deeply nested ZIP entries can be created and opened on Windows.
+func longPath(path string) string {
+	if len(path) < maxShortPath || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + strings.TrimPrefix(abs, `\\`)
+	}
+	return `\\?\` + abs
+}
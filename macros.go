@@ -0,0 +1,232 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// vbaModules lists the module names of VBA projects in the files table
+// (--vba-modules)
+var vbaModules bool
+
+// maxVBAProjectSize bounds how much of a vbaProject.bin is read to list its
+// modules
+const maxVBAProjectSize = 64 << 20
+
+// containerMacros is what a container holds that can run code when opened
+type containerMacros struct {
+	vbaProject *zip.File
+	activeX    bool
+}
+
+// scanMacros looks for the VBA project and ActiveX control parts of a
+// container's entries
+func scanMacros(files []*zip.File) containerMacros {
+	var m containerMacros
+	for _, f := range files {
+		name := strings.ToLower(f.Name)
+		switch {
+		case path.Base(name) == "vbaproject.bin":
+			m.vbaProject = f
+		case strings.Contains(name, "/activex/"):
+			m.activeX = true
+		}
+	}
+	return m
+}
+
+// recordContainer adds the files table entry of a container, flagging the
+// VBA projects and ActiveX controls it holds. Its entries are listed
+// separately, so the row has skip reason "container".
+func recordContainer(zipFile, containerPath string, files []*zip.File) error {
+	if filesTable == nil || retryFilter != nil {
+		return nil
+	}
+	info, err := os.Stat(zipFile)
+	if err != nil {
+		return wrapFSError("stat ZIP file", zipFile, err)
+	}
+	m := scanMacros(files)
+	reason := skipContainer
+	hasVBA := m.vbaProject != nil
+	row := FileRow{FilePath: containerPath, Bytes: info.Size(), SkipReason: &reason, HasVBA: &hasVBA, HasActiveX: &m.activeX}
+	if hasVBA && vbaModules {
+		names, err := readVBAModules(m.vbaProject)
+		if err != nil {
+			return fmt.Errorf("failed to read VBA modules of %s: %v", zipFile, err)
+		}
+		list := strings.Join(names, ",")
+		row.VBAModules = &list
+	}
+	return recordFileRow(row)
+}
+
+// readVBAModules returns the sorted module names of a vbaProject.bin entry
+func readVBAModules(f *zip.File) ([]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxVBAProjectSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxVBAProjectSize {
+		return nil, fmt.Errorf("project is larger than %d bytes", maxVBAProjectSize)
+	}
+	streams, err := cfbStreams(data, "VBA")
+	if err != nil {
+		return nil, err
+	}
+	var modules []string
+	for _, name := range streams {
+		// The VBA storage also holds the project's own streams
+		if name == "dir" || strings.EqualFold(name, "_VBA_PROJECT") || strings.HasPrefix(name, "__SRP_") {
+			continue
+		}
+		modules = append(modules, name)
+	}
+	sort.Strings(modules)
+	return modules, nil
+}
+
+// cfbSignature starts every OLE compound file
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Special sector numbers and directory entry values of a compound file
+const (
+	cfbEndOfChain = 0xFFFFFFFE
+	cfbNoStream   = 0xFFFFFFFF
+	cfbStorage    = 1
+	cfbStream     = 2
+)
+
+// cfbEntry is one 128-byte directory entry of a compound file
+type cfbEntry struct {
+	name               string
+	kind               byte
+	left, right, child uint32
+}
+
+// cfbStreams returns the names of the streams directly inside the storage
+// named storage of an OLE compound file. Stream contents are not read, so
+// only the FAT and the directory are followed.
+func cfbStreams(data []byte, storage string) ([]string, error) {
+	if len(data) < 512 || !bytes.Equal(data[:8], cfbSignature) {
+		return nil, fmt.Errorf("not an OLE compound file")
+	}
+	shift := binary.LittleEndian.Uint16(data[0x1E:])
+	if shift != 9 && shift != 12 {
+		return nil, fmt.Errorf("unsupported sector size 2^%d", shift)
+	}
+	sectorSize := 1 << shift
+	sector := func(n uint32) []byte {
+		start := (int64(n) + 1) << shift
+		if n >= cfbEndOfChain-1 || start+int64(sectorSize) > int64(len(data)) {
+			return nil
+		}
+		return data[start : start+int64(sectorSize)]
+	}
+
+	// The FAT sectors are listed in the header and then in the DIFAT chain
+	var fatSectors []uint32
+	for i := 0; i < 109; i++ {
+		fatSectors = append(fatSectors, binary.LittleEndian.Uint32(data[0x4C+4*i:]))
+	}
+	difat := binary.LittleEndian.Uint32(data[0x44:])
+	for seen := 0; difat < cfbEndOfChain-1 && seen < len(data)/sectorSize; seen++ {
+		s := sector(difat)
+		if s == nil {
+			return nil, fmt.Errorf("DIFAT sector %d is out of range", difat)
+		}
+		for i := 0; i < sectorSize/4-1; i++ {
+			fatSectors = append(fatSectors, binary.LittleEndian.Uint32(s[4*i:]))
+		}
+		difat = binary.LittleEndian.Uint32(s[sectorSize-4:])
+	}
+	numFAT := int(binary.LittleEndian.Uint32(data[0x2C:]))
+	if numFAT > len(fatSectors) {
+		return nil, fmt.Errorf("FAT has %d sectors but only %d are listed", numFAT, len(fatSectors))
+	}
+	var fat []uint32
+	for _, n := range fatSectors[:numFAT] {
+		s := sector(n)
+		if s == nil {
+			return nil, fmt.Errorf("FAT sector %d is out of range", n)
+		}
+		for i := 0; i < sectorSize; i += 4 {
+			fat = append(fat, binary.LittleEndian.Uint32(s[i:]))
+		}
+	}
+
+	// Follow the directory chain, guarding against loops
+	var entries []cfbEntry
+	for n, steps := binary.LittleEndian.Uint32(data[0x30:]), 0; n != cfbEndOfChain; steps++ {
+		s := sector(n)
+		if s == nil || steps > len(fat) {
+			return nil, fmt.Errorf("directory sector %d is out of range", n)
+		}
+		for i := 0; i+128 <= sectorSize; i += 128 {
+			entries = append(entries, parseCFBEntry(s[i:i+128]))
+		}
+		if int(n) >= len(fat) {
+			return nil, fmt.Errorf("directory sector %d is not in the FAT", n)
+		}
+		n = fat[n]
+	}
+
+	for _, e := range entries {
+		if e.kind != cfbStorage || !strings.EqualFold(e.name, storage) {
+			continue
+		}
+		// A storage's children form a tree linked by left and right
+		var names []string
+		visited := make(map[uint32]bool)
+		var visit func(id uint32)
+		visit = func(id uint32) {
+			if id == cfbNoStream || int(id) >= len(entries) || visited[id] {
+				return
+			}
+			visited[id] = true
+			child := entries[id]
+			if child.kind == cfbStream {
+				names = append(names, child.name)
+			}
+			visit(child.left)
+			visit(child.right)
+		}
+		visit(e.child)
+		return names, nil
+	}
+	return nil, fmt.Errorf("no %s storage", storage)
+}
+
+// parseCFBEntry decodes a directory entry
+func parseCFBEntry(b []byte) cfbEntry {
+	nameLen := int(binary.LittleEndian.Uint16(b[64:]))
+	if nameLen > 64 {
+		nameLen = 64
+	}
+	units := make([]uint16, 0, nameLen/2)
+	for i := 0; i+1 < nameLen; i += 2 {
+		if u := binary.LittleEndian.Uint16(b[i:]); u != 0 {
+			units = append(units, u)
+		}
+	}
+	return cfbEntry{
+		name:  string(utf16.Decode(units)),
+		kind:  b[66],
+		left:  binary.LittleEndian.Uint32(b[68:]),
+		right: binary.LittleEndian.Uint32(b[72:]),
+		child: binary.LittleEndian.Uint32(b[76:]),
+	}
+}
@@ -9,22 +9,38 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/xitongsys/parquet-go-source/local"
-	"github.com/xitongsys/parquet-go/parquet"
-	"github.com/xitongsys/parquet-go/writer"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// ParquetRow represents a single row in the combined Parquet file
+// ParquetRow represents a single row in the combined Parquet file. Absent
+// values are 0 and ""; --nulls decides how they are written (see nulls.go).
+//
+// ContainerPath is the provenance of the top-level input a row was read
+// from, the archive for container entries, and EntryPath the entry's name
+// inside that archive ("" for standalone documents). Unlike FilePath they do
+// not depend on where entries would be extracted.
+//
+// Node IDs start at 1, so a ParentNodeID of 0 never refers to a node: it
+// marks a document root on node rows and is unset on attribute rows. The
+// Parquet output also carries an explicit is_root column.
 type ParquetRow struct {
-	NodeID         int64  `parquet:"name=node_id, type=INT64"`
-	ParentNodeID   int64  `parquet:"name=parent_node_id, type=INT64, repetitiontype=OPTIONAL"`
-	TagName        string `parquet:"name=tag_name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL"`
-	AttributeName  string `parquet:"name=attribute_name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL"`
-	AttributeValue string `parquet:"name=attribute_value, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL"`
-	IsNode         bool   `parquet:"name=is_node, type=BOOLEAN"`
-	FilePath       string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID         int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64" json:"node_id"`
+	ParentNodeID   int64  `parquet:"name=parent_node_id, type=INT64, convertedtype=INT_64, repetitiontype=OPTIONAL" json:"parent_node_id"`
+	TagName        string `parquet:"name=tag_name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL" json:"tag_name"`
+	AttributeName  string `parquet:"name=attribute_name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL" json:"attribute_name"`
+	AttributeValue string `parquet:"name=attribute_value, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL" json:"attribute_value"`
+	IsNode         bool   `parquet:"name=is_node, type=BOOLEAN" json:"is_node"`
+	TagID          int32  `parquet:"name=tag_id, type=INT32, convertedtype=INT_32, repetitiontype=OPTIONAL" json:"tag_id,omitempty"`
+	AttributeID    int32  `parquet:"name=attribute_id, type=INT32, convertedtype=INT_32, repetitiontype=OPTIONAL" json:"attribute_id,omitempty"`
+	FilePath       string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY" json:"file_path"`
+	ContainerPath  string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY" json:"container_path"`
+	EntryPath      string `parquet:"name=entry_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL" json:"entry_path,omitempty"`
+	SelfClosing    bool   `parquet:"name=self_closing, type=BOOLEAN, repetitiontype=OPTIONAL" json:"self_closing,omitempty"`
 }
 
 // XMLNode is used to decode the XML structure
@@ -33,14 +49,20 @@ type XMLNode struct {
 	Content string     `xml:",chardata"`
 	Attrs   []xml.Attr `xml:",any,attr"`
 	Nodes   []XMLNode  `xml:",any"`
+	// Declaration is the XML declaration of a root element's document
+	Declaration *XMLDeclaration `xml:"-"`
+	// SelfClosing is set for elements written as <a/> with --self-closing
+	SelfClosing bool `xml:"-"`
 }
 
-var nodeIDCounter int64 = 1
+// parseXMLNode processes each XML node and writes the data to the row writer
+func parseXMLNode(node XMLNode, parentNodeID int64, rowWriter RowWriter, relativePath string, ids *idBlock) int64 {
+	nodeID := ids.take()
 
-// parseXMLNode processes each XML node and writes the data to a Parquet file
-func parseXMLNode(node XMLNode, parentNodeID int64, parquetWriter *writer.ParquetWriter, relativePath string) int64 {
-	nodeID := nodeIDCounter
-	nodeIDCounter++
+	tagID, err := tagDictionary.id(node.XMLName.Local, node.XMLName.Space)
+	if err != nil {
+		log.Fatalf("Failed to record tag: %v", err)
+	}
 
 	// Write the node itself
 	row := ParquetRow{
@@ -48,29 +70,36 @@ func parseXMLNode(node XMLNode, parentNodeID int64, parquetWriter *writer.Parque
 		ParentNodeID: parentNodeID,
 		TagName:      node.XMLName.Local,
 		IsNode:       true,
+		TagID:        tagID,
 		FilePath:     relativePath,
+		SelfClosing:  node.SelfClosing,
 	}
-	if err := parquetWriter.Write(row); err != nil {
+	if err := rowWriter.Write(row); err != nil {
 		log.Fatalf("Failed to write node: %v", err)
 	}
 
 	// Add the namespace as an attribute if present
 	if node.XMLName.Space != "" {
+		attrID, err := attributeDictionary.id("xmlns:"+node.XMLName.Space, "")
+		if err != nil {
+			log.Fatalf("Failed to record attribute: %v", err)
+		}
 		row := ParquetRow{
 			NodeID:         nodeID,
 			AttributeName:  "xmlns:" + node.XMLName.Space,
 			AttributeValue: node.XMLName.Space,
 			IsNode:         false,
+			AttributeID:    attrID,
 			FilePath:       relativePath,
 		}
-		if err := parquetWriter.Write(row); err != nil {
+		if err := rowWriter.Write(row); err != nil {
 			log.Fatalf("Failed to write attribute: %v", err)
 		}
 	}
 
 	// Write the content as an attribute (if there's content)
 	if node.Content != "" {
-		trimmedContent := strings.TrimSpace(node.Content)
+		trimmedContent := normalizeText(strings.TrimSpace(node.Content))
 		if trimmedContent != "" {
 			row := ParquetRow{
 				NodeID:         nodeID,
@@ -78,176 +107,488 @@ func parseXMLNode(node XMLNode, parentNodeID int64, parquetWriter *writer.Parque
 				IsNode:         false,
 				FilePath:       relativePath,
 			}
-			if err := parquetWriter.Write(row); err != nil {
+			if err := rowWriter.Write(row); err != nil {
 				log.Fatalf("Failed to write attribute: %v", err)
 			}
+			if textIndex != nil {
+				if err := textIndex.Add(nodeID, relativePath, "", trimmedContent); err != nil {
+					log.Fatalf("Failed to index content: %v", err)
+				}
+			}
+			if languageTable != nil {
+				if err := languageTable.Add(nodeID, relativePath, trimmedContent); err != nil {
+					log.Fatalf("Failed to record language: %v", err)
+				}
+			}
 		}
 	}
 
 	// Write the other attributes
 	for _, attr := range node.Attrs {
+		attrID, err := attributeDictionary.id(attr.Name.Local, attr.Name.Space)
+		if err != nil {
+			log.Fatalf("Failed to record attribute: %v", err)
+		}
+		value := normalizeText(attr.Value)
 		row := ParquetRow{
 			NodeID:         nodeID,
 			AttributeName:  attr.Name.Local,
-			AttributeValue: attr.Value,
+			AttributeValue: value,
 			IsNode:         false,
+			AttributeID:    attrID,
 			FilePath:       relativePath,
 		}
-		if err := parquetWriter.Write(row); err != nil {
+		if err := rowWriter.Write(row); err != nil {
 			log.Fatalf("Failed to write attribute: %v", err)
 		}
+		if textIndex != nil {
+			if err := textIndex.Add(nodeID, relativePath, attr.Name.Local, value); err != nil {
+				log.Fatalf("Failed to index attribute: %v", err)
+			}
+		}
 	}
 
 	// Recursively process child nodes
 	for _, childNode := range node.Nodes {
-		parseXMLNode(childNode, nodeID, parquetWriter, relativePath)
+		parseXMLNode(childNode, nodeID, rowWriter, relativePath, ids)
 	}
 
 	return nodeID
 }
 
-// processXMLFile processes a single XML file and writes its data to the Parquet writer
-func processXMLFile(fileName string, relativePath string, parquetWriter *writer.ParquetWriter) error {
+// processXMLFile decodes a single document with its handler and writes its
+// data to the row writer
+func processXMLFile(fileName string, relativePath string, h fileHandler, rowWriter RowWriter) error {
+	if retrySkip(fileName, relativePath) {
+		return nil
+	}
+	if checkpoints.skip(relativePath, "") {
+		return recordSkip(relativePath, 0, skipResumed)
+	}
+
 	file, err := os.Open(fileName)
 	if err != nil {
-		return fmt.Errorf("failed to open XML file %s: %v", fileName, err)
+		if skipUnreadable {
+			log.Printf("Skipping unreadable file %s: %v", fileName, err)
+			return recordSkip(relativePath, 0, unreadableReason(err))
+		}
+		return wrapFSError("open XML file", fileName, err)
 	}
 	defer file.Close()
 
-	decoder := xml.NewDecoder(file)
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	if maxFileSize > 0 && size > maxFileSize {
+		return recordSkip(relativePath, size, skipTooLarge)
+	}
+
+	start := time.Now()
+	root, err := h.decode(file)
+	if err == errEmptyDocument {
+		err = handleEmptyDocument(docSource{path: relativePath, container: relativePath}, size, nodeIDs.reserve(0), time.Since(start), rowWriter)
+	}
+	if err != nil {
+		return recordFailure(fileName, relativePath, fmt.Errorf("failed to decode %s file %s: %v", strings.ToUpper(h.Handler), fileName, err))
+	}
+	if root.XMLName.Local == "" {
+		return nil // Empty document handled by --empty-parts
+	}
+
+	ids := nodeIDs.reserve(countNodes(root))
+	return writeDocument(root, ids, docSource{path: relativePath, container: relativePath}, size, time.Since(start), rowWriter)
+}
 
-	var root XMLNode
-	if err := decoder.Decode(&root); err != nil {
-		return fmt.Errorf("failed to decode XML file %s: %v", fileName, err)
+// writeDocument writes the rows of a decoded document using its reserved ID
+// block and records it in the files table. decodeTime is added to the time
+// spent writing rows so the files table reports the full cost of the document.
+func writeDocument(root XMLNode, ids *idBlock, src docSource, size int64, decodeTime time.Duration, rowWriter RowWriter) (err error) {
+	if err := checkLimits(); err != nil {
+		return err
 	}
+	relativePath := src.path
 
-	// Parse the XML and write to Parquet
-	parseXMLNode(root, 0, parquetWriter, relativePath)
+	_, span := tracer.Start(fileCtx, "xmlgo.write", trace.WithAttributes(pathAttr(relativePath)))
+	defer func() { endSpan(span, err) }()
 
-	return nil
+	start := time.Now()
+
+	// Parse the XML and write the rows
+	counter := &countingWriter{next: rowWriter, src: src, start: start}
+	parseXMLNode(root, 0, counter, relativePath, ids)
+
+	report.recordFile(counter.rows)
+	span.SetAttributes(rowsAttr(counter.rows))
+
+	doc := &Document{FilePath: relativePath, ContainerPath: src.container, EntryPath: src.entry, Root: &root, FirstNodeID: ids.first, parts: src.parts}
+	if err := runExtractors(doc); err != nil {
+		return err
+	}
+
+	if filesTable != nil {
+		elapsed := decodeTime + time.Since(start)
+		row := newFileRow(relativePath, size, counter.rows, ids, elapsed)
+		row.setDeclaration(root.Declaration)
+		if err := recordFileRow(row); err != nil {
+			return err
+		}
+	}
+
+	return checkpoints.documentDone(src.container, src.entry, rowWriter)
+}
+
+// isContainerExt reports whether files with ext are ZIP packages whose
+// entries are processed individually
+func isContainerExt(ext string) bool {
+	return ext == ".zip" || ext == ".xlsx" || ext == ".docx" || ext == ".pptx" || ext == ".xlsm" || ext == ".docm" || ext == ".pptm" || ext == ".vsdx" || ext == ".odt" || ext == ".ods" || ext == ".odp" || ext == ".epub" || ext == ".apk" || ext == ".nupkg" || ext == ".dtsx" || ext == ".plist" || ext == ".key" || ext == ".pages" || ext == ".numbers"
+}
+
+// producesRows reports whether processFile parses fileName rather than copying it
+func producesRows(fileName string, extensions []string) bool {
+	h := inputHandler(fileName, extensions)
+	return h.decodes() || h.Handler == handlerContainer
 }
 
 // processFile processes a file based on its type
-func processFile(fileName string, outputDir string, parquetWriter *writer.ParquetWriter, extensions []string) error {
-	ext := strings.ToLower(filepath.Ext(fileName))
+func processFile(fileName string, outputDir string, rowWriter RowWriter, extensions []string) (err error) {
+	ctx, span := tracer.Start(runCtx, "xmlgo.file", trace.WithAttributes(pathAttr(fileName)))
+	fileCtx = ctx
+	defer func() { endSpan(span, err) }()
 
-	relativePath, err := filepath.Rel(outputDir, fileName)
-	if err != nil {
-		return fmt.Errorf("failed to get relative path for file %s: %v", fileName, err)
+	writtenEntries = nil
+	relativePath := inputProvenance(outputDir, fileName)
+	if activeHooks != nil {
+		if err := beforeFileHooks(fileName, relativePath, outputDir); err != nil {
+			return recordFailure(fileName, relativePath, err)
+		}
+		failures := len(report.Failures)
+		defer func() { afterFileHooks(fileName, relativePath, outputDir, failures, err) }()
+	}
+	if active := streamingExtractor(fileName); active != nil {
+		return streamFile(fileName, relativePath, active, rowWriter)
 	}
 
-	for _, extension := range extensions {
-		if ext == extension {
-			dirPath := filepath.Dir(filepath.Join(outputDir, fileName))
-			if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-				os.MkdirAll(dirPath, os.ModePerm)
-			}
+	h := inputHandler(fileName, extensions)
+	switch {
+	case h.decodes():
+		dirPath := filepath.Dir(filepath.Join(outputDir, fileName))
+		if _, err := os.Stat(dirPath); os.IsNotExist(err) {
+			os.MkdirAll(dirPath, os.ModePerm)
+		}
 
-			err := processXMLFile(fileName, relativePath, parquetWriter)
-			if err != nil {
-				return err
-			}
+		err := processXMLFile(fileName, relativePath, h, rowWriter)
+		if err != nil {
+			return err
+		}
 
-			// Check if directory is empty after processing
-			if isEmptyDir(dirPath) {
-				os.Remove(dirPath)
-			}
+		// Check if directory is empty after processing
+		if isEmptyDir(dirPath) {
+			os.Remove(dirPath)
+		}
+		return nil
+	case h.Handler == handlerContainer:
+		return extractAndProcessZip(fileName, outputDir, rowWriter, extensions)
+	case h.Handler == handlerSkip:
+		if retrySkip(fileName, relativePath) {
 			return nil
 		}
+		var size int64
+		if info, err := os.Stat(fileName); err == nil {
+			size = info.Size()
+		}
+		return recordSkip(relativePath, size, skipConfigured)
 	}
 
-	if ext == ".zip" || ext == ".xlsx" || ext == ".docx" || ext == ".pptx" || ext == ".vsdx" || ext == ".odt" || ext == ".ods" || ext == ".odp" || ext == ".epub" || ext == ".apk" || ext == ".dtsx" || ext == ".csproj" || ext == ".vbproj" || ext == ".nuspec" || ext == ".plist" || ext == ".resx" || ext == ".dae" || ext == ".key" || ext == ".pages" || ext == ".numbers" {
-		return extractAndProcessZip(fileName, outputDir, parquetWriter, extensions)
-	}
+	return copyNonXMLFile(fileName, relativePath, outputDir)
+}
 
-	return copyNonXMLFile(fileName, outputDir)
+// zipEntryResult is the outcome of processing one ZIP entry on a worker
+type zipEntryResult struct {
+	file         *zip.File
+	relativePath string
+	ticket       int64
+	root         *XMLNode
+	ids          *idBlock
+	decodeTime   time.Duration
+	empty        bool
+	skipReason   string
+	err          error
+	done         chan struct{}
 }
 
-// extractAndProcessZip extracts a ZIP file and processes XML files within it
-func extractAndProcessZip(zipFile, outputDir string, parquetWriter *writer.ParquetWriter, extensions []string) error {
+// extractAndProcessZip extracts a ZIP file and processes XML files within it.
+// Entries are decoded (or copied) concurrently by up to --workers workers,
+// each reserving its node ID block from the coordinator in archive order,
+// and their rows are written in archive order as well.
+func extractAndProcessZip(zipFile, outputDir string, rowWriter RowWriter, extensions []string) error {
 	r, err := zip.OpenReader(zipFile)
 	if err != nil {
-		return fmt.Errorf("failed to open ZIP file %s: %v", zipFile, err)
+		return recordFailure(zipFile, inputProvenance(outputDir, zipFile), fmt.Errorf("failed to open ZIP file %s: %v", zipFile, err))
 	}
 	defer r.Close()
+	containerPath := inputProvenance(outputDir, zipFile)
+	parts, err := newPartFilter(r.File)
+	if err != nil {
+		return recordFailure(zipFile, containerPath, fmt.Errorf("failed to read content types of %s: %v", zipFile, err))
+	}
+	if err := recordContainer(zipFile, containerPath, r.File); err != nil {
+		return err
+	}
 
-	for _, f := range r.File {
-		filePath := filepath.Join(outputDir, f.Name)
-
-		if f.FileInfo().IsDir() {
-			continue // Skip directories entirely
+	// An unchanged container is replayed from --cache-dir instead of parsed
+	var recorder *cacheRecorder
+	if cacheDir != "" && retryFilter == nil {
+		key, err := containerCacheKey(zipFile, extensions)
+		if err != nil {
+			return err
 		}
-
-		if strings.HasSuffix(f.Name, ".xml") || strings.HasSuffix(f.Name, ".rels") {
-			dirPath := filepath.Dir(filePath)
-			if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-				os.MkdirAll(dirPath, os.ModePerm)
+		if hit, err := replayCachedContainer(key, r.File, containerPath, outputDir, rowWriter); hit || err != nil {
+			return err
+		}
+		if !resumeRun {
+			if recorder, err = newCacheRecorder(key); err != nil {
+				return err
 			}
+		}
+	}
 
-			rc, err := f.Open()
-			if err != nil {
-				return fmt.Errorf("failed to open file %s in ZIP: %v", f.Name, err)
-			}
+	var siblings *containerParts
+	if len(activeExtractors) > 0 {
+		siblings = newContainerParts(r.File)
+	}
 
-			tempFileName := filepath.Join(outputDir, f.Name)
-			tempFile, err := os.Create(tempFileName)
-			if err != nil {
-				rc.Close()
-				return fmt.Errorf("failed to create temp file for %s: %v", f.Name, err)
-			}
+	gate := newWorkerGate(workers)
+	pending := make(chan *zipEntryResult, gate.max*2)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
 
-			_, err = io.Copy(tempFile, rc)
-			rc.Close()
-			tempFile.Close()
+	go func() {
+		defer close(pending)
+		for _, f := range r.File {
+			if f.FileInfo().IsDir() {
+				continue // Skip directories entirely
+			}
 
-			if err != nil {
-				return fmt.Errorf("failed to copy contents of %s: %v", f.Name, err)
+			// Names such as ../x or /x would resolve outside the output directory
+			local := filepath.IsLocal(filepath.FromSlash(f.Name))
+			relativePath := f.Name
+			if local {
+				if rel, err := filepath.Rel(outputDir, filepath.Join(outputDir, f.Name)); err == nil {
+					relativePath = rel
+				}
+			}
+			if retrySkip(zipFile, relativePath) && retrySkip(zipFile, inputProvenance(outputDir, zipFile)) {
+				continue // Not part of the failures being retried
+			}
+			if retryDone(zipFile, f.Name) {
+				continue // Written before the retried run stopped
 			}
 
-			relativePath, err := filepath.Rel(outputDir, tempFileName)
-			if err != nil {
-				return fmt.Errorf("failed to get relative path for file %s: %v", tempFileName, err)
+			result := &zipEntryResult{file: f, relativePath: relativePath, done: make(chan struct{})}
+			switch {
+			case !local:
+				result.skipReason = skipUnsafe
+			case checkpoints.skip(containerPath, f.Name):
+				result.skipReason = skipResumed // Already committed by the run being resumed
+			case parts.excludes(f.Name):
+				result.skipReason = skipPartType
+			case maxFileSize > 0 && int64(f.UncompressedSize64) > maxFileSize:
+				result.skipReason = skipTooLarge
+			}
+			if result.skipReason != "" {
+				close(result.done)
+				select {
+				case pending <- result:
+					continue
+				case <-stop:
+					return
+				}
 			}
 
-			if err := processXMLFile(tempFileName, relativePath, parquetWriter); err != nil {
-				return fmt.Errorf("failed to process XML file %s: %v", tempFileName, err)
+			select {
+			case pending <- result:
+			case <-stop:
+				return
 			}
+			result.ticket = nodeIDs.ticket()
 
-			os.Remove(tempFileName) // Clean up the temporary XML file
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer close(result.done)
+				gate.acquire()
+				processZipEntry(result, outputDir)
+				gate.release()
+
+				// Reserve this entry's ID block after giving up the worker
+				// slot, so earlier entries can still be scheduled
+				var n int64
+				if result.root != nil {
+					n = countNodes(*result.root)
+				}
+				result.ids = nodeIDs.reserveInOrder(result.ticket, n)
+			}()
+		}
+	}()
 
-			// Check if directory is empty after processing
-			if isEmptyDir(dirPath) {
-				os.Remove(dirPath)
+	var firstErr error
+	for result := range pending {
+		<-result.done
+		if firstErr != nil {
+			continue
+		}
+		f := result.file
+		if result.err != nil {
+			recordEntry(containerPath, f.Name, entryFailed, "")
+			if recorder != nil {
+				// A container with failed entries is converted again next time
+				recorder.abort()
+				recorder = nil
 			}
-		} else {
-			dirPath := filepath.Dir(filePath)
-			if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-				os.MkdirAll(dirPath, os.ModePerm)
+			if err := recordFailure(zipFile, result.relativePath, result.err); err != nil {
+				firstErr = err
+				close(stop)
 			}
-			dstFile, err := os.Create(filePath)
-			if err != nil {
-				return fmt.Errorf("failed to create file %s: %v", filePath, err)
+			continue
+		}
+		docWriter := rowWriter
+		if recorder != nil {
+			docWriter = recorder.capture(rowWriter)
+		}
+		if result.empty {
+			src := docSource{path: result.relativePath, container: containerPath, entry: f.Name}
+			err := handleEmptyDocument(src, int64(f.UncompressedSize64), result.ids, result.decodeTime, docWriter)
+			if err == nil && recorder != nil {
+				err = recorder.add(result.relativePath, f.Name, int64(f.UncompressedSize64), result.ids, nil, true)
 			}
-			rc, err := f.Open()
 			if err != nil {
-				dstFile.Close()
-				return fmt.Errorf("failed to open file %s in ZIP: %v", f.Name, err)
+				firstErr = fmt.Errorf("failed to process XML file %s: %v", f.Name, err)
+				close(stop)
+				continue
+			}
+			recordEmptyEntry(containerPath, f.Name)
+			continue
+		}
+		if result.root == nil {
+			recordEntry(containerPath, f.Name, entrySkipDisposition(result.skipReason), result.skipReason)
+			err := recordSkip(result.relativePath, int64(f.UncompressedSize64), result.skipReason)
+			if err == nil && recorder != nil {
+				err = recorder.skip(result.relativePath, f.Name, int64(f.UncompressedSize64), result.skipReason)
 			}
-			_, err = io.Copy(dstFile, rc)
-			rc.Close()
-			dstFile.Close()
 			if err != nil {
-				return fmt.Errorf("failed to copy file %s: %v", f.Name, err)
+				firstErr = err
+				close(stop)
+			}
+			continue
+		}
+
+		start := time.Now()
+		src := docSource{path: result.relativePath, container: containerPath, entry: f.Name, parts: siblings}
+		err := writeDocument(*result.root, result.ids, src, int64(f.UncompressedSize64), result.decodeTime, docWriter)
+		if err == nil && recorder != nil {
+			err = recorder.add(result.relativePath, f.Name, int64(f.UncompressedSize64), result.ids, result.root.Declaration, false)
+		}
+		if err != nil {
+			firstErr = err
+			if err != errLimitReached {
+				firstErr = fmt.Errorf("failed to process XML file %s: %v", f.Name, err)
 			}
+			close(stop)
+			continue
 		}
+		recordEntry(containerPath, f.Name, entryParsed, "")
+		gate.observe(result.decodeTime, time.Since(start))
 	}
 
-	return nil
+	wg.Wait()
+	if recorder != nil {
+		if firstErr != nil {
+			recorder.abort()
+		} else if err := recorder.finish(); err != nil {
+			return err
+		}
+	}
+	return firstErr
+}
+
+// processZipEntry decodes an XML entry or copies any other entry to the
+// output directory, storing the outcome in result
+func processZipEntry(result *zipEntryResult, outputDir string) {
+	f := result.file
+	if !filepath.IsLocal(filepath.FromSlash(f.Name)) {
+		result.skipReason = skipUnsafe
+		return
+	}
+
+	_, span := tracer.Start(fileCtx, "xmlgo.entry", trace.WithAttributes(pathAttr(f.Name)))
+	defer func() { endSpan(span, result.err) }()
+
+	h := entryHandlerFor(f.Name)
+	if h.Handler == handlerSkip {
+		result.skipReason = skipConfigured
+		return
+	}
+	if h.decodes() {
+		rc, err := f.Open()
+		if err != nil {
+			result.err = fmt.Errorf("failed to open file %s in ZIP: %v", f.Name, err)
+			return
+		}
+		defer rc.Close()
+
+		start := time.Now()
+		root, err := h.decode(rc)
+		if err == errEmptyDocument && emptyPartPolicy != "error" {
+			result.empty = true
+			result.decodeTime = time.Since(start)
+			return
+		}
+		if err != nil {
+			result.err = fmt.Errorf("failed to process %s file %s: failed to decode %s: %v", strings.ToUpper(h.Handler), f.Name, strings.ToUpper(h.Handler), err)
+			return
+		}
+		result.root = &root
+		result.decodeTime = time.Since(start)
+		return
+	}
+
+	// Deeply nested entry names can exceed MAX_PATH on Windows
+	filePath := filepath.Join(outputDir, f.Name)
+	dirPath := filepath.Dir(filePath)
+	if _, err := os.Stat(longPath(dirPath)); os.IsNotExist(err) {
+		os.MkdirAll(longPath(dirPath), os.ModePerm)
+	}
+	dstFile, target, err := createCopyTarget(filePath)
+	if err != nil {
+		result.err = err
+		return
+	}
+	if dstFile == nil {
+		result.skipReason = skipDuplicate
+		return
+	}
+	rc, err := f.Open()
+	if err != nil {
+		dstFile.Close()
+		result.err = fmt.Errorf("failed to open file %s in ZIP: %v", f.Name, err)
+		return
+	}
+	err = copyAsset(dstFile, target, rc, int64(f.UncompressedSize64))
+	rc.Close()
+	if err == nil {
+		err = preserveAttributes(target, f.Mode(), f.Modified)
+	}
+	if err != nil {
+		result.err = fmt.Errorf("failed to copy file %s: %v", f.Name, err)
+		return
+	}
+	result.skipReason = skipExtension
 }
 
 // isEmptyDir checks if a directory is empty
 func isEmptyDir(dirPath string) bool {
-	f, err := os.Open(dirPath)
+	f, err := os.Open(longPath(dirPath))
 	if err != nil {
 		return false
 	}
@@ -259,94 +600,510 @@ func isEmptyDir(dirPath string) bool {
 
 // cleanEmptyDirs recursively removes empty directories in the specified path
 func cleanEmptyDirs(root string) error {
+	var dirs []string
 	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if info.IsDir() && isEmptyDir(path) {
-			if err := os.Remove(path); err != nil {
-				log.Printf("Failed to remove empty directory %s: %v", path, err)
-			}
+		if info.IsDir() {
+			dirs = append(dirs, path)
 		}
 		return nil
 	})
+	// Deepest first, so directories holding only empty directories go too
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if isEmptyDir(dirs[i]) {
+			if err := os.Remove(longPath(dirs[i])); err != nil {
+				log.Printf("Failed to remove empty directory %s: %v", dirs[i], err)
+			}
+		}
+	}
 	return err
 }
 
-// copyNonXMLFile copies non-XML files directly to the output directory
-func copyNonXMLFile(fileName string, outputDir string) error {
+// copyNonXMLFile copies non-XML files directly to the output directory,
+// resolving name clashes with --on-collision, and records why the file was
+// not parsed in the files table
+func copyNonXMLFile(fileName string, relativePath string, outputDir string) error {
 	srcFile, err := os.Open(fileName)
 	if err != nil {
-		return fmt.Errorf("failed to open file %s: %v", fileName, err)
+		if skipUnreadable {
+			log.Printf("Skipping unreadable file %s: %v", fileName, err)
+			return recordSkip(relativePath, 0, unreadableReason(err))
+		}
+		return wrapFSError("open file", fileName, err)
 	}
 	defer srcFile.Close()
 
+	info, err := srcFile.Stat()
+	if err != nil {
+		return wrapFSError("read", fileName, err)
+	}
+	size := info.Size()
+
 	dstFileName := filepath.Join(outputDir, filepath.Base(fileName))
-	dstFile, err := os.Create(dstFileName)
+	dstFile, target, err := createCopyTarget(dstFileName)
 	if err != nil {
-		return fmt.Errorf("failed to create file %s: %v", dstFileName, err)
+		return err
+	}
+	if dstFile == nil {
+		return recordSkip(relativePath, size, skipDuplicate)
 	}
-	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
+	err = copyAsset(dstFile, target, srcFile, size)
+	if err == nil {
+		err = preserveAttributes(target, info.Mode(), info.ModTime())
+	}
 	if err != nil {
 		return fmt.Errorf("failed to copy file %s: %v", fileName, err)
 	}
 
+	return recordSkip(relativePath, size, skipExtension)
+}
+
+// batchSize is the number of rows buffered before they are handed to the sink
+var batchSize = defaultBatchSize
+
+// pipelineDepth is the number of batches queued for the sink (0 writes synchronously)
+var pipelineDepth = defaultPipelineDepth
+
+// transformModule is the optional WebAssembly module rows pass through
+var transformModule string
+
+// newWriterChain wraps a sink with the statistics, pipeline, batching and
+// transform stages
+func newWriterChain(sink RowWriter) (RowWriter, error) {
+	if runOutputStats != nil {
+		sink = NewStatsWriter(sink, runOutputStats)
+	}
+	if pipelineDepth > 0 {
+		sink = NewPipelineWriter(sink, pipelineDepth)
+	}
+	var rowWriter RowWriter = NewBatchWriter(sink, batchSize)
+
+	if transformModule != "" {
+		w, err := NewTransformWriter(transformModule, rowWriter)
+		if err != nil {
+			rowWriter.WriteStop()
+			return nil, err
+		}
+		rowWriter = w
+	}
+	return rowWriter, nil
+}
+
+// openOutputs opens the sink for every requested format inside outputDir,
+// applying --route-tags to each, and returns them as a single writer along
+// with the main output names
+func openOutputs(formats []string, outputDir string) (RowWriter, []string, error) {
+	var sinks []RowWriter
+	var names []string
+	for _, format := range formats {
+		sink, fileName, err := newRowWriter(format, outputDir)
+		if err == nil && len(tagRoutes) > 0 {
+			sink, err = NewRoutingWriter(format, fileName, tagRoutes, sink)
+		}
+		if err != nil {
+			NewMultiWriter(sinks...).WriteStop()
+			return nil, nil, err
+		}
+		sinks = append(sinks, sink)
+		names = append(names, fileName)
+	}
+	if len(sinks) == 1 {
+		return sinks[0], names, nil
+	}
+	return NewMultiWriter(sinks...), names, nil
+}
+
+// processPerFile converts every input into its own output file per format
+func processPerFile(inputs []string, root string, formats []string, outputDir string, extensions []string) error {
+	exts := make([]string, len(formats))
+	for i, format := range formats {
+		combinedName, err := outputFileName(format, outputDir)
+		if err != nil {
+			return err
+		}
+		exts[i] = filepath.Ext(combinedName)
+	}
+	namer, err := newPerFileNamer(outputDir, root, perFileLayout)
+	if err != nil {
+		return err
+	}
+
+	for n, input := range inputs {
+		if !producesRows(input, extensions) {
+			if err := processFile(input, outputDir, nil, extensions); err != nil {
+				return err
+			}
+			continue
+		}
+		baseName, err := namer.name(input)
+		if err != nil {
+			return err
+		}
+		var sinks []RowWriter
+		for i, format := range formats {
+			sink, err := openFormatWriter(format, baseName+exts[i])
+			if err != nil {
+				NewMultiWriter(sinks...).WriteStop()
+				return err
+			}
+			sinks = append(sinks, sink)
+		}
+		rowWriter, err := newWriterChain(NewMultiWriter(sinks...))
+		if err != nil {
+			return err
+		}
+		err = processFile(input, outputDir, rowWriter, extensions)
+		if err != nil && err != errLimitReached {
+			rowWriter.WriteStop()
+			return err
+		}
+		if err := rowWriter.WriteStop(); err != nil {
+			return fmt.Errorf("failed to finalize output for %s: %v", input, err)
+		}
+		if err == errLimitReached {
+			report.markUnprocessed(inputs[n:], writtenEntries)
+			break
+		}
+		if checkLimits() != nil {
+			report.markUnprocessed(inputs[n+1:], nil)
+			break
+		}
+	}
 	return nil
 }
 
 func main() {
 	// Command-line flags
 	extensionsFlag := flag.String("extensions", ".xml,.rels", "Comma-separated list of file extensions to parse")
-	flag.Parse()
+	formatFlag := flag.String("format", "parquet", "Comma-separated output formats written in one pass: parquet, esbulk (Elasticsearch/OpenSearch bulk NDJSON), jsonl (one nested document per line) or template")
+	flag.StringVar(&parquetBackend, "parquet-backend", parquetBackend, "Parquet implementation to write with (parquet-go, or arrow when built with -tags arrow)")
+	flag.StringVar(&esIndexName, "es-index", esIndexName, "Index name written into esbulk action lines")
+	flag.Var(&workers, "workers", "Number of ZIP entries decoded concurrently, or auto to adapt to CPUs and sink latency")
+	flag.DurationVar(&flushInterval, "flush-interval", 0, "Split the output into parts finalized at this interval, with progress in checkpoint.json (e.g. 10m)")
+	flag.BoolVar(&resumeRun, "resume", false, "With --flush-interval, continue from checkpoint.json and skip documents it already committed")
+	flag.IntVar(&batchSize, "batch-size", batchSize, "Number of rows buffered before they are handed to the output writer")
+	flag.IntVar(&pipelineDepth, "pipeline-depth", pipelineDepth, "Number of row batches queued for the output writer (0 writes synchronously)")
+	flag.StringVar(&fileListFile, "filelist", "", "File listing the inputs one per line, each optionally followed by a tab and handler=, file_path= or handler options; replaces the input argument")
+	flag.StringVar(&pathBase, "path-base", "", "Directory file_path is relative to, e.g. the corpus root (default: the output directory)")
+	flag.StringVar(&outputPath, "output", "", "Write the main output here instead of inside the output directory; esbulk and template output may go to a FIFO, named pipe or unix socket (unix:/path)")
+	flag.StringVar(&templateFile, "template", "", "Render every row through this Go text/template (implies --format=template)")
+	flag.StringVar(&transformModule, "transform", "", "WebAssembly module that can modify, drop, or add rows (see transform.go for the ABI)")
+	flag.BoolVar(&keepGoing, "keep-going", false, "Record documents that fail to convert in run_report.json and continue")
+	flag.BoolVar(&estimateMode, "estimate", false, "Convert a sample of the inputs into a scratch directory and print the predicted rows, output size and runtime of the full run; the output directory may be omitted")
+	flag.IntVar(&estimateSample, "estimate-sample", estimateSample, "Inputs --estimate converts, spread evenly over the input")
+	flag.StringVar(&unicodeForm, "normalize", unicodeForm, "Unicode normalization of text values: "+strings.Join(unicodeForms, ", "))
+	flag.StringVar(&caseFolding, "case-fold", caseFolding, "Case folding of text values: "+strings.Join(caseFoldings, ", ")+" (fold is locale-independent; lower follows --text-locale)")
+	flag.StringVar(&textLocale, "text-locale", textLocale, "BCP 47 language whose lowercasing rules --case-fold=lower follows (e.g. tr); und uses the rules common to all languages")
+	flag.StringVar(&quarantineDir, "quarantine", "", "Directory that receives each input that failed to convert, with a <name>.error.json report")
+	flag.StringVar(&quarantineMode, "quarantine-mode", quarantineMode, "How failed inputs reach --quarantine: "+strings.Join(quarantineModes, ", "))
+	var cfg runConfig
+	flag.StringVar(&cfg.retryManifest, "retry-failed", "", "Re-run only the failed and unprocessed documents listed in this run_report.json, adding .retry-N outputs")
+	flag.Int64Var(&maxFileSize, "max-file-size", 0, "Skip XML documents larger than this many bytes (0 means no limit)")
+	flag.BoolVar(&skipUnreadable, "skip-unreadable", false, "Record files that cannot be opened (permissions, locks) as skipped instead of failing")
+	flag.BoolVar(&deterministic, "deterministic", false, "Write byte-identical outputs for identical inputs: single-threaded Parquet marshalling and no timings or memory figures in the metadata")
+	flag.BoolVar(&strictMode, "strict", false, "Fail instead of skipping empty, oversized or duplicate documents, and reject options that recover from errors or decode leniently")
+	flag.DurationVar(&deadline, "deadline", 0, "Finalize completed output and exit with status 3 after this long (e.g. 30m)")
+	flag.Int64Var(&maxFiles, "max-files", 0, "Stop after this many documents (0 means no limit)")
+	flag.Int64Var(&maxTotalRows, "max-total-rows", 0, "Stop before the next document once this many rows were written (0 means no limit)")
+	flag.BoolVar(&markSelfClosing, "self-closing", false, "Record in the self_closing column whether each element was written as an empty-element tag (<a/>) rather than <a></a>")
+	flag.BoolVar(&logEntries, "log-entries", false, "Log whether each ZIP entry was parsed, copied as an asset, skipped (and why) or failed")
+	flag.StringVar(&emptyPartPolicy, "empty-parts", emptyPartPolicy, "How to handle zero-byte or whitespace-only XML documents: "+strings.Join(emptyPartPolicies, ", "))
+	flag.StringVar(&schemaRegistryURL, "schema-registry", "", "Confluent-compatible schema registry URL to register the Avro row schema with")
+	flag.StringVar(&schemaSubject, "schema-subject", schemaSubject, "Schema registry subject for the row schema")
+	flag.Int64Var(&progressRows, "progress-rows", progressRows, "Log progress every this many rows within one document (0 disables)")
+	flag.IntVar(&parquetRowGroupRows, "row-group-rows", parquetRowGroupRows, "Maximum rows per Parquet row group, bounding writer memory on very wide documents")
+	flag.IntVar(&parquetBufferMiB, "writer-buffer", parquetBufferMiB, "Most MiB of rows the Parquet writer buffers before marshalling them; the buffer is otherwise sized from the observed row width (0 for no limit)")
+	flag.StringVar(&cacheDir, "cache-dir", "", "Reuse the rows of unchanged containers from this content-addressed cache")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "Export OpenTelemetry spans to this OTLP/HTTP endpoint (e.g. http://collector:4318)")
+	flag.BoolVar(&verifyReaders, "verify-readers", false, "Re-read every Parquet output with all available readers after the run; needs a build with an independent reader (-tags arrow)")
+	flag.StringVar(&compareReport, "compare-report", "", "Compare per-tag row counts with this earlier run_report.json and report tags that disappeared, appeared or changed by --anomaly-factor")
+	flag.Float64Var(&anomalyFactor, "anomaly-factor", anomalyFactor, "Ratio of growth or shrinkage of a row count that --compare-report reports as an anomaly")
+	flag.BoolVar(&failOnAnomaly, "fail-on-anomaly", false, "Fail the conversion when --compare-report finds anomalies instead of logging them")
+	flag.StringVar(&nullPolicy, "nulls", nullPolicy, "How absent parent IDs, tag names and attribute names are written to Parquet: sentinel (0 and empty string, as in earlier versions) or null")
+	flag.StringVar(&collisionPolicy, "on-collision", collisionPolicy, "What to do when a copied file already exists in the output: "+strings.Join(collisionPolicies, ", "))
+	flag.BoolVar(&colladaRawArrays, "collada-raw-arrays", false, "Keep the values of COLLADA (.dae) geometry arrays instead of summarizing them as counts and bounds")
+	flag.BoolVar(&vbaModules, "vba-modules", false, "List the module names of VBA projects in the vba_modules column of files.parquet")
+	msbuildConfigurationsFlag := flag.String("msbuild-configurations", strings.Join(msbuildConfigurations, ","), "Comma-separated Configuration|Platform pairs the msbuild extractor evaluates projects for")
+	partTypesFlag := flag.String("part-types", "", "Only process the container entries whose [Content_Types].xml type is listed, in full or by last segment (e.g. worksheet,sharedStrings)")
+	routeTagsFlag := flag.String("route-tags", "", "Write the rows of these tags to their own outputs, as tag or tag=name (e.g. c=cells,row)")
+	flag.BoolVar(&perFile, "per-file", false, "Write one output per source file instead of a single combined output")
+	flag.StringVar(&perFileLayout, "layout", perFileLayout, "Per-file output layout: mirror (recreate the source tree) or flat (collision-safe single directory)")
+	flag.BoolVar(&cfg.tagDictionary, "tag-dictionary", false, "Write a tags.parquet dictionary and reference it from the tag_id column")
+	flag.BoolVar(&tagIDsOnly, "tag-ids", false, "Store only tag_id, not tag_name, in the main table (implies --tag-dictionary)")
+	flag.BoolVar(&cfg.attributeDictionary, "attribute-dictionary", false, "Write an attributes.parquet dictionary and reference it from the attribute_id column")
+	flag.BoolVar(&attributeIDsOnly, "attribute-ids", false, "Store only attribute_id, not attribute_name, in the main table (implies --attribute-dictionary)")
+	flag.BoolVar(&cfg.textIndex, "text-index", false, "Write a term postings table of text and attribute values to text_index.parquet, queryable with SQL (not a Bleve or tantivy index)")
+	flag.BoolVar(&cfg.languages, "detect-language", false, "Write the detected ISO 639-1 language of each substantial text node to languages.parquet")
+	flag.IntVar(&languageMinChars, "language-min-chars", languageMinChars, "Letters a text node needs before --detect-language detects its language")
+	extractFlag := flag.String("extract", "", "Comma-separated format extractors writing typed tables next to the output, or all: "+strings.Join(extractorNames(), ", "))
+	flag.StringVar(&serveAddr, "serve", "", "Run an HTTP server on this address (e.g. :8080) that converts files POSTed to /convert")
+	flag.StringVar(&serveDir, "serve-dir", serveDir, "Directory the server keeps uploads and job outputs in")
+	flag.StringVar(&tenantsFile, "tenants", "", "JSON file of tenants with their keys, output prefixes and quotas for --serve")
+	flag.StringVar(&scheduleExpr, "schedule", "", "Keep running and convert changed inputs into a new run directory at times matching this cron expression (e.g. \"0 2 * * *\")")
+	flag.StringVar(&webhookURL, "webhook", "", "POST a JSON completion payload (outputs, row counts, errors) to this URL after each conversion")
+	flag.StringVar(&webhookSecret, "webhook-secret", "", "Sign webhook payloads with HMAC-SHA256 using this secret (X-Xmlgo-Signature header)")
+	flag.BoolVar(&lineageFile, "lineage", false, "Write an OpenLineage run event with the input, outputs and their schemas to lineage.json after each conversion")
+	flag.StringVar(&lineageURL, "lineage-url", "", "POST the OpenLineage run event of each conversion to this endpoint (e.g. http://marquez:5000/api/v1/lineage)")
+	flag.StringVar(&lineageNamespace, "lineage-namespace", lineageNamespace, "OpenLineage namespace of the conversion job")
+	flag.StringVar(&lineageJob, "lineage-job", "", "OpenLineage name of the conversion job (default: the output directory's name)")
+	flag.StringVar(&auditLog, "audit-log", "", "Append a hash-chained record of each conversion (user, flags, input and output hashes) to this JSON Lines file; check it with \"xmlgo verify-audit <file>\"")
+	flag.StringVar(&auditSecret, "audit-secret", "", "Key the audit log's hash chain with HMAC-SHA256 using this secret")
+	flag.StringVar(&apiTokensFile, "api-tokens", "", "File of bearer tokens (one per line) the server requires when --tenants is not used")
+	flag.StringVar(&tlsCertFile, "tls-cert", "", "PEM certificate the server presents for HTTPS")
+	flag.StringVar(&tlsKeyFile, "tls-key", "", "PEM private key of --tls-cert")
+	flag.StringVar(&tlsClientCAFile, "tls-client-ca", "", "PEM CA bundle client certificates must be signed by (enables mutual TLS)")
+	flag.IntVar(&queueSize, "queue-size", queueSize, "Jobs the server queues before answering 429")
+	configFlag := flag.String("config", "", "JSON file of flag values and per-extension or per-content-type handlers (xml, html, json, container, copy, skip)")
+	profileFlag := flag.String("profile", "", "Preset of flag values for flags not given explicitly: "+strings.Join(profileNames(), ", "))
+	flag.StringVar(&backfillSince, "since", "", "backfill: convert only objects modified at or after this date or RFC 3339 time")
+	flag.StringVar(&backfillUntil, "until", "", "backfill: convert only objects modified before this date or RFC 3339 time")
+	flag.IntVar(&backfillBatch, "backfill-batch", backfillBatch, "backfill: objects converted per checkpointed batch")
+	flag.IntVar(&downloadWorkers, "download-workers", downloadWorkers, "backfill: objects downloaded in parallel")
+	flag.BoolVar(&updateGolden, "update-golden", false, "golden: record the outputs of this build as the expected ones instead of comparing them")
+	flag.StringVar(&s3Endpoint, "s3-endpoint", "", "Endpoint of an S3-compatible store (e.g. http://minio:9000), addressed path-style")
 
-	if len(flag.Args()) != 2 {
-		log.Fatalf("Usage: %s [--extensions=.ext1,.ext2] <file> <output-dir>", os.Args[0])
+	// "xmlgo backfill <url> <output-dir>" converts an object store prefix;
+	// its flags may follow the arguments
+	cmdArgs := os.Args[1:]
+	backfill := len(cmdArgs) > 0 && cmdArgs[0] == "backfill"
+	if backfill {
+		cmdArgs = cmdArgs[1:]
+	}
+	// "xmlgo verify-audit <file>" checks the hash chain of an audit log
+	verifyAudit := len(cmdArgs) > 0 && cmdArgs[0] == "verify-audit"
+	if verifyAudit {
+		cmdArgs = cmdArgs[1:]
+	}
+	// "xmlgo as-of <output-dir> <snapshot-id|time>" prints the files and
+	// superseded inputs of a snapshot of an incremental output directory
+	asOf := len(cmdArgs) > 0 && cmdArgs[0] == "as-of"
+	if asOf {
+		cmdArgs = cmdArgs[1:]
+	}
+	// "xmlgo gen-readers <dir>" writes a Python helper module for reading
+	// datasets of the current row schema
+	genReaders := len(cmdArgs) > 0 && cmdArgs[0] == "gen-readers"
+	if genReaders {
+		cmdArgs = cmdArgs[1:]
+	}
+	// "xmlgo golden [dir]" checks the conversions of a golden corpus
+	golden := len(cmdArgs) > 0 && cmdArgs[0] == "golden"
+	if golden {
+		cmdArgs = cmdArgs[1:]
+	}
+	args := parseArgs(flag.CommandLine, cmdArgs)
+	if *configFlag != "" {
+		config, err := loadConfig(*configFlag)
+		if err != nil {
+			log.Fatalf("Invalid --config: %v", err)
+		}
+		if err := config.apply(flag.CommandLine, *configFlag); err != nil {
+			log.Fatalf("Invalid --config: %v", err)
+		}
+	}
+	if *profileFlag != "" {
+		if err := applyProfile(flag.CommandLine, *profileFlag); err != nil {
+			log.Fatalf("Invalid --profile: %v", err)
+		}
 	}
 
-	inputFile := flag.Arg(0)
-	outputDir := flag.Arg(1)
+	if asOf {
+		if len(args) != 2 {
+			log.Fatalf("Usage: %s as-of <output-dir> <snapshot-id|2024-01-01|RFC 3339 time>", os.Args[0])
+		}
+		if err := printSnapshot(args[0], args[1]); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
 
-	// Create the destination directory if it doesn't exist
-	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
-			log.Fatalf("Failed to create output directory %s: %v", outputDir, err)
+	if genReaders {
+		if len(args) != 1 {
+			log.Fatalf("Usage: %s gen-readers <dir>", os.Args[0])
 		}
+		fileName, err := generateReaders(args[0])
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("Wrote %s for row schema version %d.\n", fileName, rowSchemaVersion)
+		return
 	}
 
-	// Parse the extensions
-	extensions := strings.Split(*extensionsFlag, ",")
-	for i, ext := range extensions {
-		extensions[i] = strings.ToLower(strings.TrimSpace(ext))
+	if golden {
+		if len(args) > 1 {
+			log.Fatalf("Usage: %s golden [--update-golden] [corpus-dir]", os.Args[0])
+		}
+		dir := defaultGoldenDir
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		failed, err := runGolden(dir)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if len(failed) > 0 {
+			log.Fatalf("Golden cases failed: %s", strings.Join(failed, ", "))
+		}
+		return
 	}
 
-	// Initialize the single Parquet file writer
-	parquetFileName := filepath.Join(outputDir, "combined.parquet")
-	parquetFile, err := local.NewLocalFileWriter(parquetFileName)
-	if err != nil {
-		log.Fatalf("Failed to create Parquet file %s: %v", parquetFileName, err)
+	// With --filelist the list takes the place of the input argument
+	if fileListFile != "" && !verifyAudit {
+		if serveAddr != "" || backfill || scheduleExpr != "" {
+			log.Fatalf("--filelist cannot be combined with --serve, backfill or --schedule")
+		}
+		args = append([]string{fileListFile}, args...)
+	}
+
+	if verifyAudit {
+		if len(args) != 1 {
+			log.Fatalf("Usage: %s verify-audit [--audit-secret=...] <audit-log>", os.Args[0])
+		}
+		n, err := verifyAuditLog(args[0])
+		if err != nil {
+			log.Fatalf("Audit log %s failed verification: %v", args[0], err)
+		}
+		fmt.Printf("Audit log %s is intact: %d entries.\n", args[0], n)
+		return
 	}
-	defer parquetFile.Close()
 
-	parquetWriter, err := writer.NewParquetWriter(parquetFile, new(ParquetRow), 4)
+	if len(args) != 2 && serveAddr == "" && !(estimateMode && len(args) == 1) {
+		log.Fatalf("Usage: %s [--extensions=.ext1,.ext2] [--format=parquet,esbulk,jsonl] [--template=out.tmpl] [--per-file] [--text-index] <file-or-dir> <output-dir>\n       %s --filelist=paths.txt <output-dir>\n       %s backfill [--since=2024-01-01] <s3://bucket/prefix/> <output-dir>", os.Args[0], os.Args[0], os.Args[0])
+	}
+
+	var err error
+	cfg.formats, err = parseFormats(*formatFlag)
 	if err != nil {
-		log.Fatalf("Failed to create Parquet writer: %v", err)
+		log.Fatalf("Invalid --format: %v", err)
+	}
+	if templateFile != "" && !slices.Contains(cfg.formats, "template") {
+		cfg.formats = []string{"template"}
+	}
+
+	// Parse the extensions
+	cfg.extensions = strings.Split(*extensionsFlag, ",")
+	for i, ext := range cfg.extensions {
+		cfg.extensions[i] = strings.ToLower(strings.TrimSpace(ext))
+	}
+
+	if cfg.extractors, err = parseExtractors(*extractFlag); err != nil {
+		log.Fatalf("Invalid --extract: %v", err)
+	}
+
+	msbuildConfigurations = nil
+	for _, configuration := range strings.Split(*msbuildConfigurationsFlag, ",") {
+		if configuration = strings.TrimSpace(configuration); configuration != "" {
+			msbuildConfigurations = append(msbuildConfigurations, configuration)
+		}
+	}
+
+	for _, partType := range strings.Split(*partTypesFlag, ",") {
+		if partType = strings.TrimSpace(partType); partType != "" {
+			partTypes = append(partTypes, partType)
+		}
+	}
+
+	if *routeTagsFlag != "" {
+		tagRoutes, err = parseTagRoutes(*routeTagsFlag)
+		if err != nil {
+			log.Fatalf("Invalid --route-tags: %v", err)
+		}
 	}
 
-	// Enable ZSTD compression
-	parquetWriter.CompressionType = parquet.CompressionCodec_ZSTD
-	defer parquetWriter.WriteStop()
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("Invalid options: %v", err)
+	}
 
-	err = processFile(inputFile, outputDir, parquetWriter, extensions)
+	finishTracing := func(error) error { return nil }
+	if tracingEnabled() {
+		finishTracing, err = initTracing()
+		if err != nil {
+			log.Fatalf("Failed to start tracing: %v", err)
+		}
+	}
+
+	if estimateMode {
+		if serveAddr != "" || backfill || scheduleExpr != "" || cfg.retryManifest != "" || resumeRun || outputPath != "" {
+			log.Fatalf("--estimate cannot be combined with --serve, backfill, --schedule, --retry-failed, --resume or --output")
+		}
+		if estimateSample < 1 {
+			log.Fatalf("--estimate-sample must be at least 1")
+		}
+		err := runEstimate(&cfg, normalizePath(args[0]))
+		finishTracing(err)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
+	if serveAddr != "" {
+		err := serve(&cfg)
+		finishTracing(err)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
+	if backfill {
+		err := runBackfill(&cfg, args[0], normalizePath(args[1]))
+		finishTracing(err)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
+	input := normalizePath(args[0])
+	outputDir := normalizePath(args[1])
+
+	if scheduleExpr != "" {
+		if cfg.retryManifest != "" || resumeRun || outputPath != "" {
+			log.Fatalf("--schedule cannot be combined with --retry-failed, --resume or --output")
+		}
+		err := runSchedule(&cfg, input, outputDir)
+		finishTracing(err)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
+	outputFileName, err := convert(&cfg, input, outputDir)
+	if webhookURL != "" {
+		if err := notifyWebhook(webhookURL, newWebhookPayload(input, outputDir, err)); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	if lineageEnabled() {
+		if err := emitLineage(input, outputDir, err); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	if auditLog != "" {
+		recordAudit(nil, input, outputDir, err)
+	}
 	if err != nil {
-		log.Fatalf("Error processing file: %v", err)
+		finishTracing(err)
+		log.Fatalf("%v", err)
 	}
+	report.logSummary()
 
-	// Clean up any remaining empty directories
-	if err := cleanEmptyDirs(outputDir); err != nil {
-		log.Fatalf("Failed to clean up empty directories: %v", err)
+	if len(cfg.formats) == 1 && cfg.formats[0] == "parquet" && !perFile {
+		fmt.Println("Successfully processed file and generated Parquet file with ZSTD compression.")
+	} else {
+		fmt.Printf("Successfully processed file and generated %s.\n", outputFileName)
 	}
 
-	fmt.Println("Successfully processed file and generated Parquet file with ZSTD compression.")
+	if err := finishTracing(nil); err != nil {
+		log.Printf("Failed to export traces: %v", err)
+	}
+
+	if deadlineExceeded {
+		os.Exit(exitDeadline)
+	}
 }
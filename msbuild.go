@@ -0,0 +1,423 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	extractors["msbuild"] = msbuildExtractor{}
+}
+
+// msbuildConfigurations are the Configuration|Platform pairs MSBuild
+// projects are evaluated for (--msbuild-configurations)
+var msbuildConfigurations = []string{"Debug|AnyCPU", "Release|AnyCPU"}
+
+// MSBuildPropertyRow is the effective value of one property of an MSBuild
+// project for one configuration in msbuild_properties.parquet. node_id is
+// the element that last set it; raw is its value before expansion.
+type MSBuildPropertyRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Configuration string `parquet:"name=configuration, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Platform      string `parquet:"name=platform, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Name          string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Value         string `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Raw           string `parquet:"name=raw, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// MSBuildItemRow is one item an MSBuild project includes for one
+// configuration in msbuild_items.parquet, with properties in its Include
+// expanded and semicolon-separated lists split
+type MSBuildItemRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Configuration string `parquet:"name=configuration, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Platform      string `parquet:"name=platform, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ItemType      string `parquet:"name=item_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Include       string `parquet:"name=include, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// msbuildExtractor evaluates .csproj and .vbproj projects for each of
+// msbuildConfigurations: property groups, items and Choose blocks are
+// applied in order when their conditions hold, with $(Property)
+// references expanded. Imports (including SDK defaults), property
+// functions and item transforms are not evaluated, and Exists() is false.
+type msbuildExtractor struct{}
+
+// Tables returns the msbuild_properties and msbuild_items tables
+func (msbuildExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{
+		"msbuild_properties": new(MSBuildPropertyRow),
+		"msbuild_items":      new(MSBuildItemRow),
+	}
+}
+
+// Detect accepts .csproj and .vbproj documents rooted at Project
+func (msbuildExtractor) Detect(doc *Document) bool {
+	ext := strings.ToLower(path.Ext(doc.name()))
+	return doc.Root.XMLName.Local == "Project" && (ext == ".csproj" || ext == ".vbproj")
+}
+
+// msbuildProject is the state of evaluating a project for one configuration
+type msbuildProject struct {
+	nodeIDs    map[*XMLNode]int64
+	properties map[string]*MSBuildPropertyRow // keyed by lower-case name
+	order      []string
+	global     map[string]bool // properties the project cannot override
+	items      []MSBuildItemRow
+	row        MSBuildPropertyRow // template with the provenance columns
+}
+
+// Extract writes the effective properties and items of each configuration
+func (msbuildExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	nodeIDs := make(map[*XMLNode]int64)
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		nodeIDs[node] = nodeID
+	})
+	name := path.Base(doc.name())
+	for _, configuration := range msbuildConfigurations {
+		config, platform, _ := strings.Cut(configuration, "|")
+		if platform == "" {
+			platform = "AnyCPU"
+		}
+		p := &msbuildProject{
+			nodeIDs:    nodeIDs,
+			properties: make(map[string]*MSBuildPropertyRow),
+			global:     map[string]bool{"configuration": true, "platform": true},
+			row:        MSBuildPropertyRow{FilePath: doc.FilePath, ContainerPath: doc.ContainerPath, Configuration: config, Platform: platform},
+		}
+		p.set("Configuration", config, config, 0)
+		p.set("Platform", platform, platform, 0)
+		p.set("MSBuildProjectFile", name, name, 0)
+		p.set("MSBuildProjectName", strings.TrimSuffix(name, path.Ext(name)), strings.TrimSuffix(name, path.Ext(name)), 0)
+		p.set("MSBuildProjectExtension", path.Ext(name), path.Ext(name), 0)
+
+		// MSBuild evaluates every property before any item
+		p.evaluate(doc.Root, false)
+		p.evaluate(doc.Root, true)
+
+		for _, key := range p.order {
+			if err := rows("msbuild_properties", *p.properties[key]); err != nil {
+				return err
+			}
+		}
+		for _, item := range p.items {
+			if err := rows("msbuild_items", item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// set assigns a property unless it is a global one
+func (p *msbuildProject) set(name, value, raw string, nodeID int64) {
+	key := strings.ToLower(name)
+	if existing, ok := p.properties[key]; ok {
+		if p.global[key] {
+			return
+		}
+		existing.Value, existing.Raw, existing.NodeID = value, raw, nodeID
+		return
+	}
+	row := p.row
+	row.Name, row.Value, row.Raw, row.NodeID = name, value, raw, nodeID
+	p.properties[key] = &row
+	p.order = append(p.order, key)
+}
+
+// evaluate applies the property groups (or, in the items pass, the item
+// groups) of a Project, When or Otherwise element in order
+func (p *msbuildProject) evaluate(parent *XMLNode, items bool) {
+	for i := range parent.Nodes {
+		node := &parent.Nodes[i]
+		if !p.condition(node) {
+			continue
+		}
+		switch node.XMLName.Local {
+		case "PropertyGroup":
+			if items {
+				continue
+			}
+			for j := range node.Nodes {
+				property := &node.Nodes[j]
+				if p.condition(property) {
+					raw := strings.TrimSpace(property.Content)
+					p.set(property.XMLName.Local, p.expand(raw), raw, p.nodeIDs[property])
+				}
+			}
+		case "ItemGroup":
+			if items {
+				p.applyItems(node)
+			}
+		case "Choose":
+			// The first When whose condition holds, else Otherwise
+			for j := range node.Nodes {
+				branch := &node.Nodes[j]
+				if branch.XMLName.Local == "Otherwise" || (branch.XMLName.Local == "When" && p.condition(branch)) {
+					p.evaluate(branch, items)
+					break
+				}
+			}
+		}
+	}
+}
+
+// applyItems adds the Include and drops the Remove items of an ItemGroup
+func (p *msbuildProject) applyItems(group *XMLNode) {
+	for i := range group.Nodes {
+		item := &group.Nodes[i]
+		if !p.condition(item) {
+			continue
+		}
+		if remove := attrValue(item, "Remove"); remove != "" {
+			removed := make(map[string]bool)
+			for _, include := range splitMSBuildList(p.expand(remove)) {
+				removed[strings.ToLower(include)] = true
+			}
+			kept := p.items[:0]
+			for _, existing := range p.items {
+				if existing.ItemType != item.XMLName.Local || !removed[strings.ToLower(existing.Include)] {
+					kept = append(kept, existing)
+				}
+			}
+			p.items = kept
+			continue
+		}
+		for _, include := range splitMSBuildList(p.expand(attrValue(item, "Include"))) {
+			p.items = append(p.items, MSBuildItemRow{
+				FilePath:      p.row.FilePath,
+				NodeID:        p.nodeIDs[item],
+				ContainerPath: p.row.ContainerPath,
+				Configuration: p.row.Configuration,
+				Platform:      p.row.Platform,
+				ItemType:      item.XMLName.Local,
+				Include:       include,
+			})
+		}
+	}
+}
+
+// splitMSBuildList splits a semicolon-separated item list
+func splitMSBuildList(list string) []string {
+	var parts []string
+	for _, part := range strings.Split(list, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// msbuildPropertyRef matches a $(Property) reference
+var msbuildPropertyRef = regexp.MustCompile(`\$\(([A-Za-z_][A-Za-z0-9_\-]*)\)`)
+
+// expand replaces the $(Property) references of s with the current values,
+// undefined properties expanding to ""
+func (p *msbuildProject) expand(s string) string {
+	return msbuildPropertyRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if property, ok := p.properties[strings.ToLower(ref[2:len(ref)-1])]; ok {
+			return property.Value
+		}
+		return ""
+	})
+}
+
+// condition evaluates the Condition attribute of node, true when absent.
+// Conditions that fail to parse are false.
+func (p *msbuildProject) condition(node *XMLNode) bool {
+	condition := strings.TrimSpace(attrValue(node, "Condition"))
+	if condition == "" {
+		return true
+	}
+	c := &msbuildCondition{project: p, tokens: tokenizeMSBuildCondition(condition)}
+	result, err := c.or()
+	if err != nil || c.pos != len(c.tokens) {
+		return false
+	}
+	return result
+}
+
+// tokenizeMSBuildCondition splits a condition into quoted strings (kept
+// with their opening quote), operators, parentheses, commas and words
+func tokenizeMSBuildCondition(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				end = len(s) - i - 1
+			}
+			tokens = append(tokens, s[i:i+1+end])
+			i += end + 2
+		case strings.HasPrefix(s[i:], "==") || strings.HasPrefix(s[i:], "!=") || strings.HasPrefix(s[i:], "<=") || strings.HasPrefix(s[i:], ">="):
+			tokens = append(tokens, s[i:i+2])
+			i += 2
+		case c == '(' || c == ')' || c == '!' || c == '<' || c == '>' || c == ',':
+			tokens = append(tokens, s[i:i+1])
+			i++
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n\r'()!<>=,", rune(s[j])) {
+				// $(Name) is part of a word
+				if s[j] == '$' && j+1 < len(s) && s[j+1] == '(' {
+					if end := strings.IndexByte(s[j:], ')'); end > 0 {
+						j += end + 1
+						continue
+					}
+				}
+				j++
+			}
+			if j == i {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+// msbuildCondition is a recursive-descent parser evaluating a condition
+type msbuildCondition struct {
+	project *msbuildProject
+	tokens  []string
+	pos     int
+}
+
+// peek returns the next token, or "" at the end
+func (c *msbuildCondition) peek() string {
+	if c.pos < len(c.tokens) {
+		return c.tokens[c.pos]
+	}
+	return ""
+}
+
+// or parses a disjunction
+func (c *msbuildCondition) or() (bool, error) {
+	result, err := c.and()
+	for err == nil && strings.EqualFold(c.peek(), "or") {
+		c.pos++
+		var right bool
+		right, err = c.and()
+		result = result || right
+	}
+	return result, err
+}
+
+// and parses a conjunction
+func (c *msbuildCondition) and() (bool, error) {
+	result, err := c.not()
+	for err == nil && strings.EqualFold(c.peek(), "and") {
+		c.pos++
+		var right bool
+		right, err = c.not()
+		result = result && right
+	}
+	return result, err
+}
+
+// not parses a negation or a primary expression
+func (c *msbuildCondition) not() (bool, error) {
+	if c.peek() == "!" {
+		c.pos++
+		result, err := c.not()
+		return !result, err
+	}
+	if c.peek() == "(" {
+		c.pos++
+		result, err := c.or()
+		if err == nil && c.peek() != ")" {
+			err = fmt.Errorf("missing )")
+		}
+		c.pos++
+		return result, err
+	}
+	if c.pos+1 < len(c.tokens) && c.tokens[c.pos+1] == "(" && !strings.HasPrefix(c.peek(), "'") {
+		return c.function()
+	}
+	left, err := c.operand()
+	if err != nil {
+		return false, err
+	}
+	op := c.peek()
+	switch op {
+	case "==", "!=", "<", ">", "<=", ">=":
+		c.pos++
+	default:
+		return strings.EqualFold(left, "true"), nil
+	}
+	right, err := c.operand()
+	if err != nil {
+		return false, err
+	}
+	switch op {
+	case "==":
+		return strings.EqualFold(left, right), nil
+	case "!=":
+		return !strings.EqualFold(left, right), nil
+	}
+	l, lerr := strconv.ParseFloat(left, 64)
+	r, rerr := strconv.ParseFloat(right, 64)
+	if lerr != nil || rerr != nil {
+		return false, fmt.Errorf("%s compares non-numbers", op)
+	}
+	switch op {
+	case "<":
+		return l < r, nil
+	case ">":
+		return l > r, nil
+	case "<=":
+		return l <= r, nil
+	}
+	return l >= r, nil
+}
+
+// function evaluates the condition functions Exists (always false, since
+// the file system of the project is not available) and HasTrailingSlash
+func (c *msbuildCondition) function() (bool, error) {
+	name := c.peek()
+	c.pos += 2
+	var args []string
+	for c.peek() != ")" {
+		if c.pos >= len(c.tokens) {
+			return false, fmt.Errorf("missing )")
+		}
+		if c.peek() == "," {
+			c.pos++
+			continue
+		}
+		arg, err := c.operand()
+		if err != nil {
+			return false, err
+		}
+		args = append(args, arg)
+	}
+	c.pos++
+	switch strings.ToLower(name) {
+	case "exists":
+		return false, nil
+	case "hastrailingslash":
+		return len(args) == 1 && (strings.HasSuffix(args[0], "/") || strings.HasSuffix(args[0], "\\")), nil
+	}
+	return false, fmt.Errorf("unsupported function %s", name)
+}
+
+// operand returns the expanded value of a quoted string or word
+func (c *msbuildCondition) operand() (string, error) {
+	token := c.peek()
+	if token == "" || token == ")" || token == "(" || token == "," {
+		return "", fmt.Errorf("missing operand")
+	}
+	c.pos++
+	return c.project.expand(strings.TrimPrefix(token, "'")), nil
+}
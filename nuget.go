@@ -0,0 +1,114 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+func init() {
+	extractors["nuget"] = nugetExtractor{}
+}
+
+// NuGetPackageRow is the metadata of one .nuspec manifest in
+// nuget_packages.parquet. license is the license expression or file, or
+// the legacy licenseUrl.
+type NuGetPackageRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ID            string `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Version       string `parquet:"name=version, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Authors       string `parquet:"name=authors, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Description   string `parquet:"name=description, type=BYTE_ARRAY, convertedtype=UTF8"`
+	License       string `parquet:"name=license, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ProjectURL    string `parquet:"name=project_url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Repository    string `parquet:"name=repository, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Tags          string `parquet:"name=tags, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// NuGetDependencyRow is one dependency of a package in
+// nuget_dependencies.parquet. target_framework is "" for dependencies that
+// are not grouped by framework.
+type NuGetDependencyRow struct {
+	FilePath        string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID          int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath   string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	PackageID       string `parquet:"name=package_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	PackageVersion  string `parquet:"name=package_version, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TargetFramework string `parquet:"name=target_framework, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	DependencyID    string `parquet:"name=dependency_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	VersionRange    string `parquet:"name=version_range, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// nugetExtractor inventories NuGet packages from their .nuspec manifests,
+// inside .nupkg containers or on their own
+type nugetExtractor struct{}
+
+// Tables returns the nuget_packages and nuget_dependencies tables
+func (nugetExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{
+		"nuget_packages":     new(NuGetPackageRow),
+		"nuget_dependencies": new(NuGetDependencyRow),
+	}
+}
+
+// Detect accepts .nuspec documents rooted at package
+func (nugetExtractor) Detect(doc *Document) bool {
+	return doc.Root.XMLName.Local == "package" && strings.EqualFold(path.Ext(doc.name()), ".nuspec")
+}
+
+// Extract writes the package row and one row per dependency
+func (nugetExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	metadata := childElement(doc.Root, "metadata")
+	if metadata == nil {
+		return nil
+	}
+	text := func(local string) string {
+		if node := childElement(metadata, local); node != nil {
+			return strings.TrimSpace(node.Content)
+		}
+		return ""
+	}
+	pkg := NuGetPackageRow{
+		FilePath:      doc.FilePath,
+		ContainerPath: doc.ContainerPath,
+		ID:            text("id"),
+		Version:       text("version"),
+		Authors:       text("authors"),
+		Description:   text("description"),
+		License:       text("license"),
+		ProjectURL:    text("projectUrl"),
+		Repository:    attrValue(childElement(metadata, "repository"), "url"),
+		Tags:          text("tags"),
+	}
+	if pkg.License == "" {
+		pkg.License = text("licenseUrl")
+	}
+
+	var err error
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil {
+			return
+		}
+		switch {
+		case node == metadata:
+			pkg.NodeID = nodeID
+			err = rows("nuget_packages", pkg)
+		case node.XMLName.Local == "dependency" && parent != nil:
+			row := NuGetDependencyRow{
+				FilePath:       doc.FilePath,
+				NodeID:         nodeID,
+				ContainerPath:  doc.ContainerPath,
+				PackageID:      pkg.ID,
+				PackageVersion: pkg.Version,
+				DependencyID:   attrValue(node, "id"),
+				VersionRange:   attrValue(node, "version"),
+			}
+			if parent.XMLName.Local == "group" {
+				row.TargetFramework = attrValue(parent, "targetFramework")
+			}
+			err = rows("nuget_dependencies", row)
+		}
+	})
+	return err
+}
@@ -0,0 +1,118 @@
+package main
+
+import "fmt"
+
+// nullPolicy decides how absent values reach the OPTIONAL Parquet columns:
+// "null" writes true nulls, "sentinel" writes 0 and "" as in ParquetRow.
+// sentinel is the default so existing outputs keep their values.
+var nullPolicy = "sentinel"
+
+// nullPolicies lists the accepted --nulls values
+var nullPolicies = []string{"null", "sentinel"}
+
+// parquetRecord is the on-disk shape of a ParquetRow. The OPTIONAL columns
+// are pointers so parquet-go can tell a null from a value; see
+// newParquetRecord for when each one is null.
+type parquetRecord struct {
+	NodeID         int64   `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ParentNodeID   *int64  `parquet:"name=parent_node_id, type=INT64, convertedtype=INT_64, repetitiontype=OPTIONAL"`
+	TagName        *string `parquet:"name=tag_name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL"`
+	AttributeName  *string `parquet:"name=attribute_name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL"`
+	AttributeValue *string `parquet:"name=attribute_value, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL"`
+	IsNode         bool    `parquet:"name=is_node, type=BOOLEAN"`
+	IsRoot         bool    `parquet:"name=is_root, type=BOOLEAN"`
+	TagID          *int32  `parquet:"name=tag_id, type=INT32, convertedtype=INT_32, repetitiontype=OPTIONAL"`
+	AttributeID    *int32  `parquet:"name=attribute_id, type=INT32, convertedtype=INT_32, repetitiontype=OPTIONAL"`
+	FilePath       string  `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ContainerPath  string  `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EntryPath      *string `parquet:"name=entry_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, repetitiontype=OPTIONAL"`
+	SelfClosing    *bool   `parquet:"name=self_closing, type=BOOLEAN, repetitiontype=OPTIONAL"`
+}
+
+// validNullPolicy checks a --nulls value
+func validNullPolicy(policy string) error {
+	for _, p := range nullPolicies {
+		if p == policy {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown null policy %q (expected null or sentinel)", policy)
+}
+
+// newParquetRecord converts row for writing. Under the null policy:
+//   - parent_node_id is null for document roots and for attribute rows
+//   - tag_name is null for attribute rows
+//   - attribute_name is null for node rows and content rows
+//   - attribute_value is null for node rows
+//   - tag_id is null unless --tag-dictionary is set, and for attribute rows
+//   - attribute_id is null unless --attribute-dictionary is set, and for
+//     node rows and content rows
+//   - entry_path is null for documents that are not container entries
+//   - self_closing is null unless --self-closing is set, and for attribute
+//     rows and content rows
+//
+// With --tag-ids tag_name is null on every row and tag_id carries the tag;
+// --attribute-ids does the same for attribute_name and attribute_id.
+// An attribute whose value is empty keeps its "" value. Under the sentinel
+// policy every column carries the ParquetRow value, so roots have parent 0
+// and absent names are "". Under either policy is_root is true exactly for
+// the node row of each document's root element.
+//
+// The OPTIONAL columns point into a copy of row, not into row itself:
+// parquet-go holds records until it flushes, and by then the caller may
+// have reused row's storage for the next batch.
+func newParquetRecord(src *ParquetRow) parquetRecord {
+	row := new(ParquetRow)
+	*row = *src
+	rec := parquetRecord{
+		NodeID:        row.NodeID,
+		IsNode:        row.IsNode,
+		IsRoot:        row.IsNode && row.ParentNodeID == 0,
+		FilePath:      row.FilePath,
+		ContainerPath: row.ContainerPath,
+	}
+	if row.EntryPath != "" || nullPolicy == "sentinel" {
+		rec.EntryPath = &row.EntryPath
+	}
+
+	if nullPolicy == "sentinel" {
+		rec.ParentNodeID = &row.ParentNodeID
+		rec.TagName = &row.TagName
+		rec.AttributeName = &row.AttributeName
+		rec.AttributeValue = &row.AttributeValue
+		rec.TagID = &row.TagID
+		rec.AttributeID = &row.AttributeID
+		rec.SelfClosing = &row.SelfClosing
+		if tagIDsOnly {
+			rec.TagName = nil
+		}
+		if attributeIDsOnly {
+			rec.AttributeName = nil
+		}
+		return rec
+	}
+
+	if row.IsNode {
+		if row.ParentNodeID != 0 {
+			rec.ParentNodeID = &row.ParentNodeID
+		}
+		if row.TagID != 0 {
+			rec.TagID = &row.TagID
+		}
+		if !tagIDsOnly {
+			rec.TagName = &row.TagName
+		}
+		if markSelfClosing {
+			rec.SelfClosing = &row.SelfClosing
+		}
+		return rec
+	}
+	if row.AttributeName != "" && !attributeIDsOnly {
+		rec.AttributeName = &row.AttributeName
+	}
+	if row.AttributeID != 0 {
+		rec.AttributeID = &row.AttributeID
+	}
+	rec.AttributeValue = &row.AttributeValue
+	return rec
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// objectInfo describes one object of a listing
+type objectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// objectStore lists and reads the objects of one bucket
+type objectStore interface {
+	// list returns every object whose key starts with prefix
+	list(prefix string) ([]objectInfo, error)
+	// open streams the content of an object
+	open(key string) (io.ReadCloser, error)
+}
+
+// objectStores maps URL schemes to store constructors taking the bucket
+var objectStores = map[string]func(bucket string) (objectStore, error){
+	"s3": newS3Store,
+}
+
+// openObjectStore parses a scheme://bucket/prefix URL into a store and prefix
+func openObjectStore(rawURL string) (objectStore, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, "", fmt.Errorf("invalid object store URL %q (expected scheme://bucket/prefix)", rawURL)
+	}
+	newStore, ok := objectStores[u.Scheme]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported object store scheme %q", u.Scheme)
+	}
+	store, err := newStore(u.Host)
+	if err != nil {
+		return nil, "", err
+	}
+	return store, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// s3Endpoint overrides the AWS endpoint for S3-compatible stores such as
+// MinIO; objects are then addressed path-style
+var s3Endpoint string
+
+// s3Store reads a bucket with the S3 REST API, signing requests with
+// Signature Version 4 and the standard AWS_* environment credentials
+type s3Store struct {
+	bucket       string
+	region       string
+	endpoint     *url.URL
+	pathStyle    bool
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+// newS3Store creates a store for bucket
+func newS3Store(bucket string) (objectStore, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	s := &s3Store{
+		bucket:       bucket,
+		region:       region,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: 5 * time.Minute},
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, fmt.Errorf("S3 access requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	endpoint := "https://" + bucket + ".s3." + region + ".amazonaws.com"
+	if s3Endpoint != "" {
+		endpoint = strings.TrimSuffix(s3Endpoint, "/")
+		s.pathStyle = true
+	}
+	var err error
+	if s.endpoint, err = url.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: %v", endpoint, err)
+	}
+	return s, nil
+}
+
+// s3ListResult is the part of a ListObjectsV2 response xmlgo uses
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list pages through ListObjectsV2
+func (s *s3Store) list(prefix string) ([]objectInfo, error) {
+	var objects []objectInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do("", query)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse listing of s3://%s/%s: %v", s.bucket, prefix, err)
+		}
+
+		for _, c := range result.Contents {
+			if strings.HasSuffix(c.Key, "/") {
+				continue
+			}
+			objects = append(objects, objectInfo{Key: c.Key, Size: c.Size, LastModified: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// open downloads an object
+func (s *s3Store) open(key string) (io.ReadCloser, error) {
+	resp, err := s.do(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do sends a signed GET for key (the bucket itself when empty)
+func (s *s3Store) do(key string, query url.Values) (*http.Response, error) {
+	u := *s.endpoint
+	path := key
+	if s.pathStyle {
+		path = s.bucket + "/" + key
+	}
+	// Every segment is encoded the way the signature expects; the URL
+	// package leaves characters such as + and = alone
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = sigV4Escape(segment)
+	}
+	u.Path = "/" + path
+	u.RawPath = "/" + strings.Join(segments, "/")
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %v", err)
+	}
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request s3://%s/%s: %v", s.bucket, key, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3://%s/%s: %s: %s", s.bucket, key, resp.Status, strings.TrimSpace(string(reply)))
+	}
+	return resp, nil
+}
+
+// emptyPayloadHash is the SHA-256 of the empty body of a GET
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds the Signature Version 4 authorization headers to req
+func (s *s3Store) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.sessionToken)
+	}
+
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if s.sessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// hmacSHA256 computes HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by key with %20 for spaces, as
+// Signature Version 4 requires
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, sigV4Escape(k)+"="+sigV4Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// sigV4Escape percent-encodes everything but unreserved characters
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
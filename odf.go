@@ -0,0 +1,459 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	extractors["odf"] = &odfExtractor{}
+}
+
+// ODFParagraphRow is one paragraph or heading of an OpenDocument text or
+// presentation in odf_paragraphs.parquet. style is the display name of its
+// paragraph style, with automatic styles resolved to the style they derive
+// from; section is the slide of presentation paragraphs. list_style,
+// list_level and numbered describe paragraphs in lists.
+type ODFParagraphRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Section       string `parquet:"name=section, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Kind          string `parquet:"name=kind, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	OutlineLevel  int32  `parquet:"name=outline_level, type=INT32, convertedtype=INT_32"`
+	Style         string `parquet:"name=style, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ListStyle     string `parquet:"name=list_style, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ListLevel     int32  `parquet:"name=list_level, type=INT32, convertedtype=INT_32"`
+	Numbered      bool   `parquet:"name=numbered, type=BOOLEAN"`
+	Text          string `parquet:"name=text, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// ODFCellRow is one non-empty cell of an OpenDocument spreadsheet in
+// odf_cells.parquet, with repeated rows and columns expanded into A1 cell
+// references. value is the typed office:value (number, date, time,
+// boolean, currency or percentage) and text what the cell displays.
+type ODFCellRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Sheet         string `parquet:"name=sheet, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Cell          string `parquet:"name=cell, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ValueType     string `parquet:"name=value_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Value         string `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Text          string `parquet:"name=text, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Formula       string `parquet:"name=formula, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Style         string `parquet:"name=style, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// Parts of an OpenDocument package the extractor reads
+const (
+	odfContentPart = "content.xml"
+	odfStylesPart  = "styles.xml"
+	odfMetaPart    = "meta.xml"
+)
+
+// odfTextSpace is the namespace of ODF text elements
+const odfTextSpace = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+
+// maxODFRepeat bounds how many cells a repeated non-empty cell or row
+// expands to
+const maxODFRepeat = 1 << 16
+
+// odfExtractor resolves the content of OpenDocument text, spreadsheet and
+// presentation packages together with their styles and metadata, into
+// tables comparable to the office-properties and xlsx extractors
+type odfExtractor struct {
+	// parts and styles memoize the styles of the last package
+	parts  *containerParts
+	styles *odfStyles
+}
+
+// Tables returns the odf_properties, odf_paragraphs and odf_cells tables
+func (e *odfExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{
+		"odf_properties": new(OfficePropertyRow),
+		"odf_paragraphs": new(ODFParagraphRow),
+		"odf_cells":      new(ODFCellRow),
+	}
+}
+
+// Detect accepts the content.xml and meta.xml parts of a package
+func (e *odfExtractor) Detect(doc *Document) bool {
+	switch doc.EntryPath {
+	case odfContentPart:
+		return doc.Root.XMLName.Local == "document-content"
+	case odfMetaPart:
+		return doc.Root.XMLName.Local == "document-meta"
+	}
+	return false
+}
+
+// Extract writes the properties of meta.xml, or the paragraphs and cells
+// of content.xml
+func (e *odfExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	if doc.EntryPath == odfMetaPart {
+		return extractODFMeta(doc, rows)
+	}
+	styles, err := e.packageStyles(doc)
+	if err != nil {
+		return err
+	}
+	texts, err := odfParagraphTexts(doc)
+	if err != nil {
+		return err
+	}
+
+	parents := make(map[*XMLNode]*XMLNode)
+	paragraph := 0
+	var sheet string
+	row, nextRow, col := 0, 1, 0
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		parents[node] = parent
+		if err != nil {
+			return
+		}
+		switch node.XMLName.Local {
+		case "p", "h":
+			if node.XMLName.Space != odfTextSpace {
+				return
+			}
+			text := ""
+			if paragraph < len(texts) {
+				text = texts[paragraph]
+			}
+			paragraph++
+			if odfAncestor(parents, node, "spreadsheet") != nil {
+				return // cell text is part of odf_cells
+			}
+			err = rows("odf_paragraphs", e.paragraphRow(doc, parents, node, nodeID, text))
+		case "table":
+			sheet, nextRow = attrValue(node, "name"), 1
+		case "table-row":
+			row, col = nextRow, 0
+			nextRow += odfRepeat(node, "number-rows-repeated")
+		case "table-cell", "covered-table-cell":
+			if odfAncestor(parents, node, "spreadsheet") == nil {
+				return
+			}
+			cols := odfRepeat(node, "number-columns-repeated")
+			rowsRepeated := odfRepeat(parent, "number-rows-repeated")
+			cell := ODFCellRow{
+				FilePath:      doc.FilePath,
+				NodeID:        nodeID,
+				ContainerPath: doc.ContainerPath,
+				Sheet:         sheet,
+				ValueType:     attrValue(node, "value-type"),
+				Value:         odfCellValue(node),
+				Formula:       attrValue(node, "formula"),
+				Style:         styles.display(attrValue(node, "style-name")),
+			}
+			var lines []string
+			for i := range node.Nodes {
+				if p := &node.Nodes[i]; p.XMLName.Space == odfTextSpace && p.XMLName.Local == "p" {
+					if index := paragraph + odfParagraphIndex(node, p); index < len(texts) {
+						lines = append(lines, texts[index])
+					}
+				}
+			}
+			cell.Text = strings.Join(lines, "\n")
+			if cell.ValueType != "" || cell.Text != "" || cell.Formula != "" {
+				for r := 0; r < rowsRepeated && r < maxODFRepeat && err == nil; r++ {
+					for c := 0; c < cols && c < maxODFRepeat && err == nil; c++ {
+						cell.Cell = columnLetters(col+c+1) + strconv.Itoa(row+r)
+						err = rows("odf_cells", cell)
+					}
+				}
+			}
+			col += cols
+		}
+	})
+	return err
+}
+
+// paragraphRow builds the odf_paragraphs row of a text:p or text:h element
+func (e *odfExtractor) paragraphRow(doc *Document, parents map[*XMLNode]*XMLNode, node *XMLNode, nodeID int64, text string) ODFParagraphRow {
+	row := ODFParagraphRow{
+		FilePath:      doc.FilePath,
+		NodeID:        nodeID,
+		ContainerPath: doc.ContainerPath,
+		Kind:          "paragraph",
+		Style:         e.styles.display(attrValue(node, "style-name")),
+		Text:          text,
+	}
+	if node.XMLName.Local == "h" {
+		row.Kind = "heading"
+		level, _ := strconv.Atoi(attrValue(node, "outline-level"))
+		row.OutlineLevel = int32(level)
+	}
+	if page := odfAncestor(parents, node, "page"); page != nil {
+		row.Section = attrValue(page, "name")
+	}
+	// Nested lists inherit the style of the outermost list naming one
+	listStyle := ""
+	for n := parents[node]; n != nil; n = parents[n] {
+		if n.XMLName.Local == "list" && n.XMLName.Space == odfTextSpace {
+			row.ListLevel++
+			if name := attrValue(n, "style-name"); name != "" {
+				listStyle = name
+			}
+		}
+	}
+	if row.ListLevel > 0 {
+		row.ListStyle = e.styles.display(listStyle)
+		row.Numbered = e.styles.numbered(listStyle, int(row.ListLevel))
+	}
+	return row
+}
+
+// odfAncestor returns the nearest ancestor of node named local, or nil
+func odfAncestor(parents map[*XMLNode]*XMLNode, node *XMLNode, local string) *XMLNode {
+	for n := parents[node]; n != nil; n = parents[n] {
+		if n.XMLName.Local == local {
+			return n
+		}
+	}
+	return nil
+}
+
+// odfParagraphIndex counts the text paragraphs and headings that precede
+// child inside cell in document order, so a cell's paragraphs can be
+// matched with the texts read by odfParagraphTexts
+func odfParagraphIndex(cell, child *XMLNode) int {
+	index := 0
+	found := false
+	var count func(n *XMLNode)
+	count = func(n *XMLNode) {
+		for i := range n.Nodes {
+			c := &n.Nodes[i]
+			if found {
+				return
+			}
+			if c == child {
+				found = true
+				return
+			}
+			if c.XMLName.Space == odfTextSpace && (c.XMLName.Local == "p" || c.XMLName.Local == "h") {
+				index++
+			}
+			count(c)
+		}
+	}
+	count(cell)
+	return index
+}
+
+// odfRepeat reads a table:number-*-repeated attribute, 1 when it is absent
+func odfRepeat(node *XMLNode, local string) int {
+	n, err := strconv.Atoi(attrValue(node, local))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// odfCellValue returns the typed value of a cell from the attribute its
+// value type stores it in
+func odfCellValue(cell *XMLNode) string {
+	switch attrValue(cell, "value-type") {
+	case "date":
+		return attrValue(cell, "date-value")
+	case "time":
+		return attrValue(cell, "time-value")
+	case "boolean":
+		return attrValue(cell, "boolean-value")
+	case "string":
+		return attrValue(cell, "string-value")
+	}
+	return attrValue(cell, "value")
+}
+
+// extractODFMeta writes the properties of meta.xml, with meta:user-defined
+// properties as source custom like the custom properties of OOXML
+func extractODFMeta(doc *Document, rows func(table string, row interface{}) error) error {
+	meta := childElement(doc.Root, "meta")
+	var err error
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil || parent != meta || meta == nil {
+			return
+		}
+		row := OfficePropertyRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, Source: "meta"}
+		switch node.XMLName.Local {
+		case "user-defined":
+			row.Source, row.Name, row.Type = "custom", attrValue(node, "name"), attrValue(node, "value-type")
+			row.Value = strings.TrimSpace(node.Content)
+		case "document-statistic":
+			// Counts are attributes (meta:page-count, meta:word-count, ...)
+			for _, a := range node.Attrs {
+				row.Name, row.Value = a.Name.Local, a.Value
+				if err = rows("odf_properties", row); err != nil {
+					return
+				}
+			}
+			return
+		default:
+			if len(node.Nodes) > 0 {
+				return
+			}
+			row.Name, row.Value = node.XMLName.Local, strings.TrimSpace(node.Content)
+		}
+		err = rows("odf_properties", row)
+	})
+	return err
+}
+
+// odfStyles indexes the styles of a package: the automatic styles of
+// content.xml, which derive from the named styles of styles.xml, and the
+// list styles of both
+type odfStyles struct {
+	parent  map[string]string // automatic style to the style it derives from
+	names   map[string]string // named style to its display name
+	numbers map[string][]bool // list style to whether each level is numbered
+}
+
+// packageStyles returns the styles of doc's package
+func (e *odfExtractor) packageStyles(doc *Document) (*odfStyles, error) {
+	if e.styles != nil && e.parts == doc.parts {
+		return e.styles, nil
+	}
+	styles := &odfStyles{parent: make(map[string]string), names: make(map[string]string), numbers: make(map[string][]bool)}
+	named, err := doc.part(odfStylesPart)
+	if err != nil {
+		return nil, err
+	}
+	common := childElement(named, "styles")
+	for _, container := range []*XMLNode{common, childElement(named, "automatic-styles"), childElement(doc.Root, "automatic-styles")} {
+		for i := range nodesOf(container) {
+			style := &container.Nodes[i]
+			name := attrValue(style, "name")
+			switch style.XMLName.Local {
+			case "style":
+				if container == common {
+					styles.names[name] = attrValue(style, "display-name")
+				} else if parent := attrValue(style, "parent-style-name"); parent != "" {
+					styles.parent[name] = parent
+				}
+			case "list-style":
+				if container == common {
+					styles.names[name] = attrValue(style, "display-name")
+				}
+				for j := range style.Nodes {
+					level, _ := strconv.Atoi(attrValue(&style.Nodes[j], "level"))
+					if level < 1 || level > 10 {
+						continue
+					}
+					levels := styles.numbers[name]
+					for len(levels) < level {
+						levels = append(levels, false)
+					}
+					levels[level-1] = style.Nodes[j].XMLName.Local == "list-level-style-number"
+					styles.numbers[name] = levels
+				}
+			}
+		}
+	}
+	e.parts, e.styles = doc.parts, styles
+	return styles, nil
+}
+
+// nodesOf returns the children of node, or nil when node is nil
+func nodesOf(node *XMLNode) []XMLNode {
+	if node == nil {
+		return nil
+	}
+	return node.Nodes
+}
+
+// display resolves a style name to the display name of the named style it
+// is or derives from
+func (s *odfStyles) display(name string) string {
+	if name == "" {
+		return ""
+	}
+	if parent, ok := s.parent[name]; ok {
+		name = parent
+	}
+	if display := s.names[name]; display != "" {
+		return display
+	}
+	return name
+}
+
+// numbered reports whether level (1-based) of a list style is numbered
+func (s *odfStyles) numbered(name string, level int) bool {
+	levels := s.numbers[name]
+	return level <= len(levels) && levels[level-1]
+}
+
+// odfParagraphTexts re-reads content.xml as a token stream and returns the
+// text of every text:p and text:h in document order, including the spaces,
+// tabs and line breaks ODF encodes as elements. The node tree keeps an
+// element's text separate from its children and cannot restore their order.
+func odfParagraphTexts(doc *Document) ([]string, error) {
+	f := doc.partFile(odfContentPart)
+	if f == nil {
+		return nil, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s in ZIP: %v", odfContentPart, err)
+	}
+	defer rc.Close()
+
+	var texts []*strings.Builder
+	var open []*strings.Builder // the paragraphs being read
+	appendText := func(s string) {
+		for _, b := range open {
+			b.WriteString(s)
+		}
+	}
+	decoder := xml.NewDecoder(rc)
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			result := make([]string, len(texts))
+			for i, b := range texts {
+				result[i] = b.String()
+			}
+			return result, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", odfContentPart, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != odfTextSpace {
+				continue
+			}
+			switch t.Name.Local {
+			case "p", "h":
+				b := new(strings.Builder)
+				open, texts = append(open, b), append(texts, b)
+			case "s":
+				n := 1
+				for _, a := range t.Attr {
+					if a.Name.Local == "c" {
+						if c, err := strconv.Atoi(a.Value); err == nil && c > 0 {
+							n = c
+						}
+					}
+				}
+				appendText(strings.Repeat(" ", n))
+			case "tab":
+				appendText("\t")
+			case "line-break":
+				appendText("\n")
+			}
+		case xml.EndElement:
+			if t.Name.Space == odfTextSpace && (t.Name.Local == "p" || t.Name.Local == "h") && len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		case xml.CharData:
+			if len(open) > 0 {
+				appendText(string(t))
+			}
+		}
+	}
+}
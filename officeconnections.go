@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+func init() {
+	extractors["office-connections"] = officeConnectionsExtractor{}
+}
+
+// OfficeConnectionRow is one external link or data connection of an Office
+// document in office_connections.parquet. Passwords and keys in connection
+// strings and URLs are replaced by REDACTED.
+type OfficeConnectionRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Kind          string `parquet:"name=kind, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Name          string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Target        string `parquet:"name=target, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Command       string `parquet:"name=command, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// officeConnectionsExtractor inventories what an Office document reaches
+// outside itself: the data connections of xl/connections.xml (ODBC, OLE
+// DB, web queries, text imports) and every relationship with an external
+// target (linked workbooks, remote templates, linked OLE objects,
+// hyperlinks), whose kind is the last segment of the relationship type
+type officeConnectionsExtractor struct{}
+
+// Tables returns the office_connections table
+func (officeConnectionsExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{"office_connections": new(OfficeConnectionRow)}
+}
+
+// Detect accepts the connections part of a workbook and the relationship
+// parts of any container
+func (officeConnectionsExtractor) Detect(doc *Document) bool {
+	if doc.EntryPath == "" {
+		return false
+	}
+	root := doc.Root.XMLName.Local
+	return root == "connections" || (root == "Relationships" && strings.HasSuffix(doc.EntryPath, ".rels"))
+}
+
+// connectionKinds names the type attribute values of a connection
+var connectionKinds = map[string]string{
+	"1": "odbc", "2": "dao", "3": "file", "4": "web", "5": "oledb", "6": "text", "7": "ado", "8": "dsp",
+}
+
+// Extract writes one row per connection or external relationship
+func (officeConnectionsExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	var err error
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil || parent != doc.Root {
+			return
+		}
+		row := OfficeConnectionRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath}
+		switch node.XMLName.Local {
+		case "connection":
+			row.Kind = connectionKinds[attrValue(node, "type")]
+			if row.Kind == "" {
+				row.Kind = "connection"
+			}
+			row.Name = attrValue(node, "name")
+			if db := childElement(node, "dbPr"); db != nil {
+				row.Target = redactConnectionString(attrValue(db, "connection"))
+				row.Command = attrValue(db, "command")
+			} else if web := childElement(node, "webPr"); web != nil {
+				row.Target = redactURL(attrValue(web, "url"))
+			} else if text := childElement(node, "textPr"); text != nil {
+				row.Target = attrValue(text, "sourceFile")
+			} else if olap := childElement(node, "olapPr"); olap != nil {
+				row.Target = redactConnectionString(attrValue(olap, "connection"))
+			}
+			if file := attrValue(node, "sourceFile"); row.Target == "" && file != "" {
+				row.Target = file
+			}
+		case "Relationship":
+			if attrValue(node, "TargetMode") != "External" {
+				return
+			}
+			row.Kind = path.Base(attrValue(node, "Type"))
+			row.Name = attrValue(node, "Id")
+			row.Target = redactURL(attrValue(node, "Target"))
+		default:
+			return
+		}
+		err = rows("office_connections", row)
+	})
+	return err
+}
+
+// redactConnectionString replaces the values of credential keys in a
+// key=value; connection string
+func redactConnectionString(s string) string {
+	parts := strings.Split(s, ";")
+	for i, part := range parts {
+		key, _, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		k := strings.ToLower(strings.TrimSpace(key))
+		if strings.Contains(k, "password") || k == "pwd" || k == "accountkey" || k == "sharedaccesssignature" ||
+			strings.Contains(k, "secret") || strings.Contains(k, "token") {
+			parts[i] = key + "=REDACTED"
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// redactURL replaces the password of a URL's user info
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	return u.String()
+}
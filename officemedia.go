@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"path"
+	"strings"
+)
+
+func init() {
+	extractors["office-media"] = &officeMediaExtractor{}
+}
+
+// OfficeMediaRow is one reference from an Office document part to an image,
+// audio or video part in office_media.parquet. node_id is the referencing
+// element (a:blip, v:imagedata, p:videoFile, ...). Width and height are read
+// from PNG, JPEG and GIF headers and are 0 for other formats; bytes and
+// sha256 are empty for externally linked media.
+type OfficeMediaRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Element       string `parquet:"name=element, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Kind          string `parquet:"name=kind, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	MediaPath     string `parquet:"name=media_path, type=BYTE_ARRAY, convertedtype=UTF8"`
+	External      bool   `parquet:"name=external, type=BOOLEAN"`
+	Bytes         int64  `parquet:"name=bytes, type=INT64, convertedtype=INT_64"`
+	SHA256        string `parquet:"name=sha256, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Format        string `parquet:"name=format, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Width         int32  `parquet:"name=width, type=INT32, convertedtype=INT_32"`
+	Height        int32  `parquet:"name=height, type=INT32, convertedtype=INT_32"`
+}
+
+// mediaKinds are the relationship types whose targets are media parts
+var mediaKinds = map[string]bool{"image": true, "media": true, "video": true, "audio": true, "hdphoto": true}
+
+// mediaInfo is what officeMediaExtractor reads from a media part
+type mediaInfo struct {
+	bytes         int64
+	sha256        string
+	format        string
+	width, height int
+}
+
+// officeMediaExtractor inventories the media parts of Office documents
+// through the relationships of the parts that show them. Media entries are
+// only read to hash them and decode their header; whether they are also
+// copied to the output is up to the handler of their extension.
+type officeMediaExtractor struct {
+	// parts and media memoize the media of the last container, which its
+	// slides and sheets often share
+	parts *containerParts
+	media map[string]*mediaInfo
+}
+
+// Tables returns the office_media table
+func (e *officeMediaExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{"office_media": new(OfficeMediaRow)}
+}
+
+// Detect accepts the XML parts of a container other than relationship parts
+func (e *officeMediaExtractor) Detect(doc *Document) bool {
+	return doc.EntryPath != "" && !strings.HasSuffix(doc.EntryPath, ".rels")
+}
+
+// Extract writes one row per element referencing a media relationship of
+// the part
+func (e *officeMediaExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	rels, err := relationships(doc, doc.EntryPath)
+	if err != nil || len(rels) == 0 {
+		return err
+	}
+	media := make(map[string]opcRelationship)
+	for id, rel := range rels {
+		if mediaKinds[path.Base(rel.Type)] {
+			media[id] = rel
+		}
+	}
+	if len(media) == 0 {
+		return nil
+	}
+
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil {
+			return
+		}
+		for _, a := range node.Attrs {
+			rel, ok := media[a.Value]
+			if !ok || a.Name.Space == "xmlns" || !isRelationshipAttr(a.Name.Local) {
+				continue
+			}
+			row := OfficeMediaRow{
+				FilePath:      doc.FilePath,
+				NodeID:        nodeID,
+				ContainerPath: doc.ContainerPath,
+				Element:       node.XMLName.Local,
+				Kind:          path.Base(rel.Type),
+				MediaPath:     rel.Target,
+				External:      rel.External,
+			}
+			if !rel.External {
+				var info *mediaInfo
+				if info, err = e.inspect(doc, rel.Target); err != nil {
+					return
+				}
+				if info != nil {
+					row.Bytes, row.SHA256, row.Format = info.bytes, info.sha256, info.format
+					row.Width, row.Height = int32(info.width), int32(info.height)
+				}
+			}
+			if err = rows("office_media", row); err != nil {
+				return
+			}
+		}
+	})
+	return err
+}
+
+// isRelationshipAttr reports whether an attribute named local holds a
+// relationship Id (r:embed, r:link, r:id, r:pict)
+func isRelationshipAttr(local string) bool {
+	return local == "embed" || local == "link" || local == "id" || local == "pict"
+}
+
+// inspect hashes a media part and decodes its image header, returning nil
+// when the container has no such entry
+func (e *officeMediaExtractor) inspect(doc *Document, name string) (*mediaInfo, error) {
+	if e.parts != doc.parts {
+		e.parts, e.media = doc.parts, make(map[string]*mediaInfo)
+	}
+	if info, ok := e.media[name]; ok {
+		return info, nil
+	}
+	f := doc.partFile(name)
+	if f == nil {
+		e.media[name] = nil
+		return nil, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s in ZIP: %v", name, err)
+	}
+	defer rc.Close()
+
+	// Hash the whole part while the image decoder reads its header
+	hash := sha256.New()
+	tee := io.TeeReader(rc, hash)
+	info := &mediaInfo{format: strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")}
+	if config, format, err := image.DecodeConfig(tee); err == nil {
+		info.format, info.width, info.height = format, config.Width, config.Height
+	}
+	if _, err := io.Copy(hash, rc); err != nil {
+		return nil, fmt.Errorf("failed to read %s in ZIP: %v", name, err)
+	}
+	info.bytes = int64(f.UncompressedSize64)
+	info.sha256 = hex.EncodeToString(hash.Sum(nil))
+	e.media[name] = info
+	return info, nil
+}
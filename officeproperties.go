@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+func init() {
+	extractors["office-properties"] = officePropertiesExtractor{}
+}
+
+// OfficePropertyRow is one document property of an Office document in
+// office_properties.parquet. Source is core (author, dates, title), app
+// (application, company, counts) or custom; type is the variant type of
+// custom properties (lpwstr, filetime, i4, bool, ...).
+type OfficePropertyRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Source        string `parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Name          string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Value         string `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Type          string `parquet:"name=type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// officePropertiesExtractor flattens the docProps parts of an Office
+// document into name/value rows. Structured app properties such as
+// HeadingPairs and TitlesOfParts are left out.
+type officePropertiesExtractor struct{}
+
+// Tables returns the office_properties table
+func (officePropertiesExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{"office_properties": new(OfficePropertyRow)}
+}
+
+// Detect accepts docProps/core.xml, docProps/app.xml and docProps/custom.xml
+func (officePropertiesExtractor) Detect(doc *Document) bool {
+	switch doc.EntryPath {
+	case "docProps/core.xml":
+		return doc.Root.XMLName.Local == "coreProperties"
+	case "docProps/app.xml", "docProps/custom.xml":
+		return doc.Root.XMLName.Local == "Properties"
+	}
+	return false
+}
+
+// Extract writes one row per property
+func (officePropertiesExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	source := strings.TrimSuffix(path.Base(doc.EntryPath), ".xml")
+	var err error
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil || parent != doc.Root {
+			return
+		}
+		row := OfficePropertyRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, Source: source}
+		if source == "custom" {
+			if node.XMLName.Local != "property" || len(node.Nodes) == 0 {
+				return
+			}
+			value := &node.Nodes[0]
+			row.Name, row.Type, row.Value = attrValue(node, "name"), value.XMLName.Local, strings.TrimSpace(value.Content)
+		} else {
+			if len(node.Nodes) > 0 {
+				return
+			}
+			row.Name, row.Value = node.XMLName.Local, strings.TrimSpace(node.Content)
+		}
+		err = rows("office_properties", row)
+	})
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// opcRelationship is one entry of an OPC .rels part. Target is the part
+// name inside the container, or the raw target of an external relationship.
+type opcRelationship struct {
+	Type     string
+	Target   string
+	External bool
+}
+
+// relationships returns the relationships of the container part partName,
+// keyed by their Id
+func relationships(doc *Document, partName string) (map[string]opcRelationship, error) {
+	dir, base := path.Split(partName)
+	rels, err := doc.part(dir + "_rels/" + base + ".rels")
+	if err != nil || rels == nil {
+		return nil, err
+	}
+	result := make(map[string]opcRelationship)
+	for _, rel := range childElements(rels, "Relationship") {
+		r := opcRelationship{Type: attrValue(rel, "Type"), Target: attrValue(rel, "Target")}
+		if attrValue(rel, "TargetMode") == "External" {
+			r.External = true
+		} else if strings.HasPrefix(r.Target, "/") {
+			r.Target = strings.TrimPrefix(r.Target, "/")
+		} else {
+			r.Target = path.Join(dir, r.Target)
+		}
+		result[attrValue(rel, "Id")] = r
+	}
+	return result, nil
+}
+
+// workbookPart is the main part of a spreadsheet container
+const workbookPart = "xl/workbook.xml"
+
+// worksheetNames maps the worksheet part names of a workbook to the sheet
+// names users see
+func worksheetNames(doc *Document) (map[string]string, error) {
+	workbook, err := doc.part(workbookPart)
+	if err != nil || workbook == nil {
+		return nil, err
+	}
+	rels, err := relationships(doc, workbookPart)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, sheet := range childElements(childElement(workbook, "sheets"), "sheet") {
+		if rel, ok := rels[attrValue(sheet, "id")]; ok {
+			names[rel.Target] = attrValue(sheet, "name")
+		}
+	}
+	return names, nil
+}
+
+// externalWorkbooks returns the targets of a workbook's external links in
+// the order formulas number them ([1] is the first)
+func externalWorkbooks(doc *Document) ([]string, error) {
+	workbook, err := doc.part(workbookPart)
+	if err != nil || workbook == nil {
+		return nil, err
+	}
+	rels, err := relationships(doc, workbookPart)
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	for _, ref := range childElements(childElement(workbook, "externalReferences"), "externalReference") {
+		target := ""
+		if rel, ok := rels[attrValue(ref, "id")]; ok {
+			linkRels, err := relationships(doc, rel.Target)
+			if err != nil {
+				return nil, err
+			}
+			for _, linkRel := range linkRels {
+				if linkRel.External {
+					target = linkRel.Target
+				}
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"xmlgo/xmlstream"
+)
+
+func init() {
+	extractors["osm"] = osmExtractor{}
+}
+
+// OSMNodeRow is one node of an OpenStreetMap extract in osm_nodes.parquet
+type OSMNodeRow struct {
+	FilePath  string  `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ID        int64   `parquet:"name=id, type=INT64, convertedtype=INT_64"`
+	Lat       float64 `parquet:"name=lat, type=DOUBLE"`
+	Lon       float64 `parquet:"name=lon, type=DOUBLE"`
+	Version   int32   `parquet:"name=version, type=INT32, convertedtype=INT_32"`
+	Timestamp string  `parquet:"name=timestamp, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Changeset int64   `parquet:"name=changeset, type=INT64, convertedtype=INT_64"`
+	User      string  `parquet:"name=user, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	UID       int64   `parquet:"name=uid, type=INT64, convertedtype=INT_64"`
+	Visible   bool    `parquet:"name=visible, type=BOOLEAN"`
+}
+
+// OSMWayRow is one way in osm_ways.parquet; its nodes are in
+// osm_way_nodes.parquet
+type OSMWayRow struct {
+	FilePath  string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ID        int64  `parquet:"name=id, type=INT64, convertedtype=INT_64"`
+	Version   int32  `parquet:"name=version, type=INT32, convertedtype=INT_32"`
+	Timestamp string `parquet:"name=timestamp, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Changeset int64  `parquet:"name=changeset, type=INT64, convertedtype=INT_64"`
+	User      string `parquet:"name=user, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	UID       int64  `parquet:"name=uid, type=INT64, convertedtype=INT_64"`
+	Visible   bool   `parquet:"name=visible, type=BOOLEAN"`
+	NodeCount int32  `parquet:"name=node_count, type=INT32, convertedtype=INT_32"`
+}
+
+// OSMRelationRow is one relation in osm_relations.parquet; its members are
+// in osm_relation_members.parquet
+type OSMRelationRow struct {
+	FilePath    string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ID          int64  `parquet:"name=id, type=INT64, convertedtype=INT_64"`
+	Version     int32  `parquet:"name=version, type=INT32, convertedtype=INT_32"`
+	Timestamp   string `parquet:"name=timestamp, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Changeset   int64  `parquet:"name=changeset, type=INT64, convertedtype=INT_64"`
+	User        string `parquet:"name=user, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	UID         int64  `parquet:"name=uid, type=INT64, convertedtype=INT_64"`
+	Visible     bool   `parquet:"name=visible, type=BOOLEAN"`
+	MemberCount int32  `parquet:"name=member_count, type=INT32, convertedtype=INT_32"`
+}
+
+// OSMTagRow is one key/value tag of a node, way or relation in
+// osm_tags.parquet
+type OSMTagRow struct {
+	FilePath    string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ElementType string `parquet:"name=element_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ElementID   int64  `parquet:"name=element_id, type=INT64, convertedtype=INT_64"`
+	Key         string `parquet:"name=key, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Value       string `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// OSMWayNodeRow is the node at one position of a way in
+// osm_way_nodes.parquet
+type OSMWayNodeRow struct {
+	FilePath string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	WayID    int64  `parquet:"name=way_id, type=INT64, convertedtype=INT_64"`
+	Sequence int32  `parquet:"name=sequence, type=INT32, convertedtype=INT_32"`
+	NodeID   int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+}
+
+// OSMRelationMemberRow is one member of a relation in
+// osm_relation_members.parquet
+type OSMRelationMemberRow struct {
+	FilePath   string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	RelationID int64  `parquet:"name=relation_id, type=INT64, convertedtype=INT_64"`
+	Sequence   int32  `parquet:"name=sequence, type=INT32, convertedtype=INT_32"`
+	MemberType string `parquet:"name=member_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	MemberID   int64  `parquet:"name=member_id, type=INT64, convertedtype=INT_64"`
+	Role       string `parquet:"name=role, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// osmExtractor turns OpenStreetMap XML into typed node, way and relation
+// tables. Top-level .osm files are streamed, since planet extracts run to
+// tens of gigabytes, and write no rows to the combined output; .osm
+// documents decoded otherwise (inside containers, or listed in
+// --extensions) are read from their node tree.
+type osmExtractor struct{}
+
+// Tables returns the osm_* tables
+func (osmExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{
+		"osm_nodes":            new(OSMNodeRow),
+		"osm_ways":             new(OSMWayRow),
+		"osm_relations":        new(OSMRelationRow),
+		"osm_tags":             new(OSMTagRow),
+		"osm_way_nodes":        new(OSMWayNodeRow),
+		"osm_relation_members": new(OSMRelationMemberRow),
+	}
+}
+
+// Streams accepts top-level .osm files
+func (osmExtractor) Streams(fileName string) bool {
+	return strings.EqualFold(filepath.Ext(fileName), ".osm")
+}
+
+// Detect accepts documents rooted at osm
+func (osmExtractor) Detect(doc *Document) bool {
+	return doc.Root.XMLName.Local == "osm"
+}
+
+// Stream writes the rows of an .osm file holding only the element being
+// read in memory
+func (osmExtractor) Stream(r io.Reader, doc *Document, rows func(table string, row interface{}) error) error {
+	return xmlstream.Walk(r, &osmVisitor{reader: osmReader{filePath: doc.FilePath, rows: rows}})
+}
+
+// Extract writes the rows of a decoded OSM document
+func (osmExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	reader := osmReader{filePath: doc.FilePath, rows: rows}
+	var visit func(node *XMLNode, depth int) error
+	visit = func(node *XMLNode, depth int) error {
+		if err := reader.enter(node.XMLName.Local, node.Attrs, depth); err != nil {
+			return err
+		}
+		for i := range node.Nodes {
+			if err := visit(&node.Nodes[i], depth+1); err != nil {
+				return err
+			}
+		}
+		return reader.leave(depth)
+	}
+	return visit(doc.Root, 0)
+}
+
+// osmVisitor feeds the streaming parser's events to an osmReader
+type osmVisitor struct {
+	reader osmReader
+}
+
+// EnterElement passes the start of an element on
+func (v *osmVisitor) EnterElement(node xmlstream.Node) error {
+	return v.reader.enter(node.Name.Local, node.Attrs, node.Depth)
+}
+
+// Text ignores character data, which OSM XML does not use
+func (*osmVisitor) Text(xmlstream.Node) error {
+	return nil
+}
+
+// LeaveElement passes the end of an element on
+func (v *osmVisitor) LeaveElement(node xmlstream.Node) error {
+	return v.reader.leave(node.Depth)
+}
+
+// osmReader builds the rows of OSM elements from start and end events.
+// Nodes, ways and relations are the children of the osm root (depth 1);
+// their tag, nd and member children are at depth 2.
+type osmReader struct {
+	filePath string
+	rows     func(table string, row interface{}) error
+
+	// The element being read and how many nd or member children it has
+	kind     string
+	node     OSMNodeRow
+	way      OSMWayRow
+	relation OSMRelationRow
+	children int32
+}
+
+// enter starts an element or records a child of the current one
+func (r *osmReader) enter(local string, attrs []xml.Attr, depth int) error {
+	switch depth {
+	case 1:
+		r.kind, r.children = local, 0
+		a := osmAttrsOf(attrs)
+		switch local {
+		case "node":
+			r.node = OSMNodeRow{FilePath: r.filePath, ID: a.int("id"), Version: int32(a.int("version")), Timestamp: a["timestamp"],
+				Changeset: a.int("changeset"), User: a["user"], UID: a.int("uid"), Visible: a["visible"] != "false"}
+			r.node.Lat, _ = strconv.ParseFloat(a["lat"], 64)
+			r.node.Lon, _ = strconv.ParseFloat(a["lon"], 64)
+		case "way":
+			r.way = OSMWayRow{FilePath: r.filePath, ID: a.int("id"), Version: int32(a.int("version")), Timestamp: a["timestamp"],
+				Changeset: a.int("changeset"), User: a["user"], UID: a.int("uid"), Visible: a["visible"] != "false"}
+		case "relation":
+			r.relation = OSMRelationRow{FilePath: r.filePath, ID: a.int("id"), Version: int32(a.int("version")), Timestamp: a["timestamp"],
+				Changeset: a.int("changeset"), User: a["user"], UID: a.int("uid"), Visible: a["visible"] != "false"}
+		default:
+			r.kind = "" // bounds and other metadata
+		}
+	case 2:
+		a := osmAttrsOf(attrs)
+		switch {
+		case local == "tag" && r.kind != "":
+			return r.rows("osm_tags", OSMTagRow{FilePath: r.filePath, ElementType: r.kind, ElementID: r.elementID(), Key: a["k"], Value: a["v"]})
+		case local == "nd" && r.kind == "way":
+			r.children++
+			return r.rows("osm_way_nodes", OSMWayNodeRow{FilePath: r.filePath, WayID: r.way.ID, Sequence: r.children, NodeID: a.int("ref")})
+		case local == "member" && r.kind == "relation":
+			r.children++
+			return r.rows("osm_relation_members", OSMRelationMemberRow{FilePath: r.filePath, RelationID: r.relation.ID, Sequence: r.children,
+				MemberType: a["type"], MemberID: a.int("ref"), Role: a["role"]})
+		}
+	}
+	return nil
+}
+
+// leave writes the row of an element once its children are counted
+func (r *osmReader) leave(depth int) error {
+	if depth != 1 {
+		return nil
+	}
+	kind := r.kind
+	r.kind = ""
+	switch kind {
+	case "node":
+		return r.rows("osm_nodes", r.node)
+	case "way":
+		r.way.NodeCount = r.children
+		return r.rows("osm_ways", r.way)
+	case "relation":
+		r.relation.MemberCount = r.children
+		return r.rows("osm_relations", r.relation)
+	}
+	return nil
+}
+
+// elementID is the ID of the element being read
+func (r *osmReader) elementID() int64 {
+	switch r.kind {
+	case "node":
+		return r.node.ID
+	case "way":
+		return r.way.ID
+	}
+	return r.relation.ID
+}
+
+// osmAttrs indexes the attributes of an OSM element by local name
+type osmAttrs map[string]string
+
+// osmAttrsOf indexes an attribute list
+func osmAttrsOf(attrs []xml.Attr) osmAttrs {
+	a := make(osmAttrs, len(attrs))
+	for _, attr := range attrs {
+		a[attr.Name.Local] = attr.Value
+	}
+	return a
+}
+
+// int parses an integer attribute, 0 when absent
+func (a osmAttrs) int(name string) int64 {
+	n, _ := strconv.ParseInt(a[name], 10, 64)
+	return n
+}
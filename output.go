@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// outputPath overrides where the main output is written (--output)
+var outputPath string
+
+// unixSocketPrefix marks an --output value as a unix domain socket address
+const unixSocketPrefix = "unix:"
+
+// windowsPipePrefix is the namespace of Windows named pipes
+const windowsPipePrefix = `\\.\pipe\`
+
+// isStreamTarget reports whether path names a FIFO, socket or named pipe
+// rather than a regular file. Such targets can only be written once,
+// sequentially, so they are limited to the streaming formats.
+func isStreamTarget(path string) bool {
+	if strings.HasPrefix(path, unixSocketPrefix) || strings.HasPrefix(path, windowsPipePrefix) {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&(os.ModeNamedPipe|os.ModeSocket) != 0
+}
+
+// openOutputStream opens path for writing. Regular paths are created or
+// truncated, existing FIFOs and named pipes are opened for writing (blocking
+// until a reader attaches), and unix sockets are dialed.
+func openOutputStream(path string) (io.WriteCloser, error) {
+	if strings.HasPrefix(path, unixSocketPrefix) {
+		return dialUnix(strings.TrimPrefix(path, unixSocketPrefix))
+	}
+	if strings.HasPrefix(path, windowsPipePrefix) {
+		return os.OpenFile(path, os.O_WRONLY, 0)
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		switch {
+		case info.Mode()&os.ModeSocket != 0:
+			return dialUnix(path)
+		case info.Mode()&os.ModeNamedPipe != 0:
+			return os.OpenFile(path, os.O_WRONLY, 0)
+		}
+	}
+	return os.Create(path)
+}
+
+// dialUnix connects to a unix domain socket
+func dialUnix(path string) (io.WriteCloser, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to socket %s: %v", path, err)
+	}
+	return conn, nil
+}
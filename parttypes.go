@@ -0,0 +1,98 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// partTypes restricts OPC containers (OOXML, XPS, NuGet) to the entries
+// whose content type in [Content_Types].xml is listed (--part-types). A
+// type is given in full or by its trailing dotted segments without +xml, so
+// worksheet selects application/vnd.openxmlformats-officedocument.
+// spreadsheetml.worksheet+xml and sheet.main the workbook part.
+var partTypes []string
+
+// contentTypesName is the OPC part declaring the content type of every part
+const contentTypesName = "[Content_Types].xml"
+
+// contentTypes is the decoded [Content_Types].xml of a container
+type contentTypes struct {
+	Defaults []struct {
+		Extension   string `xml:"Extension,attr"`
+		ContentType string `xml:"ContentType,attr"`
+	} `xml:"Default"`
+	Overrides []struct {
+		PartName    string `xml:"PartName,attr"`
+		ContentType string `xml:"ContentType,attr"`
+	} `xml:"Override"`
+}
+
+// partFilter selects container entries by their declared content type
+type partFilter struct {
+	defaults  map[string]string
+	overrides map[string]string
+}
+
+// newPartFilter reads the content types of a container. It returns nil,
+// selecting every entry, without --part-types or when the container has no
+// [Content_Types].xml.
+func newPartFilter(files []*zip.File) (*partFilter, error) {
+	if len(partTypes) == 0 {
+		return nil, nil
+	}
+	for _, f := range files {
+		if f.Name != contentTypesName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s in ZIP: %v", contentTypesName, err)
+		}
+		defer rc.Close()
+		var types contentTypes
+		if err := xml.NewDecoder(rc).Decode(&types); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", contentTypesName, err)
+		}
+
+		filter := &partFilter{defaults: make(map[string]string), overrides: make(map[string]string)}
+		for _, d := range types.Defaults {
+			filter.defaults[strings.ToLower(d.Extension)] = d.ContentType
+		}
+		for _, o := range types.Overrides {
+			filter.overrides[strings.ToLower(strings.TrimPrefix(o.PartName, "/"))] = o.ContentType
+		}
+		return filter, nil
+	}
+	return nil, nil
+}
+
+// contentType returns the declared content type of an entry, or ""
+func (p *partFilter) contentType(name string) string {
+	if contentType, ok := p.overrides[strings.ToLower(name)]; ok {
+		return contentType
+	}
+	return p.defaults[strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))]
+}
+
+// excludes reports whether the entry name is left out by --part-types. Part
+// names compare case-insensitively, as OPC requires.
+func (p *partFilter) excludes(name string) bool {
+	if p == nil {
+		return false
+	}
+	contentType := strings.ToLower(p.contentType(name))
+	if contentType == "" {
+		return true
+	}
+	short := strings.TrimSuffix(contentType, "+xml")
+	for _, want := range partTypes {
+		want = strings.ToLower(want)
+		if contentType == want || strings.HasSuffix(short, "."+want) {
+			return false
+		}
+	}
+	return true
+}
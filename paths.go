@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// relPath computes the provenance path of target relative to base. Both are
+// normalized and made absolute first, so mixed relative/absolute arguments
+// and the different spellings of a UNC root (\\server\share, //server/share,
+// \\?\UNC\server\share) compare equal. When no relative path exists, e.g.
+// the input is on a network share and the output on a local drive, the
+// normalized absolute target is used instead of failing.
+func relPath(base, target string) string {
+	base, target = normalizePath(base), normalizePath(target)
+
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		absBase = base
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		absTarget = target
+	}
+
+	rel, err := filepath.Rel(absBase, absTarget)
+	if err != nil {
+		return absTarget
+	}
+	return rel
+}
+
+// withinDir reports whether path is dir or below it
+func withinDir(dir, path string) bool {
+	rel := relPath(dir, path)
+	return !filepath.IsAbs(rel) && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// provenanceRoot, when set, is the directory the file_path of top-level
+// inputs is relative to instead of the output directory. Backfills set it
+// to their staging directory so provenance is the object key.
+var provenanceRoot string
+
+// pathBase, when set, is the directory the file_path of top-level inputs
+// is relative to, such as the corpus root, so provenance does not depend on
+// where the output is written (--path-base)
+var pathBase string
+
+// inputProvenance returns the file_path recorded for a top-level input;
+// a --filelist entry may set it explicitly
+func inputProvenance(outputDir, fileName string) string {
+	if entry := fileListEntries[fileName]; entry.filePath != "" {
+		return entry.filePath
+	}
+	if provenanceRoot != "" {
+		return relPath(provenanceRoot, fileName)
+	}
+	if pathBase != "" {
+		return relPath(pathBase, fileName)
+	}
+	return relPath(outputDir, fileName)
+}
+
+// fsErrorClass groups file system errors by what an operator has to fix
+type fsErrorClass string
+
+const (
+	fsErrorNone       fsErrorClass = ""
+	fsErrorPermission fsErrorClass = "permission"
+	fsErrorLocked     fsErrorClass = "locked"
+	fsErrorNetwork    fsErrorClass = "network"
+	fsErrorNotFound   fsErrorClass = "not_found"
+)
+
+// classifyFSError reports which class a file system error belongs to
+func classifyFSError(err error) fsErrorClass {
+	if err == nil {
+		return fsErrorNone
+	}
+	if class := platformErrorClass(err); class != fsErrorNone {
+		return class
+	}
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return fsErrorPermission
+	case errors.Is(err, fs.ErrNotExist):
+		return fsErrorNotFound
+	}
+	return fsErrorNone
+}
+
+// fsErrorHint explains common file system failures, particularly on network shares
+func fsErrorHint(err error) string {
+	switch classifyFSError(err) {
+	case fsErrorPermission:
+		return " (permission denied: check the share and file permissions of the account running xmlgo)"
+	case fsErrorLocked:
+		return " (the file is locked by another process)"
+	case fsErrorNetwork:
+		return " (the network path is unavailable or the share could not be reached)"
+	}
+	return ""
+}
+
+// wrapFSError formats a file system error with its classification hint
+func wrapFSError(action, path string, err error) error {
+	return fmt.Errorf("failed to %s %s: %v%s", action, path, err, fsErrorHint(err))
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// normalizePath returns path unchanged; UNC syntax only exists on Windows
+func normalizePath(path string) string {
+	return path
+}
+
+// platformErrorClass classifies errno values seen on NFS/SMB mounts
+func platformErrorClass(err error) fsErrorClass {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return fsErrorNone
+	}
+	switch errno {
+	case syscall.ETXTBSY, syscall.EWOULDBLOCK:
+		return fsErrorLocked
+	case syscall.ESTALE, syscall.EHOSTDOWN, syscall.EHOSTUNREACH, syscall.ENOTCONN:
+		return fsErrorNetwork
+	}
+	return fsErrorNone
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+)
+
+// Win32 error codes that matter on network shares
+const (
+	errorSharingViolation    syscall.Errno = 32
+	errorLockViolation       syscall.Errno = 33
+	errorBadNetpath          syscall.Errno = 53
+	errorUnexpNetErr         syscall.Errno = 59
+	errorNetnameDeleted      syscall.Errno = 64
+	errorNetworkAccessDenied syscall.Errno = 65
+	errorBadNetName          syscall.Errno = 67
+	errorLogonFailure        syscall.Errno = 1326
+)
+
+// normalizePath converts the alternative spellings of UNC and
+// extended-length paths to their plain form: //server/share and
+// \\?\UNC\server\share become \\server\share, \\?\C:\ becomes C:\.
+func normalizePath(path string) string {
+	switch {
+	case strings.HasPrefix(path, `\\?\UNC\`):
+		path = `\\` + strings.TrimPrefix(path, `\\?\UNC\`)
+	case strings.HasPrefix(path, `\\?\`):
+		path = strings.TrimPrefix(path, `\\?\`)
+	case strings.HasPrefix(path, `//`) && !strings.HasPrefix(path, `///`):
+		path = strings.ReplaceAll(path, `/`, `\`)
+	}
+	return path
+}
+
+// platformErrorClass classifies Win32 errors that fs.ErrPermission does not cover
+func platformErrorClass(err error) fsErrorClass {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return fsErrorNone
+	}
+	switch errno {
+	case errorSharingViolation, errorLockViolation:
+		return fsErrorLocked
+	case errorNetworkAccessDenied, errorLogonFailure:
+		return fsErrorPermission
+	case errorBadNetpath, errorBadNetName, errorUnexpNetErr, errorNetnameDeleted:
+		return fsErrorNetwork
+	}
+	return fsErrorNone
+}
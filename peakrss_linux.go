@@ -0,0 +1,13 @@
+package main
+
+import "syscall"
+
+// peakRSS returns the peak resident set size of the process in bytes
+func peakRSS() uint64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	// Linux reports ru_maxrss in kilobytes
+	return uint64(usage.Maxrss) * 1024
+}
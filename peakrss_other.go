@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+// peakRSS is not available on this platform
+func peakRSS() uint64 {
+	return 0
+}
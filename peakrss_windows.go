@@ -0,0 +1,37 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// processMemoryCounters mirrors PROCESS_MEMORY_COUNTERS from psapi.h
+type processMemoryCounters struct {
+	cb                         uint32
+	pageFaultCount             uint32
+	peakWorkingSetSize         uintptr
+	workingSetSize             uintptr
+	quotaPeakPagedPoolUsage    uintptr
+	quotaPagedPoolUsage        uintptr
+	quotaPeakNonPagedPoolUsage uintptr
+	quotaNonPagedPoolUsage     uintptr
+	pagefileUsage              uintptr
+	peakPagefileUsage          uintptr
+}
+
+var procGetProcessMemoryInfo = syscall.NewLazyDLL("kernel32.dll").NewProc("K32GetProcessMemoryInfo")
+
+// peakRSS returns the peak working set size of the process in bytes
+func peakRSS() uint64 {
+	handle, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0
+	}
+	var counters processMemoryCounters
+	counters.cb = uint32(unsafe.Sizeof(counters))
+	ok, _, _ := procGetProcessMemoryInfo.Call(uintptr(handle), uintptr(unsafe.Pointer(&counters)), uintptr(counters.cb))
+	if ok == 0 {
+		return 0
+	}
+	return uint64(counters.peakWorkingSetSize)
+}
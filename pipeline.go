@@ -0,0 +1,109 @@
+package main
+
+import (
+	"sync"
+)
+
+// defaultPipelineDepth is the number of batches that may be queued for the sink
+const defaultPipelineDepth = 4
+
+// PipelineWriter hands batches to the sink on a separate goroutine through a
+// bounded channel. When the sink falls behind (e.g. a slow network store)
+// the channel fills up and Write blocks, throttling the parser instead of
+// letting parsed rows pile up in memory.
+type PipelineWriter struct {
+	next    RowWriter
+	batches chan pipelineItem
+	done    chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// pipelineItem is a batch of rows or a commit point, kept in order on the queue
+type pipelineItem struct {
+	rows   []ParquetRow
+	commit *CommitPoint
+}
+
+// NewPipelineWriter starts the sink goroutine with room for depth queued batches
+func NewPipelineWriter(next RowWriter, depth int) *PipelineWriter {
+	if depth < 1 {
+		depth = 1
+	}
+	w := &PipelineWriter{
+		next:    next,
+		batches: make(chan pipelineItem, depth),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// run writes queued batches and commit points until the channel is closed. After the first
+// failure remaining batches are drained so producers never block forever.
+func (w *PipelineWriter) run() {
+	defer close(w.done)
+	for item := range w.batches {
+		if w.failed() != nil {
+			continue
+		}
+		var err error
+		if item.commit != nil {
+			err = commitNext(w.next, *item.commit)
+		} else {
+			err = w.next.WriteBatch(item.rows)
+		}
+		if err != nil {
+			w.mu.Lock()
+			w.err = err
+			w.mu.Unlock()
+		}
+	}
+}
+
+// failed returns the first error reported by the sink, if any
+func (w *PipelineWriter) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Write queues a single row
+func (w *PipelineWriter) Write(row ParquetRow) error {
+	return w.WriteBatch([]ParquetRow{row})
+}
+
+// WriteBatch queues a copy of rows, blocking while the queue is full
+func (w *PipelineWriter) WriteBatch(rows []ParquetRow) error {
+	if err := w.failed(); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	batch := make([]ParquetRow, len(rows))
+	copy(batch, rows)
+	w.batches <- pipelineItem{rows: batch}
+	return nil
+}
+
+// Commit queues a commit point behind the rows already queued
+func (w *PipelineWriter) Commit(point CommitPoint) error {
+	if err := w.failed(); err != nil {
+		return err
+	}
+	w.batches <- pipelineItem{commit: &point}
+	return nil
+}
+
+// WriteStop waits for queued batches to be written, then finalizes the sink
+func (w *PipelineWriter) WriteStop() error {
+	close(w.batches)
+	<-w.done
+	if err := w.failed(); err != nil {
+		w.next.WriteStop()
+		return err
+	}
+	return w.next.WriteStop()
+}
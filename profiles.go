@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// profiles bundle flag values for common kinds of conversion. A profile
+// only fills in flags that were not given on the command line.
+var profiles = map[string]map[string]string{
+	// minimal keeps the main table small: XML parts only, tag and attribute
+	// names moved to dictionaries, empty parts dropped quietly
+	"minimal": {
+		"extensions":    ".xml",
+		"tag-ids":       "true",
+		"attribute-ids": "true",
+		"empty-parts":   "skip",
+	},
+	// ooxml-audit covers every XML-bearing OOXML part, records what was
+	// skipped and why, and keeps going past damaged documents
+	"ooxml-audit": {
+		"extensions":      ".xml,.rels,.vml",
+		"empty-parts":     "record",
+		"keep-going":      "true",
+		"skip-unreadable": "true",
+		"text-index":      "true",
+	},
+	// forensics preserves everything: no size limits, nothing overwritten,
+	// deterministic single-worker decoding and outputs checked by every reader
+	"forensics": {
+		"extensions":     ".xml,.rels,.vml,.xsd,.xsl",
+		"empty-parts":    "record",
+		"keep-going":     "true",
+		"on-collision":   "rename",
+		"workers":        "1",
+		"max-file-size":  "0",
+		"verify-readers": "true",
+	},
+}
+
+// profileNames lists the profiles in sorted order
+func profileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile sets the flags of the named profile that the command line
+// left at their defaults
+func applyProfile(flags *flag.FlagSet, name string) error {
+	profile, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(profileNames(), ", "))
+	}
+	return applyFlagDefaults(flags, profile, "profile "+name)
+}
+
+// applyFlagDefaults sets the flags in values that were not given on the
+// command line. source names where the values came from in errors.
+func applyFlagDefaults(flags *flag.FlagSet, values map[string]string, source string) error {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for flagName, value := range values {
+		if explicit[flagName] {
+			continue
+		}
+		if err := flags.Set(flagName, value); err != nil {
+			return fmt.Errorf("%s: invalid --%s=%s: %v", source, flagName, value, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// quarantineDir receives a copy of every input that failed to convert,
+// next to an error report, so it can be inspected and replayed
+// (--quarantine)
+var quarantineDir string
+
+// quarantineMode is "copy" to leave failed inputs in place or "move" to
+// take them out of the input directory (--quarantine-mode)
+var quarantineMode = "copy"
+
+// quarantineModes lists the accepted --quarantine-mode values
+var quarantineModes = []string{"copy", "move"}
+
+// quarantineReport is written next to a quarantined input as
+// <name>.error.json
+type quarantineReport struct {
+	Input         string       `json:"input"`
+	OutputDir     string       `json:"output_dir"`
+	QuarantinedAt time.Time    `json:"quarantined_at"`
+	Mode          string       `json:"mode"`
+	Failures      []FailedFile `json:"failures"`
+}
+
+// quarantined holds the failures of the current run by input, in the
+// order the inputs first failed
+var quarantined struct {
+	inputs   []string
+	failures map[string][]FailedFile
+}
+
+// queueQuarantine notes a failed document for quarantine at the end of the
+// run; a container is quarantined whole, with all its failed entries
+func queueQuarantine(input, entry string, err error) {
+	if quarantineDir == "" {
+		return
+	}
+	if quarantined.failures == nil {
+		quarantined.failures = make(map[string][]FailedFile)
+	}
+	if _, ok := quarantined.failures[input]; !ok {
+		quarantined.inputs = append(quarantined.inputs, input)
+	}
+	quarantined.failures[input] = append(quarantined.failures[input], FailedFile{Input: input, Entry: entry, Error: err.Error()})
+}
+
+// quarantineFailures copies or moves the failed inputs of the run below
+// quarantineDir, keeping their path relative to inputRoot, and writes
+// their error reports. It runs once the inputs are closed.
+func quarantineFailures(inputRoot, outputDir string) error {
+	inputs, failures := quarantined.inputs, quarantined.failures
+	quarantined.inputs, quarantined.failures = nil, nil
+	for _, input := range inputs {
+		rel, err := filepath.Rel(inputRoot, input)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			rel = filepath.Base(input)
+		}
+		target := filepath.Join(quarantineDir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return wrapFSError("create quarantine directory", filepath.Dir(target), err)
+		}
+		if err := quarantineFile(input, target); err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(&quarantineReport{
+			Input:         input,
+			OutputDir:     outputDir,
+			QuarantinedAt: time.Now().UTC(),
+			Mode:          quarantineMode,
+			Failures:      failures[input],
+		}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode quarantine report: %v", err)
+		}
+		reportName := target + ".error.json"
+		if err := os.WriteFile(reportName, append(data, '\n'), 0644); err != nil {
+			return wrapFSError("write quarantine report", reportName, err)
+		}
+		log.Printf("Quarantined %s in %s", input, target)
+	}
+	return nil
+}
+
+// quarantineFile copies input to target, removing input afterwards in move
+// mode. A rename is tried first when moving.
+func quarantineFile(input, target string) error {
+	if quarantineMode == "move" && os.Rename(input, target) == nil {
+		return nil
+	}
+	src, err := os.Open(input)
+	if err != nil {
+		return wrapFSError("open failed input", input, err)
+	}
+	defer src.Close()
+	dst, err := os.Create(target)
+	if err != nil {
+		return wrapFSError("create quarantined file", target, err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to quarantine %s: %v", input, err)
+	}
+	if err := dst.Close(); err != nil {
+		return wrapFSError("close quarantined file", target, err)
+	}
+	if quarantineMode == "move" {
+		src.Close()
+		if err := os.Remove(input); err != nil {
+			return wrapFSError("remove quarantined input", input, err)
+		}
+	}
+	return nil
+}
+
+// validQuarantineMode checks a --quarantine-mode value
+func validQuarantineMode(mode string) bool {
+	for _, m := range quarantineModes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// readersFileName is the Python module "xmlgo gen-readers" writes
+const readersFileName = "xmlgo_reader.py"
+
+// pythonReader is the template of the Python helper module. It is rendered
+// with the row schema, so the generated module checks that the datasets it
+// opens were written with the schema version it was generated for.
+var pythonReader = template.Must(template.New(readersFileName).Parse(`"""Read xmlgo output with pyarrow.
+
+Generated by "xmlgo gen-readers" for row schema version {{.SchemaVersion}};
+regenerate it after upgrading xmlgo. Requires pyarrow.
+
+    import xmlgo_reader as xr
+
+    ds = xr.open_dataset("out")
+    table = ds.table()                  # pyarrow.Table, names resolved
+    root = ds.tree("docs/feed.xml")     # Element tree of one document
+    for title in root.find_all("//item[@type='news']/title"):
+        print(title.text)
+"""
+
+import glob
+import json
+import os
+import re
+
+import pyarrow as pa
+import pyarrow.parquet as pq
+
+SCHEMA_VERSION = {{.SchemaVersion}}
+
+COLUMNS = [{{range $i, $f := .Fields}}{{if $i}}, {{end}}"{{$f.Name}}"{{end}}]
+
+
+class SchemaMismatch(Exception):
+    """The dataset was written with a different row schema version."""
+
+
+class Element:
+    """An element of a reconstructed document.
+
+    name is the local name and namespace the namespace URI, or "";
+    attributes maps local names to values and text is the element's own
+    text content. self_closing is only set in datasets written with
+    --self-closing, for elements written as <a/>.
+    """
+
+    __slots__ = ("id", "name", "namespace", "attributes", "text", "self_closing", "parent", "children")
+
+    def __init__(self, id, name):
+        self.id = id
+        self.name = name
+        self.namespace = ""
+        self.attributes = {}
+        self.text = ""
+        self.self_closing = False
+        self.parent = None
+        self.children = []
+
+    def __repr__(self):
+        return "<Element %s id=%d>" % (self.qualified_name(), self.id)
+
+    def qualified_name(self):
+        """The name in Clark notation, {namespace}name."""
+        if self.namespace:
+            return "{%s}%s" % (self.namespace, self.name)
+        return self.name
+
+    def iter(self):
+        """The element and its descendants in document order."""
+        yield self
+        for child in self.children:
+            yield from child.iter()
+
+    def find_all(self, path):
+        """The elements an XPath-like path selects, in document order.
+
+        Steps are element names or "*", separated by "/"; "//" selects
+        descendants at any depth, ".." the parent, and [@attr],
+        [@attr='value'] and 1-based [n] filter a step. A leading "/" starts
+        at the root, whose name is the first step.
+        """
+        absolute = path.startswith("/") and not path.startswith("//")
+        steps = _parse_path(path[1:] if absolute else path)
+        context = [self]
+        if absolute:
+            root = self
+            while root.parent is not None:
+                root = root.parent
+            if not steps:
+                return [root]
+            descendants, name, predicates = steps[0]
+            if descendants or not _matches(root, name, predicates, 1):
+                return []
+            context, steps = [root], steps[1:]
+        for step in steps:
+            selected = {}
+            for node in context:
+                for match in _apply(node, step):
+                    selected[match.id] = match
+            context = [selected[id] for id in sorted(selected)]
+        return context
+
+    def find(self, path):
+        """The first element path selects, or None."""
+        found = self.find_all(path)
+        return found[0] if found else None
+
+
+_STEP = re.compile(r"^([^\[\]/]+)((?:\[[^\]]*\])*)$")
+_PREDICATE = re.compile(r"\[([^\]]*)\]")
+
+
+def _parse_path(path):
+    if path == "":
+        return []
+    # Separators alternate with steps; a "/" inside a predicate is not one
+    parts = re.split(r"(//|/)(?![^\[]*\])", path)
+    steps = []
+    descendants = False
+    for i, part in enumerate(parts):
+        if i % 2:
+            descendants = part == "//"
+            continue
+        if part == "":
+            if i == 0 and parts[1] == "//":
+                continue
+            raise ValueError("path %r has an empty step" % path)
+        match = _STEP.match(part)
+        if not match:
+            raise ValueError("path %r: invalid step %r" % (path, part))
+        predicates = []
+        for raw in _PREDICATE.findall(match.group(2)):
+            if raw.isdigit() and int(raw) > 0:
+                predicates.append(int(raw))
+                continue
+            attribute = re.match(r"^@([^=]+)(?:=(['\"])(.*)\2)?$", raw)
+            if not attribute:
+                raise ValueError("path %r: invalid predicate [%s]" % (path, raw))
+            predicates.append((attribute.group(1), attribute.group(3)))
+        steps.append((descendants, match.group(1), predicates))
+    return steps
+
+
+def _apply(node, step):
+    descendants, name, predicates = step
+    if name == "..":
+        return [node.parent] if node.parent is not None else []
+    if name == ".":
+        return [node]
+    if descendants:
+        candidates = [n for n in node.iter() if n is not node]
+    else:
+        candidates = node.children
+    positions = {}
+    selected = []
+    for candidate in candidates:
+        if name != "*" and candidate.name != name:
+            continue
+        position = positions[candidate.parent.id] = positions.get(candidate.parent.id, 0) + 1
+        if _matches(candidate, name, predicates, position):
+            selected.append(candidate)
+    return selected
+
+
+def _matches(node, name, predicates, position):
+    if name != "*" and node.name != name:
+        return False
+    for predicate in predicates:
+        if isinstance(predicate, int):
+            if predicate != position:
+                return False
+            continue
+        attribute, value = predicate
+        if attribute not in node.attributes:
+            return False
+        if value is not None and node.attributes[attribute] != value:
+            return False
+    return True
+
+
+class Dataset:
+    """An xmlgo output directory: its combined Parquet output, including
+    rolled parts and --retry-failed additions, and its tag and attribute
+    dictionaries when present."""
+
+    def __init__(self, path, check_schema=True):
+        self.path = path
+        self.files = sorted(glob.glob(os.path.join(path, "combined*.parquet")))
+        if not self.files:
+            raise FileNotFoundError("no combined Parquet output in %s" % path)
+        if check_schema:
+            self._check_schema()
+        self.tags = self._dictionary("tags*.parquet", "tag_id", "tag_name")
+        self.attributes = self._dictionary("attributes*.parquet", "attribute_id", "name")
+
+    def _check_schema(self):
+        schema_file = os.path.join(self.path, "{{.SchemaFile}}")
+        if not os.path.exists(schema_file):
+            return
+        with open(schema_file) as f:
+            version = json.load(f).get("xmlgo.schema_version")
+        if version != SCHEMA_VERSION:
+            raise SchemaMismatch(
+                "%s was written with row schema version %s, this module reads version %d; "
+                "regenerate it with the xmlgo that wrote the dataset" % (self.path, version, SCHEMA_VERSION))
+
+    def _dictionary(self, pattern, id_column, name_column):
+        # Retry runs continue the numbering of the runs before them, so an
+        # ID naming two different entries cannot be resolved
+        names = {}
+        for name in sorted(glob.glob(os.path.join(self.path, pattern))):
+            for row in pq.read_table(name).to_pylist():
+                entry = (row.get("namespace") or "", row[name_column])
+                if names.get(row[id_column], entry) != entry:
+                    raise ValueError("%s: ID %d names both %r and %r" % (name, row[id_column], names[row[id_column]][1], entry[1]))
+                names[row[id_column]] = entry
+        return names
+
+    def table(self, columns=None, filters=None):
+        """The combined rows as one pyarrow.Table, with tag_name and
+        attribute_name filled in from the dictionaries for id-only outputs.
+        columns and filters are passed to pyarrow.parquet.read_table."""
+        tables = [pq.read_table(name, columns=columns, filters=filters) for name in self.files]
+        table = pa.concat_tables(tables) if len(tables) > 1 else tables[0]
+        names = table.column_names
+        if self.tags and "tag_name" in names and "tag_id" in names:
+            table = self._resolve(table, "tag_name", "tag_id", self.tags)
+        if self.attributes and "attribute_name" in names and "attribute_id" in names:
+            table = self._resolve(table, "attribute_name", "attribute_id", self.attributes)
+        return table
+
+    @staticmethod
+    def _resolve(table, name_column, id_column, dictionary):
+        resolved = [
+            name if name is not None or id is None else dictionary.get(id, ("", None))[1]
+            for name, id in zip(table.column(name_column).to_pylist(), table.column(id_column).to_pylist())
+        ]
+        index = table.column_names.index(name_column)
+        return table.set_column(index, name_column, pa.array(resolved, pa.string()))
+
+    def rows(self, file_path=None):
+        """The rows as dicts in document order, optionally of one file_path."""
+        filters = [("file_path", "=", file_path)] if file_path is not None else None
+        return self.table(filters=filters).to_pylist()
+
+    def file_paths(self):
+        """The file_path of every document in the dataset."""
+        return sorted(set(self.table(columns=["file_path"]).column("file_path").to_pylist()))
+
+    def tree(self, file_path):
+        """The root Element of the document recorded under file_path."""
+        roots = _build_trees(self.rows(file_path), self.tags, self.attributes)
+        if file_path not in roots:
+            raise KeyError("no document recorded under file_path %r" % file_path)
+        return roots[file_path]
+
+    def trees(self):
+        """A dict of file_path to root Element for every document."""
+        return _build_trees(self.rows(), self.tags, self.attributes)
+
+
+def open_dataset(path, check_schema=True):
+    """Open the xmlgo output directory at path."""
+    return Dataset(path, check_schema)
+
+
+def _build_trees(rows, tags, attributes):
+    """Link rows into element trees, one per file_path. Namespace rows,
+    which xmlgo writes as an xmlns:<uri> attribute whose value is the URI,
+    set the element's namespace; rows without an attribute name are text."""
+    nodes = {}
+    roots = {}
+    for row in rows:
+        key = (row["file_path"], row["node_id"])
+        if row["is_node"]:
+            node = Element(row["node_id"], row["tag_name"] or "")
+            node.self_closing = bool(row.get("self_closing"))
+            if row.get("tag_id") in tags:
+                node.namespace, node.name = tags[row["tag_id"]]
+            nodes[key] = node
+            if row["is_root"]:
+                roots[row["file_path"]] = node
+            else:
+                parent = nodes.get((row["file_path"], row["parent_node_id"]))
+                if parent is not None:
+                    node.parent = parent
+                    parent.children.append(node)
+            continue
+        node = nodes.get(key)
+        if node is None:
+            continue
+        name, value = row["attribute_name"] or "", row["attribute_value"] or ""
+        if name == "xmlns:" + value:
+            node.namespace = value
+        elif name == "" and not row.get("attribute_id"):
+            node.text += value
+        else:
+            node.attributes[name] = value
+    return roots
+`))
+
+// generateReaders writes the Python helper module for the current row
+// schema into dir
+func generateReaders(dir string) (string, error) {
+	schema, err := rowAvroSchema()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", wrapFSError("create", dir, err)
+	}
+	fileName := filepath.Join(dir, readersFileName)
+	f, err := os.Create(fileName)
+	if err != nil {
+		return "", wrapFSError("create", fileName, err)
+	}
+	data := struct {
+		*avroSchema
+		SchemaFile string
+	}{schema, schemaFileName}
+	if err := pythonReader.Execute(f, data); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write %s: %v", fileName, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", wrapFSError("close", fileName, err)
+	}
+	return fileName, nil
+}
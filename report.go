@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"slices"
+	"sort"
+	"time"
+)
+
+// RunReport is the end-of-run summary written to run_report.json
+type RunReport struct {
+	StartedAt      time.Time        `json:"started_at"`
+	DurationMs     int64            `json:"duration_ms"`
+	Files          int64            `json:"files"`
+	Rows           int64            `json:"rows"`
+	PeakRSSBytes   uint64           `json:"peak_rss_bytes"`
+	TotalAllocated uint64           `json:"total_allocated_bytes"`
+	HeapSysBytes   uint64           `json:"heap_sys_bytes"`
+	NumGC          uint32           `json:"num_gc"`
+	GCPauseTotalMs float64          `json:"gc_pause_total_ms"`
+	StoppedEarly   string           `json:"stopped_early,omitempty"`
+	Unprocessed    []string         `json:"unprocessed,omitempty"`
+	Written        []WrittenEntries `json:"written_entries,omitempty"`
+	Failures       []FailedFile     `json:"failures,omitempty"`
+	Violations     []string         `json:"contract_violations,omitempty"`
+	TagRows        map[string]int64 `json:"tag_rows,omitempty"`
+	Anomalies      []string         `json:"anomalies,omitempty"`
+	Entries        *EntryCounts     `json:"zip_entries,omitempty"`
+	NextNodeID     int64            `json:"next_node_id"`
+	Attempt        int              `json:"attempt,omitempty"`
+}
+
+// report accumulates statistics for the current run
+var report = &RunReport{StartedAt: time.Now()}
+
+// recordFile adds a parsed document to the run totals
+func (r *RunReport) recordFile(rows int64) {
+	r.Files++
+	r.Rows += rows
+}
+
+// WrittenEntries lists the entries of an unprocessed container that were
+// written before the run stopped inside it
+type WrittenEntries struct {
+	Input   string   `json:"input"`
+	Entries []string `json:"entries"`
+}
+
+// markUnprocessed lists inputs that were not fully converted because the
+// run stopped early. written holds the entries of inputs[0] that were
+// converted before the run stopped inside it; a retry leaves them alone,
+// along with those an earlier run wrote.
+func (r *RunReport) markUnprocessed(inputs []string, written []string) {
+	if len(inputs) > 0 {
+		entries := slices.Clone(written)
+		for entry := range retryWritten[inputs[0]] {
+			entries = append(entries, entry)
+		}
+		if len(entries) > 0 {
+			sort.Strings(entries)
+			r.Written = append(r.Written, WrittenEntries{Input: inputs[0], Entries: entries})
+		}
+	}
+	r.Unprocessed = append(r.Unprocessed, inputs...)
+}
+
+// finish captures the duration and memory statistics at the end of the run
+func (r *RunReport) finish() {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	r.DurationMs = time.Since(r.StartedAt).Milliseconds()
+	r.NextNodeID = nodeIDs.peek()
+	r.PeakRSSBytes = peakRSS()
+	r.TotalAllocated = mem.TotalAlloc
+	r.HeapSysBytes = mem.HeapSys
+	r.NumGC = mem.NumGC
+	r.GCPauseTotalMs = float64(mem.PauseTotalNs) / float64(time.Millisecond)
+}
+
+// write saves the report as indented JSON. With --deterministic the start
+// time, duration and memory statistics are left out.
+func (r *RunReport) write(fileName string) error {
+	saved := *r
+	if deterministic {
+		saved.StartedAt = time.Time{}
+		saved.DurationMs = 0
+		saved.PeakRSSBytes, saved.TotalAllocated, saved.HeapSysBytes = 0, 0, 0
+		saved.NumGC, saved.GCPauseTotalMs = 0, 0
+	}
+	data, err := json.MarshalIndent(&saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run report: %v", err)
+	}
+	if err := os.WriteFile(fileName, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run report %s: %v", fileName, err)
+	}
+	return nil
+}
+
+// logSummary prints a one-line summary of the run
+func (r *RunReport) logSummary() {
+	log.Printf("Processed %d files, %d rows in %dms; peak RSS %.1f MiB, allocated %.1f MiB, %d GCs pausing %.1fms",
+		r.Files, r.Rows, r.DurationMs, mebibytes(r.PeakRSSBytes), mebibytes(r.TotalAllocated), r.NumGC, r.GCPauseTotalMs)
+	if e := r.Entries; e != nil {
+		log.Printf("ZIP entries: %d parsed, %d copied as assets, %d skipped, %d failed", e.Parsed, e.Copied, e.Skipped, e.Failed)
+	}
+	if r.StoppedEarly != "" {
+		log.Printf("Stopped early: %s; %d inputs not fully converted", r.StoppedEarly, len(r.Unprocessed))
+	}
+	if len(r.Failures) > 0 {
+		log.Printf("%d documents failed; re-run them with --retry-failed", len(r.Failures))
+	}
+}
+
+// mebibytes converts a byte count for display
+func mebibytes(b uint64) float64 {
+	return float64(b) / (1 << 20)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keepGoing records documents that fail to decode in the run report and
+// carries on instead of aborting the run
+var keepGoing bool
+
+// FailedFile is a document that could not be converted. Input is the path
+// the run was given (a file or container); Entry is the document within it.
+type FailedFile struct {
+	Input string `json:"input"`
+	Entry string `json:"entry"`
+	Error string `json:"error"`
+}
+
+// recordFailure notes a failed document, or returns err when --keep-going
+// is off so the run stops as before. Either way the document's input is
+// quarantined with --quarantine.
+func recordFailure(input, entry string, err error) error {
+	queueQuarantine(input, entry, err)
+	if !keepGoing {
+		return err
+	}
+	log.Printf("Failed to convert %s: %v", entry, err)
+	report.Failures = append(report.Failures, FailedFile{Input: input, Entry: entry, Error: err.Error()})
+	return recordSkip(entry, 0, "failed")
+}
+
+// runSuffix is inserted before the extension of every output and sidecar
+// name, so a retry adds files to the dataset instead of replacing it
+var runSuffix string
+
+// withRunSuffix applies runSuffix to a file name
+func withRunSuffix(fileName string) string {
+	if runSuffix == "" {
+		return fileName
+	}
+	ext := filepath.Ext(fileName)
+	return strings.TrimSuffix(fileName, ext) + runSuffix + ext
+}
+
+// retryFilter limits a --retry-failed run to the documents that failed,
+// keyed by input; a nil entry set means the whole input is retried
+var retryFilter map[string]map[string]bool
+
+// retrySkip reports whether a retry run should leave entry of input alone
+func retrySkip(input, entry string) bool {
+	if retryFilter == nil {
+		return false
+	}
+	entries, ok := retryFilter[input]
+	if !ok {
+		return true
+	}
+	return entries != nil && !entries[entry]
+}
+
+// retryWritten lists, by input, the entries a --retry-failed run leaves
+// alone because the earlier run wrote them before stopping inside the input
+var retryWritten map[string]map[string]bool
+
+// retryDone reports whether entry of input was written by the run being
+// retried
+func retryDone(input, entry string) bool {
+	return retryWritten[input][entry]
+}
+
+// loadRetryManifest reads the run report of an earlier run and prepares a
+// run over its failed documents and unprocessed inputs. Node IDs continue
+// after the earlier run and outputs get a .retry-N suffix, so the results
+// merge into the existing dataset. It returns the inputs to process.
+func loadRetryManifest(fileName string) ([]string, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, wrapFSError("read manifest", fileName, err)
+	}
+	var previous RunReport
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %v", fileName, err)
+	}
+
+	retryFilter = make(map[string]map[string]bool)
+	var inputs []string
+	for _, input := range previous.Unprocessed {
+		if _, ok := retryFilter[input]; !ok {
+			inputs = append(inputs, input)
+		}
+		retryFilter[input] = nil
+	}
+	retryWritten = make(map[string]map[string]bool)
+	for _, written := range previous.Written {
+		entries := make(map[string]bool)
+		for _, entry := range written.Entries {
+			entries[entry] = true
+		}
+		retryWritten[written.Input] = entries
+	}
+	for _, failure := range previous.Failures {
+		entries, ok := retryFilter[failure.Input]
+		if !ok {
+			inputs = append(inputs, failure.Input)
+			entries = make(map[string]bool)
+			retryFilter[failure.Input] = entries
+		}
+		if entries != nil {
+			entries[failure.Entry] = true
+		}
+	}
+
+	report.Attempt = previous.Attempt + 1
+	runSuffix = fmt.Sprintf(".retry-%d", report.Attempt)
+	nodeIDs = newIDAllocator(previous.NextNodeID)
+	return inputs, nil
+}
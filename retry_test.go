@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// TestRetryAfterLimitSkipsWrittenEntries stops a run inside a container
+// with --max-files and retries it: the entries written before the stop
+// must not be converted a second time.
+func TestRetryAfterLimitSkipsWrittenEntries(t *testing.T) {
+	defer func(saved int64) { maxFiles = saved }(maxFiles)
+	input, outputDir := t.TempDir(), t.TempDir()
+	entries := make(map[string]string)
+	for i := 1; i <= 4; i++ {
+		entries[fmt.Sprintf("part%d.xml", i)] = fmt.Sprintf("<part n=\"%d\"><child/></part>", i)
+	}
+	writeTestZip(t, filepath.Join(input, "a.zip"), entries)
+	cfg := &runConfig{formats: []string{"parquet"}, extensions: []string{".xml"}}
+
+	maxFiles = 2
+	if _, err := convert(cfg, input, outputDir); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Written) != 1 || len(report.Written[0].Entries) != 2 {
+		t.Fatalf("run report lists written entries %v, want 2 of a.zip", report.Written)
+	}
+
+	maxFiles = 0
+	retry := &runConfig{formats: cfg.formats, extensions: cfg.extensions, retryManifest: filepath.Join(outputDir, "run_report.json")}
+	if _, err := convert(retry, input, outputDir); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := make(map[string]int)
+	for _, fileName := range []string{"combined.parquet", "combined.retry-1.parquet"} {
+		columns, rows, err := parquetRows(filepath.Join(outputDir, fileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, column := range columns {
+			if column != "entry_path" {
+				continue
+			}
+			for _, row := range rows {
+				parts[row[i]]++
+			}
+		}
+	}
+	if len(parts) != 4 {
+		t.Errorf("converted entries %v, want all 4", parts)
+	}
+	for entry, rows := range parts {
+		if rows != 3 {
+			t.Errorf("%s has %d rows, want 3", entry, rows)
+		}
+	}
+}
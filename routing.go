@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// tagRoutes maps tag names to the output they are routed to, from --route-tags
+var tagRoutes map[string]string
+
+// parseTagRoutes parses a comma-separated list of tag or tag=name entries.
+// A bare tag is written to <tag>.<ext>; tag=name writes it to <name>.<ext>,
+// and several tags may share a name.
+func parseTagRoutes(value string) (map[string]string, error) {
+	routes := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tag, name, ok := strings.Cut(entry, "=")
+		tag = strings.TrimSpace(tag)
+		name = strings.TrimSpace(name)
+		if !ok {
+			name = tag
+		}
+		if tag == "" || name == "" || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("invalid tag route %q", entry)
+		}
+		routes[tag] = name
+	}
+	return routes, nil
+}
+
+// reservedOutputNames returns the names of the other files xmlgo writes to
+// an output directory, which a tag route must not take over
+func reservedOutputNames() map[string]bool {
+	reserved := make(map[string]bool)
+	for _, fileName := range []string{
+		"combined.parquet", "files.parquet", "tags.parquet", "attributes.parquet",
+		"text_index.parquet", "languages.parquet", "run_report.json", tombstonesFileName,
+		checkpointFileName, backfillFileName, lineageFileName, snapshotsFileName,
+		incrementalFileName, jobFileName, schemaFileName, readersFileName, lockFileName,
+	} {
+		reserved[strings.TrimSuffix(fileName, filepath.Ext(fileName))] = true
+	}
+	for _, extractor := range extractors {
+		for table := range extractor.Tables() {
+			reserved[table] = true
+		}
+	}
+	return reserved
+}
+
+// RoutingWriter sends the rows of configured tags to their own outputs and
+// everything else to the main output. A node's attribute and content rows
+// follow directly after its node row, so they go wherever that node went.
+type RoutingWriter struct {
+	main    RowWriter
+	byTag   map[string]RowWriter
+	outputs []RowWriter
+	current RowWriter
+}
+
+// NewRoutingWriter opens one output per route name next to mainFile, using
+// the same format and extension as the main output. Names of the other
+// outputs and sidecars, with or without a retry run's suffix, are rejected.
+func NewRoutingWriter(format string, mainFile string, routes map[string]string, main RowWriter) (*RoutingWriter, error) {
+	w := &RoutingWriter{main: main, byTag: make(map[string]RowWriter), current: main}
+
+	reserved := reservedOutputNames()
+	byName := make(map[string]RowWriter)
+	for tag, name := range routes {
+		out, ok := byName[name]
+		if !ok {
+			if stem, _, _ := strings.Cut(name, "."); reserved[stem] {
+				w.close()
+				return nil, fmt.Errorf("tag route %s would overwrite xmlgo's own %s output", tag, stem)
+			}
+			fileName := filepath.Join(filepath.Dir(mainFile), withRunSuffix(name+filepath.Ext(mainFile)))
+			if fileName == mainFile {
+				w.close()
+				return nil, fmt.Errorf("tag route %s would overwrite the main output %s", tag, mainFile)
+			}
+			var err error
+			out, err = openFormatWriter(format, fileName)
+			if err != nil {
+				w.close()
+				return nil, err
+			}
+			byName[name] = out
+			w.outputs = append(w.outputs, out)
+		}
+		w.byTag[tag] = out
+	}
+	return w, nil
+}
+
+// route returns the output for row, updating the current node's output
+func (w *RoutingWriter) route(row ParquetRow) RowWriter {
+	if row.IsNode {
+		w.current = w.main
+		if out, ok := w.byTag[row.TagName]; ok {
+			w.current = out
+		}
+	}
+	return w.current
+}
+
+// Write sends a row to the output of the node it belongs to
+func (w *RoutingWriter) Write(row ParquetRow) error {
+	return w.route(row).Write(row)
+}
+
+// WriteBatch splits rows into runs bound for the same output
+func (w *RoutingWriter) WriteBatch(rows []ParquetRow) error {
+	start := 0
+	var out RowWriter
+	for i, row := range rows {
+		next := w.route(row)
+		if next != out && i > start {
+			if err := out.WriteBatch(rows[start:i]); err != nil {
+				return err
+			}
+			start = i
+		}
+		out = next
+	}
+	if out != nil && start < len(rows) {
+		return out.WriteBatch(rows[start:])
+	}
+	return nil
+}
+
+// close finalizes the routed outputs, returning the first error
+func (w *RoutingWriter) close() error {
+	var firstErr error
+	for _, out := range w.outputs {
+		if err := out.WriteStop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// WriteStop finalizes the main output and every routed output
+func (w *RoutingWriter) WriteStop() error {
+	err := w.main.WriteStop()
+	if closeErr := w.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRoutesCannotTakeSidecarNames checks that a route named after one of
+// xmlgo's own outputs is rejected instead of overwriting it
+func TestRoutesCannotTakeSidecarNames(t *testing.T) {
+	defer func(saved string) { runSuffix = saved }(runSuffix)
+	for _, suffix := range []string{"", ".retry-1"} {
+		runSuffix = suffix
+		for _, name := range []string{"files", "tags", "attributes", "text_index", "languages", "run_report", "checkpoint", "schema", "tombstones", "junit_cases", "tags.retry-1"} {
+			outputDir := t.TempDir()
+			main, fileName, err := newRowWriter("parquet", outputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewRoutingWriter("parquet", fileName, map[string]string{"row": name}, main); err == nil {
+				t.Errorf("route row=%s accepted with run suffix %q", name, suffix)
+			}
+			main.WriteStop()
+		}
+	}
+
+	runSuffix = ""
+	outputDir := t.TempDir()
+	main, fileName, err := newRowWriter("parquet", outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewRoutingWriter("parquet", fileName, map[string]string{"row": "rows"}, main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteStop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "rows.parquet")); err != nil {
+		t.Errorf("route row=rows was not written: %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runConfig holds the options of a conversion that are not package-level
+// settings of the stage they configure
+type runConfig struct {
+	formats             []string
+	extensions          []string
+	retryManifest       string
+	textIndex           bool
+	languages           bool
+	tagDictionary       bool
+	attributeDictionary bool
+	// extractors names the --extract extractors to run
+	extractors []string
+	// only restricts the conversion to these inputs when set
+	only map[string]bool
+}
+
+// validate rejects option combinations a conversion cannot honour
+func (cfg *runConfig) validate() error {
+	if len(cfg.formats) > 1 && (flushInterval > 0 || outputPath != "") {
+		return fmt.Errorf("multiple formats cannot be combined with --flush-interval or --output")
+	}
+	if !validEmptyPartPolicy(emptyPartPolicy) {
+		return fmt.Errorf("unknown --empty-parts %q (expected %s)", emptyPartPolicy, strings.Join(emptyPartPolicies, ", "))
+	}
+	if err := validNullPolicy(nullPolicy); err != nil {
+		return fmt.Errorf("invalid --nulls: %v", err)
+	}
+	if !validCollisionPolicy(collisionPolicy) {
+		return fmt.Errorf("unknown --on-collision %q (expected %s)", collisionPolicy, strings.Join(collisionPolicies, ", "))
+	}
+	if cfg.retryManifest != "" && (flushInterval > 0 || perFile) {
+		return fmt.Errorf("--retry-failed cannot be combined with --flush-interval or --per-file")
+	}
+	if perFile && (flushInterval > 0 || outputPath != "") {
+		return fmt.Errorf("--per-file cannot be combined with --flush-interval or --output")
+	}
+	if len(tagRoutes) > 0 && (perFile || flushInterval > 0 || isStreamTarget(outputPath)) {
+		return fmt.Errorf("--route-tags cannot be combined with --per-file, --flush-interval or a streamed --output")
+	}
+	if cacheDir != "" && (cfg.textIndex || cfg.languages || cfg.tagDictionary || tagIDsOnly || cfg.attributeDictionary || attributeIDsOnly) {
+		return fmt.Errorf("--cache-dir cannot be combined with --text-index, --detect-language or the tag and attribute dictionaries")
+	}
+	if verifyReaders && len(parquetVerifiers) < 2 {
+		return fmt.Errorf("--verify-readers needs a Parquet reader independent of parquet-go, which wrote the files; this build has none (build with -tags arrow to add apache/arrow-go)")
+	}
+	if err := validStrict(); err != nil {
+		return err
+	}
+	if err := validTextNormalization(); err != nil {
+		return err
+	}
+	if !validQuarantineMode(quarantineMode) {
+		return fmt.Errorf("unknown --quarantine-mode %q (expected %s)", quarantineMode, strings.Join(quarantineModes, ", "))
+	}
+	if err := validAnomalyFactor(anomalyFactor); err != nil {
+		return err
+	}
+	if cacheDir != "" && len(cfg.extractors) > 0 {
+		return fmt.Errorf("--cache-dir cannot be combined with --extract")
+	}
+	return nil
+}
+
+// resetRunState clears the package-level state a conversion accumulates,
+// so several conversions can run one after another in one process
+func resetRunState() {
+	report = &RunReport{StartedAt: time.Now()}
+	nodeIDs = newIDAllocator(1)
+	checkpoints = nil
+	filesTable = nil
+	tagDictionary = nil
+	attributeDictionary = nil
+	textIndex = nil
+	languageTable = nil
+	retryFilter = nil
+	retryWritten = nil
+	writtenEntries = nil
+	runSuffix = ""
+	deadlineExceeded = false
+	runOutputStats = newOutputStats(activeContract != nil)
+	quarantined.inputs, quarantined.failures = nil, nil
+	fileCtx = runCtx
+	copiedFiles.Lock()
+	copiedFiles.names = make(map[string]bool)
+	copiedFiles.Unlock()
+}
+
+// closeSidecars finalizes the tables written next to the main output,
+// returning the first error
+func closeSidecars() error {
+	var firstErr error
+	closeTable := func(what string, close func() error) {
+		if err := close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to write %s: %v", what, err)
+		}
+	}
+	if filesTable != nil {
+		closeTable("files table", filesTable.Close)
+		filesTable = nil
+	}
+	if tagDictionary != nil {
+		closeTable("tag dictionary", tagDictionary.Close)
+		tagDictionary = nil
+	}
+	if attributeDictionary != nil {
+		closeTable("attribute dictionary", attributeDictionary.Close)
+		attributeDictionary = nil
+	}
+	if textIndex != nil {
+		closeTable("text index", textIndex.Close)
+		textIndex = nil
+	}
+	if languageTable != nil {
+		closeTable("language table", languageTable.Close)
+		languageTable = nil
+	}
+	closeTable("extractor tables", closeExtractors)
+	return firstErr
+}
+
+// convert runs one conversion of input into outputDir and writes its run
+// report. It returns the name of the main output for display.
+func convert(cfg *runConfig, input string, outputDir string) (outputFileName string, err error) {
+	resetRunState()
+	if activeHooks != nil {
+		defer func() { runEndHooks(input, outputDir, err) }()
+	}
+
+	// Create the destination directory if it doesn't exist
+	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+			return "", fmt.Errorf("failed to create output directory %s: %v%s", outputDir, err, fsErrorHint(err))
+		}
+	}
+
+	// The earlier report is read before this run can replace it
+	baseline, err := loadBaseline()
+	if err != nil {
+		return "", err
+	}
+
+	inputs, inputRoot, err := runInputs(input, outputDir)
+	if err != nil {
+		return "", fmt.Errorf("error reading input: %v", err)
+	}
+	if cfg.only != nil {
+		inputs = slices.DeleteFunc(inputs, func(path string) bool { return !cfg.only[path] })
+	}
+
+	if cfg.retryManifest != "" {
+		if inputs, err = loadRetryManifest(cfg.retryManifest); err != nil {
+			return "", fmt.Errorf("failed to load manifest: %v", err)
+		}
+		keepGoing = !strictMode
+	}
+
+	// Failed inputs are quarantined once everything reading them is closed
+	defer func() {
+		if quarantineErr := quarantineFailures(inputRoot, outputDir); err == nil && quarantineErr != nil {
+			err = quarantineErr
+		}
+	}()
+
+	// Sidecars left open by a failed conversion are closed on the way out
+	defer func() {
+		if closeErr := closeSidecars(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	filesTable, err = NewParquetTable(filepath.Join(outputDir, withRunSuffix("files.parquet")), new(FileRow))
+	if err != nil {
+		return "", fmt.Errorf("failed to create files table: %v", err)
+	}
+
+	if cfg.tagDictionary || tagIDsOnly {
+		tagDictionary, err = NewTagDictionary(filepath.Join(outputDir, withRunSuffix("tags.parquet")))
+		if err != nil {
+			return "", fmt.Errorf("failed to create tag dictionary: %v", err)
+		}
+	}
+
+	if cfg.attributeDictionary || attributeIDsOnly {
+		attributeDictionary, err = NewAttributeDictionary(filepath.Join(outputDir, withRunSuffix("attributes.parquet")))
+		if err != nil {
+			return "", fmt.Errorf("failed to create attribute dictionary: %v", err)
+		}
+	}
+
+	if cfg.textIndex {
+		textIndex, err = NewTextIndex(filepath.Join(outputDir, withRunSuffix("text_index.parquet")))
+		if err != nil {
+			return "", fmt.Errorf("failed to create text index: %v", err)
+		}
+	}
+
+	if cfg.languages {
+		languageTable, err = NewLanguageTable(filepath.Join(outputDir, withRunSuffix("languages.parquet")))
+		if err != nil {
+			return "", fmt.Errorf("failed to create language table: %v", err)
+		}
+	}
+
+	if err := openExtractors(cfg.extractors, outputDir); err != nil {
+		return "", err
+	}
+
+	outputFileName = outputDir
+	if perFile {
+		if err := processPerFile(inputs, inputRoot, cfg.formats, outputDir, cfg.extensions); err != nil {
+			return "", fmt.Errorf("error processing file: %v", err)
+		}
+	} else {
+		// Initialize the single output writer
+		sink, fileNames, err := openOutputs(cfg.formats, outputDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to create output writer: %v", err)
+		}
+		outputFileName = strings.Join(fileNames, ", ")
+		if rolling, ok := sink.(*RollingWriter); ok {
+			checkpoints = newCheckpointer(flushInterval, rolling.checkpoint)
+			nodeIDs = newIDAllocator(rolling.checkpoint.NextNodeID)
+		}
+
+		rowWriter, err := newWriterChain(sink)
+		if err != nil {
+			return "", fmt.Errorf("failed to load transform: %v", err)
+		}
+
+		for n, inputFile := range inputs {
+			err := processFile(inputFile, outputDir, rowWriter, cfg.extensions)
+			if err == errLimitReached {
+				report.markUnprocessed(inputs[n:], writtenEntries)
+				break
+			}
+			if err != nil {
+				rowWriter.WriteStop()
+				return "", fmt.Errorf("error processing file: %v", err)
+			}
+		}
+
+		if err := checkpoints.commit(rowWriter); err != nil {
+			rowWriter.WriteStop()
+			return "", fmt.Errorf("failed to commit output: %v", err)
+		}
+
+		if err := rowWriter.WriteStop(); err != nil {
+			return "", fmt.Errorf("failed to finalize %s: %v", outputFileName, err)
+		}
+	}
+
+	if err := closeSidecars(); err != nil {
+		return "", err
+	}
+
+	// Clean up any remaining empty directories
+	if err := cleanEmptyDirs(outputDir); err != nil {
+		return "", fmt.Errorf("failed to clean up empty directories: %v", err)
+	}
+
+	if verifyReaders {
+		if err := verifyOutputs(outputDir); err != nil {
+			return "", fmt.Errorf("output verification failed: %v", err)
+		}
+	}
+
+	if err := writeRowSchema(filepath.Join(outputDir, schemaFileName)); err != nil {
+		return "", fmt.Errorf("failed to export row schema: %v", err)
+	}
+
+	report.TagRows = runOutputStats.tags
+	contractErr := checkContract()
+	anomalyErr := checkAnomalies(baseline)
+	report.finish()
+	if err := report.write(filepath.Join(outputDir, withRunSuffix("run_report.json"))); err != nil {
+		return "", err
+	}
+	if contractErr != nil {
+		return "", contractErr
+	}
+	if anomalyErr != nil {
+		return "", anomalyErr
+	}
+	return outputFileName, nil
+}
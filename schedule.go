@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// scheduleExpr, when set, keeps xmlgo running and converts the inputs that
+// changed since the previous run at every time matching the cron expression
+var scheduleExpr string
+
+// incrementalFileName records, in the output directory of a scheduled
+// conversion, which inputs have been converted and in which run
+const incrementalFileName = "incremental.json"
+
+// lockFileName keeps two schedulers from converting into one directory
+const lockFileName = "xmlgo.lock"
+
+// incrementalEntry is the state of an input when it was last converted.
+// Version counts its conversions; see TombstoneRow.
+type incrementalEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Run     string    `json:"run"`
+	Version int       `json:"version,omitempty"`
+}
+
+// incrementalManifest is the content of incremental.json
+type incrementalManifest struct {
+	Inputs map[string]incrementalEntry `json:"inputs"`
+}
+
+// loadIncrementalManifest reads fileName, returning an empty manifest when
+// no run has completed yet
+func loadIncrementalManifest(fileName string) (*incrementalManifest, error) {
+	manifest := &incrementalManifest{Inputs: make(map[string]incrementalEntry)}
+	data, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, wrapFSError("read", fileName, err)
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", fileName, err)
+	}
+	return manifest, nil
+}
+
+// write saves the manifest to fileName, replacing it atomically
+func (m *incrementalManifest) write(fileName string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", fileName, err)
+	}
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return wrapFSError("write", tmp, err)
+	}
+	if err := os.Rename(tmp, fileName); err != nil {
+		return wrapFSError("replace", fileName, err)
+	}
+	return nil
+}
+
+// changed returns the inputs that are new or differ in size or
+// modification time from their last conversion, with their current state
+func (m *incrementalManifest) changed(inputs []string) (map[string]incrementalEntry, error) {
+	changed := make(map[string]incrementalEntry)
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, wrapFSError("stat", input, err)
+		}
+		last, ok := m.Inputs[input]
+		if ok && last.Size == info.Size() && last.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		changed[input] = incrementalEntry{Size: info.Size(), ModTime: info.ModTime()}
+	}
+	return changed, nil
+}
+
+// deleted returns the converted inputs that are no longer among inputs
+func (m *incrementalManifest) deleted(inputs []string) []string {
+	present := make(map[string]bool, len(inputs))
+	for _, input := range inputs {
+		present[input] = true
+	}
+	var deleted []string
+	for input := range m.Inputs {
+		if !present[input] {
+			deleted = append(deleted, input)
+		}
+	}
+	return deleted
+}
+
+// acquireLock creates the lock file of outputDir, failing if another
+// scheduler holds it
+func acquireLock(outputDir string) (release func(), err error) {
+	fileName := filepath.Join(outputDir, lockFileName)
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		owner, _ := os.ReadFile(fileName)
+		return nil, fmt.Errorf("%s is locked by process %s; remove the lock file if that process is gone", outputDir, owner)
+	}
+	if err != nil {
+		return nil, wrapFSError("create", fileName, err)
+	}
+	fmt.Fprintf(f, "%d", os.Getpid())
+	f.Close()
+	return func() { os.Remove(fileName) }, nil
+}
+
+// runSchedule converts input into a new run directory below outputDir at
+// every time matching scheduleExpr until SIGINT or SIGTERM. Only inputs
+// that changed since the previous successful run are converted.
+func runSchedule(cfg *runConfig, input string, outputDir string) error {
+	schedule, err := parseCron(scheduleExpr)
+	if err != nil {
+		return fmt.Errorf("invalid --schedule %q: %v", scheduleExpr, err)
+	}
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return wrapFSError("create", outputDir, err)
+	}
+	release, err := acquireLock(outputDir)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	for {
+		next := schedule.next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("--schedule %q never matches", scheduleExpr)
+		}
+		log.Printf("Next scheduled conversion at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-signals:
+			timer.Stop()
+			log.Printf("Scheduler stopped")
+			return nil
+		case <-timer.C:
+		}
+
+		if err := runScheduled(cfg, input, outputDir, next); err != nil {
+			log.Printf("Scheduled conversion failed: %v", err)
+		}
+	}
+}
+
+// runScheduled performs one scheduled conversion of the changed inputs
+func runScheduled(cfg *runConfig, input string, outputDir string, at time.Time) error {
+	manifestFile := filepath.Join(outputDir, incrementalFileName)
+	manifest, err := loadIncrementalManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+	inputs, _, err := collectInputs(input, outputDir)
+	if err != nil {
+		return err
+	}
+	changed, err := manifest.changed(inputs)
+	if err != nil {
+		return err
+	}
+	deleted := manifest.deleted(inputs)
+	if len(changed) == 0 && len(deleted) == 0 {
+		log.Printf("No inputs changed since the previous run")
+		return nil
+	}
+
+	run := "run-" + at.UTC().Format("20060102T150405Z")
+	runDir := filepath.Join(outputDir, run)
+	runCfg := *cfg
+	runCfg.only = make(map[string]bool, len(changed))
+	for path := range changed {
+		runCfg.only[path] = true
+	}
+
+	log.Printf("Converting %d changed inputs into %s; %d were deleted", len(changed), runDir, len(deleted))
+	_, err = convert(&runCfg, input, runDir)
+	if webhookURL != "" {
+		if err := notifyWebhook(webhookURL, newWebhookPayload(input, runDir, err)); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	if lineageEnabled() {
+		if err := emitLineage(input, runDir, err); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	if auditLog != "" {
+		recordAudit(nil, input, runDir, err)
+	}
+	if err != nil {
+		return err
+	}
+	report.logSummary()
+
+	// Inputs that failed or were cut off by a limit are retried next time
+	pending := make(map[string]bool)
+	for _, failure := range report.Failures {
+		pending[failure.Input] = true
+	}
+	for _, path := range report.Unprocessed {
+		pending[path] = true
+	}
+	var tombstones []TombstoneRow
+	for path, entry := range changed {
+		if !pending[path] {
+			tombstones = append(tombstones, manifest.supersede(path, inputProvenance(runDir, path), run, entry)...)
+		}
+	}
+	for _, path := range deleted {
+		tombstones = append(tombstones, manifest.remove(path, inputProvenance(runDir, path), run))
+	}
+	if err := writeTombstones(runDir, tombstones); err != nil {
+		return err
+	}
+	if err := manifest.write(manifestFile); err != nil {
+		return err
+	}
+	return recordSnapshot(outputDir, run, tombstones)
+}
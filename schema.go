@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// rowSchemaVersion is bumped whenever the columns of the row schema change
+const rowSchemaVersion = 6
+
+// schemaFileName is the Avro schema of the rows, written next to the outputs
+const schemaFileName = "schema.avsc"
+
+// schemaRegistryURL is the Confluent-compatible registry the row schema is
+// registered with, if set
+var schemaRegistryURL string
+
+// schemaSubject is the registry subject the row schema is registered under
+var schemaSubject = "xmlgo-rows-value"
+
+// avroField is one field of an Avro record schema
+type avroField struct {
+	Name    string      `json:"name"`
+	Type    interface{} `json:"type"`
+	Default interface{} `json:"default,omitempty"`
+}
+
+// avroSchema is an Avro record schema
+type avroSchema struct {
+	Type          string      `json:"type"`
+	Name          string      `json:"name"`
+	Namespace     string      `json:"namespace"`
+	Doc           string      `json:"doc"`
+	SchemaVersion int         `json:"xmlgo.schema_version"`
+	Fields        []avroField `json:"fields"`
+}
+
+// avroTypes maps Go kinds to Avro primitive types
+var avroTypes = map[reflect.Kind]string{
+	reflect.String:  "string",
+	reflect.Int64:   "long",
+	reflect.Int32:   "int",
+	reflect.Bool:    "boolean",
+	reflect.Float64: "double",
+}
+
+// rowAvroSchema derives the Avro schema of the Parquet rows from the
+// parquet struct tags on parquetRecord, so it always matches the files
+func rowAvroSchema() (*avroSchema, error) {
+	schema := &avroSchema{
+		Type:          "record",
+		Name:          "Row",
+		Namespace:     "xmlgo",
+		Doc:           "One node or attribute row of a flattened XML document",
+		SchemaVersion: rowSchemaVersion,
+	}
+
+	t := reflect.TypeOf(parquetRecord{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("parquet")
+		if tag == "" {
+			continue
+		}
+		opts := parseParquetTag(tag)
+
+		ft := t.Field(i).Type
+		pointer := ft.Kind() == reflect.Ptr
+		if pointer {
+			ft = ft.Elem()
+		}
+		avroType, ok := avroTypes[ft.Kind()]
+		if !ok {
+			return nil, fmt.Errorf("no Avro type for field %s of kind %s", t.Field(i).Name, ft.Kind())
+		}
+
+		field := avroField{Name: opts["name"], Type: avroType}
+		if pointer || opts["repetitiontype"] == "OPTIONAL" {
+			// Avro requires the default of a union to match its first branch
+			field.Type = []string{"null", avroType}
+			field.Default = json.RawMessage("null")
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+	return schema, nil
+}
+
+// parseParquetTag splits a parquet-go struct tag into its key/value options
+func parseParquetTag(tag string) map[string]string {
+	opts := make(map[string]string)
+	for _, part := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if key != "name" {
+			value = strings.ToUpper(value)
+		}
+		opts[key] = value
+	}
+	return opts
+}
+
+// writeRowSchema saves the Avro row schema to fileName and, with
+// --schema-registry, registers it under schemaSubject
+func writeRowSchema(fileName string) error {
+	schema, err := rowAvroSchema()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode row schema: %v", err)
+	}
+	if err := os.WriteFile(fileName, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write row schema %s: %v", fileName, err)
+	}
+
+	if schemaRegistryURL != "" {
+		return registerSchema(schemaRegistryURL, schemaSubject, data)
+	}
+	return nil
+}
+
+// registerSchema posts an Avro schema to a Confluent-compatible schema registry
+func registerSchema(registry string, subject string, schema []byte) error {
+	body, err := json.Marshal(map[string]string{"schemaType": "AVRO", "schema": string(schema)})
+	if err != nil {
+		return fmt.Errorf("failed to encode schema registration: %v", err)
+	}
+
+	endpoint := strings.TrimSuffix(registry, "/") + "/subjects/" + url.PathEscape(subject) + "/versions"
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(endpoint, "application/vnd.schemaregistry.v1+json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to register schema with %s: %v", registry, err)
+	}
+	defer resp.Body.Close()
+
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("schema registry %s rejected subject %s: %s: %s", registry, subject, resp.Status, strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	extractors["security-reports"] = securityReportsExtractor{}
+	formatHandlers[".nessus"] = "xml"
+}
+
+// ScanHostRow is one scanned host in scan_hosts.parquet. scanner is
+// nessus, nmap or burp.
+type ScanHostRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Scanner       string `parquet:"name=scanner, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Address       string `parquet:"name=address, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Hostname      string `parquet:"name=hostname, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	MAC           string `parquet:"name=mac, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OS            string `parquet:"name=os, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Status        string `parquet:"name=status, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// ScanServiceRow is one port of a host in scan_services.parquet
+type ScanServiceRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Scanner       string `parquet:"name=scanner, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	HostAddress   string `parquet:"name=host_address, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Port          int32  `parquet:"name=port, type=INT32, convertedtype=INT_32"`
+	Protocol      string `parquet:"name=protocol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	State         string `parquet:"name=state, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Service       string `parquet:"name=service, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Product       string `parquet:"name=product, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Version       string `parquet:"name=version, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// ScanFindingRow is one reported issue in scan_findings.parquet: a Nessus
+// plugin result, an Nmap script result or a Burp issue. severity is info,
+// low, medium, high or critical ("" for Nmap); cves are comma-separated.
+type ScanFindingRow struct {
+	FilePath      string  `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64   `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string  `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Scanner       string  `parquet:"name=scanner, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	HostAddress   string  `parquet:"name=host_address, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Port          int32   `parquet:"name=port, type=INT32, convertedtype=INT_32"`
+	Protocol      string  `parquet:"name=protocol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	FindingID     string  `parquet:"name=finding_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Name          string  `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Severity      string  `parquet:"name=severity, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	CVSS          float64 `parquet:"name=cvss, type=DOUBLE"`
+	CVEs          string  `parquet:"name=cves, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Location      string  `parquet:"name=location, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Description   string  `parquet:"name=description, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Solution      string  `parquet:"name=solution, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Output        string  `parquet:"name=output, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// securityReportsExtractor reads the XML reports of Nessus (.nessus v2),
+// Nmap (-oX) and Burp Suite (issue export) into shared hosts, services and
+// findings tables
+type securityReportsExtractor struct{}
+
+// Tables returns the scan_hosts, scan_services and scan_findings tables
+func (securityReportsExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{
+		"scan_hosts":    new(ScanHostRow),
+		"scan_services": new(ScanServiceRow),
+		"scan_findings": new(ScanFindingRow),
+	}
+}
+
+// Detect accepts the roots of Nessus, Nmap and Burp reports
+func (securityReportsExtractor) Detect(doc *Document) bool {
+	switch doc.Root.XMLName.Local {
+	case "NessusClientData_v2", "nmaprun":
+		return true
+	case "issues":
+		return attrValue(doc.Root, "burpVersion") != "" || childElement(doc.Root, "issue") != nil
+	}
+	return false
+}
+
+// Extract writes the rows of the report
+func (securityReportsExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	switch doc.Root.XMLName.Local {
+	case "NessusClientData_v2":
+		return extractNessus(doc, rows)
+	case "nmaprun":
+		return extractNmap(doc, rows)
+	}
+	return extractBurp(doc, rows)
+}
+
+// extractNessus writes a host per ReportHost and a finding per ReportItem.
+// Services are the distinct ports the findings of a host were seen on.
+func extractNessus(doc *Document, rows func(table string, row interface{}) error) error {
+	var err error
+	services := make(map[string]bool)
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil || parent == nil {
+			return
+		}
+		switch node.XMLName.Local {
+		case "ReportHost":
+			host := ScanHostRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, Scanner: "nessus", Address: attrValue(node, "name"), Status: "up"}
+			for _, tag := range childElements(childElement(node, "HostProperties"), "tag") {
+				value := strings.TrimSpace(tag.Content)
+				switch attrValue(tag, "name") {
+				case "host-ip":
+					host.Address = value
+				case "host-fqdn":
+					host.Hostname = value
+				case "mac-address":
+					host.MAC = value
+				case "operating-system":
+					host.OS = value
+				}
+			}
+			err = rows("scan_hosts", host)
+		case "ReportItem":
+			address := nessusAddress(parent)
+			port, _ := strconv.Atoi(attrValue(node, "port"))
+			protocol := attrValue(node, "protocol")
+			if key := address + "/" + attrValue(node, "port") + "/" + protocol; port != 0 && !services[key] {
+				services[key] = true
+				err = rows("scan_services", ScanServiceRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, Scanner: "nessus",
+					HostAddress: address, Port: int32(port), Protocol: protocol, State: "open", Service: attrValue(node, "svc_name")})
+				if err != nil {
+					return
+				}
+			}
+			text := func(local string) string {
+				if child := childElement(node, local); child != nil {
+					return strings.TrimSpace(child.Content)
+				}
+				return ""
+			}
+			finding := ScanFindingRow{
+				FilePath:      doc.FilePath,
+				NodeID:        nodeID,
+				ContainerPath: doc.ContainerPath,
+				Scanner:       "nessus",
+				HostAddress:   address,
+				Port:          int32(port),
+				Protocol:      protocol,
+				FindingID:     attrValue(node, "pluginID"),
+				Name:          attrValue(node, "pluginName"),
+				Severity:      nessusSeverities[attrValue(node, "severity")],
+				Description:   text("description"),
+				Solution:      text("solution"),
+				Output:        text("plugin_output"),
+			}
+			var cves []string
+			for _, cve := range childElements(node, "cve") {
+				cves = append(cves, strings.TrimSpace(cve.Content))
+			}
+			finding.CVEs = strings.Join(cves, ",")
+			if cvss, parseErr := strconv.ParseFloat(text("cvss3_base_score"), 64); parseErr == nil {
+				finding.CVSS = cvss
+			} else {
+				finding.CVSS, _ = strconv.ParseFloat(text("cvss_base_score"), 64)
+			}
+			err = rows("scan_findings", finding)
+		}
+	})
+	return err
+}
+
+// nessusSeverities names the severity levels of Nessus plugin results
+var nessusSeverities = map[string]string{"0": "info", "1": "low", "2": "medium", "3": "high", "4": "critical"}
+
+// nessusAddress returns the address of a ReportHost, preferring its
+// host-ip property to its name
+func nessusAddress(host *XMLNode) string {
+	for _, tag := range childElements(childElement(host, "HostProperties"), "tag") {
+		if attrValue(tag, "name") == "host-ip" {
+			return strings.TrimSpace(tag.Content)
+		}
+	}
+	return attrValue(host, "name")
+}
+
+// extractNmap writes a host per host element, a service per port and a
+// finding per script result, of a port or of the host
+func extractNmap(doc *Document, rows func(table string, row interface{}) error) error {
+	var err error
+	var address string
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil || parent == nil {
+			return
+		}
+		switch {
+		case node.XMLName.Local == "host" && parent == doc.Root:
+			host := ScanHostRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, Scanner: "nmap",
+				Status: attrValue(childElement(node, "status"), "state")}
+			for _, addr := range childElements(node, "address") {
+				switch attrValue(addr, "addrtype") {
+				case "mac":
+					host.MAC = attrValue(addr, "addr")
+				default:
+					if host.Address == "" {
+						host.Address = attrValue(addr, "addr")
+					}
+				}
+			}
+			if hostname := childElement(childElement(node, "hostnames"), "hostname"); hostname != nil {
+				host.Hostname = attrValue(hostname, "name")
+			}
+			host.OS = attrValue(childElement(childElement(node, "os"), "osmatch"), "name")
+			address = host.Address
+			err = rows("scan_hosts", host)
+		case node.XMLName.Local == "port" && parent.XMLName.Local == "ports":
+			port, _ := strconv.Atoi(attrValue(node, "portid"))
+			service := childElement(node, "service")
+			err = rows("scan_services", ScanServiceRow{
+				FilePath:      doc.FilePath,
+				NodeID:        nodeID,
+				ContainerPath: doc.ContainerPath,
+				Scanner:       "nmap",
+				HostAddress:   address,
+				Port:          int32(port),
+				Protocol:      attrValue(node, "protocol"),
+				State:         attrValue(childElement(node, "state"), "state"),
+				Service:       attrValue(service, "name"),
+				Product:       attrValue(service, "product"),
+				Version:       attrValue(service, "version"),
+			})
+		case node.XMLName.Local == "script" && (parent.XMLName.Local == "port" || parent.XMLName.Local == "hostscript"):
+			finding := ScanFindingRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, Scanner: "nmap", HostAddress: address,
+				FindingID: attrValue(node, "id"), Name: attrValue(node, "id"), Output: strings.TrimSpace(attrValue(node, "output"))}
+			if parent.XMLName.Local == "port" {
+				port, _ := strconv.Atoi(attrValue(parent, "portid"))
+				finding.Port, finding.Protocol = int32(port), attrValue(parent, "protocol")
+			}
+			err = rows("scan_findings", finding)
+		}
+	})
+	return err
+}
+
+// extractBurp writes a finding per issue and a host and service per
+// distinct origin the issues were found on
+func extractBurp(doc *Document, rows func(table string, row interface{}) error) error {
+	var err error
+	origins := make(map[string]bool)
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil || parent != doc.Root || node.XMLName.Local != "issue" {
+			return
+		}
+		text := func(local string) string {
+			if child := childElement(node, local); child != nil {
+				return strings.TrimSpace(child.Content)
+			}
+			return ""
+		}
+		hostNode := childElement(node, "host")
+		origin := text("host")
+		address := attrValue(hostNode, "ip")
+		var port int
+		var scheme, hostname string
+		if u, parseErr := url.Parse(origin); parseErr == nil {
+			scheme, hostname = u.Scheme, u.Hostname()
+			if port, parseErr = strconv.Atoi(u.Port()); parseErr != nil {
+				port = map[string]int{"http": 80, "https": 443}[scheme]
+			}
+		}
+		if address == "" {
+			address = hostname
+		}
+		if !origins[origin] {
+			origins[origin] = true
+			if err = rows("scan_hosts", ScanHostRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, Scanner: "burp",
+				Address: address, Hostname: hostname, Status: "up"}); err != nil {
+				return
+			}
+			if err = rows("scan_services", ScanServiceRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, Scanner: "burp",
+				HostAddress: address, Port: int32(port), Protocol: "tcp", State: "open", Service: scheme}); err != nil {
+				return
+			}
+		}
+		severity := strings.ToLower(text("severity"))
+		if severity == "information" {
+			severity = "info"
+		}
+		err = rows("scan_findings", ScanFindingRow{
+			FilePath:      doc.FilePath,
+			NodeID:        nodeID,
+			ContainerPath: doc.ContainerPath,
+			Scanner:       "burp",
+			HostAddress:   address,
+			Port:          int32(port),
+			Protocol:      "tcp",
+			FindingID:     text("type"),
+			Name:          text("name"),
+			Severity:      severity,
+			Location:      text("location"),
+			Description:   text("issueBackground"),
+			Solution:      text("remediationBackground"),
+			Output:        text("issueDetail"),
+		})
+	})
+	return err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"io"
+)
+
+// markSelfClosing records in the self_closing column whether each element
+// was written as an empty-element tag, <a/>, rather than as <a></a>, for
+// serializers and signature checks that tell the two apart
+// (--self-closing). encoding/xml reports both forms the same way, so the
+// tags are told apart from the bytes the decoder reads.
+var markSelfClosing bool
+
+// tagEndReader is the byte source of a decoder, remembering the last two
+// bytes read. xml.Decoder reads an io.ByteReader one byte at a time and
+// stops at the > closing a start tag, so when it returns a StartElement
+// the tag was an empty-element tag exactly when / preceded that >.
+type tagEndReader struct {
+	r          io.ByteReader
+	prev, last byte
+}
+
+// trackTagEnds wraps r for a decoder when --self-closing is set, returning
+// r itself and a nil tracker otherwise
+func trackTagEnds(r io.Reader) (io.Reader, *tagEndReader) {
+	if !markSelfClosing {
+		return r, nil
+	}
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	t := &tagEndReader{r: br}
+	return t, t
+}
+
+// ReadByte reads the next byte
+func (t *tagEndReader) ReadByte() (byte, error) {
+	b, err := t.r.ReadByte()
+	if err == nil {
+		t.prev, t.last = t.last, b
+	}
+	return b, err
+}
+
+// Read reads into p; the decoder only uses ReadByte
+func (t *tagEndReader) Read(p []byte) (int, error) {
+	for i := range p {
+		b, err := t.ReadByte()
+		if err != nil {
+			return i, err
+		}
+		p[i] = b
+	}
+	return len(p), nil
+}
+
+// selfClosing reports whether the start tag just read ended in />
+func (t *tagEndReader) selfClosing() bool {
+	return t.prev == '/' && t.last == '>'
+}
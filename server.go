@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// serveAddr, when set, runs xmlgo as an HTTP server that converts uploaded
+// files instead of converting its arguments
+var serveAddr string
+
+// serveDir is where the server keeps uploads and writes job outputs
+var serveDir = "xmlgo-jobs"
+
+// queueSize is the number of accepted jobs that may wait for the converter.
+// Uploads beyond it are rejected with 429 until the queue drains.
+var queueSize = 16
+
+// Job states reported by GET /jobs/{id}
+const (
+	jobUploading = "uploading"
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+)
+
+// job is one uploaded file and the state of its conversion. Input and
+// OutputDir are paths on the server: clients see the job ID and the names
+// in Outputs instead, and only the job file records them.
+type job struct {
+	ID        string     `json:"id"`
+	Status    string     `json:"status"`
+	Input     string     `json:"-"`
+	OutputDir string     `json:"-"`
+	Error     string     `json:"error,omitempty"`
+	Report    *RunReport `json:"report,omitempty"`
+	// UploadLength is the announced size of a resumable upload
+	UploadLength int64 `json:"upload_length,omitempty"`
+	// Outputs lists the files of OutputDir once the job is done, relative to it
+	Outputs     []string   `json:"outputs,omitempty"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+
+	tenant *Tenant
+	// writing is set while a PATCH appends to the upload
+	writing bool
+}
+
+// jobServer serves the HTTP API. Conversions share package-level state, so
+// a single worker runs the queued jobs one at a time.
+type jobServer struct {
+	cfg      *runConfig
+	tenants  []*Tenant
+	tokens   []string
+	queue    chan *job
+	draining atomic.Bool
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// newJobServer creates a server converting with cfg. A nil tenants list
+// serves every request as the anonymous tenant.
+func newJobServer(cfg *runConfig, tenants []*Tenant) *jobServer {
+	return &jobServer{
+		cfg:     cfg,
+		tenants: tenants,
+		queue:   make(chan *job, queueSize),
+		jobs:    make(map[string]*job),
+	}
+}
+
+// handler routes the HTTP API
+func (s *jobServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /readyz", s.readyz)
+	mux.HandleFunc("POST /convert", s.submit)
+	mux.HandleFunc("POST /uploads", s.createUpload)
+	mux.HandleFunc("HEAD /uploads/{id}", s.uploadOffset)
+	mux.HandleFunc("PATCH /uploads/{id}", s.appendUpload)
+	mux.HandleFunc("GET /jobs", s.list)
+	mux.HandleFunc("GET /jobs/{id}", s.status)
+	mux.HandleFunc("DELETE /jobs/{id}", s.remove)
+	mux.HandleFunc("GET /jobs/{id}/output/{file...}", s.output)
+	return mux
+}
+
+// healthz reports that the process is up
+func (s *jobServer) healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz reports whether new jobs would be accepted, so load balancers stop
+// routing uploads to a saturated or shutting down instance
+func (s *jobServer) readyz(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case s.draining.Load():
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+	case len(s.queue) >= cap(s.queue):
+		http.Error(w, "queue full", http.StatusServiceUnavailable)
+	default:
+		fmt.Fprintln(w, "ready")
+	}
+}
+
+// submit stores the request body as ?name= and queues its conversion
+func (s *jobServer) submit(w http.ResponseWriter, r *http.Request) {
+	j, limit, ok := s.newJob(w, r, jobQueued)
+	if !ok {
+		return
+	}
+	if limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	if status, err := saveUpload(j.Input, r.Body); err != nil {
+		s.forget(j)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if !s.enqueue(w, j) {
+		s.forget(j)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// newJob authenticates the request and registers a job in the given state
+// for the upload named by ?name=. It returns the most the tenant's quotas let the upload write
+// (0 means no limit), or answers the request and reports false.
+func (s *jobServer) newJob(w http.ResponseWriter, r *http.Request, status string) (*job, int64, bool) {
+	if s.draining.Load() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return nil, 0, false
+	}
+	tenant := s.authenticate(r)
+	if tenant == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, 0, false
+	}
+
+	name := filepath.Base(r.URL.Query().Get("name"))
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		http.Error(w, "missing or invalid name parameter", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	tenantDir := filepath.Join(serveDir, tenant.Prefix)
+	limit := tenant.MaxUploadBytes
+	if tenant.MaxStoredBytes > 0 {
+		stored, err := storedBytes(tenantDir)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return nil, 0, false
+		}
+		remaining := tenant.MaxStoredBytes - stored
+		if remaining <= 0 {
+			http.Error(w, "storage quota exceeded", http.StatusInsufficientStorage)
+			return nil, 0, false
+		}
+		if limit == 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, 0, false
+	}
+	j := &job{
+		ID:        id,
+		Status:    status,
+		Input:     filepath.Join(tenantDir, id, "input", name),
+		OutputDir: filepath.Join(tenantDir, id, "output"),
+		tenant:    tenant,
+
+		SubmittedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tenant.MaxJobs > 0 && s.activeJobs(tenant) >= tenant.MaxJobs {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "job quota exceeded", http.StatusTooManyRequests)
+		return nil, 0, false
+	}
+	s.jobs[id] = j
+	return j, limit, true
+}
+
+// enqueue hands a job with a complete upload to the worker, answering 429
+// and reporting false when the queue is full
+func (s *jobServer) enqueue(w http.ResponseWriter, j *job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case s.queue <- j:
+		j.Status = jobQueued
+		s.save(j)
+		return true
+	default:
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "queue full", http.StatusTooManyRequests)
+		return false
+	}
+}
+
+// forget drops a job that was never queued, along with its upload
+func (s *jobServer) forget(j *job) {
+	s.mu.Lock()
+	delete(s.jobs, j.ID)
+	s.mu.Unlock()
+	os.RemoveAll(filepath.Dir(j.OutputDir))
+}
+
+// lookup returns the job named in the request if it belongs to the
+// requesting tenant. Other tenants' jobs are reported as unknown.
+func (s *jobServer) lookup(w http.ResponseWriter, r *http.Request) *job {
+	tenant := s.authenticate(r)
+	if tenant == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok || j.tenant != tenant {
+		http.Error(w, "unknown job", http.StatusNotFound)
+		return nil
+	}
+	return j
+}
+
+// status reports the state of a job
+func (s *jobServer) status(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, j)
+}
+
+// output serves a file from the output directory of a finished job, or the
+// list of its files when no file is named
+func (s *jobServer) output(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	s.mu.Lock()
+	status := j.Status
+	s.mu.Unlock()
+	if status != jobDone {
+		http.Error(w, "job is "+status, http.StatusConflict)
+		return
+	}
+	name := r.PathValue("file")
+	if name == "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		writeJSON(w, http.StatusOK, j.Outputs)
+		return
+	}
+	if !filepath.IsLocal(name) {
+		http.Error(w, "invalid file name", http.StatusBadRequest)
+		return
+	}
+	http.ServeFile(w, r, filepath.Join(j.OutputDir, name))
+}
+
+// run converts queued jobs until the queue is closed
+func (s *jobServer) run() {
+	for j := range s.queue {
+		s.setStatus(j, jobRunning, nil)
+		_, err := convert(s.cfg, j.Input, j.OutputDir)
+		if auditLog != "" {
+			recordAudit(j, j.Input, j.OutputDir, err)
+		}
+		if err != nil {
+			log.Printf("Job %s failed: %v", j.ID, err)
+			s.setStatus(j, jobFailed, err)
+		} else {
+			report.logSummary()
+			s.setStatus(j, jobDone, nil)
+		}
+		notifyJob(j, err)
+	}
+}
+
+// setStatus updates a job under the server lock and saves it
+func (s *jobServer) setStatus(j *job, status string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	j.Status = status
+	if err != nil {
+		j.Error = err.Error()
+	}
+	switch status {
+	case jobRunning:
+		j.StartedAt = &now
+	case jobDone, jobFailed:
+		j.FinishedAt = &now
+		j.Report = clientReport(report, filepath.Dir(j.Input))
+	}
+	if status == jobDone {
+		j.Outputs = listOutputs(j.OutputDir)
+	}
+	s.save(j)
+}
+
+// clientReport copies r with the input paths it lists made relative to
+// inputDir, the directory holding the job's upload
+func clientReport(r *RunReport, inputDir string) *RunReport {
+	relative := func(path string) string {
+		if rel, err := filepath.Rel(inputDir, path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+		return filepath.Base(path)
+	}
+	c := *r
+	c.Unprocessed = nil
+	for _, path := range r.Unprocessed {
+		c.Unprocessed = append(c.Unprocessed, relative(path))
+	}
+	c.Written = nil
+	for _, w := range r.Written {
+		w.Input = relative(w.Input)
+		c.Written = append(c.Written, w)
+	}
+	c.Failures = nil
+	for _, f := range r.Failures {
+		f.Input = relative(f.Input)
+		c.Failures = append(c.Failures, f)
+	}
+	return &c
+}
+
+// serve runs the HTTP server until SIGINT or SIGTERM, then stops accepting
+// jobs and waits for the queued ones to finish
+func serve(cfg *runConfig) error {
+	if queueSize < 1 {
+		return fmt.Errorf("--queue-size must be at least 1")
+	}
+	if err := os.MkdirAll(serveDir, os.ModePerm); err != nil {
+		return wrapFSError("create", serveDir, err)
+	}
+
+	var tenants []*Tenant
+	if tenantsFile != "" {
+		var err error
+		if tenants, err = loadTenants(tenantsFile); err != nil {
+			return err
+		}
+	}
+
+	var tokens []string
+	if apiTokensFile != "" {
+		if tenants != nil {
+			return fmt.Errorf("--api-tokens cannot be combined with --tenants; tenant keys are the bearer tokens")
+		}
+		var err error
+		if tokens, err = loadAPITokens(apiTokensFile); err != nil {
+			return err
+		}
+	}
+
+	tlsConfig, err := serverTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	s := newJobServer(cfg, tenants)
+	s.tokens = tokens
+	if err := s.restore(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		s.run()
+		close(done)
+	}()
+
+	server := &http.Server{Addr: serveAddr, Handler: s.handler(), TLSConfig: tlsConfig}
+	shutdown := make(chan error, 1)
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		log.Printf("Shutting down, finishing %d queued jobs", len(s.queue))
+		s.draining.Store(true)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		shutdown <- server.Shutdown(ctx)
+	}()
+
+	log.Printf("Serving on %s, writing jobs to %s", serveAddr, serveDir)
+	if tlsConfig != nil {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		return fmt.Errorf("failed to serve: %v", err)
+	}
+	err = <-shutdown
+	close(s.queue)
+	<-done
+	return err
+}
+
+// saveUpload writes an uploaded body to fileName, returning the HTTP status
+// to answer with when it fails
+func saveUpload(fileName string, body io.Reader) (int, error) {
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return http.StatusInternalServerError, wrapFSError("create", filepath.Dir(fileName), err)
+	}
+	f, err := os.Create(fileName)
+	if err != nil {
+		return http.StatusInternalServerError, wrapFSError("create", fileName, err)
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("upload exceeds the quota of %d bytes", tooLarge.Limit)
+		}
+		return http.StatusBadRequest, fmt.Errorf("failed to read upload: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return http.StatusInternalServerError, wrapFSError("write", fileName, err)
+	}
+	return 0, nil
+}
+
+// newJobID returns a random identifier for a job
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// writeJSON writes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestJobResponsesHideServerPaths runs a job whose report lists a failed
+// input: no response may name a path below the server's job directory,
+// while the job file keeps the paths a restarted server needs.
+func TestJobResponsesHideServerPaths(t *testing.T) {
+	defer func(dir string, keep bool) { serveDir, keepGoing = dir, keep }(serveDir, keepGoing)
+	serveDir, keepGoing = t.TempDir(), true
+	upload := filepath.Join(t.TempDir(), "a.zip")
+	writeTestZip(t, upload, map[string]string{"good.xml": "<a/>", "broken.xml": "<b>"})
+	body, err := os.ReadFile(upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newJobServer(&runConfig{formats: []string{"parquet"}, extensions: []string{".xml"}}, nil)
+	h := s.handler()
+	request := func(method, target string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, bytes.NewReader(body)))
+		if strings.Contains(w.Body.String(), serveDir) {
+			t.Errorf("%s %s names a server path: %s", method, target, w.Body)
+		}
+		return w
+	}
+
+	w := request(http.MethodPost, "/convert?name=a.zip", body)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /convert answered %d: %s", w.Code, w.Body)
+	}
+	var submitted job
+	if err := json.Unmarshal(w.Body.Bytes(), &submitted); err != nil {
+		t.Fatal(err)
+	}
+	close(s.queue)
+	s.run()
+
+	var done job
+	if err := json.Unmarshal(request(http.MethodGet, "/jobs/"+submitted.ID, nil).Body.Bytes(), &done); err != nil {
+		t.Fatal(err)
+	}
+	if done.Status != jobDone || done.Report == nil || len(done.Report.Failures) != 1 {
+		t.Fatalf("job finished as %+v, want done with one failure", done)
+	}
+	if input := done.Report.Failures[0].Input; input != "a.zip" {
+		t.Errorf("report lists the failed input as %q, want a.zip", input)
+	}
+	request(http.MethodGet, "/jobs", nil)
+
+	restarted := newJobServer(s.cfg, nil)
+	if err := restarted.restore(); err != nil {
+		t.Fatal(err)
+	}
+	original, j := s.jobs[submitted.ID], restarted.jobs[submitted.ID]
+	if j == nil || j.Input != original.Input || j.OutputDir != original.OutputDir {
+		t.Errorf("restored job %+v, want the paths of %+v", j, original)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// snapshotsFileName is the index of the snapshots of an incremental
+// output directory
+const snapshotsFileName = "snapshots.json"
+
+// snapshotIndex is the content of snapshots.json. Every --schedule run and
+// backfill batch adds a snapshot: the dataset as it stood once that run's
+// files were written and the files it superseded were retired.
+type snapshotIndex struct {
+	Snapshots []snapshot `json:"snapshots"`
+}
+
+// snapshot is one entry of the index. Files are relative to the output
+// directory; Superseded lists the inputs whose rows in earlier runs this
+// run replaced or deleted, as in its tombstones.parquet.
+type snapshot struct {
+	ID         int64             `json:"id"`
+	Run        string            `json:"run"`
+	CreatedAt  time.Time         `json:"created_at"`
+	ParentID   int64             `json:"parent_id,omitempty"`
+	AddedFiles []string          `json:"added_files"`
+	Superseded []supersededInput `json:"superseded,omitempty"`
+}
+
+// supersededInput names the rows of one input in one earlier run
+type supersededInput struct {
+	ContainerPath string `json:"container_path"`
+	Run           string `json:"run"`
+}
+
+// snapshotState is the dataset as of a snapshot: the data files to read
+// and the rows to leave out of them
+type snapshotState struct {
+	Snapshot  int64             `json:"snapshot"`
+	CreatedAt time.Time         `json:"created_at"`
+	Files     []string          `json:"files"`
+	Exclude   []supersededInput `json:"exclude"`
+}
+
+// loadSnapshotIndex reads the index of outputDir, returning an empty index
+// when no snapshot has been recorded yet
+func loadSnapshotIndex(outputDir string) (*snapshotIndex, error) {
+	fileName := filepath.Join(outputDir, snapshotsFileName)
+	index := &snapshotIndex{Snapshots: []snapshot{}}
+	data, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, wrapFSError("read", fileName, err)
+	}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", fileName, err)
+	}
+	return index, nil
+}
+
+// recordSnapshot adds the snapshot written by run, whose outputs are in
+// outputDir/run, to the index, replacing it atomically
+func recordSnapshot(outputDir, run string, tombstones []TombstoneRow) error {
+	index, err := loadSnapshotIndex(outputDir)
+	if err != nil {
+		return err
+	}
+	s := snapshot{ID: 1, Run: run, CreatedAt: time.Now().UTC(), AddedFiles: []string{}}
+	if n := len(index.Snapshots); n > 0 {
+		s.ParentID = index.Snapshots[n-1].ID
+		s.ID = s.ParentID + 1
+	}
+	for _, name := range listOutputs(filepath.Join(outputDir, run)) {
+		s.AddedFiles = append(s.AddedFiles, path.Join(run, name))
+	}
+	for _, t := range tombstones {
+		s.Superseded = append(s.Superseded, supersededInput{ContainerPath: t.ContainerPath, Run: t.SupersededRun})
+	}
+	index.Snapshots = append(index.Snapshots, s)
+
+	fileName := filepath.Join(outputDir, snapshotsFileName)
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", fileName, err)
+	}
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return wrapFSError("write", tmp, err)
+	}
+	if err := os.Rename(tmp, fileName); err != nil {
+		return wrapFSError("replace", fileName, err)
+	}
+	return nil
+}
+
+// asOf returns the dataset as of a snapshot, given by id or as an RFC 3339
+// time or date taking the last snapshot created by then. Only the data
+// tables are listed: combined output, extractor and sidecar tables, not
+// reports or tombstones.
+func (index *snapshotIndex) asOf(at string) (*snapshotState, error) {
+	var target *snapshot
+	if id, err := strconv.ParseInt(at, 10, 64); err == nil {
+		for i := range index.Snapshots {
+			if index.Snapshots[i].ID == id {
+				target = &index.Snapshots[i]
+			}
+		}
+		if target == nil {
+			return nil, fmt.Errorf("no snapshot %d", id)
+		}
+	} else {
+		t, err := parseBackfillTime(at)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither a snapshot id, a date (2006-01-02) nor an RFC 3339 time", at)
+		}
+		for i := range index.Snapshots {
+			if !index.Snapshots[i].CreatedAt.After(t) {
+				target = &index.Snapshots[i]
+			}
+		}
+		if target == nil {
+			return nil, fmt.Errorf("no snapshot was created by %s", at)
+		}
+	}
+
+	state := &snapshotState{Snapshot: target.ID, CreatedAt: target.CreatedAt, Files: []string{}, Exclude: []supersededInput{}}
+	for _, s := range index.Snapshots {
+		if s.ID > target.ID {
+			break
+		}
+		for _, name := range s.AddedFiles {
+			switch path.Ext(name) {
+			case ".parquet", ".jsonl", ".ndjson":
+				if path.Base(name) != tombstonesFileName {
+					state.Files = append(state.Files, name)
+				}
+			}
+		}
+		state.Exclude = append(state.Exclude, s.Superseded...)
+	}
+	return state, nil
+}
+
+// printSnapshot writes the dataset as of at in outputDir to stdout as JSON
+func printSnapshot(outputDir, at string) error {
+	index, err := loadSnapshotIndex(outputDir)
+	if err != nil {
+		return err
+	}
+	state, err := index.asOf(at)
+	if err != nil {
+		return fmt.Errorf("%s: %v", outputDir, err)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %v", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
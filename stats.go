@@ -0,0 +1,86 @@
+package main
+
+// outputStats are statistics of the rows a run writes to its combined
+// output: the rows per tag, recorded in the run report, and the null count
+// of each OPTIONAL column, which only a data contract needs
+type outputStats struct {
+	rows       int64
+	tags       map[string]int64
+	countNulls bool
+	nulls      [len(nullColumns)]int64
+}
+
+// nullColumns are the OPTIONAL columns of the combined output, in the order
+// of outputStats.nulls
+var nullColumns = [...]string{"parent_node_id", "tag_name", "attribute_name", "attribute_value", "tag_id", "attribute_id", "entry_path", "self_closing"}
+
+// runOutputStats accumulates the statistics of the current run
+var runOutputStats *outputStats
+
+// newOutputStats starts the statistics of a run
+func newOutputStats(countNulls bool) *outputStats {
+	return &outputStats{tags: make(map[string]int64), countNulls: countNulls}
+}
+
+// record adds a row to the statistics
+func (s *outputStats) record(row *ParquetRow) {
+	s.rows++
+	if row.IsNode {
+		s.tags[row.TagName]++
+	}
+	if !s.countNulls {
+		return
+	}
+	rec := newParquetRecord(row)
+	nulls := [len(nullColumns)]bool{
+		rec.ParentNodeID == nil,
+		rec.TagName == nil,
+		rec.AttributeName == nil,
+		rec.AttributeValue == nil,
+		rec.TagID == nil,
+		rec.AttributeID == nil,
+		rec.EntryPath == nil,
+		rec.SelfClosing == nil,
+	}
+	for i, null := range nulls {
+		if null {
+			s.nulls[i]++
+		}
+	}
+}
+
+// StatsWriter records the statistics of the rows passing through it on
+// their way to next
+type StatsWriter struct {
+	next  RowWriter
+	stats *outputStats
+}
+
+// NewStatsWriter records the rows written to next in stats
+func NewStatsWriter(next RowWriter, stats *outputStats) *StatsWriter {
+	return &StatsWriter{next: next, stats: stats}
+}
+
+// Write records and forwards a row
+func (w *StatsWriter) Write(row ParquetRow) error {
+	w.stats.record(&row)
+	return w.next.Write(row)
+}
+
+// WriteBatch records and forwards rows
+func (w *StatsWriter) WriteBatch(rows []ParquetRow) error {
+	for i := range rows {
+		w.stats.record(&rows[i])
+	}
+	return w.next.WriteBatch(rows)
+}
+
+// Commit forwards a commit point
+func (w *StatsWriter) Commit(point CommitPoint) error {
+	return commitNext(w.next, point)
+}
+
+// WriteStop finalizes next
+func (w *StatsWriter) WriteStop() error {
+	return w.next.WriteStop()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// strictMode fails the conversion instead of recovering from a problem or
+// leaving a document out of the output (--strict): empty, oversized,
+// unreadable and duplicate documents are errors rather than skips, and
+// options that recover from errors or decode leniently are rejected
+var strictMode bool
+
+// strictSkipReasons are the skip reasons allowed under --strict, since they
+// do not leave out data the run was asked to convert
+var strictSkipReasons = map[string]bool{
+	skipExtension:  true,
+	skipResumed:    true,
+	skipConfigured: true,
+	skipPartType:   true,
+	skipContainer:  true,
+}
+
+// strictSkip returns the error recordSkip fails with under --strict, or nil
+func strictSkip(relativePath string, reason string) error {
+	if !strictMode || strictSkipReasons[reason] {
+		return nil
+	}
+	return fmt.Errorf("strict mode: %s would be skipped (%s)", relativePath, reason)
+}
+
+// validStrict rejects the options --strict cannot be combined with
+func validStrict() error {
+	if !strictMode {
+		return nil
+	}
+	if keepGoing || skipUnreadable {
+		return fmt.Errorf("--strict cannot be combined with --keep-going or --skip-unreadable")
+	}
+	keys := make([]string, 0, len(configuredHandlers))
+	for key := range configuredHandlers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		h := configuredHandlers[key]
+		if h.Handler == "html" || (h.Handler == "xml" && h.Options["strict"] == "false") {
+			return fmt.Errorf("--strict cannot be combined with the lenient %s handler for %s", h.Handler, key)
+		}
+	}
+	return nil
+}
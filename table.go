@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// ParquetTable is a ZSTD-compressed Parquet file holding rows of a single
+// struct type, used for the sidecar tables written next to combined.parquet
+type ParquetTable struct {
+	name   string
+	file   source.ParquetFile
+	writer *writer.ParquetWriter
+}
+
+// NewParquetTable creates fileName with the schema of obj's parquet struct tags
+func NewParquetTable(fileName string, obj interface{}) (*ParquetTable, error) {
+	file, err := local.NewLocalFileWriter(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Parquet file %s: %v", fileName, err)
+	}
+
+	pw, err := writer.NewParquetWriter(file, obj, parquetParallelism())
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create Parquet writer for %s: %v", fileName, err)
+	}
+	pw.CompressionType = parquet.CompressionCodec_ZSTD
+
+	return &ParquetTable{name: fileName, file: file, writer: pw}, nil
+}
+
+// Write appends a row to the table
+func (t *ParquetTable) Write(row interface{}) error {
+	if err := t.writer.Write(row); err != nil {
+		return fmt.Errorf("failed to write row to %s: %v", t.name, err)
+	}
+	return nil
+}
+
+// Close writes the footer and closes the file
+func (t *ParquetTable) Close() error {
+	if err := t.writer.WriteStop(); err != nil {
+		t.file.Close()
+		return fmt.Errorf("failed to finalize %s: %v", t.name, err)
+	}
+	return t.file.Close()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateFile is the text/template rendered for every row when --template is set
+var templateFile string
+
+// templateFuncs are helpers available to output templates
+var templateFuncs = template.FuncMap{
+	// sql quotes a value as a SQL string literal
+	"sql": func(value string) string {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	},
+	// json encodes a value as JSON
+	"json": func(value interface{}) (string, error) {
+		b, err := json.Marshal(value)
+		return string(b), err
+	},
+}
+
+// templateOutputName derives the output file name from the template name,
+// e.g. inserts.sql.tmpl renders to inserts.sql
+func templateOutputName(templatePath string) string {
+	base := filepath.Base(templatePath)
+	if name := strings.TrimSuffix(base, ".tmpl"); name != base && name != "" {
+		return name
+	}
+	return base + ".out"
+}
+
+// TemplateWriter renders each row through a user-supplied Go text/template.
+// The template is executed once per row with the ParquetRow as its data.
+// Optional templates named "header" and "footer" are rendered once at the
+// start and end of the output, e.g. for BEGIN/COMMIT around SQL inserts.
+type TemplateWriter struct {
+	file io.WriteCloser
+	buf  *bufio.Writer
+	tmpl *template.Template
+}
+
+// NewTemplateWriter parses templatePath and creates the output file fileName
+func NewTemplateWriter(fileName string, templatePath string) (*TemplateWriter, error) {
+	tmpl, err := template.New(filepath.Base(templatePath)).Funcs(templateFuncs).ParseFiles(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", templatePath, err)
+	}
+
+	file, err := openOutputStream(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create template output %s: %v", fileName, err)
+	}
+
+	w := &TemplateWriter{file: file, buf: bufio.NewWriter(file), tmpl: tmpl}
+	if err := w.executeNamed("header"); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// executeNamed renders an optional named template with no data
+func (w *TemplateWriter) executeNamed(name string) error {
+	if w.tmpl.Lookup(name) == nil {
+		return nil
+	}
+	if err := w.tmpl.ExecuteTemplate(w.buf, name, nil); err != nil {
+		return fmt.Errorf("failed to render %s template: %v", name, err)
+	}
+	return nil
+}
+
+// Write renders a single row
+func (w *TemplateWriter) Write(row ParquetRow) error {
+	if err := w.tmpl.Execute(w.buf, row); err != nil {
+		return fmt.Errorf("failed to render row for node %d: %v", row.NodeID, err)
+	}
+	return nil
+}
+
+// WriteBatch renders each row in turn
+func (w *TemplateWriter) WriteBatch(rows []ParquetRow) error {
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteStop renders the footer and closes the file
+func (w *TemplateWriter) WriteStop() error {
+	if err := w.executeNamed("footer"); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to flush template output: %v", err)
+	}
+	return w.file.Close()
+}
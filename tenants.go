@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tenantsFile lists the tenants sharing a server. Without it every request
+// is served as one anonymous tenant.
+var tenantsFile string
+
+// Tenant is one team sharing a server: its key, where its jobs are written
+// and the quotas that keep it from starving the others
+type Tenant struct {
+	Name string `json:"-"`
+	// Key is presented as "Authorization: Bearer <key>"
+	Key string `json:"key"`
+	// Prefix is the directory below --serve-dir holding the tenant's jobs
+	// (defaults to the tenant name)
+	Prefix string `json:"prefix,omitempty"`
+	// Webhook receives the completion payloads of the tenant's jobs instead of --webhook
+	Webhook string `json:"webhook,omitempty"`
+	// MaxJobs caps the tenant's unfinished jobs (0 means no limit)
+	MaxJobs int `json:"max_jobs,omitempty"`
+	// MaxUploadBytes caps the size of a single upload (0 means no limit)
+	MaxUploadBytes int64 `json:"max_upload_bytes,omitempty"`
+	// MaxStoredBytes caps the uploads and outputs kept under the prefix (0 means no limit)
+	MaxStoredBytes int64 `json:"max_stored_bytes,omitempty"`
+}
+
+// anonymousTenant serves every request when no tenants file is given
+var anonymousTenant = &Tenant{}
+
+// loadTenants reads a JSON object mapping tenant names to their settings
+func loadTenants(fileName string) ([]*Tenant, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, wrapFSError("read", fileName, err)
+	}
+	var byName map[string]*Tenant
+	if err := json.Unmarshal(data, &byName); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file %s: %v", fileName, err)
+	}
+
+	var tenants []*Tenant
+	prefixes := make(map[string]string)
+	for name, t := range byName {
+		t.Name = name
+		if t.Key == "" {
+			return nil, fmt.Errorf("tenant %q has no key", name)
+		}
+		if t.Prefix == "" {
+			t.Prefix = name
+		}
+		t.Prefix = filepath.Clean(t.Prefix)
+		if t.Prefix == "." || !filepath.IsLocal(t.Prefix) {
+			return nil, fmt.Errorf("tenant %q has prefix %q outside --serve-dir", name, t.Prefix)
+		}
+		if other, ok := prefixes[t.Prefix]; ok {
+			return nil, fmt.Errorf("tenants %q and %q share prefix %q", other, name, t.Prefix)
+		}
+		prefixes[t.Prefix] = name
+		tenants = append(tenants, t)
+	}
+
+	// A prefix nested in another would let one tenant list the other's jobs
+	for prefix, name := range prefixes {
+		for other, otherName := range prefixes {
+			if prefix != other && strings.HasPrefix(other, prefix+string(filepath.Separator)) {
+				return nil, fmt.Errorf("prefix of tenant %q is inside the prefix of tenant %q", otherName, name)
+			}
+		}
+	}
+	return tenants, nil
+}
+
+// authenticate returns the tenant whose key the request presents, or nil.
+// Without tenants the anonymous tenant is returned, provided the request
+// presents one of the API tokens when those are configured.
+func (s *jobServer) authenticate(r *http.Request) *Tenant {
+	token := bearerToken(r)
+	if s.tenants == nil {
+		if s.tokens == nil {
+			return anonymousTenant
+		}
+		for _, want := range s.tokens {
+			if tokenMatches(token, want) {
+				return anonymousTenant
+			}
+		}
+		return nil
+	}
+	for _, t := range s.tenants {
+		if tokenMatches(token, t.Key) {
+			return t
+		}
+	}
+	return nil
+}
+
+// activeJobs counts the unfinished jobs of t, including open uploads. The caller holds s.mu.
+func (s *jobServer) activeJobs(t *Tenant) int {
+	n := 0
+	for _, j := range s.jobs {
+		if j.tenant == t && (j.Status == jobUploading || j.Status == jobQueued || j.Status == jobRunning) {
+			n++
+		}
+	}
+	return n
+}
+
+// storedBytes sums the size of the files kept under dir
+func storedBytes(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TextIndexRow is a single posting in the full-text index sidecar. Every
+// term found in a text or attribute value points back at the node it came
+// from, so a keyword lookup can be joined against combined.parquet on
+// (file_path, node_id).
+//
+// The index is a plain Parquet postings table, not a Bleve or tantivy index:
+// neither format can be written without the library that owns it (Bleve is
+// not a dependency of xmlgo, and tantivy has no Go implementation). Search
+// engines do not open text_index.parquet directly. Query it with SQL, for
+// example with DuckDB, or load it into an index of your choice. Terms are
+// lower-cased runs of letters and digits of at least minTermLength
+// characters. There is no stemming, stop-word list or relevance ranking
+// beyond the per-node frequency.
+type TextIndexRow struct {
+	Term          string `parquet:"name=term, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	AttributeName string `parquet:"name=attribute_name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Frequency     int32  `parquet:"name=frequency, type=INT32, convertedtype=INT_32"`
+}
+
+// TextIndex writes postings for text and attribute values to text_index.parquet
+type TextIndex struct {
+	table *ParquetTable
+}
+
+// textIndex is the optional sidecar enabled by --text-index
+var textIndex *TextIndex
+
+// minTermLength drops single-character tokens, which are mostly noise in OOXML values
+const minTermLength = 2
+
+// NewTextIndex creates the index sidecar at fileName
+func NewTextIndex(fileName string) (*TextIndex, error) {
+	table, err := NewParquetTable(fileName, new(TextIndexRow))
+	if err != nil {
+		return nil, err
+	}
+	return &TextIndex{table: table}, nil
+}
+
+// Add tokenizes a value and writes one posting per distinct term
+func (ti *TextIndex) Add(nodeID int64, relativePath, attributeName, value string) error {
+	counts := make(map[string]int32)
+	var order []string
+	for _, term := range tokenize(value) {
+		if counts[term] == 0 {
+			order = append(order, term)
+		}
+		counts[term]++
+	}
+
+	for _, term := range order {
+		row := TextIndexRow{
+			Term:          term,
+			NodeID:        nodeID,
+			FilePath:      relativePath,
+			AttributeName: attributeName,
+			Frequency:     counts[term],
+		}
+		if err := ti.table.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the index footer and closes the file
+func (ti *TextIndex) Close() error {
+	return ti.table.Close()
+}
+
+// tokenize lower-cases a value and splits it on anything that is not a letter or digit
+func tokenize(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := fields[:0]
+	for _, field := range fields {
+		if len([]rune(field)) < minTermLength {
+			continue
+		}
+		terms = append(terms, strings.ToLower(field))
+	}
+	return terms
+}
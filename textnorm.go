@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// unicodeForm normalizes text values of the combined output and the text
+// index to a Unicode normalization form, so the same text from differently
+// normalized sources compares equal: "nfc", "nfkc", or "none"
+// (--normalize). Tag and attribute names are left as written.
+var unicodeForm = "none"
+
+// unicodeForms lists the accepted --normalize values
+var unicodeForms = []string{"none", "nfc", "nfkc"}
+
+// caseFolding changes the case of text values after normalization: "fold"
+// applies Unicode case folding, which is the same in every locale, "lower"
+// lowercases by the rules of textLocale, and "none" keeps the case
+// (--case-fold)
+var caseFolding = "none"
+
+// caseFoldings lists the accepted --case-fold values
+var caseFoldings = []string{"none", "fold", "lower"}
+
+// textLocale is the BCP 47 language whose rules --case-fold=lower follows;
+// "und" uses the rules shared by all languages (--text-locale)
+var textLocale = "und"
+
+// casers holds Casers for the configured folding; a Caser keeps state and
+// cannot be shared by the goroutines decoding container entries
+var casers sync.Pool
+
+// validTextNormalization checks --normalize, --case-fold and --text-locale
+// and prepares the case folding
+func validTextNormalization() error {
+	if !validTextOption(unicodeForm, unicodeForms) {
+		return fmt.Errorf("unknown --normalize %q (expected %s)", unicodeForm, strings.Join(unicodeForms, ", "))
+	}
+	if !validTextOption(caseFolding, caseFoldings) {
+		return fmt.Errorf("unknown --case-fold %q (expected %s)", caseFolding, strings.Join(caseFoldings, ", "))
+	}
+	tag, err := language.Parse(textLocale)
+	if err != nil {
+		return fmt.Errorf("invalid --text-locale %q: %v", textLocale, err)
+	}
+	switch caseFolding {
+	case "fold":
+		casers.New = func() interface{} { return cases.Fold() }
+	case "lower":
+		casers.New = func() interface{} { return cases.Lower(tag) }
+	}
+	return nil
+}
+
+// validTextOption reports whether value is one of options
+func validTextOption(value string, options []string) bool {
+	for _, o := range options {
+		if value == o {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeText applies --normalize and --case-fold to a text value
+func normalizeText(s string) string {
+	switch unicodeForm {
+	case "nfc":
+		s = norm.NFC.String(s)
+	case "nfkc":
+		s = norm.NFKC.String(s)
+	}
+	if caseFolding != "none" {
+		caser := casers.Get().(cases.Caser)
+		s = caser.String(s)
+		casers.Put(caser)
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// TombstoneRow marks the rows an input wrote in an earlier run of an
+// incremental (--schedule or backfill) conversion as superseded. Each run
+// directory only holds the inputs that changed, so the latest state of the
+// dataset is every run's rows except those whose container_path and run
+// match a tombstone's container_path and superseded_run. A deleted input
+// gets a tombstone with reason "deleted" and file_version 0.
+type TombstoneRow struct {
+	ContainerPath     string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	SupersededRun     string `parquet:"name=superseded_run, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	SupersededVersion int32  `parquet:"name=superseded_version, type=INT32, convertedtype=INT_32"`
+	Run               string `parquet:"name=run, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	FileVersion       int32  `parquet:"name=file_version, type=INT32, convertedtype=INT_32"`
+	Reason            string `parquet:"name=reason, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// tombstonesFileName is the table of superseded rows in a run directory
+const tombstonesFileName = "tombstones.parquet"
+
+// Tombstone reasons
+const (
+	tombstoneChanged = "changed"
+	tombstoneDeleted = "deleted"
+)
+
+// version returns the input's version number; manifests written before
+// versions were recorded count every converted input as version 1
+func (e incrementalEntry) version() int {
+	if e.Version == 0 && e.Run != "" {
+		return 1
+	}
+	return e.Version
+}
+
+// supersede records in manifest that the input key was converted again by
+// run, returning its tombstone when an earlier run had converted it
+func (m *incrementalManifest) supersede(key, containerPath, run string, entry incrementalEntry) []TombstoneRow {
+	last, ok := m.Inputs[key]
+	entry.Run, entry.Version = run, last.version()+1
+	m.Inputs[key] = entry
+	if !ok || last.Run == "" {
+		return nil
+	}
+	return []TombstoneRow{{
+		ContainerPath:     containerPath,
+		SupersededRun:     last.Run,
+		SupersededVersion: int32(last.version()),
+		Run:               run,
+		FileVersion:       int32(entry.Version),
+		Reason:            tombstoneChanged,
+	}}
+}
+
+// remove drops the input key from manifest, returning its tombstone
+func (m *incrementalManifest) remove(key, containerPath, run string) TombstoneRow {
+	last := m.Inputs[key]
+	delete(m.Inputs, key)
+	return TombstoneRow{
+		ContainerPath:     containerPath,
+		SupersededRun:     last.Run,
+		SupersededVersion: int32(last.version()),
+		Run:               run,
+		Reason:            tombstoneDeleted,
+	}
+}
+
+// writeTombstones writes the tombstones of a run into its directory,
+// sorted by container path; a run that superseded nothing gets no table
+func writeTombstones(runDir string, tombstones []TombstoneRow) error {
+	if len(tombstones) == 0 {
+		return nil
+	}
+	sort.Slice(tombstones, func(a, b int) bool { return tombstones[a].ContainerPath < tombstones[b].ContainerPath })
+	table, err := NewParquetTable(filepath.Join(runDir, tombstonesFileName), new(TombstoneRow))
+	if err != nil {
+		return err
+	}
+	for _, row := range tombstones {
+		if err := table.Write(row); err != nil {
+			table.Close()
+			return err
+		}
+	}
+	return table.Close()
+}
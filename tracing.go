@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// otlpEndpoint is the OTLP/HTTP endpoint spans are exported to. Tracing is
+// also enabled by the standard OTEL_EXPORTER_OTLP_ENDPOINT variables.
+var otlpEndpoint string
+
+// tracer creates the run, file, entry and write spans. It is a no-op until
+// initTracing installs an exporting provider.
+var tracer = otel.Tracer("xmlgo")
+
+// runCtx carries the span of the whole run; fileCtx the span of the input
+// file being processed. Inputs are processed one at a time, so entry and
+// write spans can always parent on fileCtx.
+var (
+	runCtx  = context.Background()
+	fileCtx = context.Background()
+)
+
+// tracingEnabled reports whether spans should be exported
+func tracingEnabled() bool {
+	return otlpEndpoint != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// initTracing installs an OTLP exporting tracer provider and starts the run
+// span. A W3C TRACEPARENT in the environment makes the run a child of the
+// calling pipeline's trace. The returned function ends the run span and
+// flushes the exporter.
+func initTracing() (func(error) error, error) {
+	var opts []otlptracehttp.Option
+	if otlpEndpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(otlpEndpoint))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %v", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("xmlgo")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tracer = provider.Tracer("xmlgo")
+
+	parent := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier{
+		"traceparent": os.Getenv("TRACEPARENT"),
+		"tracestate":  os.Getenv("TRACESTATE"),
+	})
+	var span trace.Span
+	runCtx, span = tracer.Start(parent, "xmlgo.run")
+	fileCtx = runCtx
+
+	return func(runErr error) error {
+		endSpan(span, runErr)
+		return provider.Shutdown(context.Background())
+	}, nil
+}
+
+// endSpan records err, if any, and ends span
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != errLimitReached {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// pathAttr labels a span with the document or file it covers
+func pathAttr(path string) attribute.KeyValue {
+	return attribute.String("xmlgo.path", path)
+}
+
+// rowsAttr records how many rows a span wrote
+func rowsAttr(rows int64) attribute.KeyValue {
+	return attribute.Int64("xmlgo.rows", rows)
+}
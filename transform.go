@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// TransformWriter passes every row through a user-provided WebAssembly
+// module before it reaches the sink, so site-specific enrichment can be
+// plugged in without forking the tool.
+//
+// The module must export its memory and two functions:
+//
+//	alloc(size i32) i32
+//	transform(ptr i32, len i32) i64
+//
+// For each row xmlgo calls alloc, copies the row into guest memory as a JSON
+// object keyed by column name, and calls transform with its location. The
+// result packs the location of a JSON array of output rows as ptr<<32 | len:
+// an empty array drops the row, several entries emit additional rows. The
+// guest owns its memory; xmlgo never frees what it allocates. WASI is
+// available so TinyGo and Rust modules work unmodified.
+type TransformWriter struct {
+	next      RowWriter
+	ctx       context.Context
+	runtime   wazero.Runtime
+	memory    api.Memory
+	alloc     api.Function
+	transform api.Function
+}
+
+// NewTransformWriter loads the module at fileName and wraps next with it
+func NewTransformWriter(fileName string, next RowWriter) (*TransformWriter, error) {
+	wasm, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transform module %s: %v", fileName, err)
+	}
+
+	ctx := context.Background()
+	runtime := wazero.NewRuntime(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, runtime)
+
+	config := wazero.NewModuleConfig().WithStartFunctions("_initialize").WithStderr(os.Stderr)
+	module, err := runtime.InstantiateWithConfig(ctx, wasm, config)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to instantiate transform module %s: %v", fileName, err)
+	}
+
+	w := &TransformWriter{
+		next:      next,
+		ctx:       ctx,
+		runtime:   runtime,
+		memory:    module.Memory(),
+		alloc:     module.ExportedFunction("alloc"),
+		transform: module.ExportedFunction("transform"),
+	}
+	if w.memory == nil || w.alloc == nil || w.transform == nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("transform module %s must export memory, alloc and transform", fileName)
+	}
+	return w, nil
+}
+
+// Write transforms a row and writes whatever rows the module returns
+func (w *TransformWriter) Write(row ParquetRow) error {
+	input, err := json.Marshal(row)
+	if err != nil {
+		return fmt.Errorf("failed to encode row for transform: %v", err)
+	}
+
+	results, err := w.alloc.Call(w.ctx, uint64(len(input)))
+	if err != nil {
+		return fmt.Errorf("transform alloc failed: %v", err)
+	}
+	ptr := uint32(results[0])
+	if !w.memory.Write(ptr, input) {
+		return fmt.Errorf("transform alloc returned out-of-range pointer %d", ptr)
+	}
+
+	results, err = w.transform.Call(w.ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return fmt.Errorf("transform failed for node %d: %v", row.NodeID, err)
+	}
+	outPtr, outLen := uint32(results[0]>>32), uint32(results[0])
+	output, ok := w.memory.Read(outPtr, outLen)
+	if !ok {
+		return fmt.Errorf("transform returned out-of-range result for node %d", row.NodeID)
+	}
+
+	var rows []ParquetRow
+	if err := json.Unmarshal(output, &rows); err != nil {
+		return fmt.Errorf("failed to decode transform result for node %d: %v", row.NodeID, err)
+	}
+	return w.next.WriteBatch(rows)
+}
+
+// WriteBatch transforms each row in turn
+func (w *TransformWriter) WriteBatch(rows []ParquetRow) error {
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit forwards the commit point to the sink
+func (w *TransformWriter) Commit(point CommitPoint) error {
+	return commitNext(w.next, point)
+}
+
+// WriteStop unloads the module and finalizes the sink
+func (w *TransformWriter) WriteStop() error {
+	w.runtime.Close(w.ctx)
+	return w.next.WriteStop()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Resumable uploads follow the core of the tus protocol: POST /uploads
+// announces the total size in Upload-Length, each PATCH /uploads/{id}
+// appends the bytes starting at its Upload-Offset, and HEAD /uploads/{id}
+// reports how much has arrived so an interrupted client can continue from
+// there. The job is queued once the last byte is written; a PATCH with an
+// empty body at the final offset queues it again after a 429.
+
+// createUpload registers a job whose input arrives in pieces
+func (s *jobServer) createUpload(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "missing or invalid Upload-Length header", http.StatusBadRequest)
+		return
+	}
+
+	j, limit, ok := s.newJob(w, r, jobUploading)
+	if !ok {
+		return
+	}
+	if limit > 0 && length > limit {
+		s.forget(j)
+		http.Error(w, fmt.Sprintf("upload exceeds the quota of %d bytes", limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if status, err := saveUpload(j.Input, http.NoBody); err != nil {
+		s.forget(j)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	s.mu.Lock()
+	j.UploadLength = length
+	s.save(j)
+	s.mu.Unlock()
+
+	w.Header().Set("Location", "/uploads/"+j.ID)
+	w.Header().Set("Upload-Offset", "0")
+	if length == 0 {
+		if s.enqueue(w, j) {
+			writeJSON(w, http.StatusAccepted, j)
+		}
+		return
+	}
+	writeJSON(w, http.StatusCreated, j)
+}
+
+// uploadOffset reports how many bytes of an upload have arrived
+func (s *jobServer) uploadOffset(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	info, err := os.Stat(j.Input)
+	if err != nil {
+		http.Error(w, wrapFSError("stat", filepath.Base(j.Input), err).Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Size(), 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(j.UploadLength, 10))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// appendUpload writes the request body at Upload-Offset and queues the job
+// once the upload is complete
+func (s *jobServer) appendUpload(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "missing or invalid Upload-Offset header", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	if j.Status != jobUploading || j.writing {
+		status := j.Status
+		s.mu.Unlock()
+		http.Error(w, "job is "+status, http.StatusConflict)
+		return
+	}
+	j.writing = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		j.writing = false
+		s.mu.Unlock()
+	}()
+
+	size, status, err := appendAt(j.Input, offset, http.MaxBytesReader(w, r.Body, j.UploadLength-offset))
+	w.Header().Set("Upload-Offset", strconv.FormatInt(size, 10))
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if size < j.UploadLength {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if s.enqueue(w, j) {
+		writeJSON(w, http.StatusAccepted, j)
+	}
+}
+
+// appendAt appends body to fileName if offset is its current size. It
+// returns the new size and, on failure, the HTTP status to answer with.
+func appendAt(fileName string, offset int64, body io.Reader) (int64, int, error) {
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return 0, http.StatusInternalServerError, wrapFSError("open", filepath.Base(fileName), err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, http.StatusInternalServerError, wrapFSError("stat", filepath.Base(fileName), err)
+	}
+	if info.Size() != offset {
+		return info.Size(), http.StatusConflict, fmt.Errorf("upload is at offset %d, not %d", info.Size(), offset)
+	}
+
+	n, err := io.Copy(f, body)
+	size := offset + n
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return size, http.StatusRequestEntityTooLarge, fmt.Errorf("body runs past the announced Upload-Length")
+		}
+		return size, http.StatusBadRequest, fmt.Errorf("failed to read upload: %v", err)
+	}
+	return size, 0, nil
+}
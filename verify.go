@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// verifyReaders re-reads every Parquet file produced by the run once it is
+// finished, failing the run if any registered reader disagrees
+var verifyReaders bool
+
+// verifyChunkRows is the number of values decoded per read while verifying
+const verifyChunkRows = 1 << 16
+
+// parquetVerifiers maps reader names to functions that decode every column
+// of a Parquet file and return the number of rows read. parquet-go is always
+// available; independent implementations register themselves from
+// build-tagged files (see verify_arrow.go). parquet-go wrote the files, so
+// it alone checks nothing about compatibility, and --verify-readers is
+// rejected in builds without a second reader.
+var parquetVerifiers = map[string]func(fileName string) (int64, error){
+	"parquet-go": verifyWithParquetGo,
+}
+
+// verifyWithParquetGo decodes every column of fileName with parquet-go
+func verifyWithParquetGo(fileName string) (int64, error) {
+	fr, err := local.NewLocalFileReader(fileName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %v", fileName, err)
+	}
+	defer fr.Close()
+
+	pr, err := reader.NewParquetColumnReader(fr, 1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read footer of %s: %v", fileName, err)
+	}
+	defer pr.ReadStop()
+
+	rows := pr.GetNumRows()
+	for _, path := range pr.SchemaHandler.ValueColumns {
+		var read int64
+		for read < rows {
+			n := min(rows-read, verifyChunkRows)
+			values, _, _, err := pr.ReadColumnByPath(path, n)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read column %s of %s: %v", columnName(path), fileName, err)
+			}
+			if len(values) == 0 {
+				break
+			}
+			read += int64(len(values))
+		}
+		if read != rows {
+			return 0, fmt.Errorf("column %s of %s has %d values, footer says %d rows", columnName(path), fileName, read, rows)
+		}
+	}
+	return rows, nil
+}
+
+// columnName strips the schema root from a parquet-go column path
+func columnName(path string) string {
+	if i := strings.Index(path, "\x01"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+// verifyOutputs reads every Parquet file below outputDir with every
+// registered reader and checks that they agree on the row count
+func verifyOutputs(outputDir string) error {
+	var files []string
+	err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && strings.EqualFold(filepath.Ext(path), ".parquet") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list outputs in %s: %v", outputDir, err)
+	}
+
+	names := make([]string, 0, len(parquetVerifiers))
+	for name := range parquetVerifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, fileName := range files {
+		rows := int64(-1)
+		for _, name := range names {
+			n, err := parquetVerifiers[name](fileName)
+			if err != nil {
+				return fmt.Errorf("%s reader: %v", name, err)
+			}
+			if rows >= 0 && n != rows {
+				return fmt.Errorf("%s reader found %d rows in %s, expected %d", name, n, fileName, rows)
+			}
+			rows = n
+		}
+		log.Printf("Verified %s: %d rows (%s)", fileName, rows, strings.Join(names, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+//go:build arrow
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow-go/v18/parquet/file"
+)
+
+func init() {
+	parquetVerifiers["arrow"] = verifyWithArrow
+}
+
+// verifyWithArrow decodes every column of fileName with apache/arrow-go
+func verifyWithArrow(fileName string) (int64, error) {
+	r, err := file.OpenParquetFile(fileName, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %v", fileName, err)
+	}
+	defer r.Close()
+
+	var rows int64
+	for i := 0; i < r.NumRowGroups(); i++ {
+		rg := r.RowGroup(i)
+		for c := 0; c < rg.NumColumns(); c++ {
+			col, err := rg.Column(c)
+			if err != nil {
+				return 0, fmt.Errorf("failed to open column %d of %s: %v", c, fileName, err)
+			}
+			skipper, ok := col.(interface {
+				Skip(nvalues int64) (int64, error)
+			})
+			if !ok {
+				return 0, fmt.Errorf("unsupported column type %s in %s", col.Type(), fileName)
+			}
+			n, err := skipper.Skip(rg.NumRows())
+			if err != nil {
+				return 0, fmt.Errorf("failed to read column %s of %s: %v", col.Descriptor().Name(), fileName, err)
+			}
+			if n != rg.NumRows() {
+				return 0, fmt.Errorf("column %s of %s has %d values in row group %d, expected %d", col.Descriptor().Name(), fileName, n, i, rg.NumRows())
+			}
+		}
+		rows += rg.NumRows()
+	}
+	return rows, nil
+}
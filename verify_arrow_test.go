@@ -0,0 +1,178 @@
+//go:build arrow
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+)
+
+// arrowRows decodes fileName with apache/arrow-go, formatting values the
+// way parquetRows does so the two readers' results compare directly
+func arrowRows(fileName string) ([]string, [][]string, error) {
+	r, err := file.OpenParquetFile(fileName, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %v", fileName, err)
+	}
+	defer r.Close()
+	fr, err := pqarrow.NewFileReader(r, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %v", fileName, err)
+	}
+	table, err := fr.ReadTable(context.Background())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %v", fileName, err)
+	}
+	defer table.Release()
+
+	rows := make([][]string, table.NumRows())
+	var columns []string
+	for i := 0; i < int(table.NumCols()); i++ {
+		column := table.Column(i)
+		columns = append(columns, column.Name())
+		row := 0
+		for _, chunk := range column.Data().Chunks() {
+			for j := 0; j < chunk.Len(); j++ {
+				value, err := arrowValue(chunk, j)
+				if err != nil {
+					return nil, nil, fmt.Errorf("column %s of %s: %v", column.Name(), fileName, err)
+				}
+				rows[row] = append(rows[row], value)
+				row++
+			}
+		}
+	}
+	return columns, rows, nil
+}
+
+// arrowValue formats value j of arr like parquetRows
+func arrowValue(arr arrow.Array, j int) (string, error) {
+	if arr.IsNull(j) {
+		return "null", nil
+	}
+	var v interface{}
+	switch a := arr.(type) {
+	case *array.String:
+		v = a.Value(j)
+	case *array.Int64:
+		v = a.Value(j)
+	case *array.Int32:
+		v = a.Value(j)
+	case *array.Boolean:
+		v = a.Value(j)
+	case *array.Float64:
+		v = a.Value(j)
+	default:
+		return "", fmt.Errorf("unexpected type %s", arr.DataType())
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(v)), nil
+}
+
+// checkLogicalTypes checks that every string column is annotated as UTF8
+// and every integer column as a signed integer of its width, so PyArrow,
+// Spark and Trino read the columns as strings and integers
+func checkLogicalTypes(t *testing.T, fileName string) {
+	t.Helper()
+	r, err := file.OpenParquetFile(fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	s := r.MetaData().Schema
+	for i := 0; i < s.NumColumns(); i++ {
+		column := s.Column(i)
+		logical := column.LogicalType()
+		switch column.PhysicalType() {
+		case parquet.Types.ByteArray:
+			if _, ok := logical.(schema.StringLogicalType); !ok {
+				t.Errorf("%s: string column %s is annotated %s, want String", filepath.Base(fileName), column.Name(), logical)
+			}
+		case parquet.Types.Int32, parquet.Types.Int64:
+			integer, ok := logical.(schema.IntLogicalType)
+			if !ok || !integer.IsSigned() {
+				t.Errorf("%s: integer column %s is annotated %s, want a signed Int", filepath.Base(fileName), column.Name(), logical)
+			}
+		}
+	}
+}
+
+// TestCrossEngineReads converts the golden corpus with each Parquet
+// backend and option set that changes the written columns, then reads
+// every Parquet output with both parquet-go and apache/arrow-go: the two
+// must decode the same columns and values, and the columns must carry the
+// logical types other engines rely on
+func TestCrossEngineReads(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(defaultGoldenDir, "*", "input"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no golden inputs in %s: %v", defaultGoldenDir, err)
+	}
+	options := map[string]func(){
+		"default":   func() {},
+		"nulls":     func() { nullPolicy = "null" },
+		"ids":       func() { tagIDsOnly, attributeIDsOnly = true, true },
+		"selfclose": func() { markSelfClosing = true },
+	}
+	for _, backend := range parquetBackendNames() {
+		for name, apply := range options {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				defer func(b, n string, tags, attrs, self, keep bool) {
+					parquetBackend, nullPolicy, tagIDsOnly, attributeIDsOnly, markSelfClosing, keepGoing = b, n, tags, attrs, self, keep
+				}(parquetBackend, nullPolicy, tagIDsOnly, attributeIDsOnly, markSelfClosing, keepGoing)
+				parquetBackend, keepGoing = backend, true
+				apply()
+
+				for _, input := range inputs {
+					outputDir := t.TempDir()
+					cfg := &runConfig{formats: []string{"parquet"}, extensions: []string{".xml", ".rels"}}
+					if _, err := convert(cfg, input, outputDir); err != nil {
+						t.Fatalf("%s: %v", input, err)
+					}
+					files, err := filepath.Glob(filepath.Join(outputDir, "*.parquet"))
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, fileName := range files {
+						compareEngines(t, fileName)
+						checkLogicalTypes(t, fileName)
+					}
+				}
+			})
+		}
+	}
+}
+
+// compareEngines reads fileName with both readers and compares the results
+func compareEngines(t *testing.T, fileName string) {
+	t.Helper()
+	name := filepath.Join(filepath.Base(filepath.Dir(fileName)), filepath.Base(fileName))
+	goColumns, goRows, err := parquetRows(fileName)
+	if err != nil {
+		t.Fatalf("parquet-go: %v", err)
+	}
+	arrowColumns, arrowRows, err := arrowRows(fileName)
+	if err != nil {
+		t.Fatalf("arrow: %v", err)
+	}
+	if !slices.Equal(goColumns, arrowColumns) {
+		t.Fatalf("%s: parquet-go reads columns %v, arrow %v", name, goColumns, arrowColumns)
+	}
+	if len(goRows) != len(arrowRows) {
+		t.Fatalf("%s: parquet-go reads %d rows, arrow %d", name, len(goRows), len(arrowRows))
+	}
+	for i := range goRows {
+		if !slices.Equal(goRows[i], arrowRows[i]) {
+			t.Fatalf("%s row %d: parquet-go reads %v, arrow %v", name, i, goRows[i], arrowRows[i])
+		}
+	}
+}
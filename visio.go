@@ -0,0 +1,175 @@
+package main
+
+import (
+	"strings"
+)
+
+func init() {
+	extractors["visio"] = visioExtractor{}
+}
+
+// VisioShapeRow is one shape of a Visio page in visio_shapes.parquet.
+// parent_shape_id is the group holding the shape and master is the name of
+// the master it is an instance of, inherited by the shapes of the instance.
+type VisioShapeRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Page          string `parquet:"name=page, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ShapeID       string `parquet:"name=shape_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ParentShapeID string `parquet:"name=parent_shape_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Name          string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Type          string `parquet:"name=type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Master        string `parquet:"name=master, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Text          string `parquet:"name=text, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// VisioConnectorRow is one connector of a Visio page in
+// visio_connectors.parquet with the shapes its ends are glued to. node_id
+// is the first Connect element of the connector.
+type VisioConnectorRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Page          string `parquet:"name=page, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ConnectorID   string `parquet:"name=connector_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	BeginShapeID  string `parquet:"name=begin_shape_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	EndShapeID    string `parquet:"name=end_shape_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// visioExtractor turns the pages of a Visio drawing into a graph of shapes
+// and the connectors between them
+type visioExtractor struct{}
+
+// Visio parts the page names and master names are resolved from
+const (
+	visioPagesPart   = "visio/pages/pages.xml"
+	visioMastersPart = "visio/masters/masters.xml"
+)
+
+// Tables returns the visio_shapes and visio_connectors tables
+func (visioExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{
+		"visio_shapes":     new(VisioShapeRow),
+		"visio_connectors": new(VisioConnectorRow),
+	}
+}
+
+// Detect accepts the page parts of a drawing
+func (visioExtractor) Detect(doc *Document) bool {
+	return doc.Root.XMLName.Local == "PageContents" && strings.HasPrefix(doc.EntryPath, "visio/pages/")
+}
+
+// Extract writes one row per shape and per connector of the page
+func (visioExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	page, err := visioPageName(doc)
+	if err != nil {
+		return err
+	}
+	masters, err := visioMasterNames(doc)
+	if err != nil {
+		return err
+	}
+
+	parents := make(map[*XMLNode]*XMLNode)
+	shapeMasters := make(map[*XMLNode]string)
+	var connectors []*VisioConnectorRow
+	byID := make(map[string]*VisioConnectorRow)
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		parents[node] = parent
+		if err != nil {
+			return
+		}
+		switch node.XMLName.Local {
+		case "Shape":
+			row := VisioShapeRow{
+				FilePath:      doc.FilePath,
+				NodeID:        nodeID,
+				ContainerPath: doc.ContainerPath,
+				Page:          page,
+				ShapeID:       attrValue(node, "ID"),
+				Name:          attrValue(node, "NameU"),
+				Type:          attrValue(node, "Type"),
+			}
+			if row.Name == "" {
+				row.Name = attrValue(node, "Name")
+			}
+			// Shapes nest in the Shapes element of their group
+			group := parents[parent]
+			if group != nil && group.XMLName.Local == "Shape" {
+				row.ParentShapeID = attrValue(group, "ID")
+			}
+			if id := attrValue(node, "Master"); id != "" {
+				shapeMasters[node] = masters[id]
+			} else if group != nil {
+				shapeMasters[node] = shapeMasters[group]
+			}
+			row.Master = shapeMasters[node]
+			if text := childElement(node, "Text"); text != nil {
+				row.Text = strings.TrimSpace(text.Content)
+			}
+			err = rows("visio_shapes", row)
+		case "Connect":
+			id := attrValue(node, "FromSheet")
+			row, ok := byID[id]
+			if !ok {
+				row = &VisioConnectorRow{FilePath: doc.FilePath, NodeID: nodeID, ContainerPath: doc.ContainerPath, Page: page, ConnectorID: id}
+				byID[id] = row
+				connectors = append(connectors, row)
+			}
+			switch attrValue(node, "FromCell") {
+			case "BeginX":
+				row.BeginShapeID = attrValue(node, "ToSheet")
+			case "EndX":
+				row.EndShapeID = attrValue(node, "ToSheet")
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, row := range connectors {
+		if err := rows("visio_connectors", *row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visioPageName returns the name of the page doc is, from pages.xml
+func visioPageName(doc *Document) (string, error) {
+	pages, err := doc.part(visioPagesPart)
+	if err != nil || pages == nil {
+		return "", err
+	}
+	rels, err := relationships(doc, visioPagesPart)
+	if err != nil {
+		return "", err
+	}
+	for _, page := range childElements(pages, "Page") {
+		if rel, ok := rels[attrValue(childElement(page, "Rel"), "id")]; ok && rel.Target == doc.EntryPath {
+			if name := attrValue(page, "NameU"); name != "" {
+				return name, nil
+			}
+			return attrValue(page, "Name"), nil
+		}
+	}
+	return "", nil
+}
+
+// visioMasterNames maps the master IDs of a drawing to their names
+func visioMasterNames(doc *Document) (map[string]string, error) {
+	masters, err := doc.part(visioMastersPart)
+	if err != nil || masters == nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, master := range childElements(masters, "Master") {
+		name := attrValue(master, "NameU")
+		if name == "" {
+			name = attrValue(master, "Name")
+		}
+		names[attrValue(master, "ID")] = name
+	}
+	return names, nil
+}
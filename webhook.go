@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// webhookURL receives a completion payload after every conversion, so
+// downstream loaders can be triggered instead of polling the output
+var webhookURL string
+
+// webhookSecret, when set, signs each payload with HMAC-SHA256 in the
+// X-Xmlgo-Signature header as sha256=<hex>
+var webhookSecret string
+
+// webhookAttempts is how often a delivery is tried before it is given up
+const webhookAttempts = 3
+
+// webhookPayload describes a finished conversion
+type webhookPayload struct {
+	Event     string     `json:"event"`
+	Status    string     `json:"status"`
+	JobID     string     `json:"job_id,omitempty"`
+	Tenant    string     `json:"tenant,omitempty"`
+	Input     string     `json:"input"`
+	OutputDir string     `json:"output_dir"`
+	Outputs   []string   `json:"outputs,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Report    *RunReport `json:"report,omitempty"`
+}
+
+// newWebhookPayload describes the conversion of input that just finished
+// with err, taking the totals from the run report
+func newWebhookPayload(input, outputDir string, err error) *webhookPayload {
+	payload := &webhookPayload{
+		Event:     "conversion.finished",
+		Status:    jobDone,
+		Input:     input,
+		OutputDir: outputDir,
+		Report:    report,
+	}
+	if err != nil {
+		payload.Status = jobFailed
+		payload.Error = err.Error()
+	} else {
+		payload.Outputs = listOutputs(outputDir)
+	}
+	return payload
+}
+
+// notifyWebhook encodes payload and delivers it to endpoint
+func notifyWebhook(endpoint string, payload *webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %v", err)
+	}
+	return deliverWebhook(endpoint, body)
+}
+
+// deliverWebhook posts body to endpoint, retrying failed deliveries
+func deliverWebhook(endpoint string, body []byte) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	for attempt := 1; ; attempt++ {
+		err := postWebhook(client, endpoint, body)
+		if err == nil || attempt == webhookAttempts {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+// postWebhook delivers one signed payload
+func postWebhook(client *http.Client, endpoint string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(webhookSecret))
+		mac.Write(body)
+		req.Header.Set("X-Xmlgo-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to notify webhook %s: %v", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s rejected the notification: %s: %s", endpoint, resp.Status, strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
+
+// notifyJob posts the completion of a server job to its tenant's webhook,
+// or to --webhook when the tenant has none
+func notifyJob(j *job, err error) {
+	endpoint := webhookURL
+	if j.tenant.Webhook != "" {
+		endpoint = j.tenant.Webhook
+	}
+	if endpoint == "" {
+		return
+	}
+
+	payload := newWebhookPayload(j.Input, j.OutputDir, err)
+	payload.JobID = j.ID
+	payload.Tenant = j.tenant.Name
+	go func() {
+		if err := notifyWebhook(endpoint, payload); err != nil {
+			log.Printf("Job %s: %v", j.ID, err)
+		}
+	}()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	extractors["windows-events"] = windowsEventsExtractor{}
+}
+
+// WindowsEventRow is the System section of one event in
+// windows_events.parquet. time_created is in microseconds since the epoch,
+// UTC; message is the rendered text when the export includes it.
+type WindowsEventRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Provider      string `parquet:"name=provider, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventID       int32  `parquet:"name=event_id, type=INT32, convertedtype=INT_32"`
+	Version       int32  `parquet:"name=version, type=INT32, convertedtype=INT_32"`
+	Level         int32  `parquet:"name=level, type=INT32, convertedtype=INT_32"`
+	Task          int32  `parquet:"name=task, type=INT32, convertedtype=INT_32"`
+	Opcode        int32  `parquet:"name=opcode, type=INT32, convertedtype=INT_32"`
+	Keywords      string `parquet:"name=keywords, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	TimeCreated   int64  `parquet:"name=time_created, type=INT64, convertedtype=TIMESTAMP_MICROS"`
+	RecordID      int64  `parquet:"name=record_id, type=INT64, convertedtype=INT_64"`
+	ActivityID    string `parquet:"name=activity_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ProcessID     int64  `parquet:"name=process_id, type=INT64, convertedtype=INT_64"`
+	ThreadID      int64  `parquet:"name=thread_id, type=INT64, convertedtype=INT_64"`
+	Channel       string `parquet:"name=channel, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Computer      string `parquet:"name=computer, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	UserID        string `parquet:"name=user_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Message       string `parquet:"name=message, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// WindowsEventDataRow is one named value of an event's EventData or
+// UserData in windows_event_data.parquet, joined to its event on file_path
+// and event_node_id. Unnamed Data elements of classic events have an empty
+// name and are told apart by position.
+type WindowsEventDataRow struct {
+	FilePath      string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID        int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	EventNodeID   int64  `parquet:"name=event_node_id, type=INT64, convertedtype=INT_64"`
+	EventID       int32  `parquet:"name=event_id, type=INT32, convertedtype=INT_32"`
+	Section       string `parquet:"name=section, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Position      int32  `parquet:"name=position, type=INT32, convertedtype=INT_32"`
+	Name          string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Value         string `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// windowsEventsExtractor reads the XML that Event Viewer's "Save All
+// Events As" and Get-WinEvent ToXml() write: an Events root, or a single
+// Event, in the Windows event schema
+type windowsEventsExtractor struct{}
+
+// windowsEventNamespace is the namespace of the Windows event schema
+const windowsEventNamespace = "http://schemas.microsoft.com/win/2004/08/events/event"
+
+// Tables returns the windows_events and windows_event_data tables
+func (windowsEventsExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{
+		"windows_events":     new(WindowsEventRow),
+		"windows_event_data": new(WindowsEventDataRow),
+	}
+}
+
+// Detect accepts Events and Event roots holding events of the Windows
+// event schema
+func (windowsEventsExtractor) Detect(doc *Document) bool {
+	switch doc.Root.XMLName.Local {
+	case "Event":
+		return doc.Root.XMLName.Space == windowsEventNamespace
+	case "Events":
+		event := childElement(doc.Root, "Event")
+		return event != nil && event.XMLName.Space == windowsEventNamespace
+	}
+	return false
+}
+
+// Extract writes a row per event and per EventData or UserData value.
+// UserData holds one provider-defined element whose children are the
+// values.
+func (windowsEventsExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	var err error
+	var event WindowsEventRow
+	var userData *XMLNode // the provider element inside UserData
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil {
+			return
+		}
+		switch {
+		case node.XMLName.Local == "Event" && node.XMLName.Space == windowsEventNamespace:
+			event = windowsEvent(node)
+			event.FilePath, event.NodeID, event.ContainerPath = doc.FilePath, nodeID, doc.ContainerPath
+			err = rows("windows_events", event)
+		case parent == nil || event.NodeID == 0:
+		case parent.XMLName.Local == "UserData" && parent.XMLName.Space == windowsEventNamespace:
+			userData = node
+		case parent.XMLName.Local == "EventData" && (node.XMLName.Local == "Data" || node.XMLName.Local == "Binary"):
+			name := attrValue(node, "Name")
+			if node.XMLName.Local == "Binary" {
+				name = "Binary"
+			}
+			err = rows("windows_event_data", windowsEventData(doc, nodeID, event, "EventData", parent, node, name))
+		case parent == userData:
+			err = rows("windows_event_data", windowsEventData(doc, nodeID, event, "UserData", parent, node, node.XMLName.Local))
+		}
+	})
+	return err
+}
+
+// windowsEvent reads the System section and rendered message of an event
+func windowsEvent(node *XMLNode) WindowsEventRow {
+	system := childElement(node, "System")
+	text := func(local string) string {
+		if child := childElement(system, local); child != nil {
+			return strings.TrimSpace(child.Content)
+		}
+		return ""
+	}
+	number := func(s string) int64 {
+		n, _ := strconv.ParseInt(s, 10, 64)
+		return n
+	}
+	row := WindowsEventRow{
+		Provider:   attrValue(childElement(system, "Provider"), "Name"),
+		EventID:    int32(number(text("EventID"))),
+		Version:    int32(number(text("Version"))),
+		Level:      int32(number(text("Level"))),
+		Task:       int32(number(text("Task"))),
+		Opcode:     int32(number(text("Opcode"))),
+		Keywords:   text("Keywords"),
+		RecordID:   number(text("EventRecordID")),
+		ActivityID: attrValue(childElement(system, "Correlation"), "ActivityID"),
+		ProcessID:  number(attrValue(childElement(system, "Execution"), "ProcessID")),
+		ThreadID:   number(attrValue(childElement(system, "Execution"), "ThreadID")),
+		Channel:    text("Channel"),
+		Computer:   text("Computer"),
+		UserID:     attrValue(childElement(system, "Security"), "UserID"),
+	}
+	if row.Provider == "" {
+		row.Provider = attrValue(childElement(system, "Provider"), "EventSourceName")
+	}
+	if created, err := time.Parse(time.RFC3339Nano, attrValue(childElement(system, "TimeCreated"), "SystemTime")); err == nil {
+		row.TimeCreated = created.UnixMicro()
+	}
+	if message := childElement(childElement(node, "RenderingInfo"), "Message"); message != nil {
+		row.Message = strings.TrimSpace(message.Content)
+	}
+	return row
+}
+
+// windowsEventData builds the data row of a value element, numbering it
+// among its siblings
+func windowsEventData(doc *Document, nodeID int64, event WindowsEventRow, section string, parent, node *XMLNode, name string) WindowsEventDataRow {
+	position := int32(0)
+	for i := range parent.Nodes {
+		position++
+		if &parent.Nodes[i] == node {
+			break
+		}
+	}
+	return WindowsEventDataRow{
+		FilePath:      doc.FilePath,
+		NodeID:        nodeID,
+		ContainerPath: doc.ContainerPath,
+		EventNodeID:   event.NodeID,
+		EventID:       event.EventID,
+		Section:       section,
+		Position:      position,
+		Name:          name,
+		Value:         strings.TrimSpace(node.Content),
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// workerSetting is the value of --workers: a fixed count, or 0 for auto
+type workerSetting int
+
+// workers is the configured decode concurrency for container entries
+var workers workerSetting = 1
+
+// String implements flag.Value
+func (w *workerSetting) String() string {
+	if *w == 0 {
+		return "auto"
+	}
+	return strconv.Itoa(int(*w))
+}
+
+// Set implements flag.Value
+func (w *workerSetting) Set(value string) error {
+	if value == "auto" {
+		*w = 0
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return fmt.Errorf("workers must be a positive number or auto")
+	}
+	*w = workerSetting(n)
+	return nil
+}
+
+// ewmaWeight is how strongly each new observation moves the running averages
+const ewmaWeight = 0.2
+
+// workerGate limits how many workers run at once. In auto mode the limit
+// starts at the CPU count and follows the ratio of decode time to write
+// time: once the sink is the bottleneck, extra decoders only hold parsed
+// documents in memory, so the limit shrinks; when decoding dominates it
+// grows back toward the CPU count.
+type workerGate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	auto   bool
+	max    int
+	limit  int
+	active int
+
+	decodeAvg float64
+	writeAvg  float64
+}
+
+// newWorkerGate creates a gate for the given setting
+func newWorkerGate(setting workerSetting) *workerGate {
+	g := &workerGate{}
+	g.cond = sync.NewCond(&g.mu)
+	if setting == 0 {
+		g.auto = true
+		g.max = runtime.GOMAXPROCS(0)
+	} else {
+		g.max = int(setting)
+	}
+	g.limit = g.max
+	return g
+}
+
+// acquire blocks until a worker slot is free
+func (g *workerGate) acquire() {
+	g.mu.Lock()
+	for g.active >= g.limit {
+		g.cond.Wait()
+	}
+	g.active++
+	g.mu.Unlock()
+}
+
+// release frees a worker slot
+func (g *workerGate) release() {
+	g.mu.Lock()
+	g.active--
+	g.mu.Unlock()
+	g.cond.Signal()
+}
+
+// observe feeds the decode and write time of a document into the auto tuner
+func (g *workerGate) observe(decode, write time.Duration) {
+	if !g.auto {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.decodeAvg == 0 && g.writeAvg == 0 {
+		g.decodeAvg, g.writeAvg = float64(decode), float64(write)
+	} else {
+		g.decodeAvg += ewmaWeight * (float64(decode) - g.decodeAvg)
+		g.writeAvg += ewmaWeight * (float64(write) - g.writeAvg)
+	}
+
+	target := g.max
+	if g.writeAvg > 0 {
+		// One decoder per write slot keeps the sink busy; add one for jitter
+		target = int(math.Ceil(g.decodeAvg/g.writeAvg)) + 1
+	}
+	target = max(1, min(target, g.max))
+
+	if target != g.limit {
+		g.limit = target
+		g.cond.Broadcast()
+	}
+}
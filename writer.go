@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// RowWriter is the sink every parsed row is written to
+type RowWriter interface {
+	// Write appends a single row to the output
+	Write(row ParquetRow) error
+	// WriteBatch appends several rows at once
+	WriteBatch(rows []ParquetRow) error
+	// WriteStop flushes buffered rows and finalizes the output
+	WriteStop() error
+}
+
+// newRowWriter creates the sink for the requested output format inside
+// outputDir. With --flush-interval the output is split into parts that are
+// finalized periodically (see RollingWriter).
+func newRowWriter(format string, outputDir string) (RowWriter, string, error) {
+	fileName, err := outputFileName(format, outputDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if isStreamTarget(fileName) {
+		if format == "parquet" {
+			return nil, "", fmt.Errorf("%s is a pipe or socket; only streaming formats (esbulk, template) can be written to it", fileName)
+		}
+		if flushInterval > 0 {
+			return nil, "", fmt.Errorf("--flush-interval cannot split output written to a pipe or socket")
+		}
+	}
+
+	if flushInterval > 0 {
+		w, err := NewRollingWriter(format, fileName, filepath.Join(outputDir, checkpointFileName))
+		return w, fileName, err
+	}
+
+	w, err := openFormatWriter(format, fileName)
+	return w, fileName, err
+}
+
+// outputFileName returns where the given format is written: --output if
+// set, otherwise a default name inside outputDir
+func outputFileName(format string, outputDir string) (string, error) {
+	if outputPath != "" {
+		return outputPath, nil
+	}
+
+	switch format {
+	case "parquet":
+		return filepath.Join(outputDir, withRunSuffix("combined.parquet")), nil
+	case "esbulk":
+		return filepath.Join(outputDir, withRunSuffix("combined.ndjson")), nil
+	case "jsonl":
+		return filepath.Join(outputDir, withRunSuffix("combined.jsonl")), nil
+	case "template":
+		if templateFile == "" {
+			return "", fmt.Errorf("the template format requires --template")
+		}
+		return filepath.Join(outputDir, withRunSuffix(templateOutputName(templateFile))), nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (expected parquet, esbulk, jsonl or template)", format)
+	}
+}
+
+// openFormatWriter creates a writer for format at fileName
+func openFormatWriter(format string, fileName string) (RowWriter, error) {
+	switch format {
+	case "parquet":
+		return newParquetBackendWriter(fileName)
+	case "esbulk":
+		return NewESBulkWriter(fileName, esIndexName)
+	case "jsonl":
+		return NewJSONLWriter(fileName)
+	case "template":
+		return NewTemplateWriter(fileName, templateFile)
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// parseFormats splits a comma-separated --format value, rejecting duplicates
+func parseFormats(value string) ([]string, error) {
+	var formats []string
+	seen := make(map[string]bool)
+	for _, format := range strings.Split(value, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" {
+			continue
+		}
+		if seen[format] {
+			return nil, fmt.Errorf("format %q is listed twice", format)
+		}
+		seen[format] = true
+		formats = append(formats, format)
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no output format given")
+	}
+	return formats, nil
+}
+
+// MultiWriter writes every row to several sinks, so one parse can produce
+// all requested output formats
+type MultiWriter struct {
+	sinks []RowWriter
+}
+
+// NewMultiWriter fans rows out to sinks in order
+func NewMultiWriter(sinks ...RowWriter) *MultiWriter {
+	return &MultiWriter{sinks: sinks}
+}
+
+// Write appends a row to every sink
+func (w *MultiWriter) Write(row ParquetRow) error {
+	for _, sink := range w.sinks {
+		if err := sink.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteBatch appends rows to every sink
+func (w *MultiWriter) WriteBatch(rows []ParquetRow) error {
+	for _, sink := range w.sinks {
+		if err := sink.WriteBatch(rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit forwards the commit point to every sink
+func (w *MultiWriter) Commit(point CommitPoint) error {
+	for _, sink := range w.sinks {
+		if err := commitNext(sink, point); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteStop finalizes every sink, returning the first error
+func (w *MultiWriter) WriteStop() error {
+	var firstErr error
+	for _, sink := range w.sinks {
+		if err := sink.WriteStop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// parquetBackends maps --parquet-backend names to writer constructors.
+// parquet-go is always available; alternative implementations register
+// themselves from build-tagged files (see writer_arrow.go).
+var parquetBackends = map[string]func(fileName string) (RowWriter, error){
+	"parquet-go": func(fileName string) (RowWriter, error) {
+		return NewParquetRowWriter(fileName)
+	},
+}
+
+// parquetBackend selects the entry of parquetBackends used for Parquet output
+var parquetBackend = "parquet-go"
+
+// newParquetBackendWriter creates a Parquet writer using the selected backend
+func newParquetBackendWriter(fileName string) (RowWriter, error) {
+	newWriter, ok := parquetBackends[parquetBackend]
+	if !ok {
+		return nil, fmt.Errorf("unknown Parquet backend %q (available: %s)", parquetBackend, strings.Join(parquetBackendNames(), ", "))
+	}
+	return newWriter(fileName)
+}
+
+// parquetBackendNames lists the registered backends in sorted order
+func parquetBackendNames() []string {
+	names := make([]string, 0, len(parquetBackends))
+	for name := range parquetBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parquetRowGroupRows caps the rows in one row group. parquet-go only cuts
+// row groups on its size estimate, which lets its page buffers grow large
+// when one document produces millions of small rows.
+var parquetRowGroupRows = 1 << 20
+
+// parquetBufferMiB caps the row data parquet-go holds before marshalling it
+// into pages (--writer-buffer)
+var parquetBufferMiB = 64
+
+// Buffer sizing of ParquetRowWriter. parquet-go marshals its buffered rows
+// once their estimated size reaches NP*PageSize*columns bytes, so fixed
+// settings marshal a corpus of short cell values a few thousand rows at a
+// time but one of long text nodes a few dozen at a time, spread over
+// goroutines that then have next to nothing to do and cut pages far smaller
+// than the text they hold. The writer re-tunes NP and PageSize from the
+// observed row width instead, aiming for a fixed number of rows per flush.
+const (
+	parquetFlushRows        = 16384   // rows marshalled per flush
+	parquetRowsPerGoroutine = 2048    // fewest rows worth a marshalling goroutine
+	parquetMinPageSize      = 8 << 10 // parquet-go's default
+	parquetMaxPageSize      = 1 << 20
+	parquetTuneInterval     = 4096 // rows between re-tunes
+	parquetMinBufferedBytes = 256 << 10
+)
+
+// ParquetRowWriter writes rows to a ZSTD-compressed Parquet file using parquet-go
+type ParquetRowWriter struct {
+	file   source.ParquetFile
+	writer *writer.ParquetWriter
+	rows   int
+	// untuned counts the rows written since the buffer was last sized
+	untuned int
+}
+
+// NewParquetRowWriter creates the Parquet file and writer for fileName
+func NewParquetRowWriter(fileName string) (*ParquetRowWriter, error) {
+	file, err := local.NewLocalFileWriter(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Parquet file %s: %v", fileName, err)
+	}
+
+	pw, err := writer.NewParquetWriter(file, new(parquetRecord), parquetParallelism())
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create Parquet writer: %v", err)
+	}
+
+	// Enable ZSTD compression
+	pw.CompressionType = parquet.CompressionCodec_ZSTD
+
+	w := &ParquetRowWriter{file: file, writer: pw}
+	w.tune()
+	return w, nil
+}
+
+// tune sizes the buffer for parquet-go's running estimate of the row width:
+// enough rows per flush to give each page and goroutine real work, bounded
+// by parquetBufferMiB for wide rows. Marshalling goroutines are added only
+// when a flush has rows enough for them, up to parquetParallelism.
+func (w *ParquetRowWriter) tune() {
+	w.untuned = 0
+	pw := w.writer
+	width := pw.ObjSize
+	if width < 1 {
+		width = 1
+	}
+	buffered := width * parquetFlushRows
+	if limit := int64(parquetBufferMiB) << 20; limit > 0 && buffered > limit {
+		buffered = limit
+	}
+	buffered = max(buffered, parquetMinBufferedBytes)
+	np := min(max(buffered/width/parquetRowsPerGoroutine, 1), parquetParallelism())
+	pw.NP = np
+	pw.PageSize = min(max(buffered/(np*pw.SchemaHandler.GetColumnNum()), parquetMinPageSize), parquetMaxPageSize)
+}
+
+// Write appends a row to the Parquet file
+func (w *ParquetRowWriter) Write(row ParquetRow) error {
+	rec := newParquetRecord(&row)
+	if err := w.writer.Write(&rec); err != nil {
+		return err
+	}
+	return w.countRow()
+}
+
+// countRow cuts a row group once parquetRowGroupRows rows are buffered and
+// re-tunes the buffer every parquetTuneInterval rows
+func (w *ParquetRowWriter) countRow() error {
+	if w.untuned++; w.untuned >= parquetTuneInterval {
+		w.tune()
+	}
+	w.rows++
+	if parquetRowGroupRows <= 0 || w.rows < parquetRowGroupRows {
+		return nil
+	}
+	w.rows = 0
+	return w.writer.Flush(true)
+}
+
+// WriteBatch appends rows to the Parquet file
+func (w *ParquetRowWriter) WriteBatch(rows []ParquetRow) error {
+	for i := range rows {
+		rec := newParquetRecord(&rows[i])
+		if err := w.writer.Write(&rec); err != nil {
+			return err
+		}
+		if err := w.countRow(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteStop writes the Parquet footer and closes the file
+func (w *ParquetRowWriter) WriteStop() error {
+	if err := w.writer.WriteStop(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// defaultBatchSize is the number of rows buffered before they are handed to the sink
+const defaultBatchSize = 1024
+
+// BatchWriter buffers rows and forwards them to the underlying sink with
+// WriteBatch, so the per-row cost of the sink is paid once per batch.
+type BatchWriter struct {
+	next  RowWriter
+	batch []ParquetRow
+}
+
+// NewBatchWriter wraps next so rows are forwarded in batches of batchSize
+func NewBatchWriter(next RowWriter, batchSize int) *BatchWriter {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &BatchWriter{next: next, batch: make([]ParquetRow, 0, batchSize)}
+}
+
+// Write buffers a row, flushing the batch once it is full
+func (w *BatchWriter) Write(row ParquetRow) error {
+	w.batch = append(w.batch, row)
+	if len(w.batch) == cap(w.batch) {
+		return w.Flush()
+	}
+	return nil
+}
+
+// WriteBatch buffers rows, flushing whenever the batch fills up
+func (w *BatchWriter) WriteBatch(rows []ParquetRow) error {
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush forwards the buffered rows to the underlying sink
+func (w *BatchWriter) Flush() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+	err := w.next.WriteBatch(w.batch)
+	w.batch = w.batch[:0]
+	return err
+}
+
+// Commit flushes the buffered rows and forwards the commit point
+func (w *BatchWriter) Commit(point CommitPoint) error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return commitNext(w.next, point)
+}
+
+// WriteStop flushes the remaining rows and finalizes the underlying sink
+func (w *BatchWriter) WriteStop() error {
+	if err := w.Flush(); err != nil {
+		w.next.WriteStop()
+		return err
+	}
+	return w.next.WriteStop()
+}
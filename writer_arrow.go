@@ -0,0 +1,225 @@
+//go:build arrow
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+)
+
+// arrowRowGroupRows is the number of rows buffered before a row group is written
+const arrowRowGroupRows = 1 << 17
+
+func init() {
+	parquetBackends["arrow"] = func(fileName string) (RowWriter, error) {
+		return NewArrowRowWriter(fileName)
+	}
+}
+
+// arrowColumn buffers the values of one parquetRecord field for the current row group
+type arrowColumn struct {
+	field      int
+	kind       reflect.Kind
+	pointer    bool
+	optional   bool
+	int64s     []int64
+	int32s     []int32
+	bools      []bool
+	byteArrays []parquet.ByteArray
+	defLevels  []int16
+}
+
+// ArrowRowWriter writes rows with the apache/arrow-go Parquet implementation.
+// The schema is derived from the parquet struct tags on parquetRecord so both
+// backends always produce the same columns and nulls.
+type ArrowRowWriter struct {
+	file    *os.File
+	writer  *file.Writer
+	columns []*arrowColumn
+	rows    int
+}
+
+// NewArrowRowWriter creates the Parquet file and writer for fileName
+func NewArrowRowWriter(fileName string) (*ArrowRowWriter, error) {
+	root, columns, err := arrowSchemaFor(reflect.TypeOf(parquetRecord{}))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Parquet file %s: %v", fileName, err)
+	}
+
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(compress.Codecs.Zstd),
+		parquet.WithDictionaryDefault(true),
+	)
+	fw := file.NewParquetWriter(f, root, file.WithWriterProps(props))
+
+	return &ArrowRowWriter{file: f, writer: fw, columns: columns}, nil
+}
+
+// arrowSchemaFor builds the Parquet schema and column buffers from a struct's parquet tags
+func arrowSchemaFor(t reflect.Type) (*schema.GroupNode, []*arrowColumn, error) {
+	var fields schema.FieldList
+	var columns []*arrowColumn
+
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("parquet")
+		if tag == "" {
+			continue
+		}
+		opts := parseParquetTag(tag)
+
+		col := &arrowColumn{field: i, kind: t.Field(i).Type.Kind()}
+		if col.kind == reflect.Ptr {
+			col.pointer = true
+			col.kind = t.Field(i).Type.Elem().Kind()
+		}
+
+		repetition := parquet.Repetitions.Required
+		if opts["repetitiontype"] == "OPTIONAL" || col.pointer {
+			repetition = parquet.Repetitions.Optional
+			col.optional = true
+		}
+
+		var node schema.Node
+		var err error
+		switch col.kind {
+		case reflect.String:
+			node, err = schema.NewPrimitiveNodeLogical(opts["name"], repetition, schema.StringLogicalType{}, parquet.Types.ByteArray, -1, -1)
+		case reflect.Int64:
+			node, err = schema.NewPrimitiveNodeLogical(opts["name"], repetition, schema.NewIntLogicalType(64, true), parquet.Types.Int64, -1, -1)
+		case reflect.Int32:
+			node, err = schema.NewPrimitiveNodeLogical(opts["name"], repetition, schema.NewIntLogicalType(32, true), parquet.Types.Int32, -1, -1)
+		case reflect.Bool:
+			node, err = schema.NewPrimitiveNode(opts["name"], repetition, parquet.Types.Boolean, -1, -1)
+		default:
+			return nil, nil, fmt.Errorf("arrow backend does not support field %s of kind %s", t.Field(i).Name, col.kind)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to build schema for field %s: %v", t.Field(i).Name, err)
+		}
+
+		fields = append(fields, node)
+		columns = append(columns, col)
+	}
+
+	root, err := schema.NewGroupNode("schema", parquet.Repetitions.Required, fields, -1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build schema: %v", err)
+	}
+	return root, columns, nil
+}
+
+// Write buffers a row and writes a row group once enough rows are collected
+func (w *ArrowRowWriter) Write(row ParquetRow) error {
+	v := reflect.ValueOf(newParquetRecord(&row))
+	for _, col := range w.columns {
+		f := v.Field(col.field)
+		if col.pointer {
+			if f.IsNil() {
+				col.defLevels = append(col.defLevels, 0)
+				continue
+			}
+			f = f.Elem()
+		}
+		if col.optional {
+			col.defLevels = append(col.defLevels, 1)
+		}
+
+		switch col.kind {
+		case reflect.String:
+			col.byteArrays = append(col.byteArrays, parquet.ByteArray(f.String()))
+		case reflect.Int64:
+			col.int64s = append(col.int64s, f.Int())
+		case reflect.Int32:
+			col.int32s = append(col.int32s, int32(f.Int()))
+		case reflect.Bool:
+			col.bools = append(col.bools, f.Bool())
+		}
+	}
+
+	w.rows++
+	if w.rows >= arrowRowGroupRows {
+		return w.flush()
+	}
+	return nil
+}
+
+// WriteBatch buffers rows, writing row groups as they fill up
+func (w *ArrowRowWriter) WriteBatch(rows []ParquetRow) error {
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush writes the buffered rows as one row group
+func (w *ArrowRowWriter) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+
+	rgw := w.writer.AppendRowGroup()
+	for _, col := range w.columns {
+		cw, err := rgw.NextColumn()
+		if err != nil {
+			return fmt.Errorf("failed to open column writer: %v", err)
+		}
+
+		var defLevels []int16
+		if col.optional {
+			defLevels = col.defLevels
+		}
+
+		switch cw := cw.(type) {
+		case *file.ByteArrayColumnChunkWriter:
+			_, err = cw.WriteBatch(col.byteArrays, defLevels, nil)
+		case *file.Int64ColumnChunkWriter:
+			_, err = cw.WriteBatch(col.int64s, defLevels, nil)
+		case *file.Int32ColumnChunkWriter:
+			_, err = cw.WriteBatch(col.int32s, defLevels, nil)
+		case *file.BooleanColumnChunkWriter:
+			_, err = cw.WriteBatch(col.bools, defLevels, nil)
+		default:
+			err = fmt.Errorf("unexpected column writer %T", cw)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write column batch: %v", err)
+		}
+		if err := cw.Close(); err != nil {
+			return fmt.Errorf("failed to close column writer: %v", err)
+		}
+
+		col.byteArrays = col.byteArrays[:0]
+		col.int64s = col.int64s[:0]
+		col.int32s = col.int32s[:0]
+		col.bools = col.bools[:0]
+		col.defLevels = col.defLevels[:0]
+	}
+
+	w.rows = 0
+	if err := rgw.Close(); err != nil {
+		return fmt.Errorf("failed to close row group: %v", err)
+	}
+	return nil
+}
+
+// WriteStop writes the remaining rows and the footer, then closes the file
+func (w *ArrowRowWriter) WriteStop() error {
+	if err := w.flush(); err != nil {
+		w.writer.Close()
+		return err
+	}
+	return w.writer.Close()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// TestBatchedRowsKeepTheirValues writes rows through a BatchWriter straight
+// into Parquet, as --pipeline-depth 0 does, with far more rows than one
+// batch: parquet-go holds records until it flushes while the BatchWriter
+// reuses its slice, so records must not point into the batch.
+func TestBatchedRowsKeepTheirValues(t *testing.T) {
+	for _, policy := range nullPolicies {
+		t.Run(policy, func(t *testing.T) {
+			defer func(saved string) { nullPolicy = saved }(nullPolicy)
+			nullPolicy = policy
+
+			fileName := filepath.Join(t.TempDir(), "combined.parquet")
+			sink, err := NewParquetRowWriter(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := NewBatchWriter(sink, 16)
+			const elements = 5000
+			for i := 1; i <= elements; i++ {
+				id := int64(i)
+				rows := []ParquetRow{
+					{NodeID: id, ParentNodeID: id - 1, TagName: fmt.Sprintf("tag%d", i), IsNode: true, FilePath: "a.xml", ContainerPath: "a.xml"},
+					{NodeID: id, AttributeValue: fmt.Sprintf("text%d", i), FilePath: "a.xml", ContainerPath: "a.xml"},
+				}
+				if err := w.WriteBatch(rows); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.WriteStop(); err != nil {
+				t.Fatal(err)
+			}
+
+			columns, rows, err := parquetRows(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 2*elements {
+				t.Fatalf("read %d rows, want %d", len(rows), 2*elements)
+			}
+			column := func(row []string, name string) string {
+				for i, c := range columns {
+					if c == name {
+						return row[i]
+					}
+				}
+				t.Fatalf("no column %s in %v", name, columns)
+				return ""
+			}
+			for i := 0; i < elements; i++ {
+				node, text := rows[2*i], rows[2*i+1]
+				id := strconv.Quote(strconv.Itoa(i + 1))
+				if got := column(node, "node_id"); got != id {
+					t.Fatalf("row %d: node_id %s, want %s", 2*i, got, id)
+				}
+				if got, want := column(node, "tag_name"), strconv.Quote(fmt.Sprintf("tag%d", i+1)); got != want {
+					t.Fatalf("row %d: tag_name %s, want %s", 2*i, got, want)
+				}
+				if got, want := column(text, "attribute_value"), strconv.Quote(fmt.Sprintf("text%d", i+1)); got != want {
+					t.Fatalf("row %d: attribute_value %s, want %s", 2*i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestDefaultNullPolicyWritesSentinels checks that by default attribute and
+// text rows keep the 0 and "" values earlier versions wrote
+func TestDefaultNullPolicyWritesSentinels(t *testing.T) {
+	rec := newParquetRecord(&ParquetRow{NodeID: 2, AttributeValue: "text"})
+	if rec.ParentNodeID == nil || *rec.ParentNodeID != 0 {
+		t.Errorf("parent_node_id = %v, want 0", rec.ParentNodeID)
+	}
+	if rec.TagName == nil || *rec.TagName != "" {
+		t.Errorf("tag_name = %v, want empty string", rec.TagName)
+	}
+	if rec.AttributeName == nil || *rec.AttributeName != "" {
+		t.Errorf("attribute_name = %v, want empty string", rec.AttributeName)
+	}
+}
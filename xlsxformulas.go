@@ -0,0 +1,263 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	extractors["xlsx-formulas"] = xlsxFormulasExtractor{}
+}
+
+// XLSXFormulaRefRow is one dependency edge in xlsx_formula_refs.parquet:
+// the formula of a cell referencing a cell or range. References into other
+// workbooks carry the target of the external link in target_workbook.
+type XLSXFormulaRefRow struct {
+	FilePath       string `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID         int64  `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath  string `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Sheet          string `parquet:"name=sheet, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Cell           string `parquet:"name=cell, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Formula        string `parquet:"name=formula, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TargetWorkbook string `parquet:"name=target_workbook, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	TargetSheet    string `parquet:"name=target_sheet, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	TargetRef      string `parquet:"name=target_ref, type=BYTE_ARRAY, convertedtype=UTF8"`
+	IsRange        bool   `parquet:"name=is_range, type=BOOLEAN"`
+}
+
+// xlsxFormulasExtractor lists the cell and range references of every
+// worksheet formula, expanding shared formulas for the cells that reuse
+// them. Defined names are not resolved.
+type xlsxFormulasExtractor struct{}
+
+// Tables returns the xlsx_formula_refs table
+func (xlsxFormulasExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{"xlsx_formula_refs": new(XLSXFormulaRefRow)}
+}
+
+// Detect accepts the worksheet parts of a workbook
+func (xlsxFormulasExtractor) Detect(doc *Document) bool {
+	return doc.Root.XMLName.Local == "worksheet" && strings.HasPrefix(doc.EntryPath, "xl/worksheets/")
+}
+
+// sharedFormula is the anchor of a shared formula group
+type sharedFormula struct {
+	formula string
+	col     int
+	row     int
+}
+
+// Extract writes one row per reference of each cell formula
+func (xlsxFormulasExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	names, err := worksheetNames(doc)
+	if err != nil {
+		return err
+	}
+	external, err := externalWorkbooks(doc)
+	if err != nil {
+		return err
+	}
+	sheet := names[doc.EntryPath]
+	shared := make(map[string]sharedFormula)
+
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil || node.XMLName.Local != "c" {
+			return
+		}
+		f := childElement(node, "f")
+		if f == nil {
+			return
+		}
+		cell := attrValue(node, "r")
+		formula := f.Content
+		if attrValue(f, "t") == "shared" {
+			col, row, _ := parseCellRef(cell)
+			si := attrValue(f, "si")
+			if anchor, ok := shared[si]; formula == "" && ok {
+				formula = shiftFormula(anchor.formula, row-anchor.row, col-anchor.col)
+			} else if formula != "" {
+				shared[si] = sharedFormula{formula: formula, col: col, row: row}
+			}
+		}
+		for _, ref := range formulaRefs(formula) {
+			row := XLSXFormulaRefRow{
+				FilePath:      doc.FilePath,
+				NodeID:        nodeID,
+				ContainerPath: doc.ContainerPath,
+				Sheet:         sheet,
+				Cell:          cell,
+				Formula:       formula,
+				TargetSheet:   sheet,
+				TargetRef:     strings.ToUpper(strings.ReplaceAll(ref.ref, "$", "")),
+				IsRange:       strings.Contains(ref.ref, ":"),
+			}
+			if ref.sheet != "" {
+				row.TargetSheet = ref.sheet
+			}
+			if ref.workbook > 0 {
+				row.TargetWorkbook = "[" + strconv.Itoa(ref.workbook) + "]"
+				if ref.workbook <= len(external) && external[ref.workbook-1] != "" {
+					row.TargetWorkbook = external[ref.workbook-1]
+				}
+			}
+			if err = rows("xlsx_formula_refs", row); err != nil {
+				return
+			}
+		}
+	})
+	return err
+}
+
+// formulaRefPattern matches an optionally sheet-qualified A1 reference: a
+// cell, a cell range, whole columns or whole rows
+var formulaRefPattern = regexp.MustCompile(`(?:('(?:[^']|'')+'|[A-Za-z0-9_.\[\]]+)!)?(\$?[A-Za-z]{1,3}\$?[0-9]+(?::\$?[A-Za-z]{1,3}\$?[0-9]+)?|\$?[A-Za-z]{1,3}:\$?[A-Za-z]{1,3}|\$?[0-9]+:\$?[0-9]+)`)
+
+// formulaRef is one reference found in a formula
+type formulaRef struct {
+	workbook   int // external link number, 0 for the workbook itself
+	sheet      string
+	ref        string
+	start, end int // position of the reference without its sheet prefix
+}
+
+// formulaRefs finds the references of a formula, ignoring string literals
+// and function names such as LOG10
+func formulaRefs(formula string) []formulaRef {
+	// Blank out string literals so their content cannot match
+	masked := []byte(formula)
+	inString := false
+	for i := range masked {
+		if masked[i] == '"' {
+			inString = !inString
+		} else if inString {
+			masked[i] = ' '
+		}
+	}
+
+	var refs []formulaRef
+	for _, m := range formulaRefPattern.FindAllSubmatchIndex(masked, -1) {
+		if m[0] > 0 && isNameByte(masked[m[0]-1]) {
+			continue
+		}
+		if m[1] < len(masked) && (isNameByte(masked[m[1]]) || masked[m[1]] == '(') {
+			continue
+		}
+		ref := formulaRef{ref: formula[m[4]:m[5]], start: m[4], end: m[5]}
+		if m[2] >= 0 {
+			prefix := formula[m[2]:m[3]]
+			if strings.HasPrefix(prefix, "'") {
+				prefix = strings.ReplaceAll(prefix[1:len(prefix)-1], "''", "'")
+			}
+			if strings.HasPrefix(prefix, "[") {
+				if end := strings.IndexByte(prefix, ']'); end > 0 {
+					ref.workbook, _ = strconv.Atoi(prefix[1:end])
+					prefix = prefix[end+1:]
+				}
+			}
+			ref.sheet = prefix
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// isNameByte reports whether b can be part of a function or defined name
+func isNameByte(b byte) bool {
+	return b == '_' || b == '.' || b == '$' || b >= '0' && b <= '9' || b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z'
+}
+
+// shiftFormula moves the relative references of a shared formula by the
+// offset of the cell reusing it from the anchor cell
+func shiftFormula(formula string, rows, cols int) string {
+	var b strings.Builder
+	last := 0
+	for _, ref := range formulaRefs(formula) {
+		b.WriteString(formula[last:ref.start])
+		parts := strings.Split(ref.ref, ":")
+		for i, part := range parts {
+			parts[i] = shiftRef(part, rows, cols)
+		}
+		b.WriteString(strings.Join(parts, ":"))
+		last = ref.end
+	}
+	b.WriteString(formula[last:])
+	return b.String()
+}
+
+// shiftRef moves one cell, column or row reference, leaving $-anchored
+// coordinates in place
+func shiftRef(ref string, rows, cols int) string {
+	i := 0
+	var b strings.Builder
+	absCol := i < len(ref) && ref[i] == '$'
+	if absCol {
+		i++
+	}
+	j := i
+	for j < len(ref) && (ref[j] >= 'A' && ref[j] <= 'Z' || ref[j] >= 'a' && ref[j] <= 'z') {
+		j++
+	}
+	if j > i {
+		col := columnNumber(ref[i:j])
+		if !absCol {
+			col += cols
+		}
+		if absCol {
+			b.WriteByte('$')
+		}
+		b.WriteString(columnLetters(col))
+	} else if absCol {
+		j = i - 1 // a $ anchoring a row
+	}
+	rest := ref[j:]
+	if rest == "" {
+		return b.String()
+	}
+	absRow := rest[0] == '$'
+	row, err := strconv.Atoi(strings.TrimPrefix(rest, "$"))
+	if err != nil {
+		return ref
+	}
+	if absRow {
+		b.WriteByte('$')
+	} else {
+		row += rows
+	}
+	b.WriteString(strconv.Itoa(row))
+	return b.String()
+}
+
+// parseCellRef splits an A1 cell reference into its column and row numbers
+func parseCellRef(ref string) (col, row int, ok bool) {
+	ref = strings.ReplaceAll(ref, "$", "")
+	i := 0
+	for i < len(ref) && (ref[i] >= 'A' && ref[i] <= 'Z' || ref[i] >= 'a' && ref[i] <= 'z') {
+		i++
+	}
+	row, err := strconv.Atoi(ref[i:])
+	if i == 0 || err != nil {
+		return 0, 0, false
+	}
+	return columnNumber(ref[:i]), row, true
+}
+
+// columnNumber converts column letters to a 1-based column number
+func columnNumber(letters string) int {
+	n := 0
+	for _, c := range strings.ToUpper(letters) {
+		n = n*26 + int(c-'A') + 1
+	}
+	return n
+}
+
+// columnLetters converts a 1-based column number to its letters
+func columnLetters(n int) string {
+	var name []byte
+	for n > 0 {
+		n--
+		name = append([]byte{byte('A' + n%26)}, name...)
+		n /= 26
+	}
+	return string(name)
+}
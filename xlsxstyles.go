@@ -0,0 +1,233 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+func init() {
+	extractors["xlsx-styles"] = &xlsxStylesExtractor{}
+}
+
+// XLSXCellStyleRow is the resolved formatting of one worksheet cell in
+// xlsx_cell_styles.parquet. Colors are ARGB hex (FFFF0000); theme and
+// indexed colors are resolved against the workbook theme and the default
+// palette, without applying tints.
+type XLSXCellStyleRow struct {
+	FilePath       string  `parquet:"name=file_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	NodeID         int64   `parquet:"name=node_id, type=INT64, convertedtype=INT_64"`
+	ContainerPath  string  `parquet:"name=container_path, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Cell           string  `parquet:"name=cell, type=BYTE_ARRAY, convertedtype=UTF8"`
+	StyleIndex     int32   `parquet:"name=style_index, type=INT32, convertedtype=INT_32"`
+	NumberFormatID int32   `parquet:"name=number_format_id, type=INT32, convertedtype=INT_32"`
+	NumberFormat   string  `parquet:"name=number_format, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	FontName       string  `parquet:"name=font_name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	FontSize       float64 `parquet:"name=font_size, type=DOUBLE"`
+	Bold           bool    `parquet:"name=bold, type=BOOLEAN"`
+	Italic         bool    `parquet:"name=italic, type=BOOLEAN"`
+	Underline      string  `parquet:"name=underline, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	FontColor      string  `parquet:"name=font_color, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	FillPattern    string  `parquet:"name=fill_pattern, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	FillColor      string  `parquet:"name=fill_color, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// xlsxStylesExtractor joins the cells of every worksheet with the cell
+// formats of xl/styles.xml
+type xlsxStylesExtractor struct {
+	// styles and resolved memoize the formats of the last styles part
+	styles   *XMLNode
+	resolved []XLSXCellStyleRow
+}
+
+// Tables returns the xlsx_cell_styles table
+func (e *xlsxStylesExtractor) Tables() map[string]interface{} {
+	return map[string]interface{}{"xlsx_cell_styles": new(XLSXCellStyleRow)}
+}
+
+// Detect accepts the worksheet parts of a workbook
+func (e *xlsxStylesExtractor) Detect(doc *Document) bool {
+	return doc.Root.XMLName.Local == "worksheet" && strings.HasPrefix(doc.EntryPath, "xl/worksheets/")
+}
+
+// Extract writes one row per cell with the format its s attribute selects
+func (e *xlsxStylesExtractor) Extract(doc *Document, rows func(table string, row interface{}) error) error {
+	styles, err := doc.part("xl/styles.xml")
+	if err != nil {
+		return err
+	}
+	if styles != e.styles {
+		theme, err := doc.part("xl/theme/theme1.xml")
+		if err != nil {
+			return err
+		}
+		e.styles, e.resolved = styles, resolveCellFormats(styles, themeColors(theme))
+	}
+
+	doc.walk(func(node, parent *XMLNode, nodeID int64) {
+		if err != nil || node.XMLName.Local != "c" || parent == nil || parent.XMLName.Local != "row" {
+			return
+		}
+		index, _ := strconv.Atoi(attrValue(node, "s"))
+		row := XLSXCellStyleRow{NumberFormat: builtinNumberFormats[0]}
+		if index >= 0 && index < len(e.resolved) {
+			row = e.resolved[index]
+		}
+		row.FilePath, row.NodeID, row.ContainerPath = doc.FilePath, nodeID, doc.ContainerPath
+		row.Cell, row.StyleIndex = attrValue(node, "r"), int32(index)
+		err = rows("xlsx_cell_styles", row)
+	})
+	return err
+}
+
+// resolveCellFormats resolves every cellXfs entry of a styles part into the
+// style columns of a row
+func resolveCellFormats(styles *XMLNode, theme []string) []XLSXCellStyleRow {
+	if styles == nil {
+		return nil
+	}
+	numberFormats := make(map[int]string)
+	for id, code := range builtinNumberFormats {
+		numberFormats[id] = code
+	}
+	fonts := childElements(childElement(styles, "fonts"), "font")
+	fills := childElements(childElement(styles, "fills"), "fill")
+	for _, numFmt := range childElements(childElement(styles, "numFmts"), "numFmt") {
+		id, _ := strconv.Atoi(attrValue(numFmt, "numFmtId"))
+		numberFormats[id] = attrValue(numFmt, "formatCode")
+	}
+
+	var resolved []XLSXCellStyleRow
+	for _, xf := range childElements(childElement(styles, "cellXfs"), "xf") {
+		numFmtID, _ := strconv.Atoi(attrValue(xf, "numFmtId"))
+		row := XLSXCellStyleRow{NumberFormatID: int32(numFmtID), NumberFormat: numberFormats[numFmtID]}
+
+		if fontID, err := strconv.Atoi(attrValue(xf, "fontId")); err == nil && fontID < len(fonts) {
+			font := fonts[fontID]
+			row.FontName = attrValue(childElement(font, "name"), "val")
+			row.FontSize, _ = strconv.ParseFloat(attrValue(childElement(font, "sz"), "val"), 64)
+			row.Bold = flagElement(childElement(font, "b"))
+			row.Italic = flagElement(childElement(font, "i"))
+			if u := childElement(font, "u"); u != nil {
+				row.Underline = attrValue(u, "val")
+				if row.Underline == "" {
+					row.Underline = "single"
+				}
+			}
+			row.FontColor = resolveColor(childElement(font, "color"), theme)
+		}
+		if fillID, err := strconv.Atoi(attrValue(xf, "fillId")); err == nil && fillID < len(fills) {
+			if pattern := childElement(fills[fillID], "patternFill"); pattern != nil {
+				row.FillPattern = attrValue(pattern, "patternType")
+				row.FillColor = resolveColor(childElement(pattern, "fgColor"), theme)
+			}
+		}
+		resolved = append(resolved, row)
+	}
+	return resolved
+}
+
+// childElement returns the first child of node named local, or nil
+func childElement(node *XMLNode, local string) *XMLNode {
+	if node == nil {
+		return nil
+	}
+	for i := range node.Nodes {
+		if node.Nodes[i].XMLName.Local == local {
+			return &node.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// childElements returns the children of node named local
+func childElements(node *XMLNode, local string) []*XMLNode {
+	if node == nil {
+		return nil
+	}
+	var children []*XMLNode
+	for i := range node.Nodes {
+		if node.Nodes[i].XMLName.Local == local {
+			children = append(children, &node.Nodes[i])
+		}
+	}
+	return children
+}
+
+// flagElement reads a boolean property such as <b/> or <i val="0"/>
+func flagElement(node *XMLNode) bool {
+	if node == nil {
+		return false
+	}
+	val := attrValue(node, "val")
+	return val != "0" && val != "false"
+}
+
+// resolveColor returns the ARGB value of a color element
+func resolveColor(color *XMLNode, theme []string) string {
+	if color == nil {
+		return ""
+	}
+	if rgb := attrValue(color, "rgb"); rgb != "" {
+		return strings.ToUpper(rgb)
+	}
+	if value := attrValue(color, "theme"); value != "" {
+		if i, err := strconv.Atoi(value); err == nil && i >= 0 && i < len(theme) {
+			return theme[i]
+		}
+		return "theme:" + value
+	}
+	if value := attrValue(color, "indexed"); value != "" {
+		if i, err := strconv.Atoi(value); err == nil && i >= 0 && i < len(indexedColors) {
+			return "FF" + indexedColors[i]
+		}
+		return "indexed:" + value
+	}
+	return ""
+}
+
+// themeColors returns the ARGB colors of a theme part in the order cells
+// index them, which swaps the first two pairs of the color scheme
+func themeColors(theme *XMLNode) []string {
+	scheme := childElement(childElement(theme, "themeElements"), "clrScheme")
+	if scheme == nil {
+		return nil
+	}
+	colors := make([]string, 0, len(scheme.Nodes))
+	for i := range scheme.Nodes {
+		var rgb string
+		if c := childElement(&scheme.Nodes[i], "srgbClr"); c != nil {
+			rgb = attrValue(c, "val")
+		} else if c := childElement(&scheme.Nodes[i], "sysClr"); c != nil {
+			rgb = attrValue(c, "lastClr")
+		}
+		colors = append(colors, "FF"+strings.ToUpper(rgb))
+	}
+	if len(colors) >= 4 {
+		colors[0], colors[1] = colors[1], colors[0]
+		colors[2], colors[3] = colors[3], colors[2]
+	}
+	return colors
+}
+
+// builtinNumberFormats are the number formats every workbook has without
+// declaring them
+var builtinNumberFormats = map[int]string{
+	0: "General", 1: "0", 2: "0.00", 3: "#,##0", 4: "#,##0.00",
+	9: "0%", 10: "0.00%", 11: "0.00E+00", 12: "# ?/?", 13: "# ??/??",
+	14: "mm-dd-yy", 15: "d-mmm-yy", 16: "d-mmm", 17: "mmm-yy",
+	18: "h:mm AM/PM", 19: "h:mm:ss AM/PM", 20: "h:mm", 21: "h:mm:ss", 22: "m/d/yy h:mm",
+	37: "#,##0 ;(#,##0)", 38: "#,##0 ;[Red](#,##0)", 39: "#,##0.00;(#,##0.00)", 40: "#,##0.00;[Red](#,##0.00)",
+	45: "mm:ss", 46: "[h]:mm:ss", 47: "mmss.0", 48: "##0.0E+0", 49: "@",
+}
+
+// indexedColors is the default legacy palette indexed colors refer to
+var indexedColors = []string{
+	"000000", "FFFFFF", "FF0000", "00FF00", "0000FF", "FFFF00", "FF00FF", "00FFFF",
+	"000000", "FFFFFF", "FF0000", "00FF00", "0000FF", "FFFF00", "FF00FF", "00FFFF",
+	"800000", "008000", "000080", "808000", "800080", "008080", "C0C0C0", "808080",
+	"9999FF", "993366", "FFFFCC", "CCFFFF", "660066", "FF8080", "0066CC", "CCCCFF",
+	"000080", "FF00FF", "FFFF00", "00FFFF", "800080", "800000", "008080", "0000FF",
+	"00CCFF", "CCFFFF", "CCFFCC", "FFFF99", "99CCFF", "FF99CC", "CC99FF", "FFCC99",
+	"3366FF", "33CCCC", "99CC00", "FFCC00", "FF9900", "FF6600", "666699", "969696",
+	"003366", "339966", "003300", "333300", "993300", "993366", "333399", "333333",
+}
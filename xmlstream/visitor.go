@@ -0,0 +1,52 @@
+package xmlstream
+
+import (
+	"errors"
+	"io"
+)
+
+// SkipSubtree is returned by Visitor.EnterElement to skip the element's
+// content. Neither Text nor LeaveElement is called for a skipped element.
+var SkipSubtree = errors.New("skip this subtree")
+
+// Visitor receives callbacks as Walk streams through a document
+type Visitor interface {
+	// EnterElement is called at the start of each element
+	EnterElement(node Node) error
+	// Text is called for non-whitespace character data inside an element
+	Text(node Node) error
+	// LeaveElement is called at the end of each element. The node carries
+	// the element's name and IDs but not its attributes.
+	LeaveElement(node Node) error
+}
+
+// Walk streams the document read from r through v. Returning SkipSubtree
+// from EnterElement skips that element; any other error stops the walk and
+// is returned.
+func Walk(r io.Reader, v Visitor) error {
+	s := newScanner(r)
+	for {
+		ev, node, err := s.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch ev {
+		case enterEvent:
+			err = v.EnterElement(node)
+			if err == SkipSubtree {
+				err = s.skip()
+			}
+		case textEvent:
+			err = v.Text(node)
+		case leaveEvent:
+			err = v.LeaveElement(node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package xmlstream
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recorder is a Visitor that logs its callbacks, skipping the elements in
+// skip and failing with fail on the element named failAt
+type recorder struct {
+	events []string
+	skip   map[string]bool
+	failAt string
+	fail   error
+}
+
+func (r *recorder) EnterElement(node Node) error {
+	r.events = append(r.events, fmt.Sprintf("enter %s %d", node.Name.Local, node.ID))
+	if node.Name.Local == r.failAt {
+		return r.fail
+	}
+	if r.skip[node.Name.Local] {
+		return SkipSubtree
+	}
+	return nil
+}
+
+func (r *recorder) Text(node Node) error {
+	r.events = append(r.events, fmt.Sprintf("text %q %d", node.Text, node.ID))
+	return nil
+}
+
+func (r *recorder) LeaveElement(node Node) error {
+	r.events = append(r.events, fmt.Sprintf("leave %s %d", node.Name.Local, node.ID))
+	return nil
+}
+
+// visitorDoc nests content inside the element the tests skip or fail at
+const visitorDoc = `<root><skipped a="1">hidden<inner><deep/></inner>more</skipped><next>shown</next><last/></root>`
+
+// TestWalkSkipSubtree checks that SkipSubtree hides an element's content
+// and its LeaveElement, and that the walk resumes at the next sibling
+func TestWalkSkipSubtree(t *testing.T) {
+	r := &recorder{skip: map[string]bool{"skipped": true}}
+	if err := Walk(strings.NewReader(visitorDoc), r); err != nil {
+		t.Fatal(err)
+	}
+	// The skipped element's descendants still take IDs 3 and 4, so the
+	// walk resumes at its next sibling with the ID it would have had
+	want := []string{
+		"enter root 1",
+		"enter skipped 2",
+		"enter next 5",
+		`text "shown" 5`,
+		"leave next 5",
+		"enter last 6",
+		"leave last 6",
+		"leave root 1",
+	}
+	if strings.Join(r.events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(r.events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestWalkStopsAtVisitorError checks that an error other than SkipSubtree
+// ends the walk at once and is returned as is
+func TestWalkStopsAtVisitorError(t *testing.T) {
+	stop := errors.New("stop here")
+	r := &recorder{failAt: "inner", fail: stop}
+	if err := Walk(strings.NewReader(visitorDoc), r); err != stop {
+		t.Fatalf("Walk returned %v, want the visitor's error", err)
+	}
+	want := []string{
+		"enter root 1",
+		"enter skipped 2",
+		`text "hidden" 2`,
+		"enter inner 3",
+	}
+	if strings.Join(r.events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events after the error:\n%s\nwant:\n%s", strings.Join(r.events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestWalkReturnsDecodeError checks that malformed XML fails the walk
+func TestWalkReturnsDecodeError(t *testing.T) {
+	if err := Walk(strings.NewReader("<a><b></a>"), &recorder{}); err == nil {
+		t.Error("Walk accepted a malformed document")
+	}
+}
@@ -0,0 +1,170 @@
+// Package xmlstream exposes xmlgo's streaming view of an XML document as a
+// sequence of nodes, for Go programs that want to build their own sinks or
+// analyses without going through Parquet.
+//
+// Text is reported differently from xmlgo's converter. The converter writes
+// one text row per element, holding all of the element's character data
+// joined together, trimmed, and normalized by --normalize and
+// --case-fold. xmlstream yields each chunk of character data the decoder
+// returns between tags, comments, processing instructions and CDATA
+// boundaries, as it appears in the document: untrimmed and unnormalized,
+// with only whitespace-only chunks skipped. For <p>a<b/>c</p> the converter
+// writes "ac" for p, while xmlstream yields "a" and "c". Joining an
+// element's text would mean holding it until the element ends; callers that
+// want the converter's values can join the TextNodes of an element and trim
+// the result.
+package xmlstream
+
+import (
+	"encoding/xml"
+	"io"
+	"iter"
+	"strings"
+)
+
+// Kind identifies what a Node represents
+type Kind int
+
+const (
+	// ElementNode is the start of an element, carrying its name and attributes
+	ElementNode Kind = iota
+	// TextNode is one chunk of character data directly inside an element,
+	// as described in the package comment
+	TextNode
+)
+
+// Node is a single element or text chunk in document order.
+//
+// Element IDs are assigned in pre-order starting at 1 in every document;
+// the root element has ParentID 0. A text node carries the ID of the element
+// that contains it. The converter numbers nodes across a whole run, so its
+// node_id column equals ID only for the first document converted; for the
+// others it is ID plus the document's first_node_id minus 1.
+type Node struct {
+	Kind     Kind
+	ID       int64
+	ParentID int64
+	Depth    int
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Text     string
+}
+
+// ParseNodes streams the nodes of the XML document read from r. Only the
+// current element stack is held in memory, so arbitrarily large documents can
+// be consumed. Whitespace-only character data is skipped. If decoding fails
+// the sequence yields the error once and stops.
+func ParseNodes(r io.Reader) iter.Seq2[Node, error] {
+	return func(yield func(Node, error) bool) {
+		s := newScanner(r)
+		for {
+			ev, node, err := s.next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(Node{}, err)
+				return
+			}
+			if ev == leaveEvent {
+				continue
+			}
+			if !yield(node, nil) {
+				return
+			}
+		}
+	}
+}
+
+// event is what the scanner saw at the current token
+type event int
+
+const (
+	enterEvent event = iota
+	textEvent
+	leaveEvent
+)
+
+// scanner turns decoder tokens into node events and keeps pre-order IDs
+type scanner struct {
+	decoder *xml.Decoder
+	nextID  int64
+	stack   []Node
+}
+
+// newScanner creates a scanner reading from r
+func newScanner(r io.Reader) *scanner {
+	return &scanner{decoder: xml.NewDecoder(r), nextID: 1}
+}
+
+// next returns the following event, or io.EOF at the end of the document
+func (s *scanner) next() (event, Node, error) {
+	for {
+		token, err := s.decoder.Token()
+		if err != nil {
+			return 0, Node{}, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := Node{
+				Kind:     ElementNode,
+				ID:       s.nextID,
+				ParentID: s.currentID(),
+				Depth:    len(s.stack),
+				Name:     t.Name,
+				Attrs:    t.Copy().Attr,
+			}
+			s.nextID++
+			s.stack = append(s.stack, Node{Kind: ElementNode, ID: node.ID, ParentID: node.ParentID, Depth: node.Depth, Name: node.Name})
+			return enterEvent, node, nil
+
+		case xml.EndElement:
+			node := s.stack[len(s.stack)-1]
+			s.stack = s.stack[:len(s.stack)-1]
+			return leaveEvent, node, nil
+
+		case xml.CharData:
+			if len(s.stack) == 0 || strings.TrimSpace(string(t)) == "" {
+				continue
+			}
+			owner := s.stack[len(s.stack)-1]
+			return textEvent, Node{
+				Kind:     TextNode,
+				ID:       owner.ID,
+				ParentID: owner.ParentID,
+				Depth:    len(s.stack),
+				Text:     string(t),
+			}, nil
+		}
+	}
+}
+
+// skip consumes the rest of the innermost open element. Elements inside it
+// still consume IDs so the numbering of later nodes is unaffected.
+func (s *scanner) skip() error {
+	depth := 1
+	for depth > 0 {
+		token, err := s.decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			s.nextID++
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	s.stack = s.stack[:len(s.stack)-1]
+	return nil
+}
+
+// currentID returns the ID of the innermost open element, or 0 at the top level
+func (s *scanner) currentID() int64 {
+	if len(s.stack) == 0 {
+		return 0
+	}
+	return s.stack[len(s.stack)-1].ID
+}
@@ -0,0 +1,160 @@
+package xmlstream
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// goldenDir holds a corpus case whose combined.jsonl the converter wrote
+// from two documents, the second numbered after the first
+const goldenDir = "../testdata/golden/namespaces"
+
+// convertedNode is an element of the converter's nested JSON output
+type convertedNode struct {
+	NodeID    int64           `json:"node_id"`
+	Tag       string          `json:"tag"`
+	Namespace string          `json:"namespace"`
+	Text      string          `json:"text"`
+	Children  []convertedNode `json:"children"`
+}
+
+// element is what a test compares of one element, in document order
+type element struct {
+	id, parent int64
+	name, text string
+}
+
+// convertedDocuments reads the converter's elements of each document
+func convertedDocuments(t *testing.T) map[string][]element {
+	t.Helper()
+	f, err := os.Open(filepath.Join(goldenDir, "expected", "combined.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	docs := make(map[string][]element)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line struct {
+			FilePath string        `json:"file_path"`
+			Root     convertedNode `json:"root"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		var walk func(n convertedNode, parent int64)
+		walk = func(n convertedNode, parent int64) {
+			docs[line.FilePath] = append(docs[line.FilePath], element{n.NodeID, parent, n.Namespace + " " + n.Tag, n.Text})
+			for _, child := range n.Children {
+				walk(child, n.NodeID)
+			}
+		}
+		walk(line.Root, 0)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return docs
+}
+
+// TestParseNodesMatchesConverter streams each document of the corpus case
+// and checks its elements against the converter's rows: the same order
+// and parents once IDs are offset by the document's first node_id, and
+// text that joins and trims to the converter's text
+func TestParseNodesMatchesConverter(t *testing.T) {
+	docs := convertedDocuments(t)
+	if len(docs) != 2 {
+		t.Fatalf("corpus case has %d documents, want 2", len(docs))
+	}
+	for filePath, want := range docs {
+		f, err := os.Open(filepath.Join(goldenDir, "input", filePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		// The converter numbered this document's nodes after the documents
+		// before it; a parent ID of 0 marks the root in both
+		offset := want[0].id - 1
+		shift := func(id int64) int64 {
+			if id == 0 {
+				return 0
+			}
+			return id + offset
+		}
+		var got []element
+		index := make(map[int64]int)
+		for node, err := range ParseNodes(f) {
+			if err != nil {
+				t.Fatalf("%s: %v", filePath, err)
+			}
+			switch node.Kind {
+			case ElementNode:
+				if node.Depth == 0 && node.ParentID != 0 {
+					t.Errorf("%s: root has ParentID %d", filePath, node.ParentID)
+				}
+				index[node.ID] = len(got)
+				got = append(got, element{shift(node.ID), shift(node.ParentID), node.Name.Space + " " + node.Name.Local, ""})
+			case TextNode:
+				i, ok := index[node.ID]
+				if !ok {
+					t.Fatalf("%s: text %q belongs to element %d, which has not started", filePath, node.Text, node.ID)
+				}
+				if shift(node.ParentID) != got[i].parent {
+					t.Errorf("%s: text %q has ParentID %d, unlike its element", filePath, node.Text, node.ParentID)
+				}
+				got[i].text += node.Text
+			}
+		}
+		for i := range got {
+			got[i].text = strings.TrimSpace(got[i].text)
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("%s: streamed %d elements, converter wrote %d", filePath, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s element %d: streamed %+v, converter wrote %+v", filePath, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+// TestParseNodesYieldsTextChunks checks that text is reported chunk by
+// chunk around child elements and CDATA, as the package comment describes
+func TestParseNodesYieldsTextChunks(t *testing.T) {
+	var texts []string
+	for node, err := range ParseNodes(strings.NewReader("<p> a &amp; b<![CDATA[c]]><b/>\n \n<i>d</i>e </p>")) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.Kind == TextNode {
+			texts = append(texts, node.Text)
+		}
+	}
+	want := []string{" a & b", "c", "d", "e "}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("text chunks %q, want %q", texts, want)
+	}
+}
+
+// TestParseNodesStopsAtError checks that a decoding error is yielded once
+// and ends the sequence
+func TestParseNodesStopsAtError(t *testing.T) {
+	var nodes, errs int
+	for _, err := range ParseNodes(strings.NewReader("<a><b></a>")) {
+		if err != nil {
+			errs++
+			continue
+		}
+		nodes++
+	}
+	if nodes != 2 || errs != 1 {
+		t.Errorf("got %d nodes and %d errors, want 2 nodes then 1 error", nodes, errs)
+	}
+}